@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"journal/internal/model"
+	"journal/internal/storage"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// command is a CLI subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"import", "Import entries from other formats (csv)", runImport},
+		{"export", "Export the journal to other formats (latex, print, html, share, ical, markdown, json)", runExport},
+		{"decrypt", "Decrypt an export encrypted with --encrypt", runDecrypt},
+		{"grep", "Print entry lines matching a pattern", runGrep},
+		{"words", "Report the most frequent words and their usage over time", runWords},
+		{"digest", "Summarise a week's entries as Markdown", runDigest},
+		{"theme", "Export the current theme to a file, or import a shared one", runTheme},
+		{"verify", "Check entries and their history against their checksums", runVerify},
+		{"tasks", "List the checkbox tasks in entries, or sync them to Taskwarrior", runTasks},
+		{"highlight", "Add a line to the Highlights section of today's entry", runHighlight},
+	}
+}
+
+// TodayFlag removes --today from args, reporting whether it was there. It
+// opens the active journal straight into today's entry.
+func TodayFlag(args []string) ([]string, bool) {
+	var rest []string
+	today := false
+	for _, arg := range args {
+		if arg == "--today" || arg == "-today" {
+			today = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return rest, today
+}
+
+// LowMemoryFlag removes --low-memory from args, reporting whether it was
+// there. It keeps as little of the journal in memory as possible, for small
+// servers and old hardware.
+func LowMemoryFlag(args []string) ([]string, bool) {
+	var rest []string
+	lowMemory := false
+	for _, arg := range args {
+		if arg == "--low-memory" || arg == "-low-memory" {
+			lowMemory = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return rest, lowMemory
+}
+
+// IsCommand reports whether args start with a known subcommand
+func IsCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		return true
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// Run executes the subcommand named by args[0]
+func Run(args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(os.Stdout)
+		return nil
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	printUsage(os.Stderr)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: journal [command] [options]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run without a command to start the interactive journal.")
+	fmt.Fprintln(w, "Add --profile <file> to any invocation to write CPU and heap profiles.")
+	fmt.Fprintln(w, "Add --debug[=<file>] (or set JOURNAL_DEBUG) to log storage operations.")
+	fmt.Fprintln(w, "Run with --script <file> to drive the interactive journal from a script.")
+	fmt.Fprintln(w, "Set JOURNAL_PASSWORD_COMMAND to a command printing the password of encrypted journals.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+}
+
+// openedJournal is a journal loaded for a CLI command
+type openedJournal struct {
+	config   *model.Config
+	db       model.JournalDB
+	journal  *model.Journal
+	password string
+	store    storage.Store
+}
+
+// passwordCommandEnv names a command that prints the password of an
+// encrypted journal, run instead of prompting for it
+const passwordCommandEnv = "JOURNAL_PASSWORD_COMMAND"
+
+// openJournal resolves a journal by name or path (the active journal when
+// empty), getting the password of an encrypted journal from the command in
+// JOURNAL_PASSWORD_COMMAND or else a prompt
+func openJournal(nameOrPath string) (*openedJournal, error) {
+	return openJournalWithPassword(nameOrPath, os.Getenv(passwordCommandEnv))
+}
+
+// openJournalWithPassword is openJournal with the password of an encrypted
+// journal printed by passwordCommand, or prompted for when it is empty
+func openJournalWithPassword(nameOrPath, passwordCommand string) (*openedJournal, error) {
+	exists, err := storage.ConfigExists()
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("no configuration found; run journal once to set up a journal")
+	}
+	config, err := storage.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	storage.MigrateConfigToNewFormat(config)
+	if err := storage.SetKDFParams(config.KDF); err != nil {
+		return nil, err
+	}
+	if err := storage.SetFileMode(config.FileMode); err != nil {
+		return nil, err
+	}
+
+	var db *model.JournalDB
+	if nameOrPath == "" {
+		db = storage.FindJournal(config, config.ActiveJournal)
+	} else {
+		for i := range config.Journals {
+			if strings.EqualFold(config.Journals[i].Name, nameOrPath) || storage.SamePath(config.Journals[i].Path, nameOrPath) {
+				db = &config.Journals[i]
+				break
+			}
+		}
+	}
+	if db == nil {
+		if nameOrPath == "" {
+			return nil, errors.New("no active journal; use --journal to choose one")
+		}
+		return nil, fmt.Errorf("journal %q not found in config", nameOrPath)
+	}
+
+	opened := &openedJournal{config: config, db: *db}
+	if db.Encrypted && passwordCommand != "" {
+		opened.password, err = runPasswordCommand(passwordCommand)
+		if err != nil {
+			return nil, err
+		}
+	} else if db.Encrypted {
+		opened.password, err = readPassword("Password for " + db.Name + ": ")
+		if err != nil {
+			return nil, err
+		}
+	}
+	opened.store = storage.OpenStore(db, opened.password)
+	opened.journal, err = opened.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return opened, nil
+}
+
+// runPasswordCommand runs command with the shell and returns its output
+// without the trailing newline, for password managers such as
+// "pass show journal"
+func runPasswordCommand(command string) (string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("password command: %w", err)
+	}
+	password := strings.TrimRight(string(out), "\r\n")
+	if password == "" {
+		return "", errors.New("password command printed nothing")
+	}
+	return password, nil
+}
+
+func readPassword(prompt string) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return "", errors.New("encrypted journal requires an interactive terminal for the password")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// readNewPassword prompts for a new password twice, failing when the two
+// don't match
+func readNewPassword(prompt string) (string, error) {
+	password, err := readPassword(prompt)
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", errors.New("the password is empty")
+	}
+	confirm, err := readPassword("Repeat the password: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm != password {
+		return "", errors.New("the passwords don't match")
+	}
+	return password, nil
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"journal/internal/storage"
+)
+
+// DebugEnv enables the debug log when set: to 1 or true for the default
+// file, or to a file path
+const DebugEnv = "JOURNAL_DEBUG"
+
+// defaultDebugLog is the debug log's file name in the config directory
+const defaultDebugLog = "debug.log"
+
+// StartDebugLog looks for a --debug or --debug=<file> flag anywhere in args,
+// or the JOURNAL_DEBUG environment variable, and if present logs storage
+// operations to the file (debug.log in the config directory by default).
+// It returns args without the flag and a function closing the log.
+func StartDebugLog(args []string) ([]string, func(), error) {
+	path := os.Getenv(DebugEnv)
+	enabled := path != "" && path != "0" && path != "false"
+	if path == "1" || path == "true" {
+		path = ""
+	}
+
+	var rest []string
+	for _, arg := range args {
+		switch {
+		case arg == "--debug" || arg == "-debug":
+			enabled = true
+		case strings.HasPrefix(arg, "--debug="):
+			enabled = true
+			path = strings.TrimPrefix(arg, "--debug=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if !enabled {
+		return args, func() {}, nil
+	}
+
+	if path == "" {
+		configPath, err := storage.GetConfigPath()
+		if err != nil {
+			return nil, nil, err
+		}
+		path = filepath.Join(filepath.Dir(configPath), defaultDebugLog)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger := slog.New(slog.NewTextHandler(f, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	command, flags := debugArgs(rest)
+	logger.Info("session started", "pid", os.Getpid(), "command", command, "flags", flags)
+	storage.SetLogger(logger)
+
+	return rest, func() {
+		storage.SetLogger(nil)
+		f.Close()
+	}, nil
+}
+
+// debugArgs returns the command and the names of the flags in args, for
+// the debug log. Other arguments and flag values are left out, as they can
+// hold entry text, search patterns, or passwords.
+func debugArgs(args []string) (command, flags string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+	}
+	var names []string
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if strings.HasPrefix(arg, "-") {
+			name, _, _ := strings.Cut(arg, "=")
+			names = append(names, name)
+		}
+	}
+	return command, strings.Join(names, " ")
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"journal/internal/storage"
+)
+
+func runDecrypt(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	out := fs.String("out", "", "write the decrypted export here (default: the file name without .enc)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: journal decrypt [--out <file>] <export.enc>")
+	}
+	src := fs.Arg(0)
+
+	dest := *out
+	if dest == "" {
+		var ok bool
+		if dest, ok = strings.CutSuffix(src, storage.EncryptedExportExtension); !ok {
+			return fmt.Errorf("%s doesn't end in %s; use --out to name the decrypted file", src, storage.EncryptedExportExtension)
+		}
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+
+	var password string
+	var err error
+	if command := os.Getenv(passwordCommandEnv); command != "" {
+		password, err = runPasswordCommand(command)
+	} else {
+		password, err = readPassword("Password for " + src + ": ")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := storage.DecryptExport(src, dest, password); err != nil {
+		return err
+	}
+	fmt.Printf("Decrypted %s to %s\n", src, dest)
+	return nil
+}
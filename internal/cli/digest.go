@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"journal/internal/dates"
+	"journal/internal/stats"
+	"journal/internal/storage"
+)
+
+func runDigest(args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	week := fs.Bool("week", true, "summarise a week, Monday to Sunday")
+	of := fs.String("of", "today", "a date in the week to summarise (YYYY-MM-DD, or e.g. \"7 days ago\")")
+	out := fs.String("out", "", "write the digest to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*week {
+		return errors.New("only weekly digests are supported; use --week")
+	}
+
+	date, err := dates.Parse(*of, time.Now())
+	if err != nil {
+		return err
+	}
+	day, _ := time.Parse(dates.Layout, date)
+	from, to := stats.WeekOf(day)
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+	digest := stats.BuildDigest(opened.journal, from, to)
+
+	if *out == "" {
+		return digest.WriteMarkdown(os.Stdout)
+	}
+	f, err := storage.CreateFile(*out)
+	if err != nil {
+		return err
+	}
+	if err := digest.WriteMarkdown(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote the digest of %s to %s to %s\n", from, to, *out)
+	return nil
+}
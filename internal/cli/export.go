@@ -0,0 +1,508 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"journal/internal/dates"
+	"journal/internal/model"
+	"journal/internal/storage"
+	"journal/internal/theme"
+)
+
+func runExport(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: journal export <latex|print|html|share|ical|markdown|json> [options] <destination>")
+	}
+	switch args[0] {
+	case "latex":
+		return runExportLaTeX(args[1:])
+	case "print":
+		return runExportPrint(args[1:])
+	case "html":
+		return runExportHTML(args[1:])
+	case "share":
+		return runExportShare(args[1:])
+	case "ical":
+		return runExportICal(args[1:])
+	case "markdown":
+		return runExportMarkdown(args[1:])
+	case "json":
+		return runExportJSON(args[1:])
+	}
+	return fmt.Errorf("unknown export format %q", args[0])
+}
+
+func runExportLaTeX(args []string) error {
+	fs := flag.NewFlagSet("export latex", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	year := fs.String("year", "", "only export entries from this year")
+	title := fs.String("title", "", "book title (default: journal name)")
+	encryption := addEncryptionFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: journal export latex [options] <dir>")
+	}
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+	enc, err := encryption.resolve(opened)
+	if err != nil {
+		return err
+	}
+
+	journal := opened.journal
+	if *year != "" {
+		journal = filterByYear(journal, *year)
+	}
+	if *title == "" {
+		*title = opened.db.Name
+		if *year != "" {
+			*title += " " + *year
+		}
+	}
+
+	dest, err := writeExport(fs.Arg(0), enc, func(fsys storage.ExportFS, path string) error {
+		return storage.ExportLaTeX(fsys, journal, opened.store, path, *title)
+	})
+	if err != nil {
+		return err
+	}
+	if enc.Enabled() {
+		fmt.Printf("Exported %d entries to %s, encrypted\n", len(journal.Entries), dest)
+		return nil
+	}
+	fmt.Printf("Exported %d entries to %s (build with: pdflatex main.tex)\n", len(journal.Entries), dest)
+	return nil
+}
+
+func runExportPrint(args []string) error {
+	fs := flag.NewFlagSet("export print", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	year := fs.String("year", "", "only export entries from this year")
+	title := fs.String("title", "", "document title (default: journal name)")
+	encryption := addEncryptionFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: journal export print [options] <file.html>")
+	}
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+	enc, err := encryption.resolve(opened)
+	if err != nil {
+		return err
+	}
+
+	journal := opened.journal
+	if *year != "" {
+		journal = filterByYear(journal, *year)
+	}
+	if *title == "" {
+		*title = opened.db.Name
+		if *year != "" {
+			*title += " " + *year
+		}
+	}
+
+	dest, err := writeExport(fs.Arg(0), enc, func(fsys storage.ExportFS, path string) error {
+		return storage.ExportPrintHTML(fsys, journal, opened.store, path, *title)
+	})
+	if err != nil {
+		return err
+	}
+	if enc.Enabled() {
+		fmt.Printf("Exported %d entries to %s, encrypted\n", len(journal.Entries), dest)
+		return nil
+	}
+	fmt.Printf("Exported %d entries to %s (open in a browser and print, or save as PDF)\n", len(journal.Entries), dest)
+	return nil
+}
+
+func runExportHTML(args []string) error {
+	fs := flag.NewFlagSet("export html", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	year := fs.String("year", "", "only export entries from this year")
+	title := fs.String("title", "", "page title (default: journal name)")
+	oldestFirst := fs.Bool("oldest-first", false, "list entries oldest first rather than newest first")
+	encryption := addEncryptionFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: journal export html [options] <file.html>")
+	}
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+	enc, err := encryption.resolve(opened)
+	if err != nil {
+		return err
+	}
+
+	journal := opened.journal
+	if *year != "" {
+		journal = filterByYear(journal, *year)
+	}
+	if *title == "" {
+		*title = opened.db.Name
+		if *year != "" {
+			*title += " " + *year
+		}
+	}
+
+	opts, err := htmlOptions(opened.config, *title)
+	if err != nil {
+		return err
+	}
+	opts.NewestFirst = !*oldestFirst
+
+	dest, err := writeExport(fs.Arg(0), enc, func(fsys storage.ExportFS, path string) error {
+		return storage.ExportHTML(fsys, journal, opened.store, path, opts)
+	})
+	if err != nil {
+		return err
+	}
+	if enc.Enabled() {
+		fmt.Printf("Exported %d entries to %s, encrypted\n", len(journal.Entries), dest)
+		return nil
+	}
+	fmt.Printf("Exported %d entries to %s\n", len(journal.Entries), dest)
+	return nil
+}
+
+func runExportShare(args []string) error {
+	fs := flag.NewFlagSet("export share", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	since := fs.String("since", "", "first date to share, e.g. 2024-03-01 or \"last monday\"")
+	until := fs.String("until", "", "last date to share (default: today)")
+	title := fs.String("title", "", "page title (default: journal name)")
+	attachments := fs.Bool("attachments", false, "include image attachments, which are left out by default")
+	noPassword := fs.Bool("no-password", false, "write the page without a password")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *since == "" {
+		return errors.New("usage: journal export share --since <date> [options] <file.html>")
+	}
+	from, err := dates.Parse(*since, time.Now())
+	if err != nil {
+		return err
+	}
+	to := time.Now().Format(dates.Layout)
+	if *until != "" {
+		if to, err = dates.Parse(*until, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+
+	// Private lines and paragraphs stay in the journal, and entries with
+	// nothing else are left out
+	shared := &model.Journal{}
+	redacted := 0
+	for _, e := range opened.journal.Entries {
+		if e.Date < from || e.Date > to {
+			continue
+		}
+		content := model.Redact(e.Content)
+		for _, line := range strings.Split(e.Content, "\n") {
+			if strings.HasPrefix(strings.TrimSpace(line), model.PrivateMarker) {
+				redacted++
+				break
+			}
+		}
+		if content == "" {
+			continue
+		}
+		// Earlier versions may still hold what was since marked private
+		e.Content = content
+		e.History = nil
+		if !*attachments {
+			e.Attachments = nil
+		}
+		shared.Entries = append(shared.Entries, e)
+	}
+	if len(shared.Entries) == 0 {
+		return fmt.Errorf("no entries to share between %s and %s", from, to)
+	}
+
+	if *title == "" {
+		*title = opened.db.Name
+	}
+	opts, err := htmlOptions(opened.config, *title)
+	if err != nil {
+		return err
+	}
+	opts.NewestFirst = true
+	if !*noPassword {
+		if opts.Password, err = readNewPassword("Password for the shared page: "); err != nil {
+			return err
+		}
+	}
+
+	if err := storage.ExportHTML(storage.DiskFS, shared, opened.store, fs.Arg(0), opts); err != nil {
+		return err
+	}
+	fmt.Printf("Shared %d entries from %s to %s in %s", len(shared.Entries), from, to, fs.Arg(0))
+	if redacted > 0 {
+		fmt.Printf(", with private parts of %d left out", redacted)
+	}
+	if opts.Password != "" {
+		fmt.Print(" (opens with the password in a browser)")
+	}
+	fmt.Println()
+	return nil
+}
+
+// htmlOptions returns the options of an HTML export titled title, in the
+// colors of the theme the app is set to
+func htmlOptions(config *model.Config, title string) (storage.HTMLOptions, error) {
+	if err := theme.SetBackground(config.Background); err != nil {
+		return storage.HTMLOptions{}, err
+	}
+	if config.Theme != "" {
+		theme.Set(config.Theme)
+	}
+	if err := theme.SetOverrides(config.ThemeColors); err != nil {
+		return storage.HTMLOptions{}, err
+	}
+	return storage.HTMLOptions{
+		Title: title,
+		Theme: theme.Current(),
+		Light: theme.IsLight(),
+	}, nil
+}
+
+func runExportICal(args []string) error {
+	fs := flag.NewFlagSet("export ical", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	year := fs.String("year", "", "only export entries from this year")
+	name := fs.String("name", "", "calendar name (default: journal name)")
+	full := fs.Bool("full", false, "describe each event with the whole entry rather than its opening")
+	encryption := addEncryptionFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: journal export ical [options] <file.ics>")
+	}
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+	enc, err := encryption.resolve(opened)
+	if err != nil {
+		return err
+	}
+
+	journal := opened.journal
+	if *year != "" {
+		journal = filterByYear(journal, *year)
+	}
+	if *name == "" {
+		*name = opened.db.Name
+	}
+
+	dest, err := writeExport(fs.Arg(0), enc, func(fsys storage.ExportFS, path string) error {
+		return storage.ExportICal(fsys, journal, path, *name, *full)
+	})
+	if err != nil {
+		return err
+	}
+	if enc.Enabled() {
+		fmt.Printf("Exported %d entries to %s, encrypted\n", len(journal.Entries), dest)
+		return nil
+	}
+	fmt.Printf("Exported %d entries to %s (import it into your calendar app)\n", len(journal.Entries), dest)
+	return nil
+}
+
+func runExportMarkdown(args []string) error {
+	fs := flag.NewFlagSet("export markdown", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	year := fs.String("year", "", "only export entries from this year")
+	title := fs.String("title", "", "document title (default: journal name)")
+	files := fs.Bool("files", false, "write one YYYY-MM-DD.md file per entry into the destination folder")
+	encryption := addEncryptionFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: journal export markdown [options] <file.md | dir>")
+	}
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+	enc, err := encryption.resolve(opened)
+	if err != nil {
+		return err
+	}
+
+	journal := opened.journal
+	if *year != "" {
+		journal = filterByYear(journal, *year)
+	}
+	if *title == "" {
+		*title = opened.db.Name
+		if *year != "" {
+			*title += " " + *year
+		}
+	}
+
+	dest, err := writeExport(fs.Arg(0), enc, func(fsys storage.ExportFS, path string) error {
+		if *files {
+			return storage.ExportMarkdownFolder(fsys, journal, opened.store, path)
+		}
+		if !enc.Enabled() || !hasAttachments(journal) {
+			return storage.ExportMarkdown(fsys, journal, opened.store, path, *title)
+		}
+		// Encrypted, the document and its assets are archived together
+		if err := fsys.MkdirAll(path); err != nil {
+			return err
+		}
+		return storage.ExportMarkdown(fsys, journal, opened.store, filepath.Join(path, filepath.Base(path)), *title)
+	})
+	if err != nil {
+		return err
+	}
+	if enc.Enabled() {
+		fmt.Printf("Exported %d entries to %s, encrypted\n", len(journal.Entries), dest)
+		return nil
+	}
+	if *files {
+		fmt.Printf("Exported %d entries to %s as Markdown files\n", len(journal.Entries), dest)
+		return nil
+	}
+	if hasAttachments(journal) {
+		fmt.Printf("Exported %d entries to %s, with their attachments in %s\n", len(journal.Entries), dest,
+			filepath.Join(filepath.Dir(dest), storage.MarkdownAssetsDir))
+		return nil
+	}
+	fmt.Printf("Exported %d entries to %s\n", len(journal.Entries), dest)
+	return nil
+}
+
+func runExportJSON(args []string) error {
+	fs := flag.NewFlagSet("export json", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	year := fs.String("year", "", "only export entries from this year")
+	encryption := addEncryptionFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: journal export json [options] <file.json>")
+	}
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+	enc, err := encryption.resolve(opened)
+	if err != nil {
+		return err
+	}
+
+	journal := opened.journal
+	if *year != "" {
+		journal = filterByYear(journal, *year)
+	}
+
+	dest, err := writeExport(fs.Arg(0), enc, func(fsys storage.ExportFS, path string) error {
+		return storage.ExportJSON(fsys, journal, path)
+	})
+	if err != nil {
+		return err
+	}
+	if enc.Enabled() {
+		fmt.Printf("Exported %d entries to %s, encrypted\n", len(journal.Entries), dest)
+		return nil
+	}
+	fmt.Printf("Exported %d entries to %s\n", len(journal.Entries), dest)
+	return nil
+}
+
+// encryptionFlags are the flags choosing how an export is encrypted
+type encryptionFlags struct {
+	encrypt *bool
+	age     *string
+}
+
+func addEncryptionFlags(fs *flag.FlagSet) encryptionFlags {
+	return encryptionFlags{
+		encrypt: fs.Bool("encrypt", false, "encrypt the export with the journal's password (or a new one for unencrypted journals)"),
+		age:     fs.String("age", "", "encrypt the export to this age recipient with the age command"),
+	}
+}
+
+// resolve returns the encryption the flags ask for. Exports of an
+// unencrypted journal are encrypted with a password typed twice.
+func (f encryptionFlags) resolve(opened *openedJournal) (storage.ExportEncryption, error) {
+	switch {
+	case *f.encrypt && *f.age != "":
+		return storage.ExportEncryption{}, errors.New("use either --encrypt or --age, not both")
+	case *f.age != "":
+		return storage.ExportEncryption{AgeRecipient: *f.age}, nil
+	case !*f.encrypt:
+		return storage.ExportEncryption{}, nil
+	case opened.password != "":
+		return storage.ExportEncryption{Password: opened.password}, nil
+	}
+	password, err := readNewPassword("Password for the export: ")
+	if err != nil {
+		return storage.ExportEncryption{}, err
+	}
+	return storage.ExportEncryption{Password: password}, nil
+}
+
+// writeExport runs export to dest, or encrypted to dest with the
+// encryption's extension when enc is enabled, and returns the path written
+func writeExport(dest string, enc storage.ExportEncryption, export func(fsys storage.ExportFS, path string) error) (string, error) {
+	if !enc.Enabled() {
+		return dest, export(storage.DiskFS, dest)
+	}
+	return storage.EncryptExport(dest, enc, export)
+}
+
+// hasAttachments reports whether any entry of the journal has attachments
+func hasAttachments(journal *model.Journal) bool {
+	for _, e := range journal.Entries {
+		if len(e.Attachments) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// filterByYear returns a journal holding only entries from the given year
+func filterByYear(journal *model.Journal, year string) *model.Journal {
+	filtered := &model.Journal{}
+	for _, e := range journal.Entries {
+		if strings.HasPrefix(e.Date, year+"-") {
+			filtered.Entries = append(filtered.Entries, e)
+		}
+	}
+	return filtered
+}
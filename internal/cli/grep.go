@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"journal/internal/model"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ErrNoMatches is returned by grep when nothing matched. As with grep, it
+// should only set the exit status to 1, without a message.
+var ErrNoMatches = errors.New("no matches")
+
+func runGrep(args []string) error {
+	fs := flag.NewFlagSet("grep", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	since := fs.String("since", "", "only search entries on or after this date (YYYY-MM-DD)")
+	until := fs.String("until", "", "only search entries on or before this date (YYYY-MM-DD)")
+	tag := fs.String("tag", "", "only search entries with this tag")
+	ignoreCase := fs.Bool("i", false, "ignore case")
+	passwordCommand := fs.String("password-command", os.Getenv(passwordCommandEnv), "command printing the password of an encrypted journal")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: journal grep [options] <pattern>")
+	}
+	for _, date := range []string{*since, *until} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			return fmt.Errorf("invalid date %q, use YYYY-MM-DD", date)
+		}
+	}
+
+	pattern := fs.Arg(0)
+	if *ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	opened, err := openJournalWithPassword(*journalName, *passwordCommand)
+	if err != nil {
+		return err
+	}
+	matched := false
+	for i := range opened.journal.Entries {
+		entry := &opened.journal.Entries[i]
+		if (*since != "" && entry.Date < *since) || (*until != "" && entry.Date > *until) {
+			continue
+		}
+		if *tag != "" && !slices.Contains(entry.Tags, *tag) {
+			continue
+		}
+		if grepEntry(os.Stdout, entry, re) {
+			matched = true
+		}
+	}
+	if !matched {
+		return ErrNoMatches
+	}
+	return nil
+}
+
+var (
+	grepDateStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("5"))
+	grepLineStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	grepMatchStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
+)
+
+// grepEntry prints the lines of entry matching re as date:line:text, with
+// the matches highlighted when writing to a terminal, and reports whether
+// any matched
+func grepEntry(w io.Writer, entry *model.Entry, re *regexp.Regexp) bool {
+	matched := false
+	for i, line := range strings.Split(entry.Content, "\n") {
+		locs := re.FindAllStringIndex(line, -1)
+		if locs == nil {
+			continue
+		}
+		matched = true
+
+		var b strings.Builder
+		last := 0
+		for _, loc := range locs {
+			b.WriteString(line[last:loc[0]])
+			b.WriteString(grepMatchStyle.Render(line[loc[0]:loc[1]]))
+			last = loc[1]
+		}
+		b.WriteString(line[last:])
+		fmt.Fprintf(w, "%s:%s:%s\n", grepDateStyle.Render(entry.Date), grepLineStyle.Render(fmt.Sprint(i+1)), b.String())
+	}
+	return matched
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"journal/internal/clock"
+	"journal/internal/dates"
+	"journal/internal/storage"
+)
+
+func runHighlight(args []string) error {
+	fs := flag.NewFlagSet("highlight", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	on := fs.String("date", "today", "date of the entry to add to (YYYY-MM-DD, or e.g. yesterday)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	text := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(text) == "" {
+		return errors.New("usage: journal highlight [--date <date>] \"what went well\"")
+	}
+
+	date, err := dates.Parse(*on, time.Now())
+	if err != nil {
+		return err
+	}
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+
+	template := opened.config.TemplateFor(date)
+	if _, err := storage.AddHighlight(opened.store, date, text, template, clock.System, clock.UUID); err != nil {
+		return err
+	}
+	fmt.Printf("Added to the highlights of %s\n", date)
+	return nil
+}
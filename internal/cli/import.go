@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"journal/internal/storage"
+)
+
+func runImport(args []string) error {
+	if len(args) == 0 || args[0] != "csv" {
+		return errors.New("usage: journal import csv [options] <file>")
+	}
+	return runImportCSV(args[1:])
+}
+
+func runImportCSV(args []string) error {
+	fs := flag.NewFlagSet("import csv", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	dateCol := fs.String("date-column", "date", "column holding the entry date (header name or 1-based index)")
+	contentCol := fs.String("content-column", "content", "column holding the entry content")
+	tagsCol := fs.String("tags-column", "", "column holding tags (optional)")
+	dateFormat := fs.String("date-format", "2006-01-02", "Go time layout of the date column")
+	tagSep := fs.String("tag-separator", ",", "separator between tags in the tags column")
+	delimiter := fs.String("delimiter", ",", "field delimiter")
+	noHeader := fs.Bool("no-header", false, "the file has no header row; columns are 1-based indexes")
+	dryRun := fs.Bool("dry-run", false, "report what would be imported without saving")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: journal import csv [options] <file>")
+	}
+
+	delim, size := utf8.DecodeRuneInString(*delimiter)
+	if size == 0 || size != len(*delimiter) {
+		return fmt.Errorf("delimiter must be a single character")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+
+	result, err := storage.ImportCSV(f, storage.CSVMapping{
+		DateColumn:    *dateCol,
+		ContentColumn: *contentCol,
+		TagsColumn:    *tagsCol,
+		DateFormat:    *dateFormat,
+		TagSeparator:  *tagSep,
+		Delimiter:     delim,
+		NoHeader:      *noHeader,
+	}, opened.journal)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range result.Skipped {
+		fmt.Printf("skipped line %d: %s\n", s.Line, s.Reason)
+	}
+	for _, d := range result.Duplicates {
+		fmt.Printf("warning: line %d (%s) is nearly identical to the entry of %s\n", d.Line, d.Date, d.Of)
+	}
+
+	if !*dryRun && len(result.Imported) > 0 {
+		// Only the new entries are written, in one transaction
+		if err := opened.store.SaveEntries(result.Imported); err != nil {
+			return err
+		}
+	}
+
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s %d entries into %s, skipped %d rows\n", verb, len(result.Imported), opened.db.Name, len(result.Skipped))
+	return nil
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+)
+
+// StartProfile looks for a --profile <file> flag anywhere in args and, if
+// present, starts CPU profiling into file. It returns args without the flag
+// and a stop function that finishes the CPU profile and writes a heap
+// profile to file.heap; stop must be called before the program exits.
+func StartProfile(args []string) ([]string, func(), error) {
+	path := ""
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--profile" || arg == "-profile":
+			if i+1 >= len(args) {
+				return nil, nil, errors.New("--profile requires a file name")
+			}
+			path = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--profile="):
+			path = strings.TrimPrefix(arg, "--profile=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if path == "" {
+		return args, func() {}, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	stop := func() {
+		pprof.StopCPUProfile()
+		f.Close()
+
+		heap, err := os.Create(path + ".heap")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing heap profile: %v\n", err)
+			return
+		}
+		defer heap.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(heap); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing heap profile: %v\n", err)
+		}
+	}
+	return rest, stop, nil
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"errors"
+	"strings"
+)
+
+// ScriptFile looks for a --script <file> flag anywhere in args, returning
+// args without the flag and the file, or an empty string when absent
+func ScriptFile(args []string) ([]string, string, error) {
+	path := ""
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--script" || arg == "-script":
+			if i+1 >= len(args) {
+				return nil, "", errors.New("--script requires a file name")
+			}
+			path = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--script="):
+			path = strings.TrimPrefix(arg, "--script=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if path == "" {
+		return args, "", nil
+	}
+	return rest, path, nil
+}
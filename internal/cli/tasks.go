@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"journal/internal/model"
+)
+
+// taskwarriorTag marks the Taskwarrior tasks that come from the journal
+const taskwarriorTag = "+journal"
+
+func runTasks(args []string) error {
+	fs := flag.NewFlagSet("tasks", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	all := fs.Bool("all", false, "list done tasks too")
+	taskwarrior := fs.Bool("taskwarrior", false, "sync tasks to Taskwarrior with its task command")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+
+	if *taskwarrior {
+		tasks, err := opened.store.ListTasks(false)
+		if err != nil {
+			return err
+		}
+		return syncTaskwarrior(tasks)
+	}
+
+	tasks, err := opened.store.ListTasks(!*all)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		box := "[ ]"
+		if t.Done {
+			box = "[x]"
+		}
+		fmt.Printf("%s %s %s\n", t.Date, box, t.Text)
+	}
+	return nil
+}
+
+// taskwarriorTask is the part of a task in Taskwarrior's JSON export the
+// sync reads
+type taskwarriorTask struct {
+	UUID        string `json:"uuid"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+}
+
+// syncTaskwarrior adds the journal's open tasks to Taskwarrior, tagged
+// journal and created on their entry's date, and completes the Taskwarrior
+// tasks ticked off in the journal. Tasks are matched by their text.
+func syncTaskwarrior(tasks []model.Task) error {
+	if _, err := exec.LookPath("task"); err != nil {
+		return errors.New("Taskwarrior's task command was not found in PATH")
+	}
+
+	out, err := runTask(taskwarriorTag, "export")
+	if err != nil {
+		return err
+	}
+	var existing []taskwarriorTask
+	if err := json.Unmarshal(out, &existing); err != nil {
+		return fmt.Errorf("reading Taskwarrior's export: %w", err)
+	}
+	byText := make(map[string]taskwarriorTask)
+	for _, t := range existing {
+		if t.Status == "deleted" {
+			continue
+		}
+		if prev, ok := byText[t.Description]; !ok || prev.Status != "pending" {
+			byText[t.Description] = t
+		}
+	}
+
+	added, completed := 0, 0
+	for _, t := range tasks {
+		tw, ok := byText[t.Text]
+		switch {
+		case !t.Done && !ok:
+			if _, err := runTask("add", taskwarriorTag, "entry:"+t.Date, "--", t.Text); err != nil {
+				return err
+			}
+			byText[t.Text] = taskwarriorTask{Description: t.Text, Status: "pending"}
+			added++
+		case t.Done && ok && tw.Status == "pending" && tw.UUID != "":
+			if _, err := runTask(tw.UUID, "done"); err != nil {
+				return err
+			}
+			tw.Status = "completed"
+			byText[t.Text] = tw
+			completed++
+		}
+	}
+	fmt.Printf("Taskwarrior: %d added, %d completed\n", added, completed)
+	return nil
+}
+
+// runTask runs Taskwarrior's task command without confirmations or
+// messages and returns its output
+func runTask(args ...string) ([]byte, error) {
+	args = append([]string{"rc.confirmation=off", "rc.verbose=nothing"}, args...)
+	cmd := exec.Command("task", args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("task %s: %w", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
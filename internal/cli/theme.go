@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"journal/internal/storage"
+	"journal/internal/theme"
+)
+
+func runTheme(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: journal theme <export|import> [options] [file]")
+	}
+	switch args[0] {
+	case "export":
+		return runThemeExport(args[1:])
+	case "import":
+		return runThemeImport(args[1:])
+	}
+	return fmt.Errorf("unknown theme command %q", args[0])
+}
+
+func runThemeExport(args []string) error {
+	fs := flag.NewFlagSet("theme export", flag.ContinueOnError)
+	full := fs.Bool("full", false, "write every color, for the current terminal background, not just the overridden ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New("usage: journal theme export [--full] [file]")
+	}
+
+	config, err := storage.LoadConfig()
+	if err != nil {
+		return err
+	}
+	file := theme.File{Theme: config.Theme, Colors: config.ThemeColors}
+	if file.Theme == "" {
+		file.Theme = theme.Current().Name
+	}
+	if *full {
+		if err := theme.SetBackground(config.Background); err != nil {
+			return err
+		}
+		theme.Set(file.Theme)
+		if err := theme.SetOverrides(config.ThemeColors); err != nil {
+			return err
+		}
+		file.Colors = theme.Colors(theme.Current())
+	}
+
+	data, err := file.Marshal()
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	dest, err := storage.ExpandPath(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Exported theme %s with %d colors to %s\n", file.Theme, len(file.Colors), dest)
+	return nil
+}
+
+func runThemeImport(args []string) error {
+	fs := flag.NewFlagSet("theme import", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: journal theme import <file>")
+	}
+
+	path, err := storage.ExpandPath(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file, err := theme.ParseFile(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	config, err := storage.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if err := storage.SetFileMode(config.FileMode); err != nil {
+		return err
+	}
+	// The imported colors replace the overrides rather than adding to
+	// them, so the theme looks as it did for whoever shared it
+	config.Theme = file.Theme
+	config.ThemeColors = file.Colors
+	if err := storage.SaveConfig(config); err != nil {
+		return err
+	}
+	fmt.Printf("Imported theme %s with %d colors\n", file.Theme, len(file.Colors))
+	return nil
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+)
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+
+	problems, err := opened.store.Verify()
+	if err != nil {
+		return err
+	}
+	damaged := make(map[string]bool)
+	for _, p := range problems {
+		fmt.Println(p)
+		damaged[p.EntryID] = true
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d versions of %d entries don't match their checksums", len(problems), len(damaged))
+	}
+	fmt.Printf("All %d entries match their checksums\n", len(opened.journal.Entries))
+	return nil
+}
@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"journal/internal/stats"
+	"journal/internal/storage"
+)
+
+func runWords(args []string) error {
+	fs := flag.NewFlagSet("words", flag.ContinueOnError)
+	journalName := fs.String("journal", "", "journal name or path (default: active journal)")
+	top := fs.Int("top", 30, "number of words to report")
+	by := fs.String("by", "month", "group usage over time by month or year")
+	csvPath := fs.String("csv", "", "write the report as CSV to this file (- for stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	period := stats.PeriodMonth
+	switch *by {
+	case "month":
+	case "year":
+		period = stats.PeriodYear
+	default:
+		return fmt.Errorf("--by must be month or year")
+	}
+
+	opened, err := openJournal(*journalName)
+	if err != nil {
+		return err
+	}
+
+	report := stats.WordFrequency(opened.journal, *top, period)
+
+	switch *csvPath {
+	case "":
+		for i, wc := range report.Words {
+			fmt.Printf("%3d. %-20s %6d\n", i+1, wc.Word, wc.Total)
+		}
+		return nil
+	case "-":
+		return report.WriteCSV(os.Stdout)
+	}
+
+	f, err := storage.CreateFile(*csvPath)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d words to %s\n", len(report.Words), *csvPath)
+	return nil
+}
@@ -0,0 +1,79 @@
+// Package clock provides the current time and new entry and attachment IDs.
+// Code that stamps or names what it saves takes them as a Clock and an
+// IDGenerator, so the same inputs can produce the same entries and history.
+package clock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// IDGenerator returns a new unique ID on each call
+type IDGenerator interface {
+	NewID() string
+}
+
+// System is the real clock
+var System Clock = systemClock{}
+
+// UUID generates random UUIDs
+var UUID IDGenerator = uuidGenerator{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+type uuidGenerator struct{}
+
+func (uuidGenerator) NewID() string { return uuid.New().String() }
+
+// Fixed is a clock that starts at a set time and moves forward by a step each
+// time it is read, so consecutive saves get distinct, predictable times
+type Fixed struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+// NewFixed returns a clock reading start, then start+step, and so on
+func NewFixed(start time.Time, step time.Duration) *Fixed {
+	return &Fixed{now: start, step: step}
+}
+
+func (c *Fixed) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// Sequence generates the IDs prefix-1, prefix-2, and so on
+type Sequence struct {
+	mu     sync.Mutex
+	prefix string
+	next   int
+}
+
+// NewSequence returns a generator of numbered IDs starting with prefix
+func NewSequence(prefix string) *Sequence {
+	return &Sequence{prefix: prefix, next: 1}
+}
+
+func (s *Sequence) NewID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := fmt.Sprintf("%s-%d", s.prefix, s.next)
+	s.next++
+	return id
+}
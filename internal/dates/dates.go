@@ -0,0 +1,109 @@
+// Package dates reads the dates people type for entries: YYYY-MM-DD, or
+// everyday phrases such as "yesterday", "last friday", or "3 days ago".
+package dates
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout is the form entry dates are stored in
+const Layout = "2006-01-02"
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// Parse reads input as a date relative to today and returns it in
+// YYYY-MM-DD form. Besides YYYY-MM-DD it accepts "today", "yesterday",
+// "tomorrow", a weekday ("friday" is the most recent one, today included),
+// "last friday" and "next friday", "3 days ago" and "in 2 weeks" (with
+// days, weeks, months, or years, and "a" for one).
+func Parse(input string, today time.Time) (string, error) {
+	text := strings.Join(strings.Fields(strings.ToLower(input)), " ")
+	if date, err := time.Parse(Layout, text); err == nil {
+		return date.Format(Layout), nil
+	}
+	if date, ok := relative(text, today); ok {
+		return date.Format(Layout), nil
+	}
+	return "", fmt.Errorf("%q is not a date, use YYYY-MM-DD or e.g. \"yesterday\" or \"3 days ago\"", input)
+}
+
+// relative reads the phrases Parse accepts other than YYYY-MM-DD
+func relative(text string, today time.Time) (time.Time, bool) {
+	switch text {
+	case "today", "now":
+		return today, true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	}
+
+	words := strings.Fields(text)
+	switch {
+	case len(words) == 1:
+		if day, ok := weekdays[words[0]]; ok {
+			return today.AddDate(0, 0, -daysBack(today.Weekday(), day)), true
+		}
+	case len(words) == 2 && (words[0] == "last" || words[0] == "next"):
+		day, ok := weekdays[words[1]]
+		if !ok {
+			return time.Time{}, false
+		}
+		if words[0] == "last" {
+			back := daysBack(today.Weekday(), day)
+			if back == 0 {
+				back = 7
+			}
+			return today.AddDate(0, 0, -back), true
+		}
+		ahead := (int(day) - int(today.Weekday()) + 7) % 7
+		if ahead == 0 {
+			ahead = 7
+		}
+		return today.AddDate(0, 0, ahead), true
+	case len(words) == 3 && words[2] == "ago":
+		return offset(today, words[0], words[1], -1)
+	case len(words) == 3 && words[0] == "in":
+		return offset(today, words[1], words[2], 1)
+	}
+	return time.Time{}, false
+}
+
+// daysBack is how many days ago the most recent day was, counting today
+func daysBack(from, day time.Weekday) int {
+	return (int(from) - int(day) + 7) % 7
+}
+
+// offset moves today by count units, back when sign is -1
+func offset(today time.Time, count, unit string, sign int) (time.Time, bool) {
+	n := 1
+	if count != "a" && count != "an" && count != "one" {
+		var err error
+		if n, err = strconv.Atoi(count); err != nil || n < 0 {
+			return time.Time{}, false
+		}
+	}
+	n *= sign
+	switch strings.TrimSuffix(unit, "s") {
+	case "day":
+		return today.AddDate(0, 0, n), true
+	case "week":
+		return today.AddDate(0, 0, 7*n), true
+	case "month":
+		return today.AddDate(0, n, 0), true
+	case "year":
+		return today.AddDate(n, 0, 0), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,218 @@
+// Package diff compares two versions of an entry line by line, and the
+// lines that changed word by word, for showing side by side.
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Op is what happened to a piece of text between the old and new version
+type Op int
+
+const (
+	Equal  Op = iota
+	Delete    // Only in the old version
+	Insert    // Only in the new version
+)
+
+// Span is a run of text and what happened to it
+type Span struct {
+	Op   Op
+	Text string
+}
+
+// Row is a line of the comparison. A changed line is in both versions,
+// with the words removed marked Delete in Old and those added marked
+// Insert in New; a line only in one version has no spans in the other.
+type Row struct {
+	InOld, InNew bool
+	Old, New     []Span
+}
+
+// Changed reports whether the row differs between the versions
+func (r Row) Changed() bool {
+	if r.InOld != r.InNew {
+		return true
+	}
+	for _, s := range r.Old {
+		if s.Op != Equal {
+			return true
+		}
+	}
+	for _, s := range r.New {
+		if s.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// maxCells caps the table comparing two sequences. Past it, the differing
+// middle of the sequences is shown as removed and added whole rather than
+// compared, keeping huge entries from using much time or memory.
+const maxCells = 4_000_000
+
+// Rows compares old and new line by line. Runs of removed lines followed by
+// added ones are paired up and compared word by word.
+func Rows(old, new string) []Row {
+	oldLines, newLines := strings.Split(old, "\n"), strings.Split(new, "\n")
+	var rows []Row
+	var removed, added []string
+	flush := func() {
+		for i := 0; i < max(len(removed), len(added)); i++ {
+			var row Row
+			switch {
+			case i < len(removed) && i < len(added):
+				row = Row{InOld: true, InNew: true}
+				row.Old, row.New = Words(removed[i], added[i])
+			case i < len(removed):
+				row = Row{InOld: true, Old: []Span{{Delete, removed[i]}}}
+			default:
+				row = Row{InNew: true, New: []Span{{Insert, added[i]}}}
+			}
+			rows = append(rows, row)
+		}
+		removed, added = removed[:0], added[:0]
+	}
+	for _, e := range compare(oldLines, newLines) {
+		switch e.op {
+		case Delete:
+			removed = append(removed, oldLines[e.index])
+		case Insert:
+			added = append(added, newLines[e.index])
+		default:
+			flush()
+			line := []Span{{Equal, oldLines[e.index]}}
+			rows = append(rows, Row{InOld: true, InNew: true, Old: line, New: line})
+		}
+	}
+	flush()
+	return rows
+}
+
+// Words compares two lines word by word, returning the spans of the old
+// line, with the words removed marked Delete, and of the new line, with
+// the words added marked Insert
+func Words(old, new string) (oldSpans, newSpans []Span) {
+	oldWords, newWords := Tokens(old), Tokens(new)
+	for _, e := range compare(oldWords, newWords) {
+		switch e.op {
+		case Delete:
+			oldSpans = appendSpan(oldSpans, Delete, oldWords[e.index])
+		case Insert:
+			newSpans = appendSpan(newSpans, Insert, newWords[e.index])
+		default:
+			oldSpans = appendSpan(oldSpans, Equal, oldWords[e.index])
+			newSpans = appendSpan(newSpans, Equal, oldWords[e.index])
+		}
+	}
+	return oldSpans, newSpans
+}
+
+// appendSpan adds text to spans, joining it to the last span when that
+// has the same op
+func appendSpan(spans []Span, op Op, text string) []Span {
+	if n := len(spans); n > 0 && spans[n-1].Op == op {
+		spans[n-1].Text += text
+		return spans
+	}
+	return append(spans, Span{op, text})
+}
+
+// Tokens splits a line into words and the runs of spaces between them, so
+// that joining them gives the line back
+func Tokens(line string) []string {
+	var out []string
+	start, space := 0, false
+	for i, r := range line {
+		if i > start && unicode.IsSpace(r) != space {
+			out = append(out, line[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(line) {
+		out = append(out, line[start:])
+	}
+	return out
+}
+
+// edit is a step turning the old sequence into the new one: keeping
+// old[index] (Equal), removing old[index], or adding new[index]
+type edit struct {
+	op    Op
+	index int
+}
+
+// compare returns the edits turning a into b, keeping their longest common
+// subsequence. Removals come before additions in each run of changes.
+func compare(a, b []string) []edit {
+	// Common ends are kept without being compared
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []edit
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, edit{Equal, i})
+	}
+	edits = append(edits, compareMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := len(a) - suffix; i < len(a); i++ {
+		edits = append(edits, edit{Equal, i})
+	}
+	return edits
+}
+
+// compareMiddle compares the parts of two sequences between their common
+// ends, which start at aStart and bStart in them
+func compareMiddle(a, b []string, aStart, bStart int) []edit {
+	var edits []edit
+	if len(a)*len(b) > maxCells {
+		for i := range a {
+			edits = append(edits, edit{Delete, aStart + i})
+		}
+		for j := range b {
+			edits = append(edits, edit{Insert, bStart + j})
+		}
+		return edits
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{Equal, aStart + i})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{Delete, aStart + i})
+			i++
+		default:
+			edits = append(edits, edit{Insert, bStart + j})
+			j++
+		}
+	}
+	return edits
+}
@@ -1,7 +1,13 @@
 package model
 
 import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
 // Attachment represents a file attached to an entry
@@ -17,9 +23,12 @@ type Attachment struct {
 
 // SaveRecord represents a previous version of an entry
 type SaveRecord struct {
-	Content     string   `json:"content"`
+	Content     string    `json:"content"`
 	SavedAt     time.Time `json:"saved_at"`
-	Attachments []string `json:"attachments,omitempty"` // Filenames at time of save
+	Attachments []string  `json:"attachments,omitempty"` // Filenames at time of save
+	Tags        []string  `json:"tags,omitempty"`        // Tags at time of save
+	Label       string    `json:"label,omitempty"`       // Optional user annotation, e.g. "before rewrite"
+	Hash        string    `json:"hash,omitempty"`        // Checksum chained to the version before, set when stored
 }
 
 // Entry represents a single journal entry
@@ -27,10 +36,116 @@ type Entry struct {
 	ID          string       `json:"id"`
 	Date        string       `json:"date"`
 	Content     string       `json:"content"`
+	Tags        []string     `json:"tags,omitempty"`
+	Mood        string       `json:"mood,omitempty"`
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
 	History     []SaveRecord `json:"history,omitempty"`
 	Attachments []Attachment `json:"attachments,omitempty"`
+	// WritingTime is the time spent with the entry open in the editor
+	WritingTime time.Duration `json:"writing_time,omitempty"`
+	// Typing is how the entry was typed in the editor
+	Typing Typing `json:"typing,omitzero"`
+}
+
+// Typing counts the keys typed into an entry's content in the editor, and
+// the breaks taken from typing, over all the sessions it was written in
+type Typing struct {
+	Sessions     int           `json:"sessions"`     // Times the editor was opened and typed in
+	Keystrokes   int           `json:"keystrokes"`   // Keys that changed the content; pasting counts as one
+	Deletions    int           `json:"deletions"`    // Keystrokes that removed text, such as Backspace
+	Pauses       int           `json:"pauses"`       // Breaks from typing of TypingPause or longer
+	Distractions int           `json:"distractions"` // Times the terminal was left for another window while typing
+	Active       time.Duration `json:"active"`       // Time spent typing, breaks left out
+	Flow         time.Duration `json:"flow"`         // Time spent in stretches of FlowStretch or longer without a break
+	LongestFlow  time.Duration `json:"longest_flow"` // Longest such stretch
+}
+
+// TypingPause is the shortest break between keystrokes counted as a pause,
+// ending a stretch of typing
+const TypingPause = 15 * time.Second
+
+// FlowStretch is the shortest stretch of typing without a pause counted as
+// time in flow
+const FlowStretch = 5 * time.Minute
+
+// Add returns the counts of t and other together
+func (t Typing) Add(other Typing) Typing {
+	return Typing{
+		Sessions:     t.Sessions + other.Sessions,
+		Keystrokes:   t.Keystrokes + other.Keystrokes,
+		Deletions:    t.Deletions + other.Deletions,
+		Pauses:       t.Pauses + other.Pauses,
+		Distractions: t.Distractions + other.Distractions,
+		Active:       t.Active + other.Active,
+		Flow:         t.Flow + other.Flow,
+		LongestFlow:  max(t.LongestFlow, other.LongestFlow),
+	}
+}
+
+// String writes the counts as they are stored, e.g. "sessions=2 keys=950
+// deletions=61 pauses=7 distractions=1 active=14m10s flow=6m2s
+// longest_flow=6m2s", or "" when nothing was typed. Times are kept to the
+// second, as writing time is.
+func (t Typing) String() string {
+	if t == (Typing{}) {
+		return ""
+	}
+	return fmt.Sprintf("sessions=%d keys=%d deletions=%d pauses=%d distractions=%d active=%s flow=%s longest_flow=%s",
+		t.Sessions, t.Keystrokes, t.Deletions, t.Pauses, t.Distractions,
+		t.Active.Round(time.Second), t.Flow.Round(time.Second), t.LongestFlow.Round(time.Second))
+}
+
+// ParseTyping reads counts written by Typing.String. Counts it doesn't
+// know or can't read are left at zero.
+func ParseTyping(s string) Typing {
+	var t Typing
+	counts := map[string]*int{
+		"sessions": &t.Sessions, "keys": &t.Keystrokes, "deletions": &t.Deletions,
+		"pauses": &t.Pauses, "distractions": &t.Distractions,
+	}
+	durations := map[string]*time.Duration{"active": &t.Active, "flow": &t.Flow, "longest_flow": &t.LongestFlow}
+	for _, field := range strings.Fields(s) {
+		name, value, _ := strings.Cut(field, "=")
+		if n, ok := counts[name]; ok {
+			*n, _ = strconv.Atoi(value)
+		} else if d, ok := durations[name]; ok {
+			*d, _ = time.ParseDuration(value)
+		}
+	}
+	return t
+}
+
+// EntrySummary is the part of an entry shown in the entry list. Content is
+// cut to an excerpt and history and attachments are reduced to counts, so a
+// page of summaries stays small however long the entries are.
+type EntrySummary struct {
+	ID              string
+	Date            string
+	Excerpt         string
+	Tags            []string
+	Mood            string
+	HistoryCount    int
+	AttachmentCount int
+	WordCount       int
+}
+
+// EntryFilter narrows the entries returned by entry queries. Empty fields
+// match everything; Since and Until are inclusive YYYY-MM-DD dates.
+type EntryFilter struct {
+	Tag         string
+	Mood        string
+	Since       string
+	Until       string
+	Text        string // Words that must all occur in the content
+	Attachments bool   // Only entries with attachments
+	WordsOver   int    // Only entries of more than this many words, when set
+	Edited      bool   // Only entries saved more than once
+}
+
+// IsZero reports whether the filter matches every entry
+func (f EntryFilter) IsZero() bool {
+	return f == EntryFilter{}
 }
 
 // Journal represents the collection of entries
@@ -38,12 +153,48 @@ type Journal struct {
 	Entries []Entry `json:"entries"`
 }
 
+// Journal storage formats. A Markdown journal's Path is a folder, and a
+// PostgreSQL journal's Path is the server's connection string.
+const (
+	FormatSQLite   = ""
+	FormatMarkdown = "markdown"
+	FormatPostgres = "postgres"
+)
+
+// How an encrypted journal is read. By default the whole file is
+// decrypted into memory for each operation; in session mode it is
+// decrypted into memory once and kept there until the journal is closed.
+const (
+	EncryptionWholeFile = ""
+	EncryptionSession   = "session"
+)
+
 // JournalDB represents a journal database
 type JournalDB struct {
 	Name       string    `json:"name"`
 	Path       string    `json:"path"`
+	Format     string    `json:"format,omitempty"`
 	Encrypted  bool      `json:"encrypted"`
 	LastOpened time.Time `json:"last_opened"`
+	LastBackup time.Time `json:"last_backup,omitzero"`
+	Stats      *Stats    `json:"stats,omitempty"` // Cached for the journal selector
+	// PasswordHint is written by the user to jog their memory after wrong
+	// passwords. It is stored in plain text, so it never holds the password.
+	PasswordHint string `json:"password_hint,omitempty"`
+	// LockAfterDays makes entries older than this many days read-only
+	// until they are unlocked; 0 never locks them
+	LockAfterDays int `json:"lock_after_days,omitempty"`
+	// EncryptionMode is how an encrypted journal is read, chosen at setup
+	EncryptionMode string `json:"encryption_mode,omitempty"`
+}
+
+// Stats summarises a journal for the journal selector. It is cached in the
+// config and counted again when the journal's files change.
+type Stats struct {
+	Entries   int       `json:"entries"`
+	LastEntry string    `json:"last_entry,omitempty"` // Date of the newest entry
+	Size      int64     `json:"size"`                 // Bytes on disk; 0 for a server
+	ModTime   time.Time `json:"mod_time"`             // Of the newest file when counted
 }
 
 // Config represents the application configuration
@@ -53,20 +204,425 @@ type Config struct {
 	Encrypted    bool   `json:"encrypted,omitempty"`
 
 	// New fields
-	Journals      []JournalDB `json:"journals,omitempty"`
-	ActiveJournal string      `json:"active_journal,omitempty"` // Path of active journal
-	Theme         string      `json:"theme,omitempty"`          // Color theme name
+	Journals      []JournalDB       `json:"journals,omitempty"`
+	ActiveJournal string            `json:"active_journal,omitempty"` // Path of active journal
+	Theme         string            `json:"theme,omitempty"`          // Color theme name
+	ThemeColors   map[string]string `json:"theme_colors,omitempty"`   // Colors laid over the theme, e.g. {"accent": "#ff8800"}
+	Background    string            `json:"background,omitempty"`     // "light" or "dark" to override detecting the terminal's
+	MoodTracking  bool              `json:"mood_tracking,omitempty"`
+	Moods         []string          `json:"moods,omitempty"` // Custom mood set, defaults to DefaultMoods
+
+	PreviewLength int  `json:"preview_length,omitempty"` // Characters of each entry shown in the list, defaults to 40
+	ListTitles    bool `json:"list_titles,omitempty"`    // Show each entry's first line or heading in the list instead
+
+	ListBadges []string `json:"list_badges,omitzero"` // Badges after each entry in the list, in order; nil for DefaultListBadges, empty for none
+
+	RejectFutureDates bool `json:"reject_future_dates,omitempty"` // Refuse to save entries dated after today
+
+	WordGoal int `json:"word_goal,omitempty"` // Words a day's entry aims for, shaded in the date picker; 0 for none
+
+	AutosaveMinutes int `json:"autosave_minutes,omitempty"`  // Save the entry being edited this often, 0 for never
+	AwaySaveMinutes int `json:"away_save_minutes,omitempty"` // Save the entry being edited once the terminal has been out of focus this long, 0 for never
+
+	Editor string `json:"editor,omitempty"` // External editor command for Alt+E in the editor, e.g. "code --wait"; defaults to $VISUAL or $EDITOR
+
+	SpellDictionaries map[string]string `json:"spell_dictionaries,omitempty"` // Spellcheck dictionary by the language detected for an entry, e.g. {"en": "en_GB"}; laid over DefaultSpellDictionaries
+
+	Templates        map[string]string `json:"templates,omitempty"`         // Text new entries start with, by template name
+	WeekdayTemplates map[string]string `json:"weekday_templates,omitempty"` // Template name by weekday, e.g. {"monday": "planning"}
+
+	BackupSchedule string `json:"backup_schedule,omitempty"` // "daily", "weekly", or empty for off
+	BackupKeep     int    `json:"backup_keep,omitempty"`     // Backups kept per journal, defaults to 10
+
+	KDF *KDFParams `json:"kdf,omitempty"` // Key derivation for newly encrypted journals, calibrated at setup
+
+	FileMode string `json:"file_mode,omitempty"` // Permissions of the journal files, backups, and exports written, in octal, e.g. "0640"; defaults to "0600"
+
+	AttachmentCheck string `json:"attachment_check,omitempty"` // Command run on each file before it is attached, with its path as the last argument; files it fails on are refused
+}
+
+// KDFParams configures password key derivation for encrypted journals.
+// The parameters used are stored in each encrypted file's header, so
+// changing them only affects journals encrypted afterwards.
+type KDFParams struct {
+	Algorithm       string `json:"algorithm"`                 // "argon2id" or "scrypt"
+	MemoryKiB       uint32 `json:"memory_kib"`                // Memory cost in KiB
+	Iterations      uint32 `json:"iterations,omitempty"`      // Argon2 passes (unused by scrypt)
+	Threads         uint8  `json:"threads,omitempty"`         // Argon2 lanes (unused by scrypt)
+	Parallelization uint32 `json:"parallelization,omitempty"` // scrypt parallelization factor p (unused by Argon2)
+}
+
+// DefaultMoods is the mood scale used when no custom set is configured
+var DefaultMoods = []string{"😞", "🙁", "😐", "🙂", "😄"}
+
+// MoodSet returns the configured moods, or nil when mood tracking is disabled
+func (c *Config) MoodSet() []string {
+	if c == nil || !c.MoodTracking {
+		return nil
+	}
+	if len(c.Moods) > 0 {
+		return c.Moods
+	}
+	return DefaultMoods
+}
+
+// CheckTemplates reports weekday templates naming a day or template that
+// doesn't exist
+func (c *Config) CheckTemplates() error {
+	for day, name := range c.WeekdayTemplates {
+		if _, ok := weekdayNames[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("unknown weekday %q in weekday_templates, use e.g. \"monday\"", day)
+		}
+		if _, ok := c.Templates[name]; !ok {
+			return fmt.Errorf("weekday_templates uses template %q for %s, which isn't in templates", name, day)
+		}
+	}
+	return nil
+}
+
+// weekdayNames are the weekday_templates keys
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday,
+	"wednesday": time.Wednesday, "thursday": time.Thursday, "friday": time.Friday,
+	"saturday": time.Saturday,
+}
+
+// TemplateFor returns the text a new entry dated date (YYYY-MM-DD) starts
+// with: the template set for its weekday, or nothing
+func (c *Config) TemplateFor(date string) string {
+	if c == nil {
+		return ""
+	}
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return ""
+	}
+	for day, name := range c.WeekdayTemplates {
+		if weekday, ok := weekdayNames[strings.ToLower(day)]; ok && weekday == d.Weekday() {
+			if text, ok := c.Templates[name]; ok {
+				return text
+			}
+		}
+	}
+	return ""
 }
 
-// Preview returns a truncated preview of the entry content
+// ListBadgeNames are the badges the entry list can show after each entry
+var ListBadgeNames = []string{"mood", "saves", "files", "words", "tags"}
+
+// DefaultListBadges are the badges shown when none are configured
+var DefaultListBadges = []string{"mood", "saves", "files"}
+
+// Badges returns the configured entry list badges, in order
+func (c *Config) Badges() []string {
+	if c == nil || c.ListBadges == nil {
+		return DefaultListBadges
+	}
+	return c.ListBadges
+}
+
+// CheckListBadges reports list badges that don't exist or are repeated
+func (c *Config) CheckListBadges() error {
+	for i, name := range c.ListBadges {
+		if !slices.Contains(ListBadgeNames, name) {
+			return fmt.Errorf("unknown badge %q in list_badges, expected some of %s", name, strings.Join(ListBadgeNames, ", "))
+		}
+		if slices.Contains(c.ListBadges[:i], name) {
+			return fmt.Errorf("badge %q is in list_badges twice", name)
+		}
+	}
+	return nil
+}
+
+// DefaultPreviewLength is how much of each entry the entry list shows
+// when no length is configured
+const DefaultPreviewLength = 40
+
+// PreviewLen returns the configured entry list preview length
+func (c *Config) PreviewLen() int {
+	if c == nil || c.PreviewLength <= 0 {
+		return DefaultPreviewLength
+	}
+	return c.PreviewLength
+}
+
+// DayWordGoal returns the configured words a day's entry aims for, 0 when
+// there's no goal
+func (c *Config) DayWordGoal() int {
+	if c == nil {
+		return 0
+	}
+	return max(c.WordGoal, 0)
+}
+
+// DefaultSpellDictionaries are the hunspell and aspell dictionaries an
+// entry's spelling is checked with, by the code of the language it is
+// detected as written in
+var DefaultSpellDictionaries = map[string]string{
+	"en": "en_US", "de": "de_DE", "fr": "fr_FR", "es": "es_ES", "it": "it_IT",
+	"pt": "pt_PT", "nl": "nl_NL", "sv": "sv_SE", "ru": "ru_RU",
+}
+
+// SpellDictionary returns the dictionary the spelling of entries in
+// language (an ISO 639-1 code) is checked with, or "" when there is none
+func (c *Config) SpellDictionary(language string) string {
+	if c != nil {
+		if dictionary, ok := c.SpellDictionaries[language]; ok {
+			return dictionary
+		}
+	}
+	return DefaultSpellDictionaries[language]
+}
+
+// Preview returns a truncated preview of the entry content on one line,
+// with line breaks and runs of spaces collapsed
 func (e Entry) Preview(maxLen int) string {
-	content := e.Content
-	if len(content) > maxLen {
-		content = content[:maxLen] + "..."
+	content := strings.Join(strings.Fields(e.Content), " ")
+	if runes := []rune(content); len(runes) > maxLen {
+		content = string(runes[:maxLen]) + "..."
 	}
 	return content
 }
 
+// Preview returns a truncated preview of the excerpt
+func (s EntrySummary) Preview(maxLen int) string {
+	return Entry{Content: s.Excerpt}.Preview(maxLen)
+}
+
+// Title returns the entry's Markdown heading (a line starting "# "), or
+// failing that its first line that isn't blank, cut to maxLen characters
+func (e Entry) Title(maxLen int) string {
+	title := ""
+	for _, line := range strings.Split(e.Content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "# ") {
+			title = line
+			break
+		}
+		if title == "" {
+			title = line
+		}
+	}
+	title = strings.TrimSpace(strings.TrimLeft(title, "#"))
+	if runes := []rune(title); len(runes) > maxLen {
+		title = string(runes[:maxLen]) + "..."
+	}
+	return title
+}
+
+// Title returns the title of the excerpt, as Entry.Title does. Only
+// headings within the excerpt are found.
+func (s EntrySummary) Title(maxLen int) string {
+	return Entry{Content: s.Excerpt}.Title(maxLen)
+}
+
+// TagCount is a tag and the number of entries that have it
+type TagCount struct {
+	Tag     string
+	Entries int
+}
+
+// ParseTags reads tags written separated by commas or spaces, each with or
+// without a leading "#", as in "work, #travel". Repeated tags are dropped,
+// and "|", which separates tags where they are stored, is removed.
+func ParseTags(s string) []string {
+	var tags []string
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		tag := strings.ReplaceAll(strings.TrimLeft(field, "#"), "|", "")
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Task is a checkbox item in an entry, written "- [ ] ..." when open or
+// "- [x] ..." when done
+type Task struct {
+	EntryID string
+	Date    string // Date of the entry
+	Line    int    // Line of the entry content it is on, from 0
+	Text    string
+	Done    bool
+}
+
+// taskLine matches a Markdown checkbox item
+var taskLine = regexp.MustCompile(`^\s*[-*+] \[([ xX])\] (.*\S)`)
+
+// Tasks returns the checkbox items in the entry's content
+func (e Entry) Tasks() []Task {
+	var tasks []Task
+	for i, line := range strings.Split(e.Content, "\n") {
+		m := taskLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		tasks = append(tasks, Task{
+			EntryID: e.ID,
+			Date:    e.Date,
+			Line:    i,
+			Text:    strings.TrimSpace(m[2]),
+			Done:    m[1] != " ",
+		})
+	}
+	return tasks
+}
+
+// Loop is an open loop in an entry: a line starting ">>" for a thread to
+// come back to, written "<<" once it is closed
+type Loop struct {
+	EntryID string
+	Date    string // Date of the entry
+	Line    int    // Line of the entry content it is on, from 0
+	Text    string
+	Closed  bool
+}
+
+// loopLine matches an open or closed loop
+var loopLine = regexp.MustCompile(`^\s*(>>|<<)\s+(.*\S)`)
+
+// Loops returns the open and closed loops in the entry's content
+func (e Entry) Loops() []Loop {
+	var loops []Loop
+	for i, line := range strings.Split(e.Content, "\n") {
+		m := loopLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		loops = append(loops, Loop{
+			EntryID: e.ID,
+			Date:    e.Date,
+			Line:    i,
+			Text:    m[2],
+			Closed:  m[1] == "<<",
+		})
+	}
+	return loops
+}
+
+// HighlightsHeading heads the section of an entry that quick highlights
+// are added to
+const HighlightsHeading = "## Highlights"
+
+// AddHighlight returns content with text added as an item at the end of
+// its Highlights section, which is started at the end of the content when
+// there isn't one. The text is put on one line.
+func AddHighlight(content, text string) string {
+	item := "- " + strings.Join(strings.Fields(text), " ")
+	lines := strings.Split(content, "\n")
+
+	section := -1
+	for i, line := range lines {
+		if strings.EqualFold(strings.TrimSpace(line), HighlightsHeading) {
+			section = i
+			break
+		}
+	}
+	if section < 0 {
+		content = strings.TrimRight(content, "\n")
+		if content != "" {
+			content += "\n\n"
+		}
+		return content + HighlightsHeading + "\n\n" + item + "\n"
+	}
+
+	// The section ends at the next heading; the item goes after its last
+	// line that isn't blank
+	end := len(lines)
+	for i := section + 1; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "#") {
+			end = i
+			break
+		}
+	}
+	last := section
+	for i := section + 1; i < end; i++ {
+		if strings.TrimSpace(lines[i]) != "" {
+			last = i
+		}
+	}
+	if last == section {
+		item = "\n" + item
+	}
+	lines = slices.Insert(lines, last+1, item)
+	return strings.Join(lines, "\n")
+}
+
+// GoalsTag marks an entry as a month's goals: its checkbox items are the
+// goals for the month it is dated in
+const GoalsTag = "goals"
+
+// IsGoals reports whether the entry holds a month's goals
+func (e Entry) IsGoals() bool {
+	return slices.Contains(e.Tags, GoalsTag)
+}
+
+// GoalsTemplate returns the text a goals entry for the month of date
+// (YYYY-MM-DD) starts with
+func GoalsTemplate(date string) string {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "# Goals\n\n- [ ] "
+	}
+	return "# Goals for " + d.Format("January 2006") + "\n\n- [ ] "
+}
+
+// goalRef matches a reference to a goal, written "[[goal]]"
+var goalRef = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+// GoalRefs returns the goals the entry's content refers to, in the order
+// written
+func (e Entry) GoalRefs() []string {
+	var refs []string
+	for _, m := range goalRef.FindAllStringSubmatch(e.Content, -1) {
+		if ref := strings.TrimSpace(m[1]); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// RefersTo reports whether a reference written in an entry names the goal,
+// ignoring case
+func RefersTo(ref, goal string) bool {
+	return strings.EqualFold(strings.TrimSpace(ref), strings.TrimSpace(goal))
+}
+
+// PrivateMarker starts a line of an entry that is left out of redacted
+// exports. A paragraph whose first line starts with it is left out whole.
+const PrivateMarker = "!!"
+
+// Redact returns content without its private lines and paragraphs, those
+// marked with PrivateMarker, and without the blank lines they leave
+// doubled
+func Redact(content string) string {
+	var kept []string
+	private := false // Inside a paragraph marked private
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			private = false
+			if len(kept) > 0 && kept[len(kept)-1] != "" {
+				kept = append(kept, "")
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, PrivateMarker) {
+			// A marked first line hides the paragraph it opens
+			if len(kept) == 0 || kept[len(kept)-1] == "" {
+				private = true
+			}
+			continue
+		}
+		if !private {
+			kept = append(kept, line)
+		}
+	}
+	return strings.TrimRight(strings.Join(kept, "\n"), "\n")
+}
+
 // AttachmentCount returns the number of attachments
 func (e Entry) AttachmentCount() int {
 	return len(e.Attachments)
@@ -80,3 +636,83 @@ func (e Entry) AttachmentFilenames() []string {
 	}
 	return names
 }
+
+// AttachmentEvent is a file added to or removed from an entry. Versions
+// only record which files were attached when they were saved, so most
+// changes are known to have happened between two versions.
+type AttachmentEvent struct {
+	Filename string
+	Added    bool      // Added, or else removed
+	At       time.Time // When the file was added, for files still attached
+	After    time.Time // The latest version without the change, zero if it came before the first
+	Before   time.Time // The earliest version with the change, zero if only the current attachments have it
+}
+
+// AttachmentTimeline returns when files were added to and removed from
+// the entry, oldest first, from the attachments listed by each history
+// version and the entry's current attachments
+func (e Entry) AttachmentTimeline() []AttachmentEvent {
+	history := slices.Clone(e.History)
+	slices.SortStableFunc(history, func(a, b SaveRecord) int { return a.SavedAt.Compare(b.SavedAt) })
+
+	type state struct {
+		savedAt time.Time // Zero for the current attachments
+		files   []string
+	}
+	states := make([]state, 0, len(history)+1)
+	for _, record := range history {
+		states = append(states, state{record.SavedAt, record.Attachments})
+	}
+	states = append(states, state{time.Time{}, e.AttachmentFilenames()})
+
+	var events []AttachmentEvent
+	prev := map[string]int{}
+	var prevSavedAt time.Time
+	for _, st := range states {
+		counts := map[string]int{}
+		for _, name := range st.files {
+			counts[name]++
+		}
+		var names []string
+		for name := range counts {
+			names = append(names, name)
+		}
+		for name := range prev {
+			if counts[name] == 0 {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			change := counts[name] - prev[name]
+			for ; change > 0; change-- {
+				events = append(events, AttachmentEvent{Filename: name, Added: true, After: prevSavedAt, Before: st.savedAt})
+			}
+			for ; change < 0; change++ {
+				events = append(events, AttachmentEvent{Filename: name, After: prevSavedAt, Before: st.savedAt})
+			}
+		}
+		prev, prevSavedAt = counts, st.savedAt
+	}
+
+	// Files still attached were stored when they were added, which dates
+	// the addition whose versions they fall between. Attaching a file
+	// saves the version before it at the same moment, so that bound is
+	// inclusive.
+	used := make([]bool, len(e.Attachments))
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := &events[i]
+		if !ev.Added {
+			continue
+		}
+		for j, att := range e.Attachments {
+			if !used[j] && att.Filename == ev.Filename && !att.CreatedAt.Before(ev.After) &&
+				(ev.Before.IsZero() || !att.CreatedAt.After(ev.Before)) {
+				ev.At = att.CreatedAt
+				used[j] = true
+				break
+			}
+		}
+	}
+	return events
+}
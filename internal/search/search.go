@@ -0,0 +1,236 @@
+package search
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Match is a byte range of text matched by a query
+type Match struct {
+	Start int
+	End   int
+}
+
+// Options control how a query is matched
+type Options struct {
+	Regex         bool // Treat the whole query as a regular expression
+	WholeWord     bool // Only match complete words
+	CaseSensitive bool
+}
+
+// Query is a parsed search query. Text matches when every term occurs in it.
+type Query struct {
+	Raw      string
+	Options  Options
+	patterns []*regexp.Regexp
+}
+
+// Parse builds a case-insensitive query from whitespace-separated terms
+func Parse(raw string) Query {
+	q, _ := ParseWith(raw, Options{})
+	return q
+}
+
+// ParseWith builds a query using the given options. Plain queries are split
+// into whitespace-separated terms; regex queries are a single pattern and
+// return an error when the expression is invalid.
+func ParseWith(raw string, opts Options) (Query, error) {
+	q := Query{Raw: raw, Options: opts}
+
+	var terms []string
+	if opts.Regex {
+		if strings.TrimSpace(raw) != "" {
+			terms = []string{raw}
+		}
+	} else {
+		for _, term := range strings.Fields(raw) {
+			terms = append(terms, regexp.QuoteMeta(term))
+		}
+	}
+
+	for _, term := range terms {
+		expr := term
+		if opts.WholeWord {
+			expr = `\b(?:` + expr + `)\b`
+		}
+		if !opts.CaseSensitive {
+			expr = "(?i)" + expr
+		}
+		p, err := regexp.Compile(expr)
+		if err != nil {
+			return Query{Raw: raw, Options: opts}, err
+		}
+		q.patterns = append(q.patterns, p)
+	}
+	return q, nil
+}
+
+// Empty reports whether the query has no terms
+func (q Query) Empty() bool {
+	return len(q.patterns) == 0
+}
+
+// Matches reports whether every term occurs in text
+func (q Query) Matches(text string) bool {
+	if q.Empty() {
+		return false
+	}
+	for _, p := range q.patterns {
+		if !p.MatchString(text) {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns all term occurrences in text, sorted and with overlaps merged
+func (q Query) Find(text string) []Match {
+	var matches []Match
+	for _, p := range q.patterns {
+		for _, loc := range p.FindAllStringIndex(text, -1) {
+			if loc[1] > loc[0] {
+				matches = append(matches, Match{Start: loc[0], End: loc[1]})
+			}
+		}
+	}
+	return merge(matches)
+}
+
+func merge(matches []Match) []Match {
+	if len(matches) < 2 {
+		return matches
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Start < matches[j].Start
+	})
+	merged := []Match{matches[0]}
+	for _, m := range matches[1:] {
+		last := &merged[len(merged)-1]
+		if m.Start <= last.End {
+			if m.End > last.End {
+				last.End = m.End
+			}
+			continue
+		}
+		merged = append(merged, m)
+	}
+	return merged
+}
+
+// SnippetLine is one line of a search snippet with matches relative to Text
+type SnippetLine struct {
+	Number  int // 1-based line number in the original text
+	Text    string
+	Matches []Match
+	Gap     bool // Lines were skipped before this one
+}
+
+// Snippet returns the lines containing matches plus up to context lines
+// around each, limited to maxLines lines in total
+func Snippet(text string, matches []Match, context, maxLines int) []SnippetLine {
+	lines := strings.Split(text, "\n")
+
+	// Byte offset of each line start
+	starts := make([]int, len(lines))
+	offset := 0
+	for i, line := range lines {
+		starts[i] = offset
+		offset += len(line) + 1
+	}
+
+	lineMatches := make(map[int][]Match)
+	include := make(map[int]bool)
+	for _, m := range matches {
+		idx := sort.Search(len(starts), func(i int) bool { return starts[i] > m.Start }) - 1
+		if idx < 0 {
+			continue
+		}
+		end := m.End - starts[idx]
+		if end > len(lines[idx]) {
+			end = len(lines[idx])
+		}
+		lineMatches[idx] = append(lineMatches[idx], Match{Start: m.Start - starts[idx], End: end})
+		for i := idx - context; i <= idx+context; i++ {
+			if i >= 0 && i < len(lines) {
+				include[i] = true
+			}
+		}
+	}
+
+	var snippet []SnippetLine
+	prev := -1
+	for i, line := range lines {
+		if !include[i] {
+			continue
+		}
+		if strings.TrimSpace(line) == "" && lineMatches[i] == nil {
+			continue
+		}
+		if maxLines > 0 && len(snippet) >= maxLines {
+			break
+		}
+		snippet = append(snippet, SnippetLine{
+			Number:  i + 1,
+			Text:    line,
+			Matches: lineMatches[i],
+			Gap:     prev >= 0 && i > prev+1,
+		})
+		prev = i
+	}
+	return snippet
+}
+
+// Clip shortens the line to at most width bytes, keeping the first match in
+// view and marking removed text with ellipses
+func (l SnippetLine) Clip(width int) SnippetLine {
+	if width <= 0 || len(l.Text) <= width {
+		return l
+	}
+
+	start := 0
+	if len(l.Matches) > 0 && l.Matches[0].End > width {
+		start = l.Matches[0].Start - width/3
+	}
+	if start > len(l.Text)-width {
+		start = len(l.Text) - width
+	}
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && !utf8.RuneStart(l.Text[start]) {
+		start--
+	}
+	end := start + width
+	if end > len(l.Text) {
+		end = len(l.Text)
+	}
+	for end < len(l.Text) && !utf8.RuneStart(l.Text[end]) {
+		end--
+	}
+
+	clipped := SnippetLine{Number: l.Number, Gap: l.Gap, Text: l.Text[start:end]}
+	prefix := 0
+	if start > 0 {
+		clipped.Text = "…" + clipped.Text
+		prefix = len("…")
+	}
+	if end < len(l.Text) {
+		clipped.Text += "…"
+	}
+	for _, m := range l.Matches {
+		if m.End <= start || m.Start >= end {
+			continue
+		}
+		s, e := m.Start, m.End
+		if s < start {
+			s = start
+		}
+		if e > end {
+			e = end
+		}
+		clipped.Matches = append(clipped.Matches, Match{Start: s - start + prefix, End: e - start + prefix})
+	}
+	return clipped
+}
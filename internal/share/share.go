@@ -0,0 +1,135 @@
+// Package share serves a page to another device on the local network, such
+// as a phone, through a link that works once and expires after a while.
+package share
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Server serves one page at a secret link until it has been viewed once or
+// its time is up, then stops
+type Server struct {
+	URL     string    // Link to the page, on the computer's local network address
+	Expires time.Time // When the page stops being served if it hasn't been viewed
+
+	page     []byte
+	token    string
+	server   *http.Server
+	done     chan struct{}
+	stopOnce sync.Once
+
+	mu       sync.Mutex
+	viewedAt time.Time
+}
+
+// Start serves page, an HTML document, for up to ttl. The link holds a
+// random token, so it can't be guessed by others on the network.
+func Start(page []byte, ttl time.Duration) (*Server, error) {
+	token := make([]byte, 18)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	// Only the local network address, so the page can be opened from
+	// another device there but not from wider networks
+	host := localAddress()
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	s := &Server{
+		Expires: time.Now().Add(ttl),
+		page:    page,
+		token:   base64.RawURLEncoding.EncodeToString(token),
+		done:    make(chan struct{}),
+	}
+	s.URL = "http://" + net.JoinHostPort(host, port) + "/" + s.token
+	s.server = &http.Server{
+		Handler:           http.HandlerFunc(s.serve),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Stopping again once the page has been viewed does nothing
+	time.AfterFunc(ttl, s.Stop)
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// serve sends the page to the first request for the link, and nothing to
+// anything else
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || subtle.ConstantTimeCompare([]byte(r.URL.Path), []byte("/"+s.token)) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	viewed := !s.viewedAt.IsZero()
+	if !viewed {
+		s.viewedAt = time.Now()
+	}
+	s.mu.Unlock()
+	if viewed {
+		http.Error(w, "This link has already been used.", http.StatusGone)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	h.Set("Referrer-Policy", "no-referrer")
+	h.Set("X-Robots-Tag", "noindex")
+	// The page is self-contained: inline styles and embedded images only
+	h.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; img-src data:")
+	w.Write(s.page)
+
+	// Stopping waits for this response to finish
+	go s.Stop()
+}
+
+// Stop stops serving the page. It is safe to call more than once.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.server.Shutdown(ctx)
+		close(s.done)
+	})
+}
+
+// Done is closed once the page is no longer served
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// ViewedAt returns when the page was viewed, or the zero time if it hasn't
+// been
+func (s *Server) ViewedAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.viewedAt
+}
+
+// localAddress returns the computer's address on the local network, or
+// the loopback address when it isn't on one
+func localAddress() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "127.0.0.1"
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil && ipNet.IP.IsPrivate() {
+			return ipNet.IP.String()
+		}
+	}
+	return "127.0.0.1"
+}
@@ -0,0 +1,130 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"journal/internal/model"
+)
+
+// digestTitleLength is how much of each entry's title a digest shows
+const digestTitleLength = 60
+
+// digestTopTags is how many tags a digest lists
+const digestTopTags = 5
+
+// DigestEntry is one entry in a digest
+type DigestEntry struct {
+	Date  string
+	Title string
+	Words int
+}
+
+// TagCount is how many entries in a digest have a tag
+type TagCount struct {
+	Tag     string
+	Entries int
+}
+
+// Digest summarises the entries between two dates, for pasting into
+// updates and reviews
+type Digest struct {
+	From, To string // Inclusive, YYYY-MM-DD
+	Entries  []DigestEntry
+	Words    int
+	TopTags  []TagCount
+}
+
+// WeekOf returns the Monday and Sunday of the week containing date
+func WeekOf(date time.Time) (string, string) {
+	monday := date.AddDate(0, 0, -((int(date.Weekday()) + 6) % 7))
+	return monday.Format("2006-01-02"), monday.AddDate(0, 0, 6).Format("2006-01-02")
+}
+
+// BuildDigest summarises the entries of journal dated from to to,
+// inclusive, oldest first
+func BuildDigest(journal *model.Journal, from, to string) Digest {
+	digest := Digest{From: from, To: to}
+	tags := make(map[string]int)
+	for _, e := range journal.Entries {
+		if e.Date < from || e.Date > to {
+			continue
+		}
+		words := len(Words(e.Content))
+		digest.Entries = append(digest.Entries, DigestEntry{
+			Date:  e.Date,
+			Title: e.Title(digestTitleLength),
+			Words: words,
+		})
+		digest.Words += words
+		for _, tag := range e.Tags {
+			tags[tag]++
+		}
+	}
+	sort.Slice(digest.Entries, func(i, j int) bool {
+		return digest.Entries[i].Date < digest.Entries[j].Date
+	})
+
+	for tag, n := range tags {
+		digest.TopTags = append(digest.TopTags, TagCount{Tag: tag, Entries: n})
+	}
+	sort.Slice(digest.TopTags, func(i, j int) bool {
+		if digest.TopTags[i].Entries != digest.TopTags[j].Entries {
+			return digest.TopTags[i].Entries > digest.TopTags[j].Entries
+		}
+		return digest.TopTags[i].Tag < digest.TopTags[j].Tag
+	})
+	if len(digest.TopTags) > digestTopTags {
+		digest.TopTags = digest.TopTags[:digestTopTags]
+	}
+	return digest
+}
+
+// WriteMarkdown writes the digest as Markdown: a heading, totals, a line
+// per entry, and the most used tags
+func (d Digest) WriteMarkdown(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("# Journal digest: %s to %s\n\n", d.From, d.To)
+	if len(d.Entries) == 0 {
+		printf("No entries.\n")
+		return err
+	}
+	printf("%s, %s.\n\n", plural(len(d.Entries), "entry", "entries"), plural(d.Words, "word", "words"))
+
+	printf("## Entries\n\n")
+	for _, e := range d.Entries {
+		title := e.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		day := e.Date
+		if date, perr := time.Parse("2006-01-02", e.Date); perr == nil {
+			day = date.Format("Mon 2006-01-02")
+		}
+		printf("- **%s** %s (%s)\n", day, title, plural(e.Words, "word", "words"))
+	}
+
+	if len(d.TopTags) > 0 {
+		printf("\n## Top tags\n\n")
+		for _, t := range d.TopTags {
+			printf("- %s (%s)\n", t.Tag, plural(t.Entries, "entry", "entries"))
+		}
+	}
+	return err
+}
+
+// plural renders a count with the singular or plural noun
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, one)
+	}
+	return fmt.Sprintf("%d %s", n, many)
+}
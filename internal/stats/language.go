@@ -0,0 +1,114 @@
+package stats
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Language is a language an entry can be detected as written in
+type Language struct {
+	Code string // ISO 639-1 code, e.g. "de"
+	Name string // English name, e.g. "German"
+}
+
+// Languages written with the Latin or Cyrillic alphabet are told apart by
+// their most common words; Chinese, Japanese, and Korean by their scripts
+var (
+	chinese  = Language{"zh", "Chinese"}
+	japanese = Language{"ja", "Japanese"}
+	korean   = Language{"ko", "Korean"}
+)
+
+// languageWords are frequent short words of each language told apart by
+// its words. Words common to most of them, such as "a" or "in", are left
+// out.
+var languageWords = []struct {
+	Language
+	words string
+}{
+	{Language{"en", "English"}, `the and of to is that it was for with he she you they this have had
+		but not are were be been my me we our at from what when would there their just about`},
+	{Language{"de", "German"}, `der die das und ist nicht ich du sie wir ein eine einen mit auf
+		für sich auch dem den von zu war haben hat aber noch wie nach wenn mir mich heute`},
+	{Language{"fr", "French"}, `le la les et est une des du que qui pas pour dans sur avec je tu
+		nous vous ils elle mais au aux ce cette été suis avait mon ma mes très aujourd'hui`},
+	{Language{"es", "Spanish"}, `el los las y es una que del por para con pero muy está estoy fue
+		yo mi mis nosotros hoy había como más su sus cuando también ya`},
+	{Language{"it", "Italian"}, `il lo gli e è una che di del della per con non sono ho ha io mi
+		mio mia noi oggi anche ma molto questo questa era stato quando più`},
+	{Language{"pt", "Portuguese"}, `o os as e é um uma que do da dos das para com não eu meu minha
+		nós hoje muito mas foi estou está também quando mais isso`},
+	{Language{"nl", "Dutch"}, `de het een en is niet ik je jij wij we van op voor met maar ook
+		was zijn heb heeft mijn vandaag dat dit er naar nog wel`},
+	{Language{"sv", "Swedish"}, `och är att det inte jag du vi en ett på för med men också var
+		har hade min mitt idag som den till av om så`},
+	{Language{"ru", "Russian"}, `и в не на я что он она мы вы с как это был была но по к из у
+		меня мне сегодня очень так все уже`},
+}
+
+// languageOf maps each word of languageWords to its language. A word in
+// two lists, such as "de", counts for both.
+var languageOf = map[string][]int{}
+
+func init() {
+	for i, lang := range languageWords {
+		for _, w := range strings.Fields(lang.words) {
+			languageOf[w] = append(languageOf[w], i)
+		}
+	}
+}
+
+// minLanguageWords is how many of a language's common words a text needs
+// before it is taken to be written in it
+const minLanguageWords = 3
+
+// DetectLanguage returns the language text is mostly written in, and false
+// when it is too short or mixed to tell
+func DetectLanguage(text string) (Language, bool) {
+	var letters, han, kana, hangul int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.IsLetter(r):
+			letters++
+		}
+	}
+	// A single character of these scripts stands for a word or a syllable,
+	// against several letters of an alphabet
+	if cjk := han + kana + hangul; cjk > 0 && cjk*3 >= letters {
+		switch {
+		case hangul > han+kana:
+			return korean, true
+		case kana > 0:
+			return japanese, true
+		}
+		return chinese, true
+	}
+
+	hits := make([]int, len(languageWords))
+	for _, w := range Words(text) {
+		for _, i := range languageOf[strings.ReplaceAll(w, "’", "'")] {
+			hits[i]++
+		}
+	}
+	best, second := -1, 0
+	for i, n := range hits {
+		if best < 0 || n > hits[best] {
+			if best >= 0 {
+				second = hits[best]
+			}
+			best = i
+		} else if n > second {
+			second = n
+		}
+	}
+	if hits[best] < minLanguageWords || hits[best] == second {
+		return Language{}, false
+	}
+	return languageWords[best].Language, true
+}
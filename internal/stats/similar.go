@@ -0,0 +1,59 @@
+package stats
+
+// Near-duplicate detection catches an entry imported or pasted twice under
+// different dates. Entries are compared by their runs of three words, so
+// small edits, reflowed lines, or changed punctuation still match.
+
+const (
+	// DuplicateSimilarity is the Similarity from which two entries count
+	// as copies of each other
+	DuplicateSimilarity = 0.8
+	// DuplicateMinWords is the length below which entries aren't compared:
+	// short entries such as "Rest day." repeat without being copies
+	DuplicateMinWords = 10
+)
+
+// shingles returns the set of three-word runs in text
+func shingles(words []string) map[[3]string]bool {
+	set := make(map[[3]string]bool, len(words))
+	for i := 0; i+3 <= len(words); i++ {
+		set[[3]string{words[i], words[i+1], words[i+2]}] = true
+	}
+	return set
+}
+
+// Similarity returns how much wording two texts share, from 0 for none to
+// 1 for the same words in the same order. It is the Jaccard index of their
+// three-word runs, ignoring case and punctuation.
+func Similarity(a, b string) float64 {
+	sa, sb := shingles(Words(a)), shingles(Words(b))
+	if len(sa) == 0 || len(sb) == 0 {
+		return 0
+	}
+	shared := 0
+	for s := range sa {
+		if sb[s] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(sa)+len(sb)-shared)
+}
+
+// DuplicateWordRange returns the word counts an entry of words words can
+// be a near duplicate of. Texts of more different lengths can't reach
+// DuplicateSimilarity, so they needn't be compared.
+func DuplicateWordRange(words int) (lo, hi int) {
+	return int(float64(words) * DuplicateSimilarity), int(float64(words)/DuplicateSimilarity) + 1
+}
+
+// NearDuplicate reports whether two texts are nearly identical
+func NearDuplicate(a, b string) bool {
+	wa, wb := len(Words(a)), len(Words(b))
+	if wa < DuplicateMinWords || wb < DuplicateMinWords {
+		return false
+	}
+	if lo, hi := DuplicateWordRange(wa); wb < lo || wb > hi {
+		return false
+	}
+	return Similarity(a, b) >= DuplicateSimilarity
+}
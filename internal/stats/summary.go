@@ -0,0 +1,168 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+
+	"journal/internal/model"
+)
+
+// MonthCount is how much was written in a month
+type MonthCount struct {
+	Month   string // YYYY-MM
+	Entries int
+	Words   int
+	Goals   []GoalProgress // From the month's goals entries
+}
+
+// GoalProgress is a month's goal and the entries that referred to it
+type GoalProgress struct {
+	Text string
+	Done bool
+	Days int // Days whose entries refer to the goal
+}
+
+// GoalsDone returns how many of the month's goals are ticked off
+func (c MonthCount) GoalsDone() int {
+	done := 0
+	for _, g := range c.Goals {
+		if g.Done {
+			done++
+		}
+	}
+	return done
+}
+
+// GoalsPercent returns the share of the month's goals ticked off, rounded
+// down, or 0 when the month has none
+func (c MonthCount) GoalsPercent() int {
+	if len(c.Goals) == 0 {
+		return 0
+	}
+	return c.GoalsDone() * 100 / len(c.Goals)
+}
+
+// Summary totals a journal's writing
+type Summary struct {
+	Entries int
+	Words   int
+	// CurrentStreak is the days in a row with an entry up to today, or up
+	// to yesterday while today's entry isn't written yet
+	CurrentStreak int
+	LongestStreak int
+	LongestEnd    string       // Last day of the longest streak, YYYY-MM-DD
+	Typing        model.Typing // How the entries were typed in the editor, all together
+	Months        []MonthCount // Every month from the first entry's to the current one, oldest first
+}
+
+// AverageWords returns the words of an entry on average, rounded
+func (s Summary) AverageWords() int {
+	if s.Entries == 0 {
+		return 0
+	}
+	return (s.Words + s.Entries/2) / s.Entries
+}
+
+// Summarize totals the entries of journal as of today. Goals entries
+// count toward the totals but not the streaks, as they are dated for their
+// month rather than the day they were written.
+func Summarize(journal *model.Journal, today time.Time) Summary {
+	var s Summary
+	days := make(map[string]bool)
+	months := make(map[string]MonthCount)
+	first := ""
+	for _, e := range journal.Entries {
+		date, err := time.Parse("2006-01-02", e.Date)
+		if err != nil {
+			continue
+		}
+		words := len(Words(e.Content))
+		s.Entries++
+		s.Words += words
+		s.Typing = s.Typing.Add(e.Typing)
+
+		month := date.Format("2006-01")
+		count := months[month]
+		count.Month = month
+		count.Entries++
+		count.Words += words
+		if e.IsGoals() {
+			for _, task := range e.Tasks() {
+				count.Goals = append(count.Goals, GoalProgress{Text: task.Text, Done: task.Done})
+			}
+		} else {
+			days[e.Date] = true
+		}
+		months[month] = count
+		if first == "" || month < first {
+			first = month
+		}
+	}
+	if s.Entries == 0 {
+		return s
+	}
+
+	// Goals are referred to from the entries of their own month, counted
+	// once a day however often they are mentioned
+	seen := make(map[string]bool)
+	for _, e := range journal.Entries {
+		if e.IsGoals() || len(e.Date) < 7 {
+			continue
+		}
+		goals := months[e.Date[:7]].Goals
+		for _, ref := range e.GoalRefs() {
+			for i := range goals {
+				key := fmt.Sprintf("%s %d", e.Date, i)
+				if model.RefersTo(ref, goals[i].Text) && !seen[key] {
+					seen[key] = true
+					goals[i].Days++
+				}
+			}
+		}
+	}
+
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	day := today
+	if !days[day.Format("2006-01-02")] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for days[day.Format("2006-01-02")] {
+		s.CurrentStreak++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	// A streak starts on each day with an entry whose day before has none
+	for date := range days {
+		start, _ := time.Parse("2006-01-02", date)
+		if days[start.AddDate(0, 0, -1).Format("2006-01-02")] {
+			continue
+		}
+		length := 0
+		end := start
+		for days[end.Format("2006-01-02")] {
+			length++
+			end = end.AddDate(0, 0, 1)
+		}
+		last := end.AddDate(0, 0, -1).Format("2006-01-02")
+		if length > s.LongestStreak || (length == s.LongestStreak && last > s.LongestEnd) {
+			s.LongestStreak = length
+			s.LongestEnd = last
+		}
+	}
+
+	// Months without entries are listed too, so gaps show
+	month, _ := time.Parse("2006-01", first)
+	last := today.Format("2006-01")
+	for key := range months {
+		if key > last {
+			last = key
+		}
+	}
+	for ; month.Format("2006-01") <= last; month = month.AddDate(0, 1, 0) {
+		key := month.Format("2006-01")
+		count := months[key]
+		count.Month = key
+		s.Months = append(s.Months, count)
+	}
+	return s
+}
@@ -0,0 +1,70 @@
+package stats
+
+import (
+	"time"
+
+	"journal/internal/model"
+)
+
+// TypingSession follows the keys typed into an entry in one editor
+// session. Every count is kept up to date as keys are typed, so those
+// since the entry was last saved can be added to it at any time.
+type TypingSession struct {
+	counts      model.Typing // Since the entry was last saved
+	typed       bool         // Something was typed this session
+	last        time.Time    // Of the last keystroke, zero before the first and after leaving the terminal
+	stretchFrom time.Time    // When the stretch of typing without a pause began
+}
+
+// Key records a keystroke at now that changed the content, removing text
+// when deleted is set
+func (s *TypingSession) Key(now time.Time, deleted bool) {
+	switch gap := now.Sub(s.last); {
+	case s.last.IsZero():
+		s.stretchFrom = now
+	case gap >= model.TypingPause:
+		s.counts.Pauses++
+		s.stretchFrom = now
+	default:
+		s.counts.Active += gap
+		if stretch := now.Sub(s.stretchFrom); stretch >= model.FlowStretch {
+			// A stretch counts as flow from its start once it is long enough
+			if stretch-gap < model.FlowStretch {
+				s.counts.Flow += stretch
+			} else {
+				s.counts.Flow += gap
+			}
+			s.counts.LongestFlow = max(s.counts.LongestFlow, stretch)
+		}
+	}
+	if !s.typed {
+		s.typed = true
+		s.counts.Sessions++
+	}
+	s.counts.Keystrokes++
+	if deleted {
+		s.counts.Deletions++
+	}
+	s.last = now
+}
+
+// Away records that the terminal lost focus, which counts as a
+// distraction when it happens while typing. The next keystroke starts a
+// new stretch of typing, without counting the time away as a pause.
+func (s *TypingSession) Away() {
+	if !s.last.IsZero() {
+		s.counts.Distractions++
+		s.last = time.Time{}
+	}
+}
+
+// Counts returns the counts since the entry was last saved
+func (s TypingSession) Counts() model.Typing {
+	return s.counts
+}
+
+// Saved records that the counts so far were saved with the entry, so they
+// start again from zero. The stretch being typed carries on.
+func (s *TypingSession) Saved() {
+	s.counts = model.Typing{}
+}
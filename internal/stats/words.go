@@ -0,0 +1,193 @@
+package stats
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"journal/internal/model"
+)
+
+// Period controls how word usage is grouped over time
+type Period int
+
+const (
+	PeriodMonth Period = iota
+	PeriodYear
+)
+
+// WordCount is the usage of a single word across the journal
+type WordCount struct {
+	Word     string
+	Total    int
+	ByPeriod []int // Counts aligned with WordReport.Periods
+}
+
+// WordReport lists the most frequent meaningful words and their usage over time
+type WordReport struct {
+	Periods []string // Sorted oldest first, e.g. "2024-01" or "2024"
+	Words   []WordCount
+}
+
+// stopwords are common English words, and the most common Chinese
+// characters that carry little meaning alone, excluded from the report
+var stopwords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`
+		a about above after again against all also am an and any are aren't as at
+		be because been before being below between both but by can can't cannot
+		could couldn't did didn't do does doesn't doing don't down during each even
+		few for from further get got had hadn't has hasn't have haven't having he
+		he'd he'll he's her here here's hers herself him himself his how how's i
+		i'd i'll i'm i've if in into is isn't it it's its itself just let's like
+		me more most much must mustn't my myself no nor not now of off on once
+		only or other ought our ours ourselves out over own really same shan't she
+		she'd she'll she's should shouldn't so some still such than that that's
+		the their theirs them themselves then there there's these they they'd
+		they'll they're they've this those through to too today under until up
+		very was wasn't we we'd we'll we're we've were weren't what what's when
+		when's where where's which while who who's whom why why's will with won't
+		would wouldn't yet you you'd you'll you're you've your yours yourself
+		yourselves went going go day one two back think know make made lot thing
+		things way well got gonna want wanted need
+		的 了 是 我 你 他 她 它 们 在 不 有 这 那 也 就 都 和 一 个 很 到 说 要
+		会 着 没 上 去 吗 吧 呢 啊 人 日 今 天 年 月
+	`) {
+		stopwords[w] = true
+	}
+}
+
+// Words splits text into lowercase words, keeping inner apostrophes. Han
+// and kana characters are words of their own, as Chinese and Japanese are
+// written without spaces between words and counted by character. Of these,
+// the word frequency report only counts Han characters, as kana alone are
+// mostly particles.
+func Words(text string) []string {
+	text = strings.ToLower(text)
+	var words []string
+	start := -1 // Of the word being read, -1 between words
+	for i, r := range text {
+		character := unicode.Is(unicode.Han, r) || unicode.In(r, unicode.Hiragana, unicode.Katakana)
+		if start >= 0 && (character || !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’') {
+			words = append(words, text[start:i])
+			start = -1
+		}
+		switch {
+		case character:
+			words = append(words, string(r))
+		case start < 0 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '’'):
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+// meaningful reports whether a word should be counted in the report.
+// Words shorter than three letters are left out, except Han characters.
+func meaningful(word string) bool {
+	if stopwords[word] {
+		return false
+	}
+	if runes := []rune(word); len(runes) < 3 && (len(runes) != 1 || !unicode.Is(unicode.Han, runes[0])) {
+		return false
+	}
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// WordFrequency builds a report of the top most frequent meaningful words,
+// with per-period counts for tracking how usage changes over time
+func WordFrequency(journal *model.Journal, top int, period Period) WordReport {
+	totals := make(map[string]int)
+	perPeriod := make(map[string]map[string]int)
+
+	for _, e := range journal.Entries {
+		key := periodKey(e.Date, period)
+		if key == "" {
+			continue
+		}
+		if perPeriod[key] == nil {
+			perPeriod[key] = make(map[string]int)
+		}
+		for _, w := range Words(e.Content) {
+			w = strings.Trim(strings.ReplaceAll(w, "’", "'"), "'")
+			if !meaningful(w) {
+				continue
+			}
+			totals[w]++
+			perPeriod[key][w]++
+		}
+	}
+
+	report := WordReport{}
+	for key := range perPeriod {
+		report.Periods = append(report.Periods, key)
+	}
+	sort.Strings(report.Periods)
+
+	words := make([]string, 0, len(totals))
+	for w := range totals {
+		words = append(words, w)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if totals[words[i]] != totals[words[j]] {
+			return totals[words[i]] > totals[words[j]]
+		}
+		return words[i] < words[j]
+	})
+	if top > 0 && len(words) > top {
+		words = words[:top]
+	}
+
+	for _, w := range words {
+		wc := WordCount{Word: w, Total: totals[w], ByPeriod: make([]int, len(report.Periods))}
+		for i, key := range report.Periods {
+			wc.ByPeriod[i] = perPeriod[key][w]
+		}
+		report.Words = append(report.Words, wc)
+	}
+
+	return report
+}
+
+func periodKey(date string, period Period) string {
+	switch {
+	case period == PeriodYear && len(date) >= 4:
+		return date[:4]
+	case period == PeriodMonth && len(date) >= 7:
+		return date[:7]
+	}
+	return ""
+}
+
+// WriteCSV writes the report as CSV: one row per word with its total and a
+// column per period
+func (r WordReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := append([]string{"word", "total"}, r.Periods...)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, wc := range r.Words {
+		row := []string{wc.Word, strconv.Itoa(wc.Total)}
+		for _, n := range wc.ByPeriod {
+			row = append(row, strconv.Itoa(n))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
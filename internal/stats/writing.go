@@ -0,0 +1,21 @@
+package stats
+
+import (
+	"time"
+
+	"journal/internal/model"
+)
+
+// WritingTime totals the time spent writing across the journal and counts
+// the entries it was spent on
+func WritingTime(journal *model.Journal) (time.Duration, int) {
+	var total time.Duration
+	entries := 0
+	for _, e := range journal.Entries {
+		if e.WritingTime > 0 {
+			total += e.WritingTime
+			entries++
+		}
+	}
+	return total, entries
+}
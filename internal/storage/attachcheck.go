@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// attachmentCheckTimeout is how long the attachment check may take on a
+// file before the file is refused. Virus scanners can take a while to
+// load their signatures.
+const attachmentCheckTimeout = 2 * time.Minute
+
+// CheckAttachment runs command, such as "clamscan --no-summary", with the
+// path of a file about to be attached added as its last argument. The file
+// is refused when the command fails, with the command's last line of
+// output as the reason. An empty command accepts every file.
+func CheckAttachment(command, path string) (err error) {
+	if strings.TrimSpace(command) == "" {
+		return nil
+	}
+	defer trackOp("CheckAttachment", path)(&err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), attachmentCheckTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command+` "$1"`, "sh", path)
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command+` "`+path+`"`)
+	}
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("the attachment check took longer than %s", attachmentCheckTimeout)
+	}
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("running the attachment check: %w", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if reason := strings.TrimSpace(lines[len(lines)-1]); reason != "" {
+		return fmt.Errorf("refused by the attachment check: %s", reason)
+	}
+	return fmt.Errorf("refused by the attachment check (exit status %d)", exitErr.ExitCode())
+}
@@ -0,0 +1,526 @@
+package storage
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"journal/internal/model"
+)
+
+// Encrypted journals keep attachments in a separate SQLite file next to the
+// journal, with each attachment's metadata and data encrypted individually.
+// Opening a journal only decrypts the small metadata records; attachment
+// data is decrypted when it is viewed or exported.
+
+// AttachmentStoreSuffix is appended to an encrypted journal's path to name
+// its attachment store
+const AttachmentStoreSuffix = ".attachments"
+
+type attachmentStore struct {
+	db  *sql.DB
+	gcm cipher.AEAD
+}
+
+// attachmentMeta is the encrypted part of an attachment record
+type attachmentMeta struct {
+	Filename string `json:"filename"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+}
+
+// attachmentStorePath returns the attachment store path for an expanded
+// journal path
+func attachmentStorePath(expandedPath string) string {
+	return expandedPath + AttachmentStoreSuffix
+}
+
+// openAttachmentStore opens the attachment store of an encrypted journal.
+// When the store doesn't exist it returns nil, unless create is set.
+func openAttachmentStore(path, password string, create bool) (*attachmentStore, error) {
+	expandedPath, err := ExpandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	storePath := attachmentStorePath(expandedPath)
+	if _, err := os.Stat(storePath); os.IsNotExist(err) {
+		if !create {
+			return nil, nil
+		}
+		// Check the password against the journal before keying a new store
+		if info, err := os.Stat(expandedPath); err == nil && info.Size() > 0 {
+			if err := checkPassword(expandedPath, password); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := createPrivate(storePath); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", storePath)
+	if err != nil {
+		return nil, err
+	}
+	store, err := initAttachmentStore(db, password)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func initAttachmentStore(db *sql.DB, password string) (*attachmentStore, error) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS store_meta (
+			key TEXT PRIMARY KEY,
+			value BLOB NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS attachments (
+			id TEXT PRIMARY KEY,
+			entry_id TEXT NOT NULL,
+			meta BLOB NOT NULL,
+			data BLOB NOT NULL,
+			created_at DATETIME NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_store_attachments_entry ON attachments(entry_id);
+		CREATE TABLE IF NOT EXISTS trash (
+			id TEXT PRIMARY KEY,
+			entry_id TEXT NOT NULL,
+			meta BLOB NOT NULL,
+			data BLOB NOT NULL,
+			created_at DATETIME NOT NULL,
+			deleted_at DATETIME NOT NULL
+		);
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// The store has its own KDF header so its key doesn't change when the
+	// journal file is re-encrypted
+	var headerBytes []byte
+	err = db.QueryRow(`SELECT value FROM store_meta WHERE key = 'kdf'`).Scan(&headerBytes)
+	var header kdfHeader
+	switch {
+	case err == sql.ErrNoRows:
+		kdfMu.Lock()
+		header = kdfHeader{params: kdfParams, salt: make([]byte, kdfSaltSize)}
+		kdfMu.Unlock()
+		if _, err := io.ReadFull(rand.Reader, header.salt); err != nil {
+			return nil, err
+		}
+		if _, err := db.Exec(`INSERT INTO store_meta (key, value) VALUES ('kdf', ?)`, header.encode()); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		header, err = parseKDFHeader(headerBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	gcm, err := newGCM(headerKey(password, header))
+	if err != nil {
+		return nil, err
+	}
+	return &attachmentStore{db: db, gcm: gcm}, nil
+}
+
+func (s *attachmentStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *attachmentStore) seal(plaintext []byte, additionalData string) ([]byte, error) {
+	nonce := make([]byte, s.gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return s.gcm.Seal(nonce, nonce, plaintext, []byte(additionalData)), nil
+}
+
+func (s *attachmentStore) open(ciphertext []byte, additionalData string) ([]byte, error) {
+	nonceSize := s.gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, ErrInvalidPassword
+	}
+	plaintext, err := s.gcm.Open(nil, ciphertext[:nonceSize], ciphertext[nonceSize:], []byte(additionalData))
+	if err != nil {
+		return nil, ErrInvalidPassword
+	}
+	return plaintext, nil
+}
+
+// put stores an attachment, encrypting its metadata and data. The record ID
+// is bound into both so records can't be swapped.
+func (s *attachmentStore) put(att *model.Attachment) error {
+	metaJSON, err := json.Marshal(attachmentMeta{
+		Filename: att.Filename,
+		MimeType: att.MimeType,
+		Size:     att.Size,
+	})
+	if err != nil {
+		return err
+	}
+	meta, err := s.seal(metaJSON, "meta:"+att.ID)
+	if err != nil {
+		return err
+	}
+	data, err := s.seal(att.Data, "data:"+att.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT OR REPLACE INTO attachments (id, entry_id, meta, data, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, att.ID, att.EntryID, meta, data, att.CreatedAt)
+	return err
+}
+
+func (s *attachmentStore) decodeMeta(att *model.Attachment, sealed []byte) error {
+	metaJSON, err := s.open(sealed, "meta:"+att.ID)
+	if err != nil {
+		return err
+	}
+	var meta attachmentMeta
+	if err := json.Unmarshal(metaJSON, &meta); err != nil {
+		return err
+	}
+	att.Filename = meta.Filename
+	att.MimeType = meta.MimeType
+	att.Size = meta.Size
+	return nil
+}
+
+// get returns an attachment with its data, or sql.ErrNoRows
+func (s *attachmentStore) get(attachmentID string) (*model.Attachment, error) {
+	var att model.Attachment
+	var meta, data []byte
+	err := s.db.QueryRow(`SELECT id, entry_id, meta, data, created_at FROM attachments WHERE id = ?`, attachmentID).
+		Scan(&att.ID, &att.EntryID, &meta, &data, &att.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decodeMeta(&att, meta); err != nil {
+		return nil, err
+	}
+	att.Data, err = s.open(data, "data:"+att.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &att, nil
+}
+
+// list returns attachment metadata (not data) grouped by entry ID
+func (s *attachmentStore) list() (map[string][]model.Attachment, error) {
+	rows, err := s.db.Query(`SELECT id, entry_id, meta, created_at FROM attachments ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byEntry := make(map[string][]model.Attachment)
+	for rows.Next() {
+		var att model.Attachment
+		var meta []byte
+		if err := rows.Scan(&att.ID, &att.EntryID, &meta, &att.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := s.decodeMeta(&att, meta); err != nil {
+			return nil, err
+		}
+		byEntry[att.EntryID] = append(byEntry[att.EntryID], att)
+	}
+	return byEntry, rows.Err()
+}
+
+// delete moves an attachment to the trash, as trashAttachmentDB does,
+// reporting whether it was in the store
+func (s *attachmentStore) delete(attachmentID string) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	if _, err := tx.Exec(`DELETE FROM trash WHERE id = ? OR deleted_at < ?`, attachmentID, now.Add(-AttachmentTrashAge)); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO trash (id, entry_id, meta, data, created_at, deleted_at)
+		SELECT id, entry_id, meta, data, created_at, ? FROM attachments WHERE id = ?
+	`, now, attachmentID); err != nil {
+		return false, err
+	}
+	res, err := tx.Exec(`DELETE FROM attachments WHERE id = ?`, attachmentID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, tx.Commit()
+}
+
+// trashed returns the metadata of an entry's deleted attachments, most
+// recently deleted first
+func (s *attachmentStore) trashed(entryID string) ([]model.Attachment, error) {
+	rows, err := s.db.Query(`SELECT id, entry_id, meta, created_at FROM trash WHERE entry_id = ? ORDER BY deleted_at DESC`, entryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []model.Attachment
+	for rows.Next() {
+		var att model.Attachment
+		var meta []byte
+		if err := rows.Scan(&att.ID, &att.EntryID, &meta, &att.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := s.decodeMeta(&att, meta); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, att)
+	}
+	return attachments, rows.Err()
+}
+
+// restore moves an attachment from the trash back to its entry
+func (s *attachmentStore) restore(attachmentID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO attachments (id, entry_id, meta, data, created_at)
+		SELECT id, entry_id, meta, data, created_at FROM trash WHERE id = ?
+	`, attachmentID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errNotInTrash
+	}
+	if _, err := tx.Exec(`DELETE FROM trash WHERE id = ?`, attachmentID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// counts returns the number of stored attachments per entry ID
+func (s *attachmentStore) counts() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT entry_id, COUNT(*) FROM attachments GROUP BY entry_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, err
+		}
+		counts[id] = n
+	}
+	return counts, rows.Err()
+}
+
+// deleteEntry removes all attachments of an entry, including deleted ones
+func (s *attachmentStore) deleteEntry(entryID string) error {
+	if _, err := s.db.Exec(`DELETE FROM attachments WHERE entry_id = ?`, entryID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM trash WHERE entry_id = ?`, entryID)
+	return err
+}
+
+// prune removes attachments of entries that no longer exist, including
+// deleted ones
+func (s *attachmentStore) prune(entryIDs map[string]bool) error {
+	rows, err := s.db.Query(`SELECT entry_id FROM attachments UNION SELECT entry_id FROM trash`)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		if !entryIDs[id] {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range stale {
+		if err := s.deleteEntry(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeStoredAttachments adds the metadata of stored attachments to the
+// journal's entries
+func mergeStoredAttachments(journal *model.Journal, path, password string) error {
+	store, err := openAttachmentStore(path, password, false)
+	if err != nil || store == nil {
+		return err
+	}
+	defer store.Close()
+
+	byEntry, err := store.list()
+	if err != nil {
+		return err
+	}
+	for i := range journal.Entries {
+		e := &journal.Entries[i]
+		// An interrupted move can leave an attachment in both places
+		existing := make(map[string]bool, len(e.Attachments))
+		for _, att := range e.Attachments {
+			existing[att.ID] = true
+		}
+		for _, att := range byEntry[e.ID] {
+			if !existing[att.ID] {
+				e.Attachments = append(e.Attachments, att)
+			}
+		}
+		sort.SliceStable(e.Attachments, func(a, b int) bool {
+			return e.Attachments[a].CreatedAt.Before(e.Attachments[b].CreatedAt)
+		})
+	}
+	return nil
+}
+
+// moveAttachmentsToStore moves attachments held inside a decrypted journal
+// database into the attachment store. It reports whether any were moved.
+func moveAttachmentsToStore(db *sql.DB, path, password string) (bool, error) {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM attachments`).Scan(&count); err != nil || count == 0 {
+		return false, nil // No attachments table, or nothing to move
+	}
+
+	store, err := openAttachmentStore(path, password, true)
+	if err != nil {
+		return false, err
+	}
+	defer store.Close()
+
+	rows, err := db.Query(`SELECT id, entry_id, filename, mime_type, size, data, created_at FROM attachments`)
+	if err != nil {
+		return false, err
+	}
+	for rows.Next() {
+		var att model.Attachment
+		if err := rows.Scan(&att.ID, &att.EntryID, &att.Filename, &att.MimeType, &att.Size, &att.Data, &att.CreatedAt); err != nil {
+			rows.Close()
+			return false, err
+		}
+		if err := store.put(&att); err != nil {
+			rows.Close()
+			return false, err
+		}
+	}
+	rows.Close()
+
+	if _, err := db.Exec(`DELETE FROM attachments`); err != nil {
+		return false, err
+	}
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// moveAttachmentsFromStore copies every stored attachment into a decrypted
+// journal database, used when a journal is decrypted permanently
+func moveAttachmentsFromStore(db *sql.DB, path, password string) error {
+	store, err := openAttachmentStore(path, password, false)
+	if err != nil || store == nil {
+		return err
+	}
+	defer store.Close()
+
+	rows, err := store.db.Query(`SELECT id FROM attachments`)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.Prepare(`
+		INSERT OR REPLACE INTO attachments (id, entry_id, filename, mime_type, size, data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for _, id := range ids {
+		att, err := store.get(id)
+		if err != nil {
+			return err
+		}
+		_, err = insert.Exec(att.ID, att.EntryID, att.Filename, att.MimeType, att.Size, att.Data, att.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// copyAttachmentStore copies the attachment store of one journal path to
+// another, if it exists
+func copyAttachmentStore(srcJournal, destJournal string) error {
+	src := attachmentStorePath(srcJournal)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	return copyFile(src, attachmentStorePath(destJournal))
+}
+
+// removeAttachmentStore deletes a journal's attachment store if present
+func removeAttachmentStore(expandedPath string) error {
+	err := os.Remove(attachmentStorePath(expandedPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
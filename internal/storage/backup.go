@@ -0,0 +1,363 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"journal/internal/model"
+)
+
+const (
+	DefaultBackupDir = "backups"
+
+	BackupOff    = ""
+	BackupDaily  = "daily"
+	BackupWeekly = "weekly"
+
+	// DefaultBackupKeep is how many scheduled backups are kept per journal
+	DefaultBackupKeep = 10
+
+	backupTimeFormat = "20060102-150405"
+)
+
+// BackupSchedules lists the available backup schedules in display order
+var BackupSchedules = []string{BackupOff, BackupDaily, BackupWeekly}
+
+// GetBackupDir returns the directory holding journal backups
+func GetBackupDir() (string, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configPath), DefaultBackupDir), nil
+}
+
+// BackupDue reports whether a backup should be taken under the given
+// schedule when the last one was taken at last
+func BackupDue(schedule string, last, now time.Time) bool {
+	switch schedule {
+	case BackupDaily:
+		return last.IsZero() || now.Sub(last) >= 24*time.Hour
+	case BackupWeekly:
+		return last.IsZero() || now.Sub(last) >= 7*24*time.Hour
+	}
+	return false
+}
+
+// backupPrefix returns the filename prefix used for a journal's backups:
+// its name and a hash of its absolute path, which keeps apart journals of
+// the same name in different folders
+func backupPrefix(journalPath string) string {
+	path, err := ExpandPath(journalPath)
+	if err == nil {
+		path, err = filepath.Abs(path)
+	}
+	if err != nil {
+		path = journalPath
+	}
+	sum := sha256.Sum256([]byte(path))
+	return legacyBackupPrefix(journalPath) + hex.EncodeToString(sum[:4]) + "-"
+}
+
+// legacyBackupPrefix is the prefix of backups taken before backupPrefix
+// included the path hash. They are still listed, but never pruned, since
+// they may belong to another journal of the same name.
+func legacyBackupPrefix(journalPath string) string {
+	base := filepath.Base(journalPath)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + "-"
+}
+
+// parseBackupName returns the time a backup named name with the given
+// prefix was taken, and its number among those taken in the same second,
+// which is 1 for the first. A second backup in the same second is named
+// with "-2" after the time, and so on.
+func parseBackupName(name, prefix string) (time.Time, int, bool) {
+	stamp, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	stamp = strings.TrimSuffix(stamp, filepath.Ext(stamp))
+	n := 1
+	if len(stamp) > len(backupTimeFormat) {
+		var err error
+		counter, ok := strings.CutPrefix(stamp[len(backupTimeFormat):], "-")
+		if n, err = strconv.Atoi(counter); !ok || err != nil || n < 2 {
+			return time.Time{}, 0, false
+		}
+		stamp = stamp[:len(backupTimeFormat)]
+	}
+	created, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return created, n, true
+}
+
+// BackupJournal copies the journal file as-is (still encrypted if the journal
+// is) into the backups directory, returning the backup path
+func BackupJournal(journalPath string, now time.Time) (_ string, err error) {
+	defer trackOp("BackupJournal", journalPath)(&err)
+
+	expandedPath, err := ExpandPath(journalPath)
+	if err != nil {
+		return "", err
+	}
+
+	backupDir, err := GetBackupDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(backupDir, dirPerm()); err != nil {
+		return "", err
+	}
+
+	src, err := os.Open(expandedPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	name := backupPrefix(journalPath) + now.Format(backupTimeFormat)
+	dest := filepath.Join(backupDir, name+filepath.Ext(expandedPath))
+	dst, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, filePerm())
+	for n := 2; os.IsExist(err); n++ {
+		dest = filepath.Join(backupDir, fmt.Sprintf("%s-%d%s", name, n, filepath.Ext(expandedPath)))
+		dst, err = os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, filePerm())
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dest)
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dest)
+		return "", err
+	}
+
+	// Encrypted journals keep attachments in a separate store
+	if err := copyAttachmentStore(expandedPath, dest); err != nil {
+		os.Remove(dest)
+		return "", err
+	}
+
+	return dest, nil
+}
+
+// pruneBackups removes the oldest backups of a journal beyond keep
+func pruneBackups(journalPath string, keep int) error {
+	backups, err := findBackups(backupPrefix(journalPath))
+	if err != nil {
+		return err
+	}
+
+	// Oldest last
+	for len(backups) > keep {
+		oldest := backups[len(backups)-1].Path
+		if err := os.Remove(oldest); err != nil {
+			return err
+		}
+		if err := removeAttachmentStore(oldest); err != nil {
+			return err
+		}
+		backups = backups[:len(backups)-1]
+	}
+	return nil
+}
+
+// RunScheduledBackups backs up every configured journal whose backup is due,
+// updating LastBackup in the config. It returns the number of backups taken;
+// journals whose file doesn't exist yet are skipped.
+func RunScheduledBackups(config *model.Config, now time.Time) (_ int, err error) {
+	defer trackOp("RunScheduledBackups", "")(&err)
+
+	keep := config.BackupKeep
+	if keep <= 0 {
+		keep = DefaultBackupKeep
+	}
+
+	count := 0
+	var errs []string
+	for i := range config.Journals {
+		j := &config.Journals[i]
+		// Markdown journals are plain files, left to the user's own backups,
+		// and PostgreSQL journals are backed up on their server
+		if j.Format != model.FormatSQLite || !BackupDue(config.BackupSchedule, j.LastBackup, now) {
+			continue
+		}
+
+		expandedPath, err := ExpandPath(j.Path)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if _, err := os.Stat(expandedPath); os.IsNotExist(err) {
+			continue
+		}
+
+		if _, err := BackupJournal(j.Path, now); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", j.Name, err))
+			continue
+		}
+		j.LastBackup = now
+		count++
+
+		if err := pruneBackups(j.Path, keep); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", j.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return count, fmt.Errorf("backup failed: %s", strings.Join(errs, "; "))
+	}
+	return count, nil
+}
+
+// Backup describes a backup file of a journal
+type Backup struct {
+	Path      string
+	CreatedAt time.Time
+	Size      int64
+}
+
+// ListBackups returns the backups of a journal, newest first, including
+// those named before backups were told apart by the journal's folder
+func ListBackups(journalPath string) (_ []Backup, err error) {
+	defer trackOp("ListBackups", journalPath)(&err)
+
+	backups, err := findBackups(backupPrefix(journalPath))
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := findBackups(legacyBackupPrefix(journalPath))
+	if err != nil {
+		return nil, err
+	}
+	backups = append(backups, legacy...)
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// findBackups returns the backups whose names start with prefix, newest
+// first
+func findBackups(prefix string) ([]Backup, error) {
+	backupDir, err := GetBackupDir()
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(filepath.Join(backupDir, prefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	numbers := map[string]int{}
+	for _, m := range matches {
+		created, n, ok := parseBackupName(filepath.Base(m), prefix)
+		if !ok {
+			continue
+		}
+		info, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		numbers[m] = n
+		backups = append(backups, Backup{Path: m, CreatedAt: created, Size: info.Size()})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return numbers[backups[i].Path] > numbers[backups[j].Path]
+	})
+	return backups, nil
+}
+
+// copyFile copies src to dest, replacing dest if it exists
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), dirPerm()); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".restore-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, filePerm()); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, dest)
+}
+
+// RestoreBackup replaces the journal file with a backup. The current file is
+// backed up first so the restore itself can be undone.
+func RestoreBackup(backupPath, journalPath string) (err error) {
+	defer trackOp("RestoreBackup", journalPath, "backup", backupPath)(&err)
+
+	expandedPath, err := ExpandPath(journalPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(expandedPath); err == nil {
+		if _, err := BackupJournal(journalPath, time.Now()); err != nil {
+			return fmt.Errorf("backing up current journal: %w", err)
+		}
+	}
+	if err := copyFile(backupPath, expandedPath); err != nil {
+		return err
+	}
+
+	// The attachment store must match the restored file
+	if err := removeAttachmentStore(expandedPath); err != nil {
+		return err
+	}
+	return copyAttachmentStore(backupPath, expandedPath)
+}
+
+// RestoreBackupAsNew copies a backup to a new journal file at newPath,
+// refusing to overwrite an existing file
+func RestoreBackupAsNew(backupPath, newPath string) (err error) {
+	defer trackOp("RestoreBackupAsNew", newPath, "backup", backupPath)(&err)
+
+	expandedPath, err := ExpandPath(newPath)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(expandedPath); err == nil {
+		return fmt.Errorf("%s already exists", newPath)
+	}
+	if err := copyFile(backupPath, expandedPath); err != nil {
+		return err
+	}
+	return copyAttachmentStore(backupPath, expandedPath)
+}
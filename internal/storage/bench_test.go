@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"journal/internal/model"
+	"journal/internal/search"
+)
+
+// Run with: go test ./internal/storage -run '^$' -bench . -benchmem
+// Results are comparable between builds on the same machine, so run them
+// before and after a storage change.
+
+// benchSizes are the numbers of entries in the synthetic journals
+var benchSizes = []int{1000, 10000}
+
+// benchPassword encrypts the synthetic journals of encrypted benchmarks
+const benchPassword = "benchmark"
+
+// benchWords is the vocabulary of synthetic entries
+var benchWords = strings.Fields(`
+	morning coffee walk meeting project deadline friend dinner book chapter
+	garden rain sunshine train office lunch call family weekend plan idea
+	music evening tired happy quiet busy long short write read think remember
+	week month year today yesterday tomorrow work home city park river road`)
+
+// benchKDF keeps key derivation cheap. It is cached per session, so its
+// cost would only show up once, but it would make setup slow.
+var benchKDF sync.Once
+
+// benchJournals runs bench on a synthetic journal of each size, saved in
+// a temporary directory, both unencrypted and encrypted
+func benchJournals(b *testing.B, bench func(b *testing.B, path, password string, journal *model.Journal)) {
+	benchKDF.Do(func() {
+		if err := SetKDFParams(&model.KDFParams{Algorithm: KDFArgon2id, MemoryKiB: 8 * 1024, Iterations: 1, Threads: 1}); err != nil {
+			b.Fatal(err)
+		}
+	})
+	for _, n := range benchSizes {
+		for _, password := range []string{"", benchPassword} {
+			name := fmt.Sprintf("%d", n)
+			if password != "" {
+				name += "-encrypted"
+			}
+			b.Run(name, func(b *testing.B) {
+				journal := syntheticJournal(n)
+				path := filepath.Join(b.TempDir(), "bench.db")
+				if err := saveBenchJournal(journal, path, password); err != nil {
+					b.Fatal(err)
+				}
+				b.ReportAllocs()
+				bench(b, path, password, journal)
+			})
+		}
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	benchJournals(b, func(b *testing.B, path, password string, _ *model.Journal) {
+		for b.Loop() {
+			if _, err := loadBenchJournal(path, password); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkSave(b *testing.B) {
+	benchJournals(b, func(b *testing.B, path, password string, journal *model.Journal) {
+		for b.Loop() {
+			if err := saveBenchJournal(journal, path, password); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkSaveEntry(b *testing.B) {
+	benchJournals(b, func(b *testing.B, path, password string, journal *model.Journal) {
+		entry := journal.Entries[len(journal.Entries)/2]
+		for b.Loop() {
+			entry.UpdatedAt = time.Now()
+			if err := SaveEntry(path, password, &entry); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkListPage(b *testing.B) {
+	benchJournals(b, func(b *testing.B, path, password string, journal *model.Journal) {
+		offset := len(journal.Entries) / 2
+		for b.Loop() {
+			if _, err := ListEntries(path, password, offset, 100, model.EntryFilter{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkSearch(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			journal := syntheticJournal(n)
+			query := search.Parse("coffee river")
+			b.ReportAllocs()
+			for b.Loop() {
+				for _, e := range journal.Entries {
+					query.Matches(e.Content)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFilterText(b *testing.B) {
+	benchJournals(b, func(b *testing.B, path, password string, _ *model.Journal) {
+		filter := model.EntryFilter{Text: "coffee river"}
+		for b.Loop() {
+			if _, err := CountEntries(path, password, filter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// syntheticJournal builds n entries on consecutive days with a few history
+// records each. The same n always produces the same journal.
+func syntheticJournal(n int) *model.Journal {
+	rng := rand.New(rand.NewPCG(uint64(n), 1))
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	journal := &model.Journal{Entries: make([]model.Entry, n)}
+	for i := range journal.Entries {
+		date := start.AddDate(0, 0, i)
+		entry := model.Entry{
+			ID:        fmt.Sprintf("bench-%08d", i),
+			Date:      date.Format("2006-01-02"),
+			Content:   syntheticText(rng, 50+rng.IntN(300)),
+			CreatedAt: date,
+			UpdatedAt: date.Add(time.Hour),
+		}
+		if rng.IntN(4) == 0 {
+			entry.Tags = []string{benchWords[rng.IntN(len(benchWords))]}
+		}
+		for h := range rng.IntN(3) {
+			entry.History = append(entry.History, model.SaveRecord{
+				Content: syntheticText(rng, 30+rng.IntN(200)),
+				SavedAt: date.Add(time.Duration(h) * time.Minute),
+			})
+		}
+		journal.Entries[i] = entry
+	}
+	return journal
+}
+
+func syntheticText(rng *rand.Rand, words int) string {
+	var b strings.Builder
+	for i := range words {
+		if i > 0 {
+			if rng.IntN(12) == 0 {
+				b.WriteString(".\n")
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(benchWords[rng.IntN(len(benchWords))])
+	}
+	return b.String()
+}
+
+func loadBenchJournal(path, password string) (*model.Journal, error) {
+	if password != "" {
+		return LoadJournalEncrypted(path, password)
+	}
+	return LoadJournal(path)
+}
+
+func saveBenchJournal(journal *model.Journal, path, password string) error {
+	if password != "" {
+		return SaveJournalEncrypted(journal, path, password)
+	}
+	return SaveJournal(journal, path)
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"journal/internal/model"
+)
+
+// Several instances of the app, each with its own journal open, share the
+// config file. SaveConfig holds a lock file next to it while it reads the
+// copy on disk, merges in what the other instances recorded, and replaces
+// it through a temporary file, so neither a race nor a crash leaves it
+// half written.
+
+const (
+	// configLockWait is how long SaveConfig waits for another instance's
+	// lock before giving up
+	configLockWait = 2 * time.Second
+	// configLockStale is the age after which a lock is taken to have been
+	// left by an instance that crashed while holding it
+	configLockStale = 10 * time.Second
+)
+
+// lockConfig creates the lock file of the config at configPath, waiting
+// for another instance to release it, and returns the function releasing
+// it
+func lockConfig(configPath string) (func(), error) {
+	lockPath := configPath + ".lock"
+	deadline := time.Now().Add(configLockWait)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > configLockStale {
+			logger.Debug("removing stale config lock", "path", lockPath, "age", time.Since(info.ModTime()))
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("the config file is locked by another instance of the app; remove %s if none is running", lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// mergeSavedConfig brings the times other instances recorded in the config
+// file at configPath into saved, about to replace it, and into config, the
+// copy in memory it was made from. Each journal keeps the later of the two
+// last-opened and last-backup times, and the active journal is the one
+// opened last. Both configs hold the journals in the same order.
+func mergeSavedConfig(configPath string, saved, config *model.Config) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return
+	}
+	var disk model.Config
+	if err := json.Unmarshal(data, &disk); err != nil {
+		// A corrupt file is replaced rather than merged
+		return
+	}
+
+	onDisk := make(map[string]model.JournalDB, len(disk.Journals))
+	for _, j := range disk.Journals {
+		onDisk[j.Path] = j
+	}
+	var activeOpened time.Time
+	for i := range saved.Journals {
+		j := &saved.Journals[i]
+		other, ok := onDisk[j.Path]
+		if !ok {
+			continue
+		}
+		if other.LastOpened.After(j.LastOpened) {
+			j.LastOpened = other.LastOpened
+			config.Journals[i].LastOpened = other.LastOpened
+		}
+		if other.LastBackup.After(j.LastBackup) {
+			j.LastBackup = other.LastBackup
+			config.Journals[i].LastBackup = other.LastBackup
+		}
+		if j.Path == saved.ActiveJournal {
+			activeOpened = j.LastOpened
+		}
+	}
+
+	if disk.ActiveJournal == saved.ActiveJournal {
+		return
+	}
+	if other, ok := onDisk[disk.ActiveJournal]; ok && other.LastOpened.After(activeOpened) {
+		for i, j := range saved.Journals {
+			if j.Path == disk.ActiveJournal {
+				saved.ActiveJournal = j.Path
+				config.ActiveJournal = config.Journals[i].Path
+			}
+		}
+	}
+}
+
+// writeFileAtomic replaces the file at path with data through a temporary
+// file in the same folder
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// EncryptJournal rewrites a plaintext journal file encrypted with password.
+// The file is replaced atomically, so an interrupted conversion leaves the
+// original in place.
+func EncryptJournal(path, password string) (err error) {
+	defer trackOp("EncryptJournal", path)(&err)
+
+	if password == "" {
+		return errors.New("password is required")
+	}
+	expandedPath, err := ExpandPath(path)
+	if err != nil {
+		return err
+	}
+
+	ok, err := isSQLiteFile(expandedPath)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("journal is not a plaintext database")
+	}
+
+	// Use a fresh salt and the configured KDF parameters
+	resetKDFSession(expandedPath, password)
+	if err := encryptFile(expandedPath, expandedPath, password); err != nil {
+		return err
+	}
+	// The previous copy is the plaintext file
+	return removePrevious(expandedPath)
+}
+
+// DecryptJournal permanently rewrites an encrypted journal file as a
+// plaintext SQLite database
+func DecryptJournal(path, password string) (err error) {
+	defer trackOp("DecryptJournal", path)(&err)
+
+	expandedPath, err := ExpandPath(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(expandedPath)
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return CreateEmptyJournal(path)
+	}
+
+	db, err := decryptToMemory(expandedPath, password)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Fold the attachment store back into the database
+	if err := initSchema(db); err != nil {
+		return err
+	}
+	if err := moveAttachmentsFromStore(db, path, password); err != nil {
+		return err
+	}
+	if err := writeFromMemory(db, expandedPath); err != nil {
+		return err
+	}
+	if err := removePrevious(expandedPath); err != nil {
+		return err
+	}
+	return removeAttachmentStore(expandedPath)
+}
+
+// isSQLiteFile reports whether the file is empty or starts with the SQLite
+// file header
+func isSQLiteFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 16)
+	n, err := io.ReadFull(f, header)
+	if n == 0 && err == io.EOF {
+		return true, nil
+	}
+	return n == 16 && string(header) == "SQLite format 3\x00", nil
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"journal/internal/clock"
+	"journal/internal/model"
+	"journal/internal/stats"
+)
+
+// CSVMapping describes which CSV columns hold entry fields.
+// Columns are given by header name, or by 1-based index when NoHeader is set
+// or the value is a number.
+type CSVMapping struct {
+	DateColumn    string
+	ContentColumn string
+	TagsColumn    string // Optional
+	DateFormat    string // Go time layout, defaults to 2006-01-02
+	TagSeparator  string // Separator inside the tags column, defaults to ","
+	Delimiter     rune   // Field delimiter, defaults to ','
+	NoHeader      bool
+
+	// Clock and IDs stamp and name imported entries; nil uses the system
+	// clock and random UUIDs
+	Clock clock.Clock
+	IDs   clock.IDGenerator
+}
+
+// SkippedRow describes a CSV row that was not imported
+type SkippedRow struct {
+	Line   int
+	Reason string
+}
+
+// DuplicateRow describes an imported CSV row whose content is nearly
+// identical to an entry on another date, which usually means the file, or
+// part of it, was imported before under different dates
+type DuplicateRow struct {
+	Line int
+	Date string
+	Of   string // Date of the entry it duplicates
+}
+
+// CSVImportResult holds the outcome of a CSV import
+type CSVImportResult struct {
+	Imported   []model.Entry
+	Skipped    []SkippedRow
+	Duplicates []DuplicateRow // Imported all the same
+}
+
+// importedText is an entry's content with its word count, which rules most
+// entries out as duplicates before comparing their wording
+type importedText struct {
+	date, content string
+	words         int
+}
+
+// nearDuplicateOf returns the date of the entry in texts content nearly
+// duplicates, if any
+func nearDuplicateOf(content string, texts []importedText) (string, bool) {
+	words := len(stats.Words(content))
+	if words < stats.DuplicateMinWords {
+		return "", false
+	}
+	lo, hi := stats.DuplicateWordRange(words)
+	for _, t := range texts {
+		if t.words >= lo && t.words <= hi && stats.NearDuplicate(content, t.content) {
+			return t.date, true
+		}
+	}
+	return "", false
+}
+
+// ImportCSV parses entries from CSV data using the given column mapping.
+// Rows with invalid dates, empty content, or dates that already exist in
+// the journal (or earlier in the file) are skipped and reported. Rows whose
+// content nearly duplicates another entry are imported and reported.
+func ImportCSV(r io.Reader, mapping CSVMapping, journal *model.Journal) (*CSVImportResult, error) {
+	if mapping.DateColumn == "" || mapping.ContentColumn == "" {
+		return nil, errors.New("date and content columns are required")
+	}
+	if mapping.DateFormat == "" {
+		mapping.DateFormat = "2006-01-02"
+	}
+	if mapping.TagSeparator == "" {
+		mapping.TagSeparator = ","
+	}
+	if mapping.Clock == nil {
+		mapping.Clock = clock.System
+	}
+	if mapping.IDs == nil {
+		mapping.IDs = clock.UUID
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	if mapping.Delimiter != 0 {
+		reader.Comma = mapping.Delimiter
+	}
+
+	var header []string
+	if !mapping.NoHeader {
+		var err error
+		header, err = reader.Read()
+		if err == io.EOF {
+			return &CSVImportResult{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dateIdx, err := resolveCSVColumn(mapping.DateColumn, header)
+	if err != nil {
+		return nil, err
+	}
+	contentIdx, err := resolveCSVColumn(mapping.ContentColumn, header)
+	if err != nil {
+		return nil, err
+	}
+	tagsIdx := -1
+	if mapping.TagsColumn != "" {
+		tagsIdx, err = resolveCSVColumn(mapping.TagsColumn, header)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	existing := make(map[string]bool)
+	var texts []importedText
+	if journal != nil {
+		for _, e := range journal.Entries {
+			existing[e.Date] = true
+			texts = append(texts, importedText{e.Date, e.Content, len(stats.Words(e.Content))})
+		}
+	}
+
+	result := &CSVImportResult{}
+	now := mapping.Clock.Now()
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				result.Skipped = append(result.Skipped, SkippedRow{Line: parseErr.Line, Reason: parseErr.Err.Error()})
+				continue
+			}
+			return nil, err
+		}
+		// Only valid after a record has been read
+		line, _ := reader.FieldPos(0)
+
+		rawDate := csvField(record, dateIdx)
+		if rawDate == "" {
+			result.Skipped = append(result.Skipped, SkippedRow{Line: line, Reason: "missing date"})
+			continue
+		}
+		parsed, err := time.Parse(mapping.DateFormat, rawDate)
+		if err != nil {
+			result.Skipped = append(result.Skipped, SkippedRow{Line: line, Reason: fmt.Sprintf("invalid date %q", rawDate)})
+			continue
+		}
+		date := parsed.Format("2006-01-02")
+
+		content := csvField(record, contentIdx)
+		if content == "" {
+			result.Skipped = append(result.Skipped, SkippedRow{Line: line, Reason: "empty content"})
+			continue
+		}
+
+		if existing[date] {
+			result.Skipped = append(result.Skipped, SkippedRow{Line: line, Reason: "an entry for " + date + " already exists"})
+			continue
+		}
+
+		var tags []string
+		if tagsIdx >= 0 {
+			// Each field is read as tags typed in the editor are
+			for _, field := range strings.Split(csvField(record, tagsIdx), mapping.TagSeparator) {
+				for _, tag := range model.ParseTags(field) {
+					if !slices.Contains(tags, tag) {
+						tags = append(tags, tag)
+					}
+				}
+			}
+		}
+
+		if of, ok := nearDuplicateOf(content, texts); ok {
+			result.Duplicates = append(result.Duplicates, DuplicateRow{Line: line, Date: date, Of: of})
+		}
+		texts = append(texts, importedText{date, content, len(stats.Words(content))})
+
+		existing[date] = true
+		result.Imported = append(result.Imported, model.Entry{
+			ID:        mapping.IDs.NewID(),
+			Date:      date,
+			Content:   content,
+			Tags:      tags,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+
+	return result, nil
+}
+
+// resolveCSVColumn finds the index of a column by header name or 1-based index
+func resolveCSVColumn(spec string, header []string) (int, error) {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), spec) {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(spec); err == nil && n > 0 {
+		return n - 1, nil
+	}
+	return -1, fmt.Errorf("column %q not found", spec)
+}
+
+func csvField(record []string, idx int) string {
+	if idx < 0 || idx >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[idx])
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// dialect is the SQL that differs between the database servers a journal
+// can be kept in. Queries shared between them are written for SQLite, with
+// ? placeholders, and rebound for the server they run on.
+type dialect struct {
+	// driver is the database/sql driver name
+	driver string
+	// numbered is whether placeholders are written $1, $2, ... rather than ?
+	numbered bool
+	// textMatch returns the condition that entry e contains every term, and
+	// its argument
+	textMatch func(terms []string) (string, any)
+	// containsAll returns the condition that the content of row t of table
+	// (entries or history) may contain every term, ignoring case, and its
+	// arguments. It may match more rows than do, never fewer.
+	containsAll func(table string, terms []string) (string, []any)
+	// noLimit is the LIMIT argument that returns every row
+	noLimit any
+}
+
+// sqliteDialect is SQLite, searched through the entries_fts index
+var sqliteDialect = dialect{
+	driver: "sqlite",
+	textMatch: func(terms []string) (string, any) {
+		// Each term is quoted so FTS query syntax in it is matched literally
+		quoted := make([]string, len(terms))
+		for i, term := range terms {
+			quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+		}
+		return `e.rowid IN (SELECT rowid FROM entries_fts WHERE entries_fts MATCH ?)`, strings.Join(quoted, " ")
+	},
+	containsAll: func(table string, terms []string) (string, []any) {
+		// The trigram index can't look up terms shorter than three
+		// characters, which are left for the caller to match
+		var quoted []string
+		for _, term := range terms {
+			if utf8.RuneCountInString(term) >= 3 {
+				quoted = append(quoted, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+			}
+		}
+		if len(quoted) == 0 {
+			return "1", nil
+		}
+		index := table + "_search"
+		return `t.rowid IN (SELECT rowid FROM ` + index + ` WHERE ` + index + ` MATCH ?)`, []any{strings.Join(quoted, " ")}
+	},
+	noLimit: -1,
+}
+
+// postgresDialect is PostgreSQL, searched through a text search index on
+// entry content (see postgresSchema)
+var postgresDialect = dialect{
+	driver:   "pgx",
+	numbered: true,
+	textMatch: func(terms []string) (string, any) {
+		// plainto_tsquery ignores query syntax, so terms need no quoting
+		return `to_tsvector('simple', e.content) @@ plainto_tsquery('simple', ?)`, strings.Join(terms, " ")
+	},
+	containsAll: func(table string, terms []string) (string, []any) {
+		conds := make([]string, len(terms))
+		args := make([]any, len(terms))
+		for i, term := range terms {
+			conds[i] = `strpos(lower(t.content), lower(?)) > 0`
+			args[i] = term
+		}
+		return strings.Join(conds, " AND "), args
+	},
+	noLimit: nil,
+}
+
+// rebind rewrites the ? placeholders of query for the dialect
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
+
+// limit returns the LIMIT argument for limit, where a negative limit means
+// every row
+func (d dialect) limit(limit int) any {
+	if limit < 0 {
+		return d.noLimit
+	}
+	return limit
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// An entry is written in an external editor through a file, which holds
+// its plaintext even when the journal is encrypted. The file is made in a
+// folder of its own that only the user can read, in memory where the
+// system offers a RAM-backed directory, and overwritten before it is
+// deleted.
+
+// ramTempDir is a directory held in memory on Linux
+const ramTempDir = "/dev/shm"
+
+// editDirPrefix starts the name of the folder made for each file
+const editDirPrefix = "journal-edit-"
+
+// CreateEditFile writes content to a new file named after the entry's
+// date, for editing in an external editor, and returns its path. The file
+// is removed by FinishEditFile.
+func CreateEditFile(date, content string) (_ string, err error) {
+	defer trackOp("CreateEditFile", date)(&err)
+
+	base := ""
+	if info, err := os.Stat(ramTempDir); err == nil && info.IsDir() {
+		base = ramTempDir
+	}
+	dir, err := os.MkdirTemp(base, editDirPrefix)
+	if err != nil {
+		return "", err
+	}
+	name := "entry.md"
+	if date != "" && !strings.ContainsAny(date, `/\`) {
+		name = date + ".md"
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return path, nil
+}
+
+// FinishEditFile returns the text of a file made by CreateEditFile, then
+// overwrites and deletes its folder, along with any backup or swap files
+// the editor left next to it
+func FinishEditFile(path string) (_ string, err error) {
+	defer trackOp("FinishEditFile", path)(&err)
+
+	data, err := os.ReadFile(path)
+	if dir := filepath.Dir(path); strings.HasPrefix(filepath.Base(dir), editDirPrefix) {
+		secureRemoveAll(dir)
+	} else {
+		secureRemove(path)
+	}
+	return string(data), err
+}
+
+// EditorCommand returns the command that opens path in the external
+// editor: editor when set, such as "code --wait", or else $VISUAL or
+// $EDITOR, falling back to vi (Notepad on Windows)
+func EditorCommand(editor, path string) *exec.Cmd {
+	for _, command := range []string{editor, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if strings.TrimSpace(command) != "" {
+			editor = command
+			break
+		}
+	}
+	if runtime.GOOS == "windows" {
+		if editor == "" {
+			editor = "notepad"
+		}
+		return exec.Command("cmd", "/C", editor+` "`+path+`"`)
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	return exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+}
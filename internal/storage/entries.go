@@ -0,0 +1,645 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"time"
+
+	"journal/internal/model"
+	"journal/internal/stats"
+)
+
+// Entry-level access for the entry list and editor, so the application
+// doesn't have to keep every entry's content and history in memory. Each
+// call opens the journal on its own; for encrypted journals that means
+// decrypting the file into an in-memory database, unless the journal is in
+// session mode. Password is empty for plaintext journals.
+
+// entryExcerptLen is how much content ListEntries returns for previews
+const entryExcerptLen = 200
+
+// filterClause returns a WHERE clause over the entries table aliased as e,
+// and its arguments. storeAttached lists the entries with attachments kept
+// outside the database, in an encrypted journal's attachment store.
+func filterClause(d dialect, filter model.EntryFilter, storeAttached ...string) (string, []any) {
+	var conds []string
+	var args []any
+	if filter.Tag != "" {
+		conds = append(conds, `e.id IN (SELECT entry_id FROM entry_tags WHERE tag = ?)`)
+		args = append(args, filter.Tag)
+	}
+	if terms := strings.Fields(filter.Text); len(terms) > 0 {
+		cond, arg := d.textMatch(terms)
+		conds = append(conds, cond)
+		args = append(args, arg)
+	}
+	if filter.Mood != "" {
+		conds = append(conds, `e.mood = ?`)
+		args = append(args, filter.Mood)
+	}
+	if filter.Since != "" {
+		conds = append(conds, `e.date >= ?`)
+		args = append(args, filter.Since)
+	}
+	if filter.Until != "" {
+		conds = append(conds, `e.date <= ?`)
+		args = append(args, filter.Until)
+	}
+	if filter.Attachments {
+		cond := `e.id IN (SELECT entry_id FROM attachments)`
+		if len(storeAttached) > 0 {
+			cond = `(` + cond + ` OR e.id IN (?` + strings.Repeat(`, ?`, len(storeAttached)-1) + `))`
+			for _, id := range storeAttached {
+				args = append(args, id)
+			}
+		}
+		conds = append(conds, cond)
+	}
+	if filter.WordsOver > 0 {
+		conds = append(conds, `e.word_count > ?`)
+		args = append(args, filter.WordsOver)
+	}
+	if filter.Edited {
+		conds = append(conds, `(SELECT COUNT(*) FROM history h WHERE h.entry_id = e.id) > 1`)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// storeAttachmentEntries returns the entries with attachments in an
+// encrypted journal's attachment store, when filter needs them
+func storeAttachmentEntries(path, password string, filter model.EntryFilter) ([]string, error) {
+	if password == "" || !filter.Attachments {
+		return nil, nil
+	}
+	store, err := openAttachmentStore(path, password, false)
+	if err != nil || store == nil {
+		return nil, err
+	}
+	defer store.Close()
+	counts, err := store.counts()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// CountEntries returns the number of entries in a journal file matching
+// filter
+func CountEntries(path string, password string, filter model.EntryFilter) (_ int, err error) {
+	defer trackOp("CountEntries", path)(&err)
+
+	storeAttached, err := storeAttachmentEntries(path, password, filter)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	err = viewDB(path, password, func(db *sql.DB) error {
+		if err := migrateSchema(db); err != nil {
+			return err
+		}
+
+		count, err = countEntriesDB(db, sqliteDialect, filter, storeAttached...)
+		return err
+	})
+	return count, err
+}
+
+func countEntriesDB(db *sql.DB, d dialect, filter model.EntryFilter, storeAttached ...string) (int, error) {
+	var count int
+	where, args := filterClause(d, filter, storeAttached...)
+	err := db.QueryRow(d.rebind(`SELECT COUNT(*) FROM entries e `+where), args...).Scan(&count)
+	return count, err
+}
+
+// ListEntries returns summaries of the entries matching filter newest
+// first, skipping offset entries and returning at most limit (all when
+// limit is negative)
+func ListEntries(path, password string, offset, limit int, filter model.EntryFilter) (_ []model.EntrySummary, err error) {
+	defer trackOp("ListEntries", path, "offset", offset, "limit", limit)(&err)
+
+	storeAttached, err := storeAttachmentEntries(path, password, filter)
+	if err != nil {
+		return nil, err
+	}
+	var summaries []model.EntrySummary
+	err = viewDB(path, password, func(db *sql.DB) error {
+		if err := migrateSchema(db); err != nil {
+			return err
+		}
+
+		summaries, err = listEntriesDB(db, sqliteDialect, offset, limit, filter, storeAttached...)
+		return err
+	})
+	if err != nil || password == "" {
+		return summaries, err
+	}
+
+	// Attachments of encrypted journals are counted in the attachment store
+	// without decrypting them
+	store, err := openAttachmentStore(path, password, false)
+	if err != nil || store == nil {
+		return summaries, err
+	}
+	defer store.Close()
+	counts, err := store.counts()
+	if err != nil {
+		return nil, err
+	}
+	for i := range summaries {
+		summaries[i].AttachmentCount += counts[summaries[i].ID]
+	}
+	return summaries, nil
+}
+
+func listEntriesDB(db *sql.DB, d dialect, offset, limit int, filter model.EntryFilter, storeAttached ...string) ([]model.EntrySummary, error) {
+	where, args := filterClause(d, filter, storeAttached...)
+	args = append([]any{entryExcerptLen}, args...)
+	args = append(args, d.limit(limit), offset)
+	rows, err := db.Query(d.rebind(`
+		SELECT e.id, e.date, substr(e.content, 1, ?), COALESCE(e.tags, ''), COALESCE(e.mood, ''),
+			(SELECT COUNT(*) FROM history h WHERE h.entry_id = e.id),
+			(SELECT COUNT(*) FROM attachments a WHERE a.entry_id = e.id), COALESCE(e.word_count, 0)
+		FROM entries e
+		`+where+`
+		ORDER BY e.date DESC
+		LIMIT ? OFFSET ?
+	`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []model.EntrySummary
+	for rows.Next() {
+		var s model.EntrySummary
+		var tags string
+		if err := rows.Scan(&s.ID, &s.Date, &s.Excerpt, &tags, &s.Mood, &s.HistoryCount, &s.AttachmentCount, &s.WordCount); err != nil {
+			return nil, err
+		}
+		if tags != "" {
+			s.Tags = strings.Split(tags, "|")
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+// GetEntry loads one entry with its full content, history, and attachment
+// metadata
+func GetEntry(path, password, entryID string) (_ *model.Entry, err error) {
+	defer trackOp("GetEntry", path)(&err)
+	return getEntry(path, password, entryID, true)
+}
+
+// GetEntryContent loads one entry like GetEntry, but without its history
+func GetEntryContent(path, password, entryID string) (_ *model.Entry, err error) {
+	defer trackOp("GetEntryContent", path)(&err)
+	return getEntry(path, password, entryID, false)
+}
+
+func getEntry(path, password, entryID string, history bool) (*model.Entry, error) {
+	var entry model.Entry
+	err := viewDB(path, password, func(db *sql.DB) error {
+		if err := migrateSchema(db); err != nil {
+			return err
+		}
+
+		return getEntryDB(db, sqliteDialect, entryID, &entry, history)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if password != "" {
+		journal := &model.Journal{Entries: []model.Entry{entry}}
+		if err := mergeStoredAttachments(journal, path, password); err != nil {
+			return nil, err
+		}
+		entry = journal.Entries[0]
+	}
+	return &entry, nil
+}
+
+// getEntryDB reads an entry with its attachment metadata into entry, and
+// its history when history is set
+func getEntryDB(db *sql.DB, d dialect, entryID string, entry *model.Entry, history bool) error {
+	var tags string
+	var writingSeconds int64
+	var typing string
+	err := db.QueryRow(d.rebind(`
+		SELECT id, date, content, COALESCE(tags, ''), COALESCE(mood, ''), COALESCE(writing_seconds, 0), COALESCE(typing, ''), created_at, updated_at
+		FROM entries WHERE id = ?
+	`), entryID).Scan(&entry.ID, &entry.Date, &entry.Content, &tags, &entry.Mood, &writingSeconds, &typing, &entry.CreatedAt, &entry.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	entry.WritingTime = time.Duration(writingSeconds) * time.Second
+	entry.Typing = model.ParseTyping(typing)
+	if tags != "" {
+		entry.Tags = strings.Split(tags, "|")
+	}
+	loadEntryDetails(db, d, entry, history)
+	return nil
+}
+
+// ListTasks returns the checkbox items of every entry, newest entry first,
+// or only those not yet done when openOnly is set
+func ListTasks(path, password string, openOnly bool) (_ []model.Task, err error) {
+	defer trackOp("ListTasks", path)(&err)
+
+	var tasks []model.Task
+	err = viewDB(path, password, func(db *sql.DB) error {
+		if err := migrateSchema(db); err != nil {
+			return err
+		}
+
+		tasks, err = listTasksDB(db, sqliteDialect, openOnly)
+		return err
+	})
+	return tasks, err
+}
+
+func listTasksDB(db *sql.DB, d dialect, openOnly bool) ([]model.Task, error) {
+	query := `
+		SELECT t.entry_id, e.date, t.line, t.text, t.done
+		FROM tasks t JOIN entries e ON e.id = t.entry_id`
+	if openOnly {
+		query += ` WHERE t.done = 0`
+	}
+	query += ` ORDER BY e.date DESC, t.line`
+
+	rows, err := db.Query(d.rebind(query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []model.Task
+	for rows.Next() {
+		var task model.Task
+		var done int
+		if err := rows.Scan(&task.EntryID, &task.Date, &task.Line, &task.Text, &done); err != nil {
+			return nil, err
+		}
+		task.Done = done != 0
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// ListLoops returns the open loops of every entry, newest entry first, and
+// the closed ones too unless openOnly is set
+func ListLoops(path, password string, openOnly bool) (_ []model.Loop, err error) {
+	defer trackOp("ListLoops", path)(&err)
+
+	var loops []model.Loop
+	err = viewDB(path, password, func(db *sql.DB) error {
+		loops, err = listLoopsDB(db, sqliteDialect, openOnly)
+		return err
+	})
+	return loops, err
+}
+
+// listLoopsDB finds loops in the content of the entries that may have one.
+// Unlike tasks they aren't kept in a table of their own, as they are only
+// read here.
+func listLoopsDB(db *sql.DB, d dialect, openOnly bool) ([]model.Loop, error) {
+	query := `SELECT id, date, content FROM entries WHERE content LIKE '%>>%'`
+	if !openOnly {
+		query += ` OR content LIKE '%<<%'`
+	}
+	query += ` ORDER BY date DESC`
+
+	rows, err := db.Query(d.rebind(query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loops []model.Loop
+	for rows.Next() {
+		var entry model.Entry
+		if err := rows.Scan(&entry.ID, &entry.Date, &entry.Content); err != nil {
+			return nil, err
+		}
+		for _, loop := range entry.Loops() {
+			if !openOnly || !loop.Closed {
+				loops = append(loops, loop)
+			}
+		}
+	}
+	return loops, rows.Err()
+}
+
+// ListTags returns every tag with the number of entries that have it,
+// most used first
+func ListTags(path, password string) (_ []model.TagCount, err error) {
+	defer trackOp("ListTags", path)(&err)
+
+	var tags []model.TagCount
+	err = viewDB(path, password, func(db *sql.DB) error {
+		if err := migrateSchema(db); err != nil {
+			return err
+		}
+
+		tags, err = listTagsDB(db, sqliteDialect)
+		return err
+	})
+	return tags, err
+}
+
+func listTagsDB(db *sql.DB, d dialect) ([]model.TagCount, error) {
+	rows, err := db.Query(d.rebind(`
+		SELECT tag, COUNT(*) FROM entry_tags
+		WHERE entry_id IN (SELECT id FROM entries)
+		GROUP BY tag ORDER BY COUNT(*) DESC, tag`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []model.TagCount
+	for rows.Next() {
+		var tag model.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Entries); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// EntryUpdateTimes returns when each entry was last saved, by entry ID
+func EntryUpdateTimes(path, password string) (_ map[string]time.Time, err error) {
+	defer trackOp("EntryUpdateTimes", path)(&err)
+
+	var times map[string]time.Time
+	err = viewDB(path, password, func(db *sql.DB) error {
+		if err := migrateSchema(db); err != nil {
+			return err
+		}
+
+		times, err = entryUpdateTimesDB(db, sqliteDialect)
+		return err
+	})
+	return times, err
+}
+
+func entryUpdateTimesDB(db *sql.DB, d dialect) (map[string]time.Time, error) {
+	rows, err := db.Query(d.rebind(`SELECT id, updated_at FROM entries`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	times := make(map[string]time.Time)
+	for rows.Next() {
+		var id string
+		var updated time.Time
+		if err := rows.Scan(&id, &updated); err != nil {
+			return nil, err
+		}
+		times[id] = updated
+	}
+	return times, rows.Err()
+}
+
+// FindEntryByDate returns the ID of the entry for date, or an empty string
+// when there is none
+func FindEntryByDate(path, password, date string) (_ string, err error) {
+	defer trackOp("FindEntryByDate", path)(&err)
+
+	var id string
+	err = viewDB(path, password, func(db *sql.DB) error {
+		id, err = findEntryByDateDB(db, sqliteDialect, date)
+		return err
+	})
+	return id, err
+}
+
+func findEntryByDateDB(db *sql.DB, d dialect, date string) (string, error) {
+	var id string
+	err := db.QueryRow(d.rebind(`SELECT id FROM entries WHERE date = ?`), date).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// EntryPosition returns the index of an entry in the newest-first order
+// used by ListEntries with the same filter, or -1 when it doesn't exist or
+// doesn't match
+func EntryPosition(path, password, entryID string, filter model.EntryFilter) (_ int, err error) {
+	defer trackOp("EntryPosition", path)(&err)
+
+	storeAttached, err := storeAttachmentEntries(path, password, filter)
+	if err != nil {
+		return -1, err
+	}
+	position := -1
+	err = viewDB(path, password, func(db *sql.DB) error {
+		if err := migrateSchema(db); err != nil {
+			return err
+		}
+
+		position, err = entryPositionDB(db, sqliteDialect, entryID, filter, storeAttached...)
+		return err
+	})
+	return position, err
+}
+
+func entryPositionDB(db *sql.DB, d dialect, entryID string, filter model.EntryFilter, storeAttached ...string) (int, error) {
+	where, args := filterClause(d, filter, storeAttached...)
+	if where == "" {
+		where = "WHERE e.id = ?"
+	} else {
+		where += " AND e.id = ?"
+	}
+	var date string
+	err := db.QueryRow(d.rebind(`SELECT e.date FROM entries e `+where), append(args, entryID)...).Scan(&date)
+	if err == sql.ErrNoRows {
+		return -1, nil
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	where, args = filterClause(d, filter, storeAttached...)
+	if where == "" {
+		where = "WHERE e.date > ?"
+	} else {
+		where += " AND e.date > ?"
+	}
+	var position int
+	err = db.QueryRow(d.rebind(`SELECT COUNT(*) FROM entries e `+where), append(args, date)...).Scan(&position)
+	return position, err
+}
+
+// SaveEntry inserts or updates one entry, adding any history records that
+// aren't stored yet
+func SaveEntry(path, password string, entry *model.Entry) error {
+	return SaveEntries(path, password, []model.Entry{*entry})
+}
+
+// SaveEntries inserts or updates entries in a single transaction, used for
+// bulk changes such as imports so the rest of the journal isn't rewritten
+func SaveEntries(path, password string, entries []model.Entry) (err error) {
+	defer trackOp("SaveEntries", path, "entries", len(entries))(&err)
+
+	return withDB(path, password, func(db *sql.DB) error {
+		if err := initSchema(db); err != nil {
+			return err
+		}
+		return saveEntriesDB(db, entries)
+	})
+}
+
+// DeleteEntryEncrypted deletes an entry, its history, and its attachments
+// from an encrypted journal
+func DeleteEntryEncrypted(path, password, entryID string) (err error) {
+	defer trackOp("DeleteEntryEncrypted", path)(&err)
+
+	err = withDB(path, password, func(db *sql.DB) error {
+		return deleteEntryDB(db, entryID)
+	})
+	if err != nil {
+		return err
+	}
+
+	store, err := openAttachmentStore(path, password, false)
+	if err != nil || store == nil {
+		return err
+	}
+	defer store.Close()
+	return store.deleteEntry(entryID)
+}
+
+// UnlockJournal checks password against an encrypted journal. Attachments
+// in files written before the attachment store existed are moved into it.
+func UnlockJournal(path, password string) (err error) {
+	defer trackOp("UnlockJournal", path)(&err)
+
+	expandedPath, err := ExpandPath(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(expandedPath)
+	if os.IsNotExist(err) || (err == nil && info.Size() == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = withDB(path, password, func(db *sql.DB) error {
+		moved, err := moveAttachmentsToStore(db, path, password)
+		if err != nil || !moved {
+			// Nothing to write back; unmoved attachments stay readable
+			return errSkipWrite
+		}
+		return nil
+	})
+	if err == errSkipWrite {
+		return nil
+	}
+	return err
+}
+
+// SearchEntries returns the IDs of the entries whose content, or with
+// history one of their saved versions, may contain every term, ignoring
+// case. It narrows what the search view reads rather than matching
+// exactly, so the caller checks the entries it returns.
+func SearchEntries(path, password string, terms []string, history bool) (_ []string, err error) {
+	defer trackOp("SearchEntries", path)(&err)
+
+	var ids []string
+	err = viewDB(path, password, func(db *sql.DB) error {
+		if err := migrateSchema(db); err != nil {
+			return err
+		}
+
+		ids, err = searchEntriesDB(db, sqliteDialect, terms, history)
+		return err
+	})
+	return ids, err
+}
+
+// searchEntriesDB looks up the entries that may contain every term in
+// their content or, with history, in one of their versions
+func searchEntriesDB(db *sql.DB, d dialect, terms []string, history bool) ([]string, error) {
+	cond, args := d.containsAll("entries", terms)
+	query := `SELECT t.id FROM entries t WHERE ` + cond
+	if history {
+		historyCond, historyArgs := d.containsAll("history", terms)
+		query += ` UNION SELECT t.entry_id FROM history t WHERE ` + historyCond
+		args = append(args, historyArgs...)
+	}
+	rows, err := db.Query(d.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SimilarEntries returns the other entries whose content is nearly
+// identical to content, such as a copy imported under another date
+func SimilarEntries(path, password, entryID, content string) (_ []model.EntrySummary, err error) {
+	defer trackOp("SimilarEntries", path)(&err)
+
+	var similar []model.EntrySummary
+	err = viewDB(path, password, func(db *sql.DB) error {
+		if err := migrateSchema(db); err != nil {
+			return err
+		}
+
+		similar, err = similarEntriesDB(db, sqliteDialect, entryID, content)
+		return err
+	})
+	return similar, err
+}
+
+// similarEntriesDB compares content with the entries of about its length,
+// which the stored word counts find without reading every entry
+func similarEntriesDB(db *sql.DB, d dialect, entryID, content string) ([]model.EntrySummary, error) {
+	words := len(stats.Words(content))
+	if words < stats.DuplicateMinWords {
+		return nil, nil
+	}
+	lo, hi := stats.DuplicateWordRange(words)
+	rows, err := db.Query(d.rebind(`SELECT id, date, content FROM entries WHERE word_count BETWEEN ? AND ? AND id != ? ORDER BY date`), lo, hi, entryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var similar []model.EntrySummary
+	for rows.Next() {
+		var s model.EntrySummary
+		var other string
+		if err := rows.Scan(&s.ID, &s.Date, &other); err != nil {
+			return nil, err
+		}
+		if stats.NearDuplicate(content, other) {
+			similar = append(similar, s)
+		}
+	}
+	return similar, rows.Err()
+}
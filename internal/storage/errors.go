@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Kinds of storage failure the UI can explain and offer recovery for.
+// Errors returned by exported storage functions match one of these with
+// errors.Is when the cause is recognised; the underlying error stays
+// reachable too.
+var (
+	ErrCorruptDB    = errors.New("journal file is damaged")
+	ErrPermission   = errors.New("permission denied")
+	ErrPathNotFound = errors.New("file or folder not found")
+	ErrLocked       = errors.New("journal is in use by another program")
+)
+
+// Error is a storage failure of a known kind concerning Path
+type Error struct {
+	Kind error // One of the Err* kinds above
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// classifyError wraps err in an *Error when its cause is recognised, and
+// returns it unchanged otherwise
+func classifyError(err error, path string) error {
+	if err == nil {
+		return nil
+	}
+	var storageErr *Error
+	if errors.As(err, &storageErr) {
+		return err
+	}
+	if kind := errorKind(err, path); kind != nil {
+		return &Error{Kind: kind, Path: path, Err: err}
+	}
+	return err
+}
+
+func errorKind(err error, path string) error {
+	switch {
+	case errors.Is(err, ErrCorruptJournal):
+		return ErrCorruptDB
+	case errors.Is(err, fs.ErrPermission):
+		return ErrPermission
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrPathNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "55P03", "40P01": // lock_not_available, deadlock_detected
+			return ErrLocked
+		case "42501", "28000", "28P01": // insufficient_privilege, invalid authorization or password
+			return ErrPermission
+		}
+		return nil
+	}
+
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return ErrLocked
+	case sqlite3.SQLITE_CORRUPT, sqlite3.SQLITE_NOTADB:
+		return ErrCorruptDB
+	case sqlite3.SQLITE_PERM, sqlite3.SQLITE_READONLY, sqlite3.SQLITE_AUTH:
+		return ErrPermission
+	case sqlite3.SQLITE_CANTOPEN:
+		// SQLite doesn't say why; a missing folder is the usual reason
+		if expanded, err := ExpandPath(path); err == nil && path != "" {
+			if _, err := os.Stat(filepath.Dir(expanded)); os.IsNotExist(err) {
+				return ErrPathNotFound
+			}
+			if _, err := os.Stat(expanded); os.IsPermission(err) {
+				return ErrPermission
+			}
+		}
+	}
+	return nil
+}
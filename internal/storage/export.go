@@ -0,0 +1,265 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"journal/internal/model"
+)
+
+// sortedEntries returns copies of the journal's entries oldest first, in
+// the same order and with the same timestamps however they were loaded, so
+// exporting unchanged entries gives the same bytes and exports kept under
+// version control diff cleanly. Times are in UTC, attachments are in the
+// order they were added, and history is newest first.
+func sortedEntries(journal *model.Journal) []model.Entry {
+	entries := make([]model.Entry, len(journal.Entries))
+	for i, e := range journal.Entries {
+		e.CreatedAt = e.CreatedAt.UTC()
+		e.UpdatedAt = e.UpdatedAt.UTC()
+
+		e.History = slices.Clone(e.History)
+		for j := range e.History {
+			e.History[j].SavedAt = e.History[j].SavedAt.UTC()
+		}
+		sort.SliceStable(e.History, func(a, b int) bool {
+			return e.History[a].SavedAt.After(e.History[b].SavedAt)
+		})
+
+		e.Attachments = slices.Clone(e.Attachments)
+		for j := range e.Attachments {
+			e.Attachments[j].CreatedAt = e.Attachments[j].CreatedAt.UTC()
+		}
+		sort.SliceStable(e.Attachments, func(a, b int) bool {
+			x, y := e.Attachments[a], e.Attachments[b]
+			if !x.CreatedAt.Equal(y.CreatedAt) {
+				return x.CreatedAt.Before(y.CreatedAt)
+			}
+			return x.ID < y.ID
+		})
+		entries[i] = e
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Date != entries[j].Date {
+			return entries[i].Date < entries[j].Date
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+// MarkdownAssetsDir is the folder next to a Markdown export that its
+// entries' attachments are written to
+const MarkdownAssetsDir = "assets"
+
+// markdownLinkTarget matches the target of a Markdown link or image, as in
+// [text](target) or ![alt](<target with spaces>)
+var markdownLinkTarget = regexp.MustCompile(`\]\(\s*(<[^>\n]*>|[^)\s]*)`)
+
+// markdownTextEscaper keeps filenames from ending the text of a link
+var markdownTextEscaper = strings.NewReplacer("[", "\\[", "]", "\\]")
+
+// ExportMarkdown writes the journal as a single Markdown document: a title,
+// then each entry (oldest first) under a heading with its date, followed by
+// its tags and mood. Attachments are read from store and written to
+// assets/<date>/ next to the document, so it is self-contained: links in
+// an entry to an attachment's filename are pointed at its copy, and the
+// attachments it doesn't link to are listed after it.
+func ExportMarkdown(fsys ExportFS, journal *model.Journal, store Store, destPath, title string) error {
+	expandedDest, err := ExpandPath(destPath)
+	if err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(filepath.Dir(expandedDest)); err != nil {
+		return err
+	}
+	assetsDir := filepath.Join(filepath.Dir(expandedDest), MarkdownAssetsDir)
+
+	var b strings.Builder
+	b.WriteString("# " + title + "\n")
+	for _, e := range sortedEntries(journal) {
+		b.WriteString("\n## " + printDate(e.Date) + "\n\n")
+		var meta []string
+		if len(e.Tags) > 0 {
+			meta = append(meta, "Tags: "+strings.Join(e.Tags, ", "))
+		}
+		if e.Mood != "" {
+			meta = append(meta, "Mood: "+e.Mood)
+		}
+		if len(meta) > 0 {
+			b.WriteString("*" + strings.Join(meta, " · ") + "*\n\n")
+		}
+
+		links, err := writeMarkdownAssets(fsys, store, e, assetsDir)
+		if err != nil {
+			return err
+		}
+		b.WriteString(markdownEntryBody(e, links))
+	}
+
+	return fsys.WriteFile(expandedDest, []byte(b.String()))
+}
+
+// ExportMarkdownFolder writes the journal into dir as one YYYY-MM-DD.md
+// file per entry, for note-taking apps such as Obsidian that read a folder
+// of Markdown files. Each file starts with front matter giving the entry's
+// date, tags, and mood. Attachments are read from store and written to
+// assets/<date>/ inside dir, linked the same way as by ExportMarkdown.
+func ExportMarkdownFolder(fsys ExportFS, journal *model.Journal, store Store, dir string) error {
+	expandedDir, err := ExpandPath(dir)
+	if err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(expandedDir); err != nil {
+		return err
+	}
+	assetsDir := filepath.Join(expandedDir, MarkdownAssetsDir)
+
+	for _, e := range sortedEntries(journal) {
+		var b strings.Builder
+		b.WriteString("---\n")
+		b.WriteString("date: " + e.Date + "\n")
+		if len(e.Tags) > 0 {
+			tags := make([]string, len(e.Tags))
+			for i, tag := range e.Tags {
+				tags[i] = yamlString(tag)
+			}
+			b.WriteString("tags: [" + strings.Join(tags, ", ") + "]\n")
+		}
+		if e.Mood != "" {
+			b.WriteString("mood: " + yamlString(e.Mood) + "\n")
+		}
+		b.WriteString("---\n\n")
+
+		links, err := writeMarkdownAssets(fsys, store, e, assetsDir)
+		if err != nil {
+			return err
+		}
+		b.WriteString(markdownEntryBody(e, links))
+
+		if err := fsys.WriteFile(filepath.Join(expandedDir, e.Date+markdownExt), []byte(b.String())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlString quotes a front matter value when YAML would read it as
+// something other than the plain string
+func yamlString(value string) string {
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsAny(value, ":#,[]{}&*!|>'\"%@`") {
+		return strconv.Quote(value)
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return strconv.Quote(value)
+	}
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// markdownEntryBody returns the entry's content with its links to
+// attachments pointed at their copies, followed by a list of the
+// attachments it doesn't link to
+func markdownEntryBody(e model.Entry, links []string) string {
+	content, linked := linkAttachments(e.Content, e.Attachments, links)
+	body := strings.TrimRight(content, "\n") + "\n"
+
+	var listed []string
+	for i, att := range e.Attachments {
+		if linked[i] {
+			continue
+		}
+		item := "[" + markdownTextEscaper.Replace(att.Filename) + "](" + links[i] + ")"
+		if strings.HasPrefix(att.MimeType, "image/") {
+			item = "!" + item
+		}
+		listed = append(listed, "- "+item)
+	}
+	if len(listed) > 0 {
+		body += "\n" + strings.Join(listed, "\n") + "\n"
+	}
+	return body
+}
+
+// writeMarkdownAssets writes the entry's attachments to assets/<date>/ and
+// returns the link to each, relative to the document. Attachments of the
+// same name are told apart by a number.
+func writeMarkdownAssets(fsys ExportFS, store Store, e model.Entry, assetsDir string) ([]string, error) {
+	links := make([]string, len(e.Attachments))
+	if len(e.Attachments) == 0 {
+		return links, nil
+	}
+	dir := filepath.Join(assetsDir, e.Date)
+	if err := fsys.MkdirAll(dir); err != nil {
+		return nil, err
+	}
+	used := map[string]bool{}
+	for i, att := range e.Attachments {
+		name := filepath.Base(att.Filename)
+		ext := filepath.Ext(name)
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(filepath.Base(att.Filename), ext), n, ext)
+		}
+		used[name] = true
+
+		full, err := store.GetAttachment(att.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := fsys.WriteFile(filepath.Join(dir, name), full.Data); err != nil {
+			return nil, err
+		}
+		links[i] = (&url.URL{Path: MarkdownAssetsDir + "/" + e.Date + "/" + name}).String()
+	}
+	return links, nil
+}
+
+// linkAttachments points the links in content whose target is an
+// attachment's filename (as written, "./name", or URL-escaped) at that
+// attachment's link, reporting which attachments were linked
+func linkAttachments(content string, attachments []model.Attachment, links []string) (string, []bool) {
+	linked := make([]bool, len(attachments))
+	content = markdownLinkTarget.ReplaceAllStringFunc(content, func(match string) string {
+		target := markdownLinkTarget.FindStringSubmatch(match)[1]
+		name := strings.TrimPrefix(strings.Trim(target, "<>"), "./")
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+		for i, att := range attachments {
+			if att.Filename == name {
+				linked[i] = true
+				return strings.TrimSuffix(match, target) + links[i]
+			}
+		}
+		return match
+	})
+	return content, linked
+}
+
+// ExportJSON writes the journal's entries (oldest first) as JSON, with
+// their history and the details of their attachments but not the files
+func ExportJSON(fsys ExportFS, journal *model.Journal, destPath string) error {
+	expandedDest, err := ExpandPath(destPath)
+	if err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(filepath.Dir(expandedDest)); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(model.Journal{Entries: sortedEntries(journal)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsys.WriteFile(expandedDest, append(data, '\n'))
+}
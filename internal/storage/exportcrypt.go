@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExportEncryption says how an export is encrypted. The zero value leaves
+// exports in plaintext.
+type ExportEncryption struct {
+	// Password encrypts the export in the same format as encrypted
+	// journals, to be read back with DecryptExport
+	Password string
+	// AgeRecipient encrypts the export to an age public key (or SSH key)
+	// with the age command, to be read back with "age -d"
+	AgeRecipient string
+}
+
+// Enabled reports whether exports are encrypted
+func (e ExportEncryption) Enabled() bool {
+	return e.Password != "" || e.AgeRecipient != ""
+}
+
+// Extension returns the suffix added to the name of an encrypted export
+func (e ExportEncryption) Extension() string {
+	if e.AgeRecipient != "" {
+		return ".age"
+	}
+	return EncryptedExportExtension
+}
+
+// EncryptedExportExtension is the suffix of exports encrypted with a password
+const EncryptedExportExtension = ".enc"
+
+// EncryptExport runs export against destPath in memory and writes what it
+// wrote to destPath, encrypted, with the encryption's extension added, so
+// no plaintext reaches the disk. An export writing a directory is archived
+// as a tar file as it is encrypted. Returns the path written.
+func EncryptExport(destPath string, enc ExportEncryption, export func(fsys ExportFS, path string) error) (_ string, err error) {
+	defer trackOp("EncryptExport", destPath)(&err)
+
+	if enc.Password != "" && enc.AgeRecipient != "" {
+		return "", errors.New("choose either a password or an age recipient, not both")
+	}
+	if enc.AgeRecipient != "" {
+		if _, err := exec.LookPath("age"); err != nil {
+			return "", errors.New("the age command was not found in PATH")
+		}
+	}
+	expandedDest, err := ExpandPath(destPath)
+	if err != nil {
+		return "", err
+	}
+
+	plain := newMemoryFS(expandedDest)
+	if err := export(plain, expandedDest); err != nil {
+		return "", err
+	}
+
+	finalPath := expandedDest
+	var src io.Reader
+	if data, ok := plain.single(); ok {
+		src = bytes.NewReader(data)
+	} else {
+		finalPath += ".tar"
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(plain.writeTar(pw))
+		}()
+		defer pr.Close()
+		src = pr
+	}
+	finalPath += enc.Extension()
+
+	if err := os.MkdirAll(filepath.Dir(finalPath), dirPerm()); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(finalPath), ".export-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	if enc.AgeRecipient != "" {
+		err = encryptAge(tmp, src, enc.AgeRecipient)
+	} else {
+		err = encryptStream(tmp, src, finalPath, enc.Password)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, filePerm())
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return finalPath, nil
+}
+
+// DecryptExport decrypts an export encrypted with a password into destPath
+func DecryptExport(srcPath, destPath, password string) (err error) {
+	defer trackOp("DecryptExport", srcPath)(&err)
+
+	expandedSrc, err := ExpandPath(srcPath)
+	if err != nil {
+		return err
+	}
+	expandedDest, err := ExpandPath(destPath)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(expandedSrc)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(expandedDest), ".decrypt-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	err = decryptStream(tmp, in, expandedSrc, password)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, filePerm())
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, expandedDest)
+}
+
+// encryptAge encrypts r to recipient with the age command, writing to w
+func encryptAge(w io.Writer, r io.Reader, recipient string) error {
+	cmd := exec.Command("age", "--encrypt", "--recipient", recipient)
+	cmd.Stdin = r
+	cmd.Stdout = w
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("age: %s", msg)
+		}
+		return fmt.Errorf("age: %w", err)
+	}
+	return nil
+}
+
+// secureRemoveAll overwrites the files under dir and removes it
+func secureRemoveAll(dir string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			secureRemove(path)
+		}
+		return nil
+	})
+	os.RemoveAll(dir)
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExportFS is where an export writes its files: the filesystem, or memory
+// for an export encrypted before any of it reaches the disk
+type ExportFS interface {
+	// MkdirAll creates the directory dir and any parents it needs
+	MkdirAll(dir string) error
+	// WriteFile writes data to the file at path, replacing it
+	WriteFile(path string, data []byte) error
+}
+
+// DiskFS writes exports to the filesystem
+var DiskFS ExportFS = diskFS{}
+
+type diskFS struct{}
+
+func (diskFS) MkdirAll(dir string) error {
+	return os.MkdirAll(dir, dirPerm())
+}
+
+func (diskFS) WriteFile(path string, data []byte) error {
+	return os.WriteFile(path, data, filePerm())
+}
+
+// memoryFS keeps the files an export writes at or under root in memory
+type memoryFS struct {
+	root    string
+	files   []exportFile // In the order written
+	written map[string]int
+}
+
+// exportFile is a file or directory written to a memoryFS
+type exportFile struct {
+	name string // Relative to root's parent, with slashes
+	data []byte
+	dir  bool
+}
+
+func newMemoryFS(root string) *memoryFS {
+	return &memoryFS{root: filepath.Clean(root), written: map[string]int{}}
+}
+
+// name returns path relative to root's parent, failing for paths outside
+// root. The parent itself has no name.
+func (m *memoryFS) name(path string) (string, error) {
+	rel, err := filepath.Rel(filepath.Dir(m.root), filepath.Clean(path))
+	if err == nil && rel == "." {
+		return "", nil
+	}
+	base := filepath.Base(m.root)
+	if err != nil || (rel != base && !strings.HasPrefix(rel, base+string(filepath.Separator))) {
+		return "", fmt.Errorf("the export wrote %s, outside %s", path, m.root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func (m *memoryFS) MkdirAll(dir string) error {
+	name, err := m.name(dir)
+	if err != nil || name == "" {
+		return err
+	}
+	// Parents come first, as they would be walked on disk
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		parent := strings.Join(parts[:i+1], "/")
+		if _, ok := m.written[parent]; !ok {
+			m.written[parent] = len(m.files)
+			m.files = append(m.files, exportFile{name: parent, dir: true})
+		}
+	}
+	return nil
+}
+
+func (m *memoryFS) WriteFile(path string, data []byte) error {
+	name, err := m.name(path)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("the export wrote %s, outside %s", path, m.root)
+	}
+	if i, ok := m.written[name]; ok {
+		if m.files[i].dir {
+			return fmt.Errorf("%s is a directory", path)
+		}
+		m.files[i].data = data
+		return nil
+	}
+	m.written[name] = len(m.files)
+	m.files = append(m.files, exportFile{name: name, data: data})
+	return nil
+}
+
+// single returns the data of the export when it is a single file written
+// to root itself
+func (m *memoryFS) single() ([]byte, bool) {
+	if len(m.files) != 1 || m.files[0].dir || m.files[0].name != filepath.Base(m.root) {
+		return nil, false
+	}
+	return m.files[0].data, true
+}
+
+// writeTar writes the files to w as a tar archive, which unpacks into a
+// directory of root's name
+func (m *memoryFS) writeTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, f := range m.files {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     f.name,
+			Mode:     int64(filePerm()),
+			Size:     int64(len(f.data)),
+			ModTime:  now,
+		}
+		if f.dir {
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+			header.Mode = int64(dirPerm())
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
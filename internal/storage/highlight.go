@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"journal/internal/clock"
+	"journal/internal/model"
+)
+
+// AddHighlight adds text to the Highlights section of the entry dated date,
+// starting the entry with template when there is none yet. An existing
+// entry's previous content goes to its history, as when it is saved from
+// the editor.
+func AddHighlight(store Store, date, text, template string, clk clock.Clock, ids clock.IDGenerator) (*model.Entry, error) {
+	id, err := store.FindEntryByDate(date)
+	if err != nil {
+		return nil, err
+	}
+	now := clk.Now()
+	if id == "" {
+		entry := &model.Entry{
+			ID:        ids.NewID(),
+			Date:      date,
+			Content:   model.AddHighlight(template, text),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		return entry, store.SaveEntries([]model.Entry{*entry})
+	}
+
+	entry, err := store.GetEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	entry.History = append(entry.History, model.SaveRecord{
+		Content:     entry.Content,
+		SavedAt:     entry.UpdatedAt,
+		Attachments: entry.AttachmentFilenames(),
+		Tags:        entry.Tags,
+	})
+	entry.Content = model.AddHighlight(entry.Content, text)
+	entry.UpdatedAt = now
+	return entry, store.SaveEntries([]model.Entry{*entry})
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"html"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"journal/internal/model"
+	"journal/internal/theme"
+)
+
+// HTMLOptions configures an HTML export
+type HTMLOptions struct {
+	Title       string
+	NewestFirst bool        // List entries newest first, as in the entry list, rather than oldest first
+	Theme       theme.Theme // Colors of the page
+	Light       bool        // Theme is the variant for light backgrounds
+	Password    string      // Protects the page, which asks for it in the browser, when set
+}
+
+// htmlCSS styles an HTML export. The {name} placeholders are filled with
+// the theme's colors, by their names in theme.ColorNames, by htmlStyle.
+const htmlCSS = `
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; line-height: 1.6; background: {background}; color: {text}; max-width: 46em; margin: 0 auto; padding: 2em 1em; }
+a { color: {accent}; }
+h1 { color: {title}; }
+nav { border-bottom: 1px solid {disabled}; padding-bottom: 1em; margin-bottom: 2em; }
+nav ol { columns: 3; list-style: none; padding: 0; margin: 0; font-size: 0.9em; }
+nav a { text-decoration: none; }
+.entry { border-bottom: 1px solid {disabled}; padding-bottom: 1.5em; margin-bottom: 1.5em; }
+.entry h2 { color: {title}; font-size: 1.3em; margin-bottom: 0.2em; }
+.entry .meta { color: {muted}; font-size: 0.9em; margin: 0 0 1em 0; }
+.entry .meta .tag { color: {info}; }
+.entry .content p { margin: 0 0 0.8em 0; }
+.entry figure { margin: 1em 0; text-align: center; }
+.entry figure img { max-width: 100%; border-radius: 4px; }
+.entry figcaption, .entry .files { color: {muted}; font-size: 0.85em; font-style: italic; }
+`
+
+// htmlStyle returns htmlCSS in the colors of the options' theme
+func htmlStyle(opts HTMLOptions) string {
+	background := "#1c1c1c"
+	if opts.Light {
+		background = "#ffffff"
+	}
+	replacements := []string{"{background}", background}
+	for name, color := range theme.Colors(opts.Theme) {
+		hex := theme.Hex(color)
+		if hex == "" {
+			hex = "inherit"
+		}
+		replacements = append(replacements, "{"+name+"}", hex)
+	}
+	return strings.NewReplacer(replacements...).Replace(htmlCSS)
+}
+
+// ExportHTML writes the journal as a single read-only HTML page to share
+// or keep as an archive: a date index, then every entry with its tags and
+// mood, in the colors of the theme. Image attachments are read from store
+// and embedded as data URIs, so the page needs no other files. With a
+// password the page is encrypted, and decrypted in the browser.
+func ExportHTML(fsys ExportFS, journal *model.Journal, store Store, destPath string, opts HTMLOptions) error {
+	expandedDest, err := ExpandPath(destPath)
+	if err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(filepath.Dir(expandedDest)); err != nil {
+		return err
+	}
+	page, err := renderHTML(journal, store, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Password != "" {
+		if page, err = protectHTML(page, opts.Password, opts); err != nil {
+			return err
+		}
+	}
+	return fsys.WriteFile(expandedDest, page)
+}
+
+// EntryHTML renders a single entry as an HTML page like ExportHTML's,
+// without the date index
+func EntryHTML(entry model.Entry, store Store, opts HTMLOptions) ([]byte, error) {
+	return renderHTML(&model.Journal{Entries: []model.Entry{entry}}, store, opts)
+}
+
+// renderHTML renders the page of ExportHTML. A single entry gets no index.
+func renderHTML(journal *model.Journal, store Store, opts HTMLOptions) ([]byte, error) {
+	entries := sortedEntries(journal)
+	if opts.NewestFirst {
+		slices.Reverse(entries)
+	}
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	b.WriteString("<title>" + html.EscapeString(opts.Title) + "</title>\n")
+	b.WriteString("<style>" + htmlStyle(opts) + "</style>\n</head>\n<body>\n")
+
+	b.WriteString("<h1>" + html.EscapeString(opts.Title) + "</h1>\n")
+	if len(entries) > 1 {
+		b.WriteString("<nav>\n<ol>\n")
+		for _, e := range entries {
+			b.WriteString("<li><a href=\"#" + html.EscapeString(e.Date) + "\">" + html.EscapeString(e.Date) + "</a></li>\n")
+		}
+		b.WriteString("</ol>\n</nav>\n")
+	}
+
+	for _, e := range entries {
+		b.WriteString("<article class=\"entry\" id=\"" + html.EscapeString(e.Date) + "\">\n")
+		b.WriteString("<h2>" + html.EscapeString(printDate(e.Date)) + "</h2>\n")
+		var meta []string
+		for _, tag := range e.Tags {
+			meta = append(meta, "<span class=\"tag\">#"+html.EscapeString(tag)+"</span>")
+		}
+		if e.Mood != "" {
+			meta = append(meta, html.EscapeString(e.Mood))
+		}
+		if len(meta) > 0 {
+			b.WriteString("<p class=\"meta\">" + strings.Join(meta, " ") + "</p>\n")
+		}
+		writeHTMLContent(&b, e.Content)
+		if err := writeHTMLAttachments(&b, store, e); err != nil {
+			return nil, err
+		}
+		b.WriteString("</article>\n")
+	}
+
+	b.WriteString("</body>\n</html>\n")
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"journal/internal/model"
+)
+
+// icalPreviewLength is how much of an entry its event description holds,
+// unless the full content is exported
+const icalPreviewLength = 200
+
+// icalTitleLength is how much of an entry's title its event summary holds
+const icalTitleLength = 80
+
+// ExportICal writes the journal as an iCalendar (.ics) file with one
+// all-day event per entry, titled with the entry's title and described by
+// its opening text, or all of it when full is set. Events keep the entry
+// IDs as UIDs, so importing a newer export updates the events rather than
+// duplicating them.
+func ExportICal(fsys ExportFS, journal *model.Journal, destPath, calendarName string, full bool) error {
+	expandedDest, err := ExpandPath(destPath)
+	if err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(filepath.Dir(expandedDest)); err != nil {
+		return err
+	}
+
+	entries := make([]model.Entry, len(journal.Entries))
+	copy(entries, journal.Entries)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Date < entries[j].Date
+	})
+
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldICalLine(s))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//journal//Journal export//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:" + escapeICalText(calendarName))
+
+	for _, e := range entries {
+		day, err := time.Parse("2006-01-02", e.Date)
+		if err != nil {
+			continue // Not a date an event can be placed on
+		}
+		description := e.Preview(icalPreviewLength)
+		if full {
+			description = e.Content
+		}
+		line("BEGIN:VEVENT")
+		line("UID:" + escapeICalText(e.ID) + "@journal")
+		line("DTSTAMP:" + e.UpdatedAt.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE:" + day.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + day.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICalText(e.Title(icalTitleLength)))
+		line("DESCRIPTION:" + escapeICalText(description))
+		if len(e.Tags) > 0 {
+			tags := make([]string, len(e.Tags))
+			for i, tag := range e.Tags {
+				tags[i] = escapeICalText(tag)
+			}
+			line("CATEGORIES:" + strings.Join(tags, ","))
+		}
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	return fsys.WriteFile(expandedDest, []byte(b.String()))
+}
+
+// escapeICalText escapes a value of an iCalendar text property
+func escapeICalText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// foldICalLine splits a content line longer than 75 octets, continuing it
+// on lines starting with a space. Lines are split between characters, not
+// inside one.
+func foldICalLine(s string) string {
+	const limit = 75
+	var b strings.Builder
+	width := limit
+	for len(s) > width {
+		cut := width
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		width = limit - 1 // The leading space counts
+	}
+	b.WriteString(s)
+	return b.String()
+}
@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Entries carry checksums so silent corruption, or edits made to the
+// database behind the app's back, can be found. Each history version's
+// checksum covers its content, when it was saved, and the checksum of the
+// version before it, and the entry's checksum covers its date, current
+// content, and last update and the checksum of its latest version, so a
+// changed, removed, or reordered version breaks the chain. History is
+// append-only: the database refuses to change or remove a version, other
+// than by deleting its entry. The checksums aren't keyed: they catch damage
+// and careless tampering, not someone who recomputes them.
+
+// querier runs queries against a database or inside a transaction
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// chainHash returns the checksum of a version of an entry, chained to the
+// checksum of the version before it ("" for the first)
+func chainHash(prev string, fields ...string) string {
+	h := sha256.New()
+	h.Write([]byte(prev))
+	for _, field := range fields {
+		h.Write([]byte{0})
+		h.Write([]byte(field))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// versionHash returns the checksum of a history version
+func versionHash(prev string, savedAt time.Time, content string) string {
+	return chainHash(prev, hashTime(savedAt), content)
+}
+
+// entryHash returns the checksum of an entry's current content
+func entryHash(prev, date string, updatedAt time.Time, content string) string {
+	return chainHash(prev, date, hashTime(updatedAt), content)
+}
+
+// hashTime formats a time for a checksum. Times are checksummed as read
+// back from the database, so precision the database drops doesn't matter.
+func hashTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// historyVersion is a history record as read for checksumming
+type historyVersion struct {
+	id      int64
+	content string
+	savedAt time.Time
+	hash    string
+}
+
+// loadHistoryVersions reads an entry's history in the order it was saved
+func loadHistoryVersions(q querier, d dialect, entryID string) ([]historyVersion, error) {
+	rows, err := q.Query(d.rebind(`SELECT id, content, saved_at, COALESCE(hash, '') FROM history WHERE entry_id = ? ORDER BY saved_at, id`), entryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []historyVersion
+	for rows.Next() {
+		var v historyVersion
+		if err := rows.Scan(&v.id, &v.content, &v.savedAt, &v.hash); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// sealEntry gives checksums to the history versions of an entry that have
+// none, chained in the order they were saved, and checksums the entry's
+// current content at the end of the chain. Versions already sealed keep
+// their checksums, so a version changed since fails to verify rather than
+// being sealed again.
+func sealEntry(q querier, d dialect, entryID string) error {
+	versions, err := loadHistoryVersions(q, d, entryID)
+	if err != nil {
+		return err
+	}
+	prev := ""
+	for _, v := range versions {
+		if v.hash == "" {
+			v.hash = versionHash(prev, v.savedAt, v.content)
+			if _, err := q.Exec(d.rebind(`UPDATE history SET hash = ? WHERE id = ?`), v.hash, v.id); err != nil {
+				return err
+			}
+		}
+		prev = v.hash
+	}
+
+	var date, content string
+	var updatedAt time.Time
+	err = q.QueryRow(d.rebind(`SELECT date, content, updated_at FROM entries WHERE id = ?`), entryID).Scan(&date, &content, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil // History saved before its entry, which seals it when saved
+	}
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(d.rebind(`UPDATE entries SET content_hash = ? WHERE id = ?`), entryHash(prev, date, updatedAt, content), entryID)
+	return err
+}
+
+// sealUnsealedEntries seals the entries saved before checksums were kept
+func sealUnsealedEntries(q querier, d dialect) error {
+	rows, err := q.Query(`SELECT id FROM entries WHERE COALESCE(content_hash, '') = ''`)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := sealEntry(q, d, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// historyAppendOnlySchema keeps SQLite from changing or removing history
+// versions. Labels and attachment lists are annotations and stay editable,
+// and a version's checksum can only be filled in once. Versions can be
+// removed once their entry is deleted.
+const historyAppendOnlySchema = `
+	CREATE TRIGGER IF NOT EXISTS history_append_only BEFORE UPDATE OF entry_id, content, saved_at ON history BEGIN
+		SELECT RAISE(ABORT, 'history is append-only');
+	END;
+	CREATE TRIGGER IF NOT EXISTS history_hash_once BEFORE UPDATE OF hash ON history
+	WHEN COALESCE(old.hash, '') != '' BEGIN
+		SELECT RAISE(ABORT, 'history checksums are written once');
+	END;
+	CREATE TRIGGER IF NOT EXISTS history_no_delete BEFORE DELETE ON history
+	WHEN EXISTS (SELECT 1 FROM entries WHERE id = old.entry_id) BEGIN
+		SELECT RAISE(ABORT, 'history is append-only; delete the entry instead');
+	END;
+`
+
+// postgresHistoryAppendOnly does the same as historyAppendOnlySchema for
+// PostgreSQL journals
+var postgresHistoryAppendOnly = []string{
+	`CREATE OR REPLACE FUNCTION journal_history_append_only() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP = 'DELETE' THEN
+			IF EXISTS (SELECT 1 FROM entries WHERE id = OLD.entry_id) THEN
+				RAISE EXCEPTION 'history is append-only; delete the entry instead';
+			END IF;
+			RETURN OLD;
+		END IF;
+		IF NEW.entry_id IS DISTINCT FROM OLD.entry_id OR NEW.content IS DISTINCT FROM OLD.content
+			OR NEW.saved_at IS DISTINCT FROM OLD.saved_at THEN
+			RAISE EXCEPTION 'history is append-only';
+		END IF;
+		IF COALESCE(OLD.hash, '') != '' AND NEW.hash IS DISTINCT FROM OLD.hash THEN
+			RAISE EXCEPTION 'history checksums are written once';
+		END IF;
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql`,
+	`DO $$ BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'history_append_only') THEN
+			CREATE TRIGGER history_append_only BEFORE UPDATE OR DELETE ON history
+				FOR EACH ROW EXECUTE FUNCTION journal_history_append_only();
+		END IF;
+	END $$`,
+}
+
+// IntegrityProblem is a version of an entry whose content doesn't match
+// its checksum
+type IntegrityProblem struct {
+	EntryID string
+	Date    string
+	SavedAt time.Time // The history version's save time, zero for the current content
+	Problem string
+}
+
+func (p IntegrityProblem) String() string {
+	version := "current content"
+	if !p.SavedAt.IsZero() {
+		version = "version saved " + p.SavedAt.Local().Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprintf("%s (%s), %s: %s", p.Date, p.EntryID, version, p.Problem)
+}
+
+// verifyDB checks every entry and history version against its checksum
+func verifyDB(q querier, d dialect) ([]IntegrityProblem, error) {
+	type storedEntry struct {
+		id, date, content, hash string
+		updatedAt               time.Time
+	}
+	rows, err := q.Query(`SELECT id, date, content, updated_at, COALESCE(content_hash, '') FROM entries ORDER BY date`)
+	if err != nil {
+		return nil, err
+	}
+	var entries []storedEntry
+	for rows.Next() {
+		var e storedEntry
+		if err := rows.Scan(&e.id, &e.date, &e.content, &e.updatedAt, &e.hash); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var problems []IntegrityProblem
+	for _, e := range entries {
+		versions, err := loadHistoryVersions(q, d, e.id)
+		if err != nil {
+			return nil, err
+		}
+		prev := ""
+		for _, v := range versions {
+			expected := versionHash(prev, v.savedAt, v.content)
+			switch {
+			case v.hash == "":
+				problems = append(problems, IntegrityProblem{e.id, e.date, v.savedAt, "no checksum"})
+				v.hash = expected
+			case v.hash != expected:
+				problems = append(problems, IntegrityProblem{e.id, e.date, v.savedAt,
+					"doesn't match its checksum; it or its save time was changed, or a version before it removed"})
+			}
+			// Later versions were chained to the checksum as stored
+			prev = v.hash
+		}
+
+		switch expected := entryHash(prev, e.date, e.updatedAt, e.content); {
+		case e.hash == "":
+			problems = append(problems, IntegrityProblem{e.id, e.date, time.Time{}, "no checksum"})
+		case e.hash != expected:
+			problems = append(problems, IntegrityProblem{e.id, e.date, time.Time{},
+				"doesn't match its checksum; it or its date was changed, or its latest version removed"})
+		}
+	}
+	return problems, nil
+}
+
+// VerifyJournal checks the entries of a SQLite journal against their
+// checksums. Password is empty for plaintext journals.
+func VerifyJournal(path, password string) (_ []IntegrityProblem, err error) {
+	defer trackOp("VerifyJournal", path)(&err)
+
+	var problems []IntegrityProblem
+	err = viewDB(path, password, func(db *sql.DB) error {
+		if err := migrateSchema(db); err != nil {
+			return err
+		}
+
+		problems, err = verifyDB(db, sqliteDialect)
+		return err
+	})
+	return problems, err
+}
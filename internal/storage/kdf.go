@@ -0,0 +1,314 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"runtime"
+	"sync"
+	"time"
+
+	"journal/internal/model"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	KDFArgon2id = "argon2id"
+	KDFScrypt   = "scrypt"
+)
+
+// kdfMagic starts the header of encrypted files written with a password KDF.
+// Files without it use the original SHA-256 key derivation.
+var kdfMagic = []byte("JRNLKDF1")
+
+const (
+	kdfSaltSize   = 16
+	kdfHeaderSize = 8 + 1 + 4 + 4 + 1 + kdfSaltSize
+	maxKDFMemory  = 4 << 20 // 4 GiB in KiB, rejects absurd header values
+)
+
+// KDFCalibrationTarget is how long key derivation should take after
+// calibration
+const KDFCalibrationTarget = 500 * time.Millisecond
+
+// DefaultKDFParams are used for new encrypted files until calibrated
+var DefaultKDFParams = model.KDFParams{
+	Algorithm:  KDFArgon2id,
+	MemoryKiB:  64 * 1024,
+	Iterations: 3,
+	Threads:    uint8(min(runtime.NumCPU(), 4)),
+}
+
+// kdfHeader describes how the key of an encrypted file was derived
+type kdfHeader struct {
+	params model.KDFParams
+	salt   []byte
+}
+
+var (
+	kdfMu     sync.Mutex
+	kdfParams = DefaultKDFParams
+	// kdfKeys caches derived keys so repeated saves don't pay the KDF cost
+	kdfKeys = make(map[[32]byte][]byte)
+	// kdfSessions remembers the header last used with each journal file and
+	// password, so re-encrypting a journal keeps its salt and parameters
+	kdfSessions = make(map[[32]byte]kdfHeader)
+)
+
+// SetKDFParams sets the parameters used for newly encrypted files. Zero
+// fields fall back to the defaults.
+func SetKDFParams(params *model.KDFParams) error {
+	p := resolveKDFParams(params)
+	if err := validateKDFParams(p); err != nil {
+		return err
+	}
+
+	kdfMu.Lock()
+	kdfParams = p
+	kdfMu.Unlock()
+	return nil
+}
+
+// resolveKDFParams fills the zero fields of configured parameters with the
+// defaults, keeping only the fields the algorithm uses
+func resolveKDFParams(params *model.KDFParams) model.KDFParams {
+	p := DefaultKDFParams
+	if params == nil {
+		return p
+	}
+	if params.Algorithm != "" {
+		p.Algorithm = params.Algorithm
+	}
+	if params.MemoryKiB != 0 {
+		p.MemoryKiB = params.MemoryKiB
+	}
+	if p.Algorithm != KDFScrypt {
+		if params.Iterations != 0 {
+			p.Iterations = params.Iterations
+		}
+		if params.Threads != 0 {
+			p.Threads = params.Threads
+		}
+		return p
+	}
+
+	// scrypt has no passes or lanes. Configs written before it had its own
+	// field gave the parallelization factor as iterations.
+	p.Iterations, p.Threads = 0, 0
+	switch {
+	case params.Parallelization != 0:
+		p.Parallelization = params.Parallelization
+	case params.Iterations != 0:
+		p.Parallelization = params.Iterations
+	default:
+		p.Parallelization = 1
+	}
+	return p
+}
+
+func validateKDFParams(p model.KDFParams) error {
+	switch p.Algorithm {
+	case KDFArgon2id, KDFScrypt:
+	default:
+		return fmt.Errorf("unknown key derivation algorithm %q", p.Algorithm)
+	}
+	if p.MemoryKiB < 1024 || p.MemoryKiB > maxKDFMemory {
+		return fmt.Errorf("key derivation memory must be between 1 MiB and 4 GiB")
+	}
+	if p.Algorithm == KDFScrypt {
+		if p.Parallelization == 0 || p.Parallelization > 1000 {
+			return fmt.Errorf("scrypt parallelization must be between 1 and 1000")
+		}
+		return nil
+	}
+	if p.Iterations == 0 || p.Iterations > 1000 {
+		return fmt.Errorf("key derivation iterations must be between 1 and 1000")
+	}
+	if p.Threads == 0 {
+		return fmt.Errorf("key derivation threads must be at least 1")
+	}
+	return nil
+}
+
+// CalibrateKDF measures key derivation on this machine and returns the
+// default parameters with iterations adjusted to take about target
+func CalibrateKDF(target time.Duration) model.KDFParams {
+	p := DefaultKDFParams
+	p.Iterations = 1
+	salt := make([]byte, kdfSaltSize)
+
+	// Measure twice: the first estimate includes one-off allocation cost
+	for range 2 {
+		start := time.Now()
+		deriveKDFKey("calibration", salt, p)
+		elapsed := time.Since(start)
+		if elapsed <= 0 {
+			elapsed = time.Millisecond
+		}
+		perIteration := elapsed / time.Duration(p.Iterations)
+		iterations := uint32((target + perIteration/2) / perIteration)
+		p.Iterations = max(1, min(iterations, 100))
+	}
+	return p
+}
+
+func deriveKDFKey(password string, salt []byte, p model.KDFParams) []byte {
+	if p.Algorithm == KDFScrypt {
+		// scrypt uses 128*r bytes per N; with r = 8 that is 1 KiB
+		n := 1 << (bits.Len32(p.MemoryKiB) - 1)
+		key, err := scrypt.Key([]byte(password), salt, n, 8, int(p.Parallelization), 32)
+		if err != nil {
+			// Parameters are validated before use
+			panic(err)
+		}
+		return key
+	}
+	return argon2.IDKey([]byte(password), salt, p.Iterations, p.MemoryKiB, p.Threads, 32)
+}
+
+func kdfCacheKey(password string, h kdfHeader) [32]byte {
+	hash := sha256.New()
+	hash.Write([]byte(password))
+	hash.Write(h.encode())
+	var sum [32]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
+
+// headerKey derives (or fetches from cache) the key for password and h
+func headerKey(password string, h kdfHeader) []byte {
+	cacheKey := kdfCacheKey(password, h)
+
+	kdfMu.Lock()
+	key, ok := kdfKeys[cacheKey]
+	kdfMu.Unlock()
+	if ok {
+		return key
+	}
+
+	start := time.Now()
+	key = deriveKDFKey(password, h.salt, h.params)
+	logger.Debug("derived key", "algorithm", h.params.Algorithm, "memory_kib", h.params.MemoryKiB,
+		"iterations", h.params.Iterations, "parallelization", h.params.Parallelization, "duration", time.Since(start))
+
+	kdfMu.Lock()
+	kdfKeys[cacheKey] = key
+	kdfMu.Unlock()
+	return key
+}
+
+// kdfSessionID identifies the file at expandedPath opened with password.
+// Journals sharing a password each get their own salt, and so their own key.
+func kdfSessionID(expandedPath, password string) [32]byte {
+	hash := sha256.New()
+	hash.Write([]byte(expandedPath))
+	hash.Write([]byte{0})
+	hash.Write([]byte(password))
+	var sum [32]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
+
+// sessionHeader returns the header to encrypt the file at expandedPath with
+// for password: the one last seen for them, or a fresh salt with the
+// configured parameters
+func sessionHeader(expandedPath, password string) (kdfHeader, error) {
+	id := kdfSessionID(expandedPath, password)
+
+	kdfMu.Lock()
+	h, ok := kdfSessions[id]
+	params := kdfParams
+	kdfMu.Unlock()
+	if ok {
+		return h, nil
+	}
+
+	h = kdfHeader{params: params, salt: make([]byte, kdfSaltSize)}
+	if _, err := io.ReadFull(rand.Reader, h.salt); err != nil {
+		return kdfHeader{}, err
+	}
+	rememberHeader(expandedPath, password, h)
+	return h, nil
+}
+
+func rememberHeader(expandedPath, password string, h kdfHeader) {
+	kdfMu.Lock()
+	kdfSessions[kdfSessionID(expandedPath, password)] = h
+	kdfMu.Unlock()
+}
+
+// resetKDFSession makes the next encryption of the file at expandedPath
+// with password use a fresh salt and the configured parameters
+func resetKDFSession(expandedPath, password string) {
+	kdfMu.Lock()
+	delete(kdfSessions, kdfSessionID(expandedPath, password))
+	kdfMu.Unlock()
+}
+
+func (h kdfHeader) encode() []byte {
+	buf := make([]byte, 0, kdfHeaderSize)
+	buf = append(buf, kdfMagic...)
+	algorithm := byte(1)
+	if h.params.Algorithm == KDFScrypt {
+		algorithm = 2
+	}
+	buf = append(buf, algorithm)
+	buf = binary.BigEndian.AppendUint32(buf, h.params.MemoryKiB)
+	// scrypt's parallelization factor takes the place of Argon2's passes.
+	// Its lanes byte is unused; it is written as 1 because older versions
+	// reject 0.
+	cost, threads := h.params.Iterations, h.params.Threads
+	if h.params.Algorithm == KDFScrypt {
+		cost, threads = h.params.Parallelization, 1
+	}
+	buf = binary.BigEndian.AppendUint32(buf, cost)
+	buf = append(buf, threads)
+	return append(buf, h.salt...)
+}
+
+func hasKDFHeader(data []byte) bool {
+	return len(data) >= kdfHeaderSize && bytes.Equal(data[:len(kdfMagic)], kdfMagic)
+}
+
+func parseKDFHeader(data []byte) (kdfHeader, error) {
+	if !hasKDFHeader(data) {
+		return kdfHeader{}, errors.New("missing key derivation header")
+	}
+	return parseKDFFields(data)
+}
+
+// parseKDFFields parses a KDF header without checking its magic, which
+// differs between the single-block and chunked file formats
+func parseKDFFields(data []byte) (kdfHeader, error) {
+	if len(data) < kdfHeaderSize {
+		return kdfHeader{}, errors.New("missing key derivation header")
+	}
+	var h kdfHeader
+	switch data[8] {
+	case 1:
+		h.params.Algorithm = KDFArgon2id
+	case 2:
+		h.params.Algorithm = KDFScrypt
+	default:
+		return kdfHeader{}, fmt.Errorf("unknown key derivation algorithm %d", data[8])
+	}
+	h.params.MemoryKiB = binary.BigEndian.Uint32(data[9:13])
+	if h.params.Algorithm == KDFScrypt {
+		h.params.Parallelization = binary.BigEndian.Uint32(data[13:17])
+	} else {
+		h.params.Iterations = binary.BigEndian.Uint32(data[13:17])
+		h.params.Threads = data[17]
+	}
+	h.salt = bytes.Clone(data[18:kdfHeaderSize])
+	if err := validateKDFParams(h.params); err != nil {
+		return kdfHeader{}, err
+	}
+	return h, nil
+}
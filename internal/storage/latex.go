@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"journal/internal/model"
+)
+
+// latexEscaper escapes characters with special meaning in LaTeX
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`%`, `\%`,
+	`_`, `\_`,
+	`^`, `\textasciicircum{}`,
+	`~`, `\textasciitilde{}`,
+)
+
+// latexFigureTypes are attachment MIME types pdflatex can include as figures
+var latexFigureTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"application/pdf": true,
+}
+
+// ExportLaTeX writes the journal as a LaTeX book project into dir: a main.tex
+// including one chapter file per year, with a section per month. Image and
+// PDF attachments are copied into figures/ and included as figures; other
+// attachments are listed by name, and attachment data is read from store.
+func ExportLaTeX(fsys ExportFS, journal *model.Journal, store Store, dir, title string) error {
+	expandedDir, err := ExpandPath(dir)
+	if err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(filepath.Join(expandedDir, "chapters")); err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(filepath.Join(expandedDir, "figures")); err != nil {
+		return err
+	}
+
+	entries := make([]model.Entry, len(journal.Entries))
+	copy(entries, journal.Entries)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Date < entries[j].Date
+	})
+
+	// Group entries by year
+	var years []string
+	byYear := make(map[string][]model.Entry)
+	for _, e := range entries {
+		if len(e.Date) < 4 {
+			continue
+		}
+		year := e.Date[:4]
+		if _, ok := byYear[year]; !ok {
+			years = append(years, year)
+		}
+		byYear[year] = append(byYear[year], e)
+	}
+
+	for _, year := range years {
+		chapter, err := latexChapter(fsys, year, byYear[year], store, expandedDir)
+		if err != nil {
+			return err
+		}
+		if err := fsys.WriteFile(filepath.Join(expandedDir, "chapters", year+".tex"), []byte(chapter)); err != nil {
+			return err
+		}
+	}
+
+	var main strings.Builder
+	main.WriteString("\\documentclass[11pt,a5paper]{book}\n")
+	main.WriteString("\\usepackage[utf8]{inputenc}\n")
+	main.WriteString("\\usepackage[T1]{fontenc}\n")
+	main.WriteString("\\usepackage{graphicx}\n")
+	main.WriteString("\\usepackage{caption}\n")
+	main.WriteString("\\usepackage[margin=2cm]{geometry}\n")
+	main.WriteString("\\graphicspath{{figures/}}\n\n")
+	main.WriteString("\\title{" + latexEscaper.Replace(title) + "}\n")
+	main.WriteString("\\date{}\n\n")
+	main.WriteString("\\begin{document}\n")
+	main.WriteString("\\maketitle\n")
+	main.WriteString("\\tableofcontents\n\n")
+	for _, year := range years {
+		main.WriteString("\\include{chapters/" + year + "}\n")
+	}
+	main.WriteString("\n\\end{document}\n")
+
+	return fsys.WriteFile(filepath.Join(expandedDir, "main.tex"), []byte(main.String()))
+}
+
+func latexChapter(fsys ExportFS, year string, entries []model.Entry, store Store, dir string) (string, error) {
+	var b strings.Builder
+	b.WriteString("\\chapter{" + year + "}\n")
+
+	currentMonth := ""
+	for _, e := range entries {
+		month, heading := "", e.Date
+		if t, err := time.Parse("2006-01-02", e.Date); err == nil {
+			month = t.Format("January")
+			heading = t.Format("Monday, January 2")
+		}
+		if month != currentMonth {
+			currentMonth = month
+			b.WriteString("\n\\section{" + month + "}\n")
+		}
+		b.WriteString("\n\\subsection*{" + latexEscaper.Replace(heading) + "}\n")
+		b.WriteString("\\addcontentsline{toc}{subsection}{" + latexEscaper.Replace(e.Date) + "}\n\n")
+
+		for _, lines := range latexParagraphs(e.Content) {
+			for i, line := range lines {
+				lines[i] = latexEscaper.Replace(line)
+			}
+			b.WriteString(strings.Join(lines, "\\\\\n"))
+			b.WriteString("\n\n")
+		}
+
+		var others []string
+		for _, att := range e.Attachments {
+			if !latexFigureTypes[att.MimeType] {
+				others = append(others, latexEscaper.Replace(att.Filename))
+				continue
+			}
+
+			full, err := store.GetAttachment(att.ID)
+			if err != nil {
+				return "", err
+			}
+			figName := fmt.Sprintf("%s-%s%s", e.Date, att.ID, strings.ToLower(filepath.Ext(att.Filename)))
+			if err := fsys.WriteFile(filepath.Join(dir, "figures", figName), full.Data); err != nil {
+				return "", err
+			}
+
+			b.WriteString("\\begin{figure}[htbp]\n")
+			b.WriteString("\\centering\n")
+			b.WriteString("\\includegraphics[width=0.8\\linewidth,height=0.4\\textheight,keepaspectratio]{" + figName + "}\n")
+			b.WriteString("\\caption*{" + latexEscaper.Replace(att.Filename) + "}\n")
+			b.WriteString("\\end{figure}\n\n")
+		}
+		if len(others) > 0 {
+			b.WriteString("\\noindent\\textit{Attached: " + strings.Join(others, ", ") + "}\n\n")
+		}
+	}
+
+	return b.String(), nil
+}
+
+// latexParagraphs splits content into paragraphs at runs of blank lines,
+// returning the lines of each. Lines holding only spaces count as blank,
+// as LaTeX fails on a line break with no line to end.
+func latexParagraphs(content string) [][]string {
+	var paragraphs [][]string
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line != "" {
+			lines = append(lines, line)
+			continue
+		}
+		if len(lines) > 0 {
+			paragraphs = append(paragraphs, lines)
+			lines = nil
+		}
+	}
+	if len(lines) > 0 {
+		paragraphs = append(paragraphs, lines)
+	}
+	return paragraphs
+}
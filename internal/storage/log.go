@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"log/slog"
+	"time"
+)
+
+// logger records storage operations for debugging. It is silent unless
+// SetLogger is called. Only operation names, paths, sizes, timings, and
+// errors are logged, never entry content or passwords.
+var logger = slog.New(slog.DiscardHandler)
+
+// SetLogger sets where storage operations are logged; nil disables logging
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.DiscardHandler)
+	}
+	logger = l
+}
+
+// trackOp logs the start of an operation and returns a function that
+// classifies its error (see classifyError) and logs its outcome and
+// duration. Use it with a named error result:
+//
+//	defer trackOp("SaveEntry", path)(&err)
+func trackOp(op, path string, attrs ...any) func(*error) {
+	start := time.Now()
+	logger.Debug("start", append([]any{"op", op, "path", path}, attrs...)...)
+	return func(errp *error) {
+		args := append([]any{"op", op, "path", path, "duration", time.Since(start)}, attrs...)
+		if errp != nil && *errp != nil {
+			*errp = classifyError(*errp, path)
+			logger.Error("failed", append(args, "error", (*errp).Error())...)
+			return
+		}
+		logger.Info("done", args...)
+	}
+}
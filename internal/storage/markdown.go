@@ -0,0 +1,662 @@
+package storage
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"journal/internal/clock"
+	"journal/internal/model"
+)
+
+// A Markdown journal is a folder with one YYYY-MM-DD.md file per entry, so
+// it can be read, searched, and edited with other tools. Tags, mood, and
+// timestamps go in a front matter block at the top of each file. History,
+// attachments, and a copy of every entry for listing and full-text search
+// live in an index database in the same folder. Before the index is read,
+// files changed outside the app are read back into it; an edited file
+// keeps its previous content as a history record, like a save in the app.
+
+// MarkdownIndexName is the index database inside a Markdown journal's folder
+const MarkdownIndexName = ".journal.db"
+
+// markdownExt is the extension of entry files
+const markdownExt = ".md"
+
+// markdownStore is a folder of Markdown entry files with an index database
+type markdownStore struct {
+	dir   string
+	index sqliteStore
+	clock clock.Clock
+	ids   clock.IDGenerator
+}
+
+// NewMarkdownStore returns the store of the Markdown journal in dir. New
+// entries found in the folder get times from clk and IDs from ids.
+func NewMarkdownStore(dir string, clk clock.Clock, ids clock.IDGenerator) Store {
+	return markdownStore{
+		dir:   dir,
+		index: sqliteStore{path: filepath.Join(dir, MarkdownIndexName)},
+		clock: clk,
+		ids:   ids,
+	}
+}
+
+// CreateMarkdownJournal creates the folder and index of a Markdown
+// journal. Entry files already in the folder are picked up when it is
+// first read.
+func CreateMarkdownJournal(dir string) (err error) {
+	defer trackOp("CreateMarkdownJournal", dir)(&err)
+
+	db, err := openDB(filepath.Join(dir, MarkdownIndexName))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := initSchema(db); err != nil {
+		return err
+	}
+	return initMarkdownSchema(db)
+}
+
+// initMarkdownSchema creates the table recording which file holds each
+// entry, and its size and modification time when last read or written
+func initMarkdownSchema(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS markdown_files (
+			name TEXT PRIMARY KEY,
+			entry_id TEXT NOT NULL,
+			mod_time INTEGER NOT NULL,
+			size INTEGER NOT NULL
+		)
+	`)
+	return err
+}
+
+type markdownFile struct {
+	entryID string
+	modTime int64
+	size    int64
+}
+
+func (s markdownStore) Path() string { return s.dir }
+
+func (s markdownStore) Load() (*model.Journal, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	return s.index.Load()
+}
+
+func (s markdownStore) Save(journal *model.Journal) error {
+	return s.SaveEntries(journal.Entries)
+}
+
+func (s markdownStore) CountEntries(filter model.EntryFilter) (int, error) {
+	if err := s.sync(); err != nil {
+		return 0, err
+	}
+	return s.index.CountEntries(filter)
+}
+
+func (s markdownStore) ListEntries(offset, limit int, filter model.EntryFilter) ([]model.EntrySummary, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	return s.index.ListEntries(offset, limit, filter)
+}
+
+func (s markdownStore) GetEntry(entryID string) (*model.Entry, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	return s.index.GetEntry(entryID)
+}
+
+func (s markdownStore) GetEntryContent(entryID string) (*model.Entry, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	return s.index.GetEntryContent(entryID)
+}
+
+func (s markdownStore) FindEntryByDate(date string) (string, error) {
+	if err := s.sync(); err != nil {
+		return "", err
+	}
+	return s.index.FindEntryByDate(date)
+}
+
+func (s markdownStore) ListTasks(openOnly bool) ([]model.Task, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	return s.index.ListTasks(openOnly)
+}
+
+func (s markdownStore) ListLoops(openOnly bool) ([]model.Loop, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	return s.index.ListLoops(openOnly)
+}
+
+func (s markdownStore) ListTags() ([]model.TagCount, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	return s.index.ListTags()
+}
+
+func (s markdownStore) EntryUpdateTimes() (map[string]time.Time, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	return s.index.EntryUpdateTimes()
+}
+
+func (s markdownStore) SimilarEntries(entryID, content string) ([]model.EntrySummary, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	return s.index.SimilarEntries(entryID, content)
+}
+
+func (s markdownStore) SearchEntries(terms []string, history bool) ([]string, error) {
+	if err := s.sync(); err != nil {
+		return nil, err
+	}
+	return s.index.SearchEntries(terms, history)
+}
+
+func (s markdownStore) EntryPosition(entryID string, filter model.EntryFilter) (int, error) {
+	if err := s.sync(); err != nil {
+		return 0, err
+	}
+	return s.index.EntryPosition(entryID, filter)
+}
+
+// SaveEntries writes the entry files, removing the old file of an entry
+// whose date changed, then updates the index
+func (s markdownStore) SaveEntries(entries []model.Entry) (err error) {
+	defer trackOp("SaveMarkdownEntries", s.dir, "entries", len(entries))(&err)
+
+	// Entry files are named after their dates, so a date that isn't one
+	// could name a file outside the journal's folder
+	for _, entry := range entries {
+		if _, ok := markdownEntryDate(entry.Date + markdownExt); !ok {
+			return fmt.Errorf("invalid entry date %q", entry.Date)
+		}
+	}
+
+	if err := s.sync(); err != nil {
+		return err
+	}
+	return s.withIndex(func(db *sql.DB, dir string) error {
+		files, err := loadMarkdownFiles(db)
+		if err != nil {
+			return err
+		}
+
+		written := make(map[string]markdownFile)
+		var removed []string
+		for i := range entries {
+			entry := &entries[i]
+			name := entry.Date + markdownExt
+			for oldName, f := range files {
+				if f.entryID == entry.ID && oldName != name {
+					if err := os.Remove(filepath.Join(dir, oldName)); err != nil && !os.IsNotExist(err) {
+						return err
+					}
+					removed = append(removed, oldName)
+				}
+			}
+			f, err := writeMarkdownEntry(filepath.Join(dir, name), entry)
+			if err != nil {
+				return err
+			}
+			written[name] = f
+		}
+
+		if err := saveEntriesDB(db, entries); err != nil {
+			return err
+		}
+		return updateMarkdownFiles(db, written, removed)
+	})
+}
+
+func (s markdownStore) DeleteEntry(entryID string) (err error) {
+	defer trackOp("DeleteMarkdownEntry", s.dir)(&err)
+
+	if err := s.sync(); err != nil {
+		return err
+	}
+	return s.withIndex(func(db *sql.DB, dir string) error {
+		files, err := loadMarkdownFiles(db)
+		if err != nil {
+			return err
+		}
+		var removed []string
+		for name, f := range files {
+			if f.entryID != entryID {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			removed = append(removed, name)
+		}
+		if err := deleteEntryDB(db, entryID); err != nil {
+			return err
+		}
+		return updateMarkdownFiles(db, nil, removed)
+	})
+}
+
+// History and attachments are only kept in the index
+
+func (s markdownStore) AddHistoryRecord(entryID string, record model.SaveRecord) error {
+	return s.index.AddHistoryRecord(entryID, record)
+}
+
+func (s markdownStore) UpdateHistoryLabel(entryID string, savedAt time.Time, label string) error {
+	return s.index.UpdateHistoryLabel(entryID, savedAt, label)
+}
+
+// Verify isn't supported: Markdown journals are plain files meant to be
+// edited outside the app, so changes to them aren't corruption
+func (s markdownStore) Verify() ([]IntegrityProblem, error) {
+	return nil, errors.New("Markdown journals are plain files that can be edited outside the app, so they have no checksums to verify")
+}
+
+func (s markdownStore) AddAttachment(attachment *model.Attachment) error {
+	return s.index.AddAttachment(attachment)
+}
+
+func (s markdownStore) GetAttachment(attachmentID string) (*model.Attachment, error) {
+	return s.index.GetAttachment(attachmentID)
+}
+
+func (s markdownStore) DeleteAttachment(attachmentID string) error {
+	return s.index.DeleteAttachment(attachmentID)
+}
+
+func (s markdownStore) ExportAttachment(attachmentID, destPath string) error {
+	return s.index.ExportAttachment(attachmentID, destPath)
+}
+
+func (s markdownStore) TrashedAttachments(entryID string) ([]model.Attachment, error) {
+	return s.index.TrashedAttachments(entryID)
+}
+
+func (s markdownStore) RestoreAttachment(attachmentID string) error {
+	return s.index.RestoreAttachment(attachmentID)
+}
+
+// withIndex runs fn with the index database open and the expanded folder
+func (s markdownStore) withIndex(fn func(db *sql.DB, dir string) error) error {
+	dir, err := ExpandPath(s.dir)
+	if err != nil {
+		return err
+	}
+	db, err := openDB(s.index.path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := initSchema(db); err != nil {
+		return err
+	}
+	if err := initMarkdownSchema(db); err != nil {
+		return err
+	}
+	return fn(db, dir)
+}
+
+// sync reads entry files added, changed, or removed outside the app into
+// the index
+func (s markdownStore) sync() error {
+	dir, err := ExpandPath(s.dir)
+	if err != nil {
+		return err
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	return s.withIndex(func(db *sql.DB, dir string) error {
+		files, err := loadMarkdownFiles(db)
+		if err != nil {
+			return err
+		}
+
+		changed := make(map[string]markdownFile)
+		seenIDs := make(map[string]bool)
+		var entries []model.Entry
+		for _, de := range dirEntries {
+			name := de.Name()
+			date, ok := markdownEntryDate(name)
+			if !ok || !de.Type().IsRegular() {
+				continue
+			}
+			info, err := de.Info()
+			if err != nil {
+				return err
+			}
+			known, ok := files[name]
+			if ok && known.modTime == info.ModTime().UnixNano() && known.size == info.Size() {
+				seenIDs[known.entryID] = true
+				continue
+			}
+
+			entry, err := s.readChangedEntry(db, filepath.Join(dir, name), date, info.ModTime(), known.entryID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			seenIDs[entry.ID] = true
+			entries = append(entries, *entry)
+			changed[name] = markdownFile{entryID: entry.ID, modTime: info.ModTime().UnixNano(), size: info.Size()}
+		}
+
+		var removed []string
+		for name, f := range files {
+			if _, ok := changed[name]; ok {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				continue
+			}
+			removed = append(removed, name)
+			// A file renamed to another date keeps its entry
+			if !seenIDs[f.entryID] {
+				if err := deleteEntryDB(db, f.entryID); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(entries) == 0 && len(removed) == 0 {
+			return nil
+		}
+		if len(entries) > 0 {
+			if err := saveEntriesDB(db, entries); err != nil {
+				return err
+			}
+		}
+		return updateMarkdownFiles(db, changed, removed)
+	})
+}
+
+// readChangedEntry reads an entry file that is new or changed since the
+// index last saw it. knownID is the entry the file held before, if any.
+func (s markdownStore) readChangedEntry(db *sql.DB, path, date string, modTime time.Time, knownID string) (*model.Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := parseMarkdownEntry(string(data))
+	if err != nil {
+		return nil, err
+	}
+	entry.Date = date
+
+	// Files without an id, e.g. written by hand, take over the entry of the
+	// file or date they replace
+	if entry.ID == "" {
+		entry.ID = knownID
+	}
+	if entry.ID == "" {
+		err := db.QueryRow(`SELECT id FROM entries WHERE date = ?`, date).Scan(&entry.ID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	if entry.ID == "" {
+		entry.ID = s.ids.NewID()
+	}
+
+	// Another entry may still hold this date in the index, if its file was
+	// replaced by this one
+	var staleID string
+	err = db.QueryRow(`SELECT id FROM entries WHERE date = ? AND id != ?`, date, entry.ID).Scan(&staleID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if staleID != "" {
+		if err := deleteEntryDB(db, staleID); err != nil {
+			return nil, err
+		}
+	}
+
+	var oldContent, oldTags string
+	var oldCreated, oldUpdated time.Time
+	err = db.QueryRow(`SELECT content, COALESCE(tags, ''), created_at, updated_at FROM entries WHERE id = ?`, entry.ID).
+		Scan(&oldContent, &oldTags, &oldCreated, &oldUpdated)
+	switch {
+	case err == sql.ErrNoRows:
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = modTime
+		}
+		if entry.UpdatedAt.IsZero() {
+			entry.UpdatedAt = modTime
+		}
+	case err != nil:
+		return nil, err
+	default:
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = oldCreated
+		}
+		if oldContent != entry.Content {
+			record := model.SaveRecord{Content: oldContent, SavedAt: oldUpdated}
+			if oldTags != "" {
+				record.Tags = strings.Split(oldTags, "|")
+			}
+			entry.History = []model.SaveRecord{record}
+			entry.UpdatedAt = modTime
+		} else if entry.UpdatedAt.IsZero() {
+			entry.UpdatedAt = oldUpdated
+		}
+	}
+	if entry.UpdatedAt.IsZero() {
+		entry.UpdatedAt = s.clock.Now()
+	}
+	return entry, nil
+}
+
+// markdownEntryDate returns the date of an entry file name, or false for
+// other files
+func markdownEntryDate(name string) (string, bool) {
+	date, ok := strings.CutSuffix(name, markdownExt)
+	if !ok {
+		return "", false
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return "", false
+	}
+	return date, true
+}
+
+func loadMarkdownFiles(db *sql.DB) (map[string]markdownFile, error) {
+	rows, err := db.Query(`SELECT name, entry_id, mod_time, size FROM markdown_files`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make(map[string]markdownFile)
+	for rows.Next() {
+		var name string
+		var f markdownFile
+		if err := rows.Scan(&name, &f.entryID, &f.modTime, &f.size); err != nil {
+			return nil, err
+		}
+		files[name] = f
+	}
+	return files, rows.Err()
+}
+
+func updateMarkdownFiles(db *sql.DB, written map[string]markdownFile, removed []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, name := range removed {
+		if _, err := tx.Exec(`DELETE FROM markdown_files WHERE name = ?`, name); err != nil {
+			return err
+		}
+	}
+	for name, f := range written {
+		_, err := tx.Exec(`INSERT OR REPLACE INTO markdown_files (name, entry_id, mod_time, size) VALUES (?, ?, ?, ?)`,
+			name, f.entryID, f.modTime, f.size)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// writeMarkdownEntry writes an entry file through a temporary file, so an
+// interrupted write never leaves a partial entry, and returns its record
+func writeMarkdownEntry(path string, entry *model.Entry) (markdownFile, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*.tmp")
+	if err != nil {
+		return markdownFile{}, err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.WriteString(formatMarkdownEntry(entry)); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return markdownFile{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return markdownFile{}, err
+	}
+	if err := os.Chmod(tmpPath, filePerm()); err != nil {
+		os.Remove(tmpPath)
+		return markdownFile{}, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return markdownFile{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return markdownFile{}, err
+	}
+	return markdownFile{entryID: entry.ID, modTime: info.ModTime().UnixNano(), size: info.Size()}, nil
+}
+
+// formatMarkdownEntry renders an entry file: front matter, a blank line,
+// then the content followed by one newline
+func formatMarkdownEntry(entry *model.Entry) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "id: %s\n", entry.ID)
+	if len(entry.Tags) > 0 {
+		fmt.Fprintf(&b, "tags: %s\n", formatFrontMatterTags(entry.Tags))
+	}
+	if entry.Mood != "" {
+		fmt.Fprintf(&b, "mood: %s\n", entry.Mood)
+	}
+	if entry.WritingTime > 0 {
+		fmt.Fprintf(&b, "writing_time: %s\n", entry.WritingTime)
+	}
+	if typing := entry.Typing.String(); typing != "" {
+		fmt.Fprintf(&b, "typing: %s\n", typing)
+	}
+	fmt.Fprintf(&b, "created: %s\n", entry.CreatedAt.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "updated: %s\n", entry.UpdatedAt.Format(time.RFC3339Nano))
+	b.WriteString("---\n\n")
+	b.WriteString(entry.Content)
+	b.WriteString("\n")
+	return b.String()
+}
+
+// parseMarkdownEntry reads an entry file. The front matter is optional, so
+// plain Markdown files written elsewhere become entries too.
+func parseMarkdownEntry(text string) (*model.Entry, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	entry := &model.Entry{}
+
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		front, body, ok := strings.Cut(rest, "\n---\n")
+		if !ok {
+			return nil, errors.New("front matter is not closed with ---")
+		}
+		scanner := bufio.NewScanner(strings.NewReader(front))
+		for scanner.Scan() {
+			key, value, ok := strings.Cut(scanner.Text(), ":")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(key) {
+			case "id":
+				entry.ID = value
+			case "tags":
+				entry.Tags = parseFrontMatterTags(value)
+			case "mood":
+				entry.Mood = value
+			case "writing_time":
+				entry.WritingTime, _ = time.ParseDuration(value)
+			case "typing":
+				entry.Typing = model.ParseTyping(value)
+			case "created":
+				entry.CreatedAt, _ = time.Parse(time.RFC3339Nano, value)
+			case "updated":
+				entry.UpdatedAt, _ = time.Parse(time.RFC3339Nano, value)
+			}
+		}
+		text = strings.TrimPrefix(body, "\n")
+	}
+
+	entry.Content = strings.TrimSuffix(text, "\n")
+	return entry, nil
+}
+
+// formatFrontMatterTags writes tags separated by commas, quoting those
+// that hold a comma or quote or start or end with a space, so they read
+// back as they were
+func formatFrontMatterTags(tags []string) string {
+	written := make([]string, len(tags))
+	for i, tag := range tags {
+		if strings.ContainsAny(tag, `,"`) || tag != strings.TrimSpace(tag) {
+			tag = strconv.Quote(tag)
+		}
+		written[i] = tag
+	}
+	return strings.Join(written, ", ")
+}
+
+// parseFrontMatterTags reads tags written by formatFrontMatterTags, or
+// written by hand separated by commas
+func parseFrontMatterTags(value string) []string {
+	var tags []string
+	for value = strings.TrimSpace(value); value != ""; value = strings.TrimSpace(value) {
+		var tag string
+		if quoted, err := strconv.QuotedPrefix(value); err == nil {
+			tag, _ = strconv.Unquote(quoted)
+			_, value, _ = strings.Cut(value[len(quoted):], ",")
+		} else {
+			tag, value, _ = strings.Cut(value, ",")
+			tag = strings.TrimSpace(tag)
+		}
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,387 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"modernc.org/sqlite"
+	"modernc.org/sqlite/vfs"
+)
+
+// Encrypted journals are decrypted into in-memory SQLite databases, so
+// their plaintext never reaches the disk. SQLite reads the decrypted bytes
+// through a read-only file system and copies them into a ":memory:"
+// database with the backup API. For files in the current format the bytes
+// come straight from the decrypting stream, so only the database itself is
+// held; older formats are decrypted into a buffer first. Writes serialize
+// the database, a second full copy while the write runs, and encrypt it
+// into the journal file a chunk at a time.
+
+// openMemoryDB returns a new, empty in-memory database
+func openMemoryDB() (*sql.DB, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	// Each connection to :memory: is a separate database, so the database
+	// lives in the one connection it is opened in
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	return db, nil
+}
+
+// decryptToMemory decrypts an encrypted journal file into a new in-memory
+// database. The caller closes it.
+func decryptToMemory(expandedPath, password string) (*sql.DB, error) {
+	in, err := os.Open(expandedPath)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	start := time.Now()
+	db, err := openMemoryDB()
+	if err != nil {
+		return nil, err
+	}
+	if size, ok := streamedPlainSize(in); ok {
+		err = restoreStreamed(db, in, expandedPath, password, size)
+		if errors.Is(err, errBackwardSeek) {
+			// SQLite read the file out of order; decrypt it again into a buffer
+			logger.Debug("reading the decrypted journal out of order, buffering it", "path", expandedPath)
+			db.Close()
+			if db, err = openMemoryDB(); err != nil {
+				return nil, err
+			}
+			if _, err = in.Seek(0, io.SeekStart); err == nil {
+				err = restoreBuffered(db, in, expandedPath, password)
+			}
+		}
+	} else {
+		err = restoreBuffered(db, in, expandedPath, password)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	logger.Debug("decrypted journal into memory", "path", expandedPath, "duration", time.Since(start))
+	return db, nil
+}
+
+// restoreBuffered decrypts the encrypted journal read from in into a
+// buffer, then copies it into the in-memory database db
+func restoreBuffered(db *sql.DB, in io.Reader, expandedPath, password string) error {
+	var plain bytes.Buffer
+	if err := decryptStream(&plain, in, expandedPath, password); err != nil {
+		return err
+	}
+	if plain.Len() == 0 {
+		return nil
+	}
+	return restoreImage(db, memoryFile(plain.Bytes()))
+}
+
+// restoreStreamed copies the encrypted journal read from in into the
+// in-memory database db as it is decrypted. size is the size of the
+// plaintext.
+func restoreStreamed(db *sql.DB, in io.Reader, expandedPath, password string, size int64) error {
+	if size == 0 {
+		return decryptStream(io.Discard, in, expandedPath, password)
+	}
+
+	pr, pw := io.Pipe()
+	decrypted := make(chan error, 1)
+	go func() {
+		err := decryptStream(pw, in, expandedPath, password)
+		pw.CloseWithError(err)
+		decrypted <- err
+	}()
+
+	f := &streamedFile{r: pr, size: size}
+	err := restoreImage(db, streamedFS{f})
+	// Decrypt the rest, so every chunk is authenticated and a damaged file
+	// is reported as such rather than as a damaged database
+	io.Copy(io.Discard, pr)
+	if decryptErr := <-decrypted; decryptErr != nil {
+		return decryptErr
+	}
+	if f.err != nil {
+		return f.err
+	}
+	return err
+}
+
+// streamedPlainSize returns the size of the plaintext of an encrypted
+// journal file in the current format, worked out from the size of the
+// file, or false for other formats
+func streamedPlainSize(in *os.File) (int64, bool) {
+	magic := make([]byte, len(fileMagic))
+	if _, err := in.ReadAt(magic, 0); err != nil || !bytes.Equal(magic, fileMagic) {
+		return 0, false
+	}
+	info, err := in.Stat()
+	if err != nil {
+		return 0, false
+	}
+	body := info.Size() - fileHeaderSize
+	if body < streamTagSize {
+		return 0, false
+	}
+	// Every chunk but the last is full, and each has a tag
+	sealed := int64(streamChunkSize + streamTagSize)
+	chunks := (body + sealed - 1) / sealed
+	return body - chunks*streamTagSize, true
+}
+
+// restoreImage copies the database in fsys, a SQLite file named
+// memoryFileName, into the in-memory database db
+func restoreImage(db *sql.DB, fsys fs.FS) error {
+	name, vfsys, err := vfs.New(fsys)
+	if err != nil {
+		return err
+	}
+	defer vfsys.Close()
+
+	return withMemoryConn(db, func(c memoryConn) error {
+		restore, err := c.NewRestore("file:" + memoryFileName + "?vfs=" + name + "&mode=ro")
+		if err != nil {
+			return err
+		}
+		_, err = restore.Step(-1)
+		if finishErr := restore.Finish(); err == nil {
+			err = finishErr
+		}
+		return err
+	})
+}
+
+// encryptFromMemory encrypts the in-memory database db into the journal
+// file, replacing it atomically
+func encryptFromMemory(db *sql.DB, expandedPath, password string) error {
+	plain, err := serializeMemoryDB(db)
+	if err != nil {
+		return err
+	}
+	tmpPath, err := encryptBeside(bytes.NewReader(plain), expandedPath, password)
+	if err != nil {
+		return err
+	}
+	return replaceJournalFile(tmpPath, expandedPath)
+}
+
+// writeFromMemory writes the in-memory database db as a plaintext SQLite
+// file at expandedPath, replacing it atomically
+func writeFromMemory(db *sql.DB, expandedPath string) error {
+	plain, err := serializeMemoryDB(db)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(expandedPath), ".decrypt-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_, err = tmp.Write(plain)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, filePerm())
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, expandedPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
+// serializeMemoryDB returns the bytes of the SQLite file the in-memory
+// database db would be written as
+func serializeMemoryDB(db *sql.DB) ([]byte, error) {
+	var plain []byte
+	err := withMemoryConn(db, func(c memoryConn) error {
+		var err error
+		plain, err = c.Serialize()
+		return err
+	})
+	return plain, err
+}
+
+// memoryConn is the part of the SQLite driver's connection that copies a
+// database into memory and back out
+type memoryConn interface {
+	NewRestore(srcURI string) (*sqlite.Backup, error)
+	Serialize() ([]byte, error)
+}
+
+// withMemoryConn runs fn with the driver connection of db
+func withMemoryConn(db *sql.DB, fn func(c memoryConn) error) error {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(memoryConn)
+		if !ok {
+			return errors.New("the SQLite driver can't copy databases to memory")
+		}
+		return fn(c)
+	})
+}
+
+// memoryFileName is the name of the database in a memoryFile
+const memoryFileName = "journal.db"
+
+// memoryFile is a file system holding a single SQLite file, for SQLite to
+// read a decrypted database from without it being written out
+type memoryFile []byte
+
+func (f memoryFile) Open(name string) (fs.File, error) {
+	if name != memoryFileName {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return openMemoryFile{bytes.NewReader(f)}, nil
+}
+
+// openMemoryFile is a memoryFile's database opened for reading
+type openMemoryFile struct {
+	*bytes.Reader
+}
+
+func (f openMemoryFile) Stat() (fs.FileInfo, error) { return memoryFileInfo(f.Size()), nil }
+func (f openMemoryFile) Close() error               { return nil }
+
+// memoryFileInfo describes a memoryFile's database of the given size
+type memoryFileInfo int64
+
+func (i memoryFileInfo) Name() string       { return memoryFileName }
+func (i memoryFileInfo) Size() int64        { return int64(i) }
+func (i memoryFileInfo) Mode() fs.FileMode  { return 0400 }
+func (i memoryFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memoryFileInfo) IsDir() bool        { return false }
+func (i memoryFileInfo) Sys() any           { return nil }
+
+// errBackwardSeek is returned by a streamedFile read out of order
+var errBackwardSeek = errors.New("the decrypted journal was read out of order")
+
+// streamedHeadSize is how much of the start of a streamedFile is kept to
+// be read again. It holds the first page of the database, which SQLite
+// reads more than once, at the largest page size.
+const streamedHeadSize = 64 * 1024
+
+// streamedFS is a file system holding a single SQLite file read from a
+// stream, for SQLite to copy a database from as it is decrypted
+type streamedFS struct {
+	f *streamedFile
+}
+
+func (s streamedFS) Open(name string) (fs.File, error) {
+	if name != memoryFileName {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return s.f, nil
+}
+
+// Stat describes the file without opening it, as the stream can only be
+// read once
+func (s streamedFS) Stat(name string) (fs.FileInfo, error) {
+	if name != memoryFileName {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return memoryFileInfo(s.f.size), nil
+}
+
+// streamedFile is a SQLite file read from r, which only goes forward.
+// Reads may skip ahead, or go back to the first streamedHeadSize bytes;
+// going back further fails with errBackwardSeek.
+type streamedFile struct {
+	r    io.Reader
+	size int64
+	head []byte // The first bytes read from r
+	read int64  // How much of r has been read
+	pos  int64  // Where the next Read starts
+	err  error  // errBackwardSeek, once the file was read out of order
+}
+
+func (f *streamedFile) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart || offset < 0 {
+		return 0, errors.New("streamed file: unsupported seek")
+	}
+	if offset < f.read && offset >= int64(len(f.head)) {
+		f.err = errBackwardSeek
+		return 0, f.err
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *streamedFile) Read(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+	p = p[:min(int64(len(p)), f.size-f.pos)]
+
+	n := 0
+	if f.pos < int64(len(f.head)) {
+		n = copy(p, f.head[f.pos:])
+		f.pos += int64(n)
+	}
+	switch {
+	case n == len(p):
+		return n, nil
+	case f.pos < f.read:
+		// The rest was read from the stream past the head
+		f.err = errBackwardSeek
+		return n, f.err
+	case f.pos > f.read:
+		if err := f.skip(f.pos - f.read); err != nil {
+			return n, err
+		}
+	}
+	m, err := f.readStream(p[n:])
+	f.pos += int64(m)
+	return n + m, err
+}
+
+// skip reads and drops the next n bytes of the stream
+func (f *streamedFile) skip(n int64) error {
+	buf := make([]byte, min(n, streamChunkSize))
+	for n > 0 {
+		m, err := f.readStream(buf[:min(n, int64(len(buf)))])
+		n -= int64(m)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readStream fills p from the stream, keeping what falls in the head
+func (f *streamedFile) readStream(p []byte) (int, error) {
+	n, err := io.ReadFull(f.r, p)
+	if keep := min(int64(n), streamedHeadSize-f.read); keep > 0 {
+		f.head = append(f.head, p[:keep]...)
+	}
+	f.read += int64(n)
+	return n, err
+}
+
+func (f *streamedFile) Stat() (fs.FileInfo, error) { return memoryFileInfo(f.size), nil }
+func (f *streamedFile) Close() error               { return nil }
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"journal/internal/model"
+)
+
+// ExpandPath resolves a path as the user typed it. A leading ~ is the home
+// directory, followed by / or, on Windows, \. Environment variables written
+// %NAME%, as Windows shows them (e.g. %USERPROFILE%), are expanded when
+// set. Drive-letter and UNC paths need nothing special since filepath
+// understands them on Windows.
+func ExpandPath(path string) (string, error) {
+	path = expandWindowsEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") || (runtime.GOOS == "windows" && strings.HasPrefix(path, `~\`)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, path[min(len(path), 2):]), nil
+	}
+	return path, nil
+}
+
+// windowsEnvVar matches an environment variable written %NAME%
+var windowsEnvVar = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_()]*)%`)
+
+// expandWindowsEnv expands the %NAME% variables of path that are set,
+// leaving the rest as written
+func expandWindowsEnv(path string) string {
+	if !strings.Contains(path, "%") {
+		return path
+	}
+	return windowsEnvVar.ReplaceAllStringFunc(path, func(v string) string {
+		if value, ok := os.LookupEnv(v[1 : len(v)-1]); ok {
+			return value
+		}
+		return v
+	})
+}
+
+// SamePath reports whether two journal paths name the same file, after
+// expanding them and making them absolute. Windows paths are compared
+// without regard to case, as the filesystem does.
+func SamePath(a, b string) bool {
+	ea, errA := ExpandPath(a)
+	eb, errB := ExpandPath(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	if abs, err := filepath.Abs(ea); err == nil {
+		ea = abs
+	}
+	if abs, err := filepath.Abs(eb); err == nil {
+		eb = abs
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(ea, eb)
+	}
+	return ea == eb
+}
+
+// AbsPath expands path and makes it absolute against the working
+// directory, for paths typed by the user before they are saved in the
+// config file, where relative paths mean the config directory
+func AbsPath(path string) (string, error) {
+	expanded, err := ExpandPath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(expanded)
+}
+
+// Journal paths in the config file may be relative to the config
+// directory, so the directory can be moved or synced between machines
+// without editing them. LoadConfig resolves them, so they are absolute in
+// memory, and SaveConfig writes paths inside the directory relative to it,
+// with / separators so they work on every platform.
+
+// resolveConfigPath returns path joined to configDir when it is relative
+func resolveConfigPath(configDir, path string) string {
+	expanded, err := ExpandPath(path)
+	if path == "" || err != nil || filepath.IsAbs(expanded) {
+		return path
+	}
+	return filepath.Join(configDir, filepath.FromSlash(expanded))
+}
+
+// relativeConfigPath returns path relative to configDir when it is inside
+// it, and unchanged otherwise
+func relativeConfigPath(configDir, path string) string {
+	expanded, err := ExpandPath(path)
+	if err != nil || !filepath.IsAbs(expanded) {
+		return path
+	}
+	rel, err := filepath.Rel(configDir, expanded)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// mapConfigPaths applies fn to the paths of the journals on this machine,
+// including the active and legacy journal paths. PostgreSQL connection
+// strings are left alone.
+func mapConfigPaths(config *model.Config, fn func(string) string) {
+	active := config.ActiveJournal
+	activeLocal := true
+	for i := range config.Journals {
+		j := &config.Journals[i]
+		if j.Format == model.FormatPostgres {
+			activeLocal = activeLocal && j.Path != active
+			continue
+		}
+		j.Path = fn(j.Path)
+	}
+	if activeLocal && active != "" {
+		config.ActiveJournal = fn(active)
+	}
+	if config.DatabasePath != "" {
+		config.DatabasePath = fn(config.DatabasePath)
+	}
+}
+
+// CheckJournalDir checks that the folder a new journal at path would be
+// created in exists and can be written, so setup can say so before the
+// journal is created. The error is of kind ErrPathNotFound when the folder
+// is missing and ErrPermission when files can't be created in it.
+func CheckJournalDir(path string) (err error) {
+	defer trackOp("CheckJournalDir", path)(&err)
+
+	expanded, err := ExpandPath(path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(expanded)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", dir)
+	}
+
+	f, err := os.CreateTemp(dir, ".journal-check-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// CreateJournalDir creates the folder a new journal at path will be
+// created in, with any missing parents
+func CreateJournalDir(path string) (err error) {
+	defer trackOp("CreateJournalDir", path)(&err)
+
+	expanded, err := ExpandPath(path)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(filepath.Dir(expanded), dirPerm())
+}
+
+// CheckJournal checks that the files of journal j can be read, so a
+// journal that was moved or deleted outside the app is reported before it
+// is opened. The error is of kind ErrPathNotFound when the file or folder
+// is missing and ErrPermission when it can't be read. A server journal
+// isn't checked, since that takes a connection.
+func CheckJournal(j *model.JournalDB) (err error) {
+	if j.Format == model.FormatPostgres {
+		return nil
+	}
+	defer trackOp("CheckJournal", j.Path)(&err)
+
+	expanded, err := ExpandPath(j.Path)
+	if err != nil {
+		return err
+	}
+	if j.Format == model.FormatMarkdown {
+		_, err = os.ReadDir(expanded)
+		return err
+	}
+	f, err := os.Open(expanded)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a folder", expanded)
+	}
+	return nil
+}
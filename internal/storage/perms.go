@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// Journals, their backups and exports are private by default: files the
+// app writes are readable by their owner only, and so are the folders it
+// creates for them. The config's file_mode loosens or tightens this, e.g.
+// to share a journal's folder with a group.
+
+// DefaultFileMode is the permissions of the files the app writes, unless
+// the config sets another
+const DefaultFileMode fs.FileMode = 0600
+
+var (
+	fileModeMu sync.Mutex
+	fileMode   = DefaultFileMode
+)
+
+// SetFileMode sets the permissions of the files written from now on, from
+// an octal mode such as "0640". An empty mode restores the default.
+func SetFileMode(mode string) error {
+	m := DefaultFileMode
+	if mode != "" {
+		n, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || n > 0777 {
+			return fmt.Errorf("file mode %q is not an octal permission such as 0600", mode)
+		}
+		m = fs.FileMode(n)
+		if m&0600 != 0600 {
+			return fmt.Errorf("file mode %s must let the owner read and write", mode)
+		}
+	}
+
+	fileModeMu.Lock()
+	fileMode = m
+	fileModeMu.Unlock()
+	return nil
+}
+
+// filePerm returns the permissions of the files the app writes
+func filePerm() fs.FileMode {
+	fileModeMu.Lock()
+	defer fileModeMu.Unlock()
+	return fileMode
+}
+
+// dirPerm returns the permissions of the folders the app creates: the
+// file permissions, with folders searchable by whoever can read the files
+func dirPerm() fs.FileMode {
+	m := filePerm()
+	return m | (m&0444)>>2
+}
+
+// CreateFile creates or truncates the file at path for writing, like
+// os.Create, but with the permissions of the files the app writes. Its
+// folder is created when missing.
+func CreateFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm()); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, filePerm())
+}
+
+// createPrivate creates an empty file at path with the configured
+// permissions when there is none, so the SQLite database opened there next
+// doesn't get the driver's default permissions
+func createPrivate(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, filePerm())
+	if errors.Is(err, os.ErrExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
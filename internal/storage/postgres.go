@@ -0,0 +1,562 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"journal/internal/model"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// PostgreSQL journals live on a server, so several devices can open the
+// same journal. The tables mirror the SQLite schema and the entry queries
+// are shared with it through postgresDialect. The connection string is
+// the journal's path; passwords are best kept in ~/.pgpass or PGPASSWORD
+// rather than in it, since the path is saved in the config file.
+
+// postgresSchema creates the journal tables. There are no foreign keys,
+// matching SQLite, where they aren't enforced: history and attachments
+// may be added before their entry is first saved.
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS entries (
+		id TEXT PRIMARY KEY,
+		date TEXT NOT NULL UNIQUE,
+		content TEXT NOT NULL,
+		tags TEXT DEFAULT '',
+		mood TEXT DEFAULT '',
+		writing_seconds BIGINT DEFAULT 0,
+		typing TEXT DEFAULT '',
+		word_count INTEGER,
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	)`,
+	`ALTER TABLE entries ADD COLUMN IF NOT EXISTS writing_seconds BIGINT DEFAULT 0`,
+	`ALTER TABLE entries ADD COLUMN IF NOT EXISTS content_hash TEXT DEFAULT ''`,
+	`ALTER TABLE entries ADD COLUMN IF NOT EXISTS word_count INTEGER`,
+	`ALTER TABLE entries ADD COLUMN IF NOT EXISTS typing TEXT DEFAULT ''`,
+	`CREATE TABLE IF NOT EXISTS history (
+		id BIGSERIAL PRIMARY KEY,
+		entry_id TEXT NOT NULL,
+		content TEXT NOT NULL,
+		saved_at TIMESTAMPTZ NOT NULL,
+		attachment_names TEXT DEFAULT '',
+		label TEXT DEFAULT '',
+		UNIQUE (entry_id, saved_at)
+	)`,
+	`ALTER TABLE history ADD COLUMN IF NOT EXISTS hash TEXT DEFAULT ''`,
+	`ALTER TABLE history ADD COLUMN IF NOT EXISTS tags TEXT DEFAULT ''`,
+	`CREATE TABLE IF NOT EXISTS attachments (
+		id TEXT PRIMARY KEY,
+		entry_id TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		mime_type TEXT NOT NULL,
+		size BIGINT NOT NULL,
+		data BYTEA NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS entry_tags (
+		entry_id TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (entry_id, tag)
+	)`,
+	`CREATE TABLE IF NOT EXISTS tasks (
+		entry_id TEXT NOT NULL,
+		line INTEGER NOT NULL,
+		text TEXT NOT NULL,
+		done INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (entry_id, line)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_entries_updated ON entries(updated_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_entries_mood ON entries(mood)`,
+	`CREATE INDEX IF NOT EXISTS idx_entries_fts ON entries USING GIN (to_tsvector('simple', content))`,
+	`CREATE INDEX IF NOT EXISTS idx_history_entry ON history(entry_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_attachments_entry ON attachments(entry_id)`,
+	`CREATE TABLE IF NOT EXISTS attachment_trash (
+		id TEXT PRIMARY KEY,
+		entry_id TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		mime_type TEXT NOT NULL,
+		size BIGINT NOT NULL,
+		data BYTEA NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		deleted_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_attachment_trash_entry ON attachment_trash(entry_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_entry_tags_tag ON entry_tags(tag, entry_id)`,
+	`CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER NOT NULL
+	)`,
+}
+
+// postgresMigrations upgrade a journal on a server one version at a time,
+// as schemaMigrations do for SQLite. The number applied is stored in the
+// single row of schema_version, so each runs once per journal.
+var postgresMigrations = []func(tx *sql.Tx) error{
+	recountPostgresWords,
+}
+
+// postgresPools holds one connection pool per connection string, shared
+// by every store of that journal for the life of the process
+var (
+	postgresMu    sync.Mutex
+	postgresPools = make(map[string]*sql.DB)
+)
+
+// postgresDB returns the connection pool for dsn, connecting and creating
+// the schema the first time
+func postgresDB(dsn string) (*sql.DB, error) {
+	postgresMu.Lock()
+	defer postgresMu.Unlock()
+
+	if db, ok := postgresPools[dsn]; ok {
+		return db, nil
+	}
+	db, err := sql.Open(postgresDialect.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range append(postgresSchema, postgresHistoryAppendOnly...) {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if err := fillPostgresTasks(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := sealPostgresEntries(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := fillPostgresWordCounts(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := runPostgresMigrations(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	postgresPools[dsn] = db
+	return db, nil
+}
+
+// fillPostgresTasks fills the tasks table of a journal whose entries were
+// saved before it existed. It is filled in full or not at all, so an empty
+// table means there are no tasks yet, or it has never been filled.
+func fillPostgresTasks(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var filled bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM tasks)`).Scan(&filled); err != nil || filled {
+		return err
+	}
+	if err := fillTasksDialect(tx, postgresDialect); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sealPostgresEntries gives checksums to the entries of a journal saved
+// before they were kept
+func sealPostgresEntries(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := sealUnsealedEntries(tx, postgresDialect); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// fillPostgresWordCounts counts the words of the entries saved before word
+// counts were stored, which have none
+func fillPostgresWordCounts(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fillWordCountsDialect(tx, postgresDialect, `SELECT id, content FROM entries WHERE word_count IS NULL`); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// runPostgresMigrations applies the migrations a journal hasn't had yet,
+// in a single transaction. schema_version is locked meanwhile, so devices
+// connecting at the same time apply them once.
+func runPostgresMigrations(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("upgrading the journal: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`LOCK TABLE schema_version IN EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("upgrading the journal: %w", err)
+	}
+	var version int
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return fmt.Errorf("upgrading the journal: %w", err)
+	}
+	if version >= len(postgresMigrations) {
+		return nil
+	}
+
+	for i, migrate := range postgresMigrations[version:] {
+		if err := migrate(tx); err != nil {
+			return fmt.Errorf("upgrading the journal to version %d: %w", version+i+1, err)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM schema_version`); err != nil {
+		return fmt.Errorf("upgrading the journal: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version) VALUES ($1)`, len(postgresMigrations)); err != nil {
+		return fmt.Errorf("upgrading the journal: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("upgrading the journal: %w", err)
+	}
+	return nil
+}
+
+// recountPostgresWords counts the words of every entry again, now that
+// Chinese and Japanese are counted by character
+func recountPostgresWords(tx *sql.Tx) error {
+	return fillWordCountsDialect(tx, postgresDialect, `SELECT id, content FROM entries`)
+}
+
+// CreatePostgresJournal connects to the server at dsn and creates the
+// journal tables if they don't exist yet
+func CreatePostgresJournal(dsn string) (err error) {
+	defer trackOp("CreatePostgresJournal", RedactDSN(dsn))(&err)
+
+	_, err = postgresDB(dsn)
+	return err
+}
+
+// dsnPassword matches the password of a key/value connection string or of
+// a URL's query
+var dsnPassword = regexp.MustCompile(`password=('(\\.|[^'])*'|[^\s&]*)`)
+
+// RedactDSN returns a PostgreSQL connection string with its password
+// hidden, for display and logs
+func RedactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		dsn = u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "password=xxxxx")
+}
+
+// postgresStore is a journal in a PostgreSQL database
+type postgresStore struct {
+	dsn string
+}
+
+// NewPostgresStore returns the store of the journal in the PostgreSQL
+// database at dsn, a URL or key/value connection string
+func NewPostgresStore(dsn string) Store {
+	return postgresStore{dsn: dsn}
+}
+
+func (s postgresStore) Path() string { return s.dsn }
+
+// track is trackOp with the connection string redacted
+func (s postgresStore) track(op string, attrs ...any) func(*error) {
+	return trackOp(op, RedactDSN(s.dsn), attrs...)
+}
+
+func (s postgresStore) Load() (_ *model.Journal, err error) {
+	defer s.track("LoadJournalPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return loadAllEntries(db, postgresDialect)
+}
+
+func (s postgresStore) Save(journal *model.Journal) error {
+	return s.SaveEntries(journal.Entries)
+}
+
+func (s postgresStore) CountEntries(filter model.EntryFilter) (_ int, err error) {
+	defer s.track("CountEntriesPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return 0, err
+	}
+	return countEntriesDB(db, postgresDialect, filter)
+}
+
+func (s postgresStore) ListEntries(offset, limit int, filter model.EntryFilter) (_ []model.EntrySummary, err error) {
+	defer s.track("ListEntriesPostgres", "offset", offset, "limit", limit)(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return listEntriesDB(db, postgresDialect, offset, limit, filter)
+}
+
+func (s postgresStore) GetEntry(entryID string) (_ *model.Entry, err error) {
+	defer s.track("GetEntryPostgres")(&err)
+	return s.getEntry(entryID, true)
+}
+
+func (s postgresStore) GetEntryContent(entryID string) (_ *model.Entry, err error) {
+	defer s.track("GetEntryContentPostgres")(&err)
+	return s.getEntry(entryID, false)
+}
+
+func (s postgresStore) getEntry(entryID string, history bool) (*model.Entry, error) {
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	var entry model.Entry
+	if err := getEntryDB(db, postgresDialect, entryID, &entry, history); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s postgresStore) FindEntryByDate(date string) (_ string, err error) {
+	defer s.track("FindEntryByDatePostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return "", err
+	}
+	return findEntryByDateDB(db, postgresDialect, date)
+}
+
+func (s postgresStore) ListTasks(openOnly bool) (_ []model.Task, err error) {
+	defer s.track("ListTasksPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return listTasksDB(db, postgresDialect, openOnly)
+}
+
+func (s postgresStore) ListLoops(openOnly bool) (_ []model.Loop, err error) {
+	defer s.track("ListLoopsPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return listLoopsDB(db, postgresDialect, openOnly)
+}
+
+func (s postgresStore) ListTags() (_ []model.TagCount, err error) {
+	defer s.track("ListTagsPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return listTagsDB(db, postgresDialect)
+}
+
+func (s postgresStore) EntryUpdateTimes() (_ map[string]time.Time, err error) {
+	defer s.track("EntryUpdateTimesPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return entryUpdateTimesDB(db, postgresDialect)
+}
+
+func (s postgresStore) SimilarEntries(entryID, content string) (_ []model.EntrySummary, err error) {
+	defer s.track("SimilarEntriesPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return similarEntriesDB(db, postgresDialect, entryID, content)
+}
+
+func (s postgresStore) SearchEntries(terms []string, history bool) (_ []string, err error) {
+	defer s.track("SearchEntriesPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return searchEntriesDB(db, postgresDialect, terms, history)
+}
+
+func (s postgresStore) EntryPosition(entryID string, filter model.EntryFilter) (_ int, err error) {
+	defer s.track("EntryPositionPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return -1, err
+	}
+	return entryPositionDB(db, postgresDialect, entryID, filter)
+}
+
+func (s postgresStore) SaveEntries(entries []model.Entry) (err error) {
+	defer s.track("SaveEntriesPostgres", "entries", len(entries))(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return err
+	}
+	return writeEntries(db, postgresDialect, entries)
+}
+
+func (s postgresStore) DeleteEntry(entryID string) (err error) {
+	defer s.track("DeleteEntryPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The entry goes first, since its history can't be removed while it exists
+	if _, err := tx.Exec(`DELETE FROM entries WHERE id = $1`, entryID); err != nil {
+		return err
+	}
+	for _, table := range []string{"history", "attachments", "attachment_trash", "entry_tags", "tasks"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE entry_id = $1`, entryID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s postgresStore) AddHistoryRecord(entryID string, record model.SaveRecord) (err error) {
+	defer s.track("AddHistoryRecordPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(postgresDialect.rebind(insertHistoryQuery),
+		entryID, record.Content, record.SavedAt, strings.Join(record.Attachments, "|"), record.Label, strings.Join(record.Tags, "|"))
+	if err != nil {
+		return err
+	}
+	return sealEntry(db, postgresDialect, entryID)
+}
+
+func (s postgresStore) UpdateHistoryLabel(entryID string, savedAt time.Time, label string) (err error) {
+	defer s.track("UpdateHistoryLabelPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE history SET label = $1 WHERE entry_id = $2 AND saved_at = $3`, label, entryID, savedAt)
+	return err
+}
+
+func (s postgresStore) Verify() (_ []IntegrityProblem, err error) {
+	defer s.track("VerifyPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return verifyDB(db, postgresDialect)
+}
+
+func (s postgresStore) AddAttachment(attachment *model.Attachment) (err error) {
+	defer s.track("AddAttachmentPostgres", "size", attachment.Size)(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO attachments (id, entry_id, filename, mime_type, size, data, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, attachment.ID, attachment.EntryID, attachment.Filename, attachment.MimeType,
+		attachment.Size, attachment.Data, attachment.CreatedAt)
+	return err
+}
+
+func (s postgresStore) GetAttachment(attachmentID string) (_ *model.Attachment, err error) {
+	defer s.track("GetAttachmentPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	var att model.Attachment
+	err = db.QueryRow(`
+		SELECT id, entry_id, filename, mime_type, size, data, created_at
+		FROM attachments WHERE id = $1
+	`, attachmentID).Scan(&att.ID, &att.EntryID, &att.Filename, &att.MimeType,
+		&att.Size, &att.Data, &att.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &att, nil
+}
+
+func (s postgresStore) DeleteAttachment(attachmentID string) (err error) {
+	defer s.track("DeleteAttachmentPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return err
+	}
+	return trashAttachmentDB(db, postgresDialect, attachmentID, time.Now())
+}
+
+func (s postgresStore) TrashedAttachments(entryID string) (_ []model.Attachment, err error) {
+	defer s.track("TrashedAttachmentsPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return trashedAttachmentsDB(db, postgresDialect, entryID)
+}
+
+func (s postgresStore) RestoreAttachment(attachmentID string) (err error) {
+	defer s.track("RestoreAttachmentPostgres")(&err)
+
+	db, err := postgresDB(s.dsn)
+	if err != nil {
+		return err
+	}
+	return restoreAttachmentDB(db, postgresDialect, attachmentID)
+}
+
+func (s postgresStore) ExportAttachment(attachmentID, destPath string) error {
+	att, err := s.GetAttachment(attachmentID)
+	if err != nil {
+		return err
+	}
+	return writeAttachment(att, destPath)
+}
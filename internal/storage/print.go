@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"encoding/base64"
+	"html"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"journal/internal/model"
+)
+
+const printCSS = `
+@page { size: A4; margin: 2cm; }
+body { font-family: Georgia, "Times New Roman", serif; font-size: 12pt; line-height: 1.5; color: #111; max-width: 42em; margin: 0 auto; padding: 1em; }
+h1 { font-size: 22pt; text-align: center; margin-top: 30%; }
+.index { page-break-after: always; break-after: page; }
+.index h2 { font-size: 16pt; }
+.index ol { columns: 2; list-style: none; padding: 0; }
+.index a { color: inherit; text-decoration: none; }
+.entry { page-break-after: always; break-after: page; }
+.entry:last-child { page-break-after: auto; break-after: auto; }
+.entry h2 { font-size: 16pt; border-bottom: 1px solid #999; padding-bottom: 0.2em; }
+.entry .content p { margin: 0 0 0.8em 0; }
+.entry figure { margin: 1em 0; text-align: center; page-break-inside: avoid; break-inside: avoid; }
+.entry figure img { max-width: 100%; max-height: 12cm; }
+.entry figcaption, .entry .files { font-size: 9pt; color: #555; font-style: italic; }
+`
+
+// ExportPrintHTML writes the journal as a single HTML document laid out for
+// printing: a title page, a date index, and one entry per page (oldest first).
+// Image attachments are read from store and embedded so the file is
+// self-contained.
+func ExportPrintHTML(fsys ExportFS, journal *model.Journal, store Store, destPath, title string) error {
+	expandedDest, err := ExpandPath(destPath)
+	if err != nil {
+		return err
+	}
+	if err := fsys.MkdirAll(filepath.Dir(expandedDest)); err != nil {
+		return err
+	}
+
+	entries := make([]model.Entry, len(journal.Entries))
+	copy(entries, journal.Entries)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Date < entries[j].Date
+	})
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	b.WriteString("<style>" + printCSS + "</style>\n</head>\n<body>\n")
+
+	// Title page and date index
+	b.WriteString("<section class=\"index\">\n<h1>" + html.EscapeString(title) + "</h1>\n")
+	b.WriteString("<h2>Index</h2>\n<ol>\n")
+	for _, e := range entries {
+		b.WriteString("<li><a href=\"#" + html.EscapeString(e.Date) + "\">" + html.EscapeString(printDate(e.Date)) + "</a></li>\n")
+	}
+	b.WriteString("</ol>\n</section>\n")
+
+	for _, e := range entries {
+		b.WriteString("<section class=\"entry\" id=\"" + html.EscapeString(e.Date) + "\">\n")
+		b.WriteString("<h2>" + html.EscapeString(printDate(e.Date)) + "</h2>\n")
+		writeHTMLContent(&b, e.Content)
+		if err := writeHTMLAttachments(&b, store, e); err != nil {
+			return err
+		}
+		b.WriteString("</section>\n")
+	}
+
+	b.WriteString("</body>\n</html>\n")
+
+	return fsys.WriteFile(expandedDest, []byte(b.String()))
+}
+
+// writeHTMLContent writes an entry's content as paragraphs, keeping its
+// line breaks
+func writeHTMLContent(b *strings.Builder, content string) {
+	b.WriteString("<div class=\"content\">\n")
+	for _, para := range strings.Split(strings.TrimSpace(content), "\n\n") {
+		lines := strings.Split(para, "\n")
+		for i, line := range lines {
+			lines[i] = html.EscapeString(line)
+		}
+		b.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+	}
+	b.WriteString("</div>\n")
+}
+
+// writeHTMLAttachments writes the entry's image attachments, read from
+// store, as figures embedded in the page, and lists the names of the rest
+func writeHTMLAttachments(b *strings.Builder, store Store, e model.Entry) error {
+	var others []string
+	for _, att := range e.Attachments {
+		if !strings.HasPrefix(att.MimeType, "image/") {
+			others = append(others, html.EscapeString(att.Filename))
+			continue
+		}
+		full, err := store.GetAttachment(att.ID)
+		if err != nil {
+			return err
+		}
+		b.WriteString("<figure><img src=\"data:" + att.MimeType + ";base64,")
+		b.WriteString(base64.StdEncoding.EncodeToString(full.Data))
+		b.WriteString("\" alt=\"" + html.EscapeString(att.Filename) + "\">")
+		b.WriteString("<figcaption>" + html.EscapeString(att.Filename) + "</figcaption></figure>\n")
+	}
+	if len(others) > 0 {
+		b.WriteString("<p class=\"files\">Attached: " + strings.Join(others, ", ") + "</p>\n")
+	}
+	return nil
+}
+
+// printDate formats an entry date for display, falling back to the raw value
+func printDate(date string) string {
+	if t, err := time.Parse("2006-01-02", date); err == nil {
+		return t.Format("Monday, January 2, 2006")
+	}
+	return date
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// A password-protected HTML page holds the page it protects encrypted
+// with AES-GCM, under a key derived from the password with PBKDF2, and
+// decrypts it in the browser with the Web Crypto API once the password is
+// typed. The recipient needs nothing but a browser. PBKDF2 is used rather
+// than the journal's key derivation because it is the one browsers have.
+
+// protectIterations is the PBKDF2-SHA256 iteration count of protected
+// pages, as recommended by OWASP
+const protectIterations = 600000
+
+// protectScript decrypts the page. The {placeholders} are filled in by
+// protectHTML.
+const protectScript = `
+const sealed = {salt: "{salt}", iv: "{iv}", iterations: {iterations}, data: "{data}"};
+const bytes = s => Uint8Array.from(atob(s), c => c.charCodeAt(0));
+document.getElementById("unlock").addEventListener("submit", async event => {
+  event.preventDefault();
+  const message = document.getElementById("message");
+  message.textContent = "Opening...";
+  try {
+    const password = new TextEncoder().encode(document.getElementById("password").value);
+    const base = await crypto.subtle.importKey("raw", password, "PBKDF2", false, ["deriveKey"]);
+    const key = await crypto.subtle.deriveKey(
+      {name: "PBKDF2", salt: bytes(sealed.salt), iterations: sealed.iterations, hash: "SHA-256"},
+      base, {name: "AES-GCM", length: 256}, false, ["decrypt"]);
+    const page = await crypto.subtle.decrypt({name: "AES-GCM", iv: bytes(sealed.iv)}, key, bytes(sealed.data));
+    document.open();
+    document.write(new TextDecoder().decode(page));
+    document.close();
+  } catch (e) {
+    message.textContent = window.crypto && crypto.subtle ? "Wrong password." : "This browser can't open protected pages.";
+  }
+});
+`
+
+// protectHTML returns a page asking for password that shows page once it
+// is typed, styled by opts
+func protectHTML(page []byte, password string, opts HTMLOptions) ([]byte, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, 12)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, protectIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nil, iv, page, nil)
+
+	script := strings.NewReplacer(
+		"{salt}", base64.StdEncoding.EncodeToString(salt),
+		"{iv}", base64.StdEncoding.EncodeToString(iv),
+		"{iterations}", fmt.Sprint(protectIterations),
+		"{data}", base64.StdEncoding.EncodeToString(sealed),
+	).Replace(protectScript)
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	b.WriteString("<title>" + html.EscapeString(opts.Title) + "</title>\n")
+	b.WriteString("<style>" + htmlStyle(opts) + "input, button { font: inherit; padding: 0.3em 0.6em; }\n</style>\n</head>\n<body>\n")
+	b.WriteString("<h1>" + html.EscapeString(opts.Title) + "</h1>\n")
+	b.WriteString("<form id=\"unlock\">\n<p>This page is protected. Enter the password to read it.</p>\n")
+	b.WriteString("<input type=\"password\" id=\"password\" autofocus> <button type=\"submit\">Open</button>\n")
+	b.WriteString("<p id=\"message\"></p>\n</form>\n")
+	b.WriteString("<script>" + script + "</script>\n</body>\n</html>\n")
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"journal/internal/model"
+)
+
+// TestQueryPlans checks with EXPLAIN QUERY PLAN that filtering and loading
+// entries look rows up through the indexes rather than scanning tables
+func TestQueryPlans(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plans.db")
+	if err := SaveJournal(syntheticJournal(500), path); err != nil {
+		t.Fatal(err)
+	}
+	db, err := openDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := migrateSchema(db); err != nil {
+		t.Fatal(err)
+	}
+
+	filters := []struct {
+		name   string
+		filter model.EntryFilter
+		uses   string
+	}{
+		{"tag", model.EntryFilter{Tag: "coffee"}, "idx_entry_tags_tag"},
+		{"text", model.EntryFilter{Text: "coffee river"}, "SCAN entries_fts VIRTUAL TABLE INDEX"},
+		{"mood", model.EntryFilter{Mood: "happy"}, "idx_entries_mood"},
+		{"dates", model.EntryFilter{Since: "2000-03-01", Until: "2000-03-31"}, "idx_entries_date"},
+	}
+	for _, tc := range filters {
+		t.Run(tc.name, func(t *testing.T) {
+			where, args := filterClause(sqliteDialect, tc.filter)
+			plan := queryPlan(t, db, `SELECT e.id FROM entries e `+where+` ORDER BY e.date DESC`, args...)
+			if !strings.Contains(plan, tc.uses) {
+				t.Errorf("plan doesn't use %s:\n%s", tc.uses, plan)
+			}
+		})
+	}
+
+	details := []struct {
+		name  string
+		query string
+		uses  string
+	}{
+		{"history", `SELECT content FROM history WHERE entry_id = ? ORDER BY entry_id, saved_at DESC`, "idx_history_entry_saved"},
+		{"attachments", `SELECT id FROM attachments WHERE entry_id = ? ORDER BY created_at, id`, "idx_attachments_entry"},
+	}
+	for _, tc := range details {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := queryPlan(t, db, tc.query, "bench-00000001")
+			if !strings.Contains(plan, tc.uses) {
+				t.Errorf("plan doesn't use %s:\n%s", tc.uses, plan)
+			}
+		})
+	}
+}
+
+// queryPlan returns the steps of query's plan, one per line
+func queryPlan(t *testing.T, db *sql.DB, query string, args ...any) string {
+	t.Helper()
+	rows, err := db.Query(`EXPLAIN QUERY PLAN `+query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var steps []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			t.Fatal(err)
+		}
+		steps = append(steps, detail)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(steps, "\n")
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"journal/internal/model"
+)
+
+// WriteRecoverySheet writes a page to print or keep offline for an
+// encrypted journal: where it is, how it is encrypted, its password hint,
+// and a line to write the password on by hand. The password itself is
+// never written. kdf is the key derivation the journal was encrypted with.
+func WriteRecoverySheet(destPath string, journal *model.JournalDB, kdf *model.KDFParams, created time.Time) error {
+	expandedDest, err := ExpandPath(destPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(expandedDest), dirPerm()); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	line := func(label, value string) {
+		fmt.Fprintf(&b, "%-12s %s\n", label+":", value)
+	}
+
+	b.WriteString("Journal recovery sheet\n")
+	b.WriteString("======================\n\n")
+	line("Journal", journal.Name)
+	line("File", journal.Path)
+	line("Created", created.Format("2006-01-02 15:04"))
+	if backupDir, err := GetBackupDir(); err == nil {
+		line("Backups", backupDir+" (encrypted with the same password)")
+	}
+	encryption := "AES-256-GCM"
+	if kdf != nil {
+		encryption += ", key derived with " + describeKDF(resolveKDFParams(kdf))
+	}
+	line("Encryption", encryption)
+	hint := journal.PasswordHint
+	if hint == "" {
+		hint = "(none)"
+	}
+	line("Hint", hint)
+
+	b.WriteString("\nThe journal can't be opened without its password, and there is no way\n")
+	b.WriteString("to recover it. Write the password below by hand, then keep this sheet\n")
+	b.WriteString("somewhere safe and offline, and delete the file once it is printed.\n\n")
+	b.WriteString("Password:    ________________________________________\n\n")
+	b.WriteString("To open the journal on another computer, copy the file (or a backup)\n")
+	b.WriteString("there, run journal, and add it with \"Open an existing journal file or\n")
+	b.WriteString("folder\" in the setup, then enter the password.\n")
+
+	// Readable only by its owner, as it names the journal and its hint
+	return os.WriteFile(expandedDest, []byte(b.String()), filePerm())
+}
+
+// describeKDF describes key derivation parameters, e.g. "argon2id (64 MiB,
+// iterations 3, parallelism 4)"
+func describeKDF(p model.KDFParams) string {
+	if p.Algorithm == KDFScrypt {
+		return fmt.Sprintf("scrypt (%d MiB, parallelization %d)", p.MemoryKiB/1024, p.Parallelization)
+	}
+	return fmt.Sprintf("%s (%d MiB, iterations %d, parallelism %d)", p.Algorithm, p.MemoryKiB/1024, p.Iterations, p.Threads)
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"sync"
+	"time"
+)
+
+// An encrypted journal in session mode (model.EncryptionSession) is
+// decrypted into an in-memory database the first time it is read, and
+// later operations run against that copy instead of decrypting the file
+// again each time. Writes still encrypt the whole copy back to the file.
+// The copy is read again when the file changes on disk, e.g. when a sync
+// client replaces it, and dropped when the journal is closed.
+
+// journalSession is the decrypted copy of a journal in session mode
+type journalSession struct {
+	mu       sync.Mutex
+	password string
+	db       *sql.DB // nil until the journal is read, and after a failure
+	size     int64   // Of the journal file when it was last read or written
+	modTime  time.Time
+}
+
+var (
+	sessionsMu sync.Mutex
+	sessions   = map[string]*journalSession{} // By expanded journal path
+)
+
+// setSessionMode turns session mode on or off for the encrypted journal at
+// path. A session with another password is dropped.
+func setSessionMode(path, password string, on bool) {
+	expandedPath, err := ExpandPath(path)
+	if err != nil {
+		return
+	}
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	s := sessions[expandedPath]
+	if on && s != nil && s.password == password {
+		return
+	}
+	if s != nil {
+		s.close()
+		delete(sessions, expandedPath)
+	}
+	if on {
+		sessions[expandedPath] = &journalSession{password: password}
+	}
+}
+
+// CloseSessions drops the decrypted copies of the journals in session
+// mode, for when they are closed
+func CloseSessions() {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	for path, s := range sessions {
+		s.close()
+		delete(sessions, path)
+	}
+}
+
+// lookupSession returns the session of the journal, or nil when it isn't
+// in session mode with password
+func lookupSession(expandedPath, password string) *journalSession {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	s := sessions[expandedPath]
+	if s == nil || s.password != password {
+		return nil
+	}
+	return s
+}
+
+// run runs fn against the session's copy of the journal, reading the file
+// first if needed, and encrypts the copy back to the file unless fn
+// returns errSkipWrite
+func (s *journalSession) run(expandedPath string, fn func(db *sql.DB) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(expandedPath)
+	if err != nil {
+		return err
+	}
+	if s.db != nil && (info.Size() != s.size || !info.ModTime().Equal(s.modTime)) {
+		logger.Debug("journal changed on disk, reading it again", "path", expandedPath)
+		s.close()
+	}
+	if s.db == nil {
+		if err := s.read(expandedPath, info); err != nil {
+			return err
+		}
+	}
+
+	err = fn(s.db)
+	if err == errSkipWrite {
+		return err
+	}
+	if err != nil {
+		// fn may have left part of its changes in the copy
+		s.close()
+		return err
+	}
+	if err := s.write(expandedPath); err != nil {
+		s.close()
+		return err
+	}
+	return nil
+}
+
+// read decrypts the journal file into a new in-memory database
+func (s *journalSession) read(expandedPath string, info os.FileInfo) error {
+	db, err := decryptToMemory(expandedPath, s.password)
+	if err != nil {
+		return err
+	}
+	s.db = db
+	s.size = info.Size()
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// write encrypts the copy back to the journal file
+func (s *journalSession) write(expandedPath string) error {
+	if err := encryptFromMemory(s.db, expandedPath, s.password); err != nil {
+		return err
+	}
+	info, err := os.Stat(expandedPath)
+	if err != nil {
+		return err
+	}
+	s.size = info.Size()
+	s.modTime = info.ModTime()
+	return nil
+}
+
+func (s *journalSession) close() {
+	if s.db != nil {
+		s.db.Close()
+		s.db = nil
+	}
+}
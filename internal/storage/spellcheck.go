@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// spellCheckTimeout is how long checking an entry's spelling may take
+const spellCheckTimeout = 30 * time.Second
+
+// ErrNoSpellChecker is returned by SpellCheck when neither hunspell nor
+// aspell is installed
+var ErrNoSpellChecker = errors.New("checking spelling needs hunspell or aspell installed")
+
+// spellCheckers are the spellcheckers tried, in order, with the arguments
+// that make them list the words of standard input that dictionary
+// doesn't know, one a line
+var spellCheckers = []struct {
+	name string
+	args func(dictionary string) []string
+}{
+	{"hunspell", func(dictionary string) []string { return []string{"-i", "utf-8", "-d", dictionary, "-l"} }},
+	{"aspell", func(dictionary string) []string { return []string{"--encoding=utf-8", "-d", dictionary, "list"} }},
+}
+
+// SpellCheck returns the words of text that dictionary, such as "en_US",
+// doesn't know, each once in the order they first appear. It runs
+// hunspell, or aspell when hunspell isn't installed.
+func SpellCheck(text, dictionary string) (_ []string, err error) {
+	defer trackOp("SpellCheck", dictionary)(&err)
+
+	for _, checker := range spellCheckers {
+		path, err := exec.LookPath(checker.name)
+		if err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), spellCheckTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, path, checker.args(dictionary)...)
+		cmd.Stdin = strings.NewReader(text)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("checking spelling took longer than %s", spellCheckTimeout)
+		}
+		if err != nil {
+			// Such as the dictionary not being installed
+			if reason := strings.TrimSpace(stderr.String()); reason != "" {
+				return nil, fmt.Errorf("%s: %s", checker.name, strings.Split(reason, "\n")[0])
+			}
+			return nil, fmt.Errorf("running %s: %w", checker.name, err)
+		}
+
+		var words []string
+		seen := make(map[string]bool)
+		for _, word := range strings.Fields(string(out)) {
+			if !seen[word] {
+				seen[word] = true
+				words = append(words, word)
+			}
+		}
+		return words, nil
+	}
+	return nil, ErrNoSpellChecker
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"database/sql"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"journal/internal/model"
+)
+
+// journalFiles returns the total size of a journal's files and the
+// modification time of the newest, which tells whether its cached stats
+// are still current. A server journal has neither.
+func journalFiles(j *model.JournalDB) (int64, time.Time, error) {
+	if j.Format == model.FormatPostgres {
+		return 0, time.Time{}, nil
+	}
+	path, err := ExpandPath(j.Path)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if j.Format != model.FormatMarkdown {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+		return info.Size(), info.ModTime(), nil
+	}
+
+	var size int64
+	var newest time.Time
+	err = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			size += info.Size()
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	return size, newest, err
+}
+
+// StatsStale reports whether the cached stats of j need counting again. A
+// server journal has no files to tell by, so its stats are kept until it
+// is counted again on leaving it, rather than contacting the server each
+// time they are shown.
+func StatsStale(j *model.JournalDB) bool {
+	if j.Stats == nil {
+		return true
+	}
+	if j.Format == model.FormatPostgres {
+		return false
+	}
+	size, modTime, err := journalFiles(j)
+	if err != nil {
+		return false
+	}
+	return size != j.Stats.Size || !modTime.Equal(j.Stats.ModTime)
+}
+
+// CountStats counts the entries of j, unlocking it with password when it
+// is encrypted, and measures its files
+func CountStats(j *model.JournalDB, password string) (_ model.Stats, err error) {
+	path := j.Path
+	if j.Format == model.FormatPostgres {
+		path = RedactDSN(path)
+	}
+	defer trackOp("CountStats", path)(&err)
+
+	var stats model.Stats
+	if j.Format != model.FormatMarkdown && j.Format != model.FormatPostgres && !j.Encrypted {
+		if stats.Entries, stats.LastEntry, err = countSQLiteReadOnly(j.Path); err != nil {
+			return stats, err
+		}
+		stats.Size, stats.ModTime, err = journalFiles(j)
+		return stats, err
+	}
+	store := OpenStore(j, password)
+	stats.Entries, err = store.CountEntries(model.EntryFilter{})
+	if err != nil {
+		return stats, err
+	}
+	newest, err := store.ListEntries(0, 1, model.EntryFilter{})
+	if err != nil {
+		return stats, err
+	}
+	if len(newest) > 0 {
+		stats.LastEntry = newest[0].Date
+	}
+	// Measured after counting, which brings a Markdown journal's index up
+	// to date and so may write to the folder
+	stats.Size, stats.ModTime, err = journalFiles(j)
+	return stats, err
+}
+
+// sqliteURIEscaper escapes the characters of a path that a SQLite URI
+// filename gives a meaning to
+var sqliteURIEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+// countSQLiteReadOnly counts the entries of a plaintext SQLite journal and
+// finds the date of the newest without writing to it, so a journal that
+// isn't open is neither migrated nor created
+func countSQLiteReadOnly(path string) (count int, lastEntry string, err error) {
+	expandedPath, err := ExpandPath(path)
+	if err != nil {
+		return 0, "", err
+	}
+	db, err := sql.Open("sqlite", "file:"+sqliteURIEscaper.Replace(filepath.ToSlash(expandedPath))+"?mode=ro&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return 0, "", err
+	}
+	defer db.Close()
+	err = db.QueryRow(`SELECT COUNT(*), COALESCE(MAX(date), '') FROM entries`).Scan(&count, &lastEntry)
+	return count, lastEntry, err
+}
+
+// UpdateJournalStats caches the stats of the journal at path in config
+func UpdateJournalStats(config *model.Config, path string, stats model.Stats) {
+	if j := FindJournal(config, path); j != nil {
+		j.Stats = &stats
+	}
+}
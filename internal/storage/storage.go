@@ -1,9 +1,9 @@
 package storage
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/json"
@@ -12,10 +12,12 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"journal/internal/model"
+	"journal/internal/stats"
 
 	_ "modernc.org/sqlite"
 )
@@ -28,18 +30,6 @@ const (
 
 var ErrInvalidPassword = errors.New("invalid password")
 
-// ExpandPath expands ~ to the user's home directory
-func ExpandPath(path string) (string, error) {
-	if strings.HasPrefix(path, "~/") {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return "", err
-		}
-		return filepath.Join(home, path[2:]), nil
-	}
-	return path, nil
-}
-
 // GetConfigPath returns the full path to the config file
 func GetConfigPath() (string, error) {
 	home, err := os.UserHomeDir()
@@ -72,7 +62,9 @@ func ConfigExists() (bool, error) {
 }
 
 // LoadConfig loads the configuration from disk
-func LoadConfig() (*model.Config, error) {
+func LoadConfig() (_ *model.Config, err error) {
+	defer trackOp("LoadConfig", "")(&err)
+
 	configPath, err := GetConfigPath()
 	if err != nil {
 		return nil, err
@@ -88,67 +80,74 @@ func LoadConfig() (*model.Config, error) {
 		return nil, err
 	}
 
+	configDir := filepath.Dir(configPath)
+	mapConfigPaths(&config, func(path string) string {
+		return resolveConfigPath(configDir, path)
+	})
 	return &config, nil
 }
 
 // SaveConfig saves the configuration to disk
-func SaveConfig(config *model.Config) error {
+func SaveConfig(config *model.Config) (err error) {
+	defer trackOp("SaveConfig", "")(&err)
+
 	configPath, err := GetConfigPath()
 	if err != nil {
 		return err
 	}
 
-	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(configPath), dirPerm()); err != nil {
 		return err
 	}
+	unlock, err := lockConfig(configPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Write a copy, leaving the paths in memory absolute
+	saved := *config
+	saved.Journals = slices.Clone(config.Journals)
+	configDir := filepath.Dir(configPath)
+	mapConfigPaths(&saved, func(path string) string {
+		return relativeConfigPath(configDir, path)
+	})
+	mergeSavedConfig(configPath, &saved, config)
 
-	data, err := json.MarshalIndent(config, "", "  ")
+	data, err := json.MarshalIndent(&saved, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(configPath, data, 0644)
+	return writeFileAtomic(configPath, data, filePerm())
 }
 
-// deriveKey derives a 32-byte key from a password using SHA-256
+// deriveKey derives a 32-byte key from a password using SHA-256. Only
+// files written before the KDF header was introduced use it.
 func deriveKey(password string) []byte {
 	hash := sha256.Sum256([]byte(password))
 	return hash[:]
 }
 
-// encrypt encrypts data using AES-GCM
-func encrypt(data []byte, password string) ([]byte, error) {
-	key := deriveKey(password)
-
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
-
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, err
-	}
-
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
-	return ciphertext, nil
-}
-
-// decrypt decrypts data using AES-GCM
-func decrypt(data []byte, password string) ([]byte, error) {
+// decrypt decrypts a file written in the single-block format used before
+// chunked encryption, reading the KDF header if present
+func decrypt(data []byte, expandedPath, password string) ([]byte, error) {
 	key := deriveKey(password)
+	var header *kdfHeader
+	var additionalData []byte
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
+	if hasKDFHeader(data) {
+		h, err := parseKDFHeader(data)
+		if err != nil {
+			return nil, err
+		}
+		header = &h
+		key = headerKey(password, h)
+		additionalData = data[:kdfHeaderSize]
+		data = data[kdfHeaderSize:]
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
@@ -159,14 +158,27 @@ func decrypt(data []byte, password string) ([]byte, error) {
 	}
 
 	nonce, ciphertext := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, ErrInvalidPassword
 	}
 
+	// Re-encrypting with this password keeps the file's salt and parameters
+	if header != nil {
+		rememberHeader(expandedPath, password, *header)
+	}
+
 	return plaintext, nil
 }
 
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 // Database operations
 
 func openDB(path string) (*sql.DB, error) {
@@ -175,11 +187,17 @@ func openDB(path string) (*sql.DB, error) {
 		return nil, err
 	}
 
-	if err := os.MkdirAll(filepath.Dir(expandedPath), 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(expandedPath), dirPerm()); err != nil {
+		return nil, err
+	}
+	if err := createPrivate(expandedPath); err != nil {
 		return nil, err
 	}
 
-	db, err := sql.Open("sqlite", expandedPath)
+	// Wait up to 5 seconds for other connections' writes, such as a
+	// migration run by a background stats count, rather than failing as
+	// locked straight away
+	db, err := sql.Open("sqlite", expandedPath+"?_pragma=busy_timeout(5000)")
 	if err != nil {
 		return nil, err
 	}
@@ -193,6 +211,12 @@ func initSchema(db *sql.DB) error {
 		id TEXT PRIMARY KEY,
 		date TEXT NOT NULL UNIQUE,
 		content TEXT NOT NULL,
+		tags TEXT DEFAULT '',
+		mood TEXT DEFAULT '',
+		writing_seconds INTEGER DEFAULT 0,
+		typing TEXT DEFAULT '',
+		word_count INTEGER DEFAULT 0,
+		content_hash TEXT DEFAULT '',
 		created_at DATETIME NOT NULL,
 		updated_at DATETIME NOT NULL
 	);
@@ -203,6 +227,9 @@ func initSchema(db *sql.DB) error {
 		content TEXT NOT NULL,
 		saved_at DATETIME NOT NULL,
 		attachment_names TEXT DEFAULT '',
+		label TEXT DEFAULT '',
+		hash TEXT DEFAULT '',
+		tags TEXT DEFAULT '',
 		FOREIGN KEY (entry_id) REFERENCES entries(id) ON DELETE CASCADE
 	);
 
@@ -220,21 +247,395 @@ func initSchema(db *sql.DB) error {
 	CREATE INDEX IF NOT EXISTS idx_entries_date ON entries(date);
 	CREATE INDEX IF NOT EXISTS idx_history_entry ON history(entry_id);
 	CREATE INDEX IF NOT EXISTS idx_attachments_entry ON attachments(entry_id);
-	`
+	` + tasksSchema + attachmentTrashSchema
 
 	_, err := db.Exec(schema)
 	if err != nil {
 		return err
 	}
 
+	return migrateSchema(db)
+}
+
+// migrateSchema adds columns introduced after a database was created, then
+// runs the schema migrations. Errors adding columns are ignored since the
+// columns may already exist; a failed migration is returned.
+func migrateSchema(db *sql.DB) error {
 	// Migration: add attachment_names column if it doesn't exist
 	_, _ = db.Exec(`ALTER TABLE history ADD COLUMN attachment_names TEXT DEFAULT ''`)
 
+	// Migration: add history label column if it doesn't exist
+	_, _ = db.Exec(`ALTER TABLE history ADD COLUMN label TEXT DEFAULT ''`)
+
+	// Migration: add tags and mood columns if they don't exist
+	_, _ = db.Exec(`ALTER TABLE entries ADD COLUMN tags TEXT DEFAULT ''`)
+	_, _ = db.Exec(`ALTER TABLE entries ADD COLUMN mood TEXT DEFAULT ''`)
+
+	// Migration: add writing time column if it doesn't exist
+	_, _ = db.Exec(`ALTER TABLE entries ADD COLUMN writing_seconds INTEGER DEFAULT 0`)
+
+	// Migration: add typing counts column if it doesn't exist
+	_, _ = db.Exec(`ALTER TABLE entries ADD COLUMN typing TEXT DEFAULT ''`)
+
+	// Migration: add word count column if it doesn't exist
+	_, _ = db.Exec(`ALTER TABLE entries ADD COLUMN word_count INTEGER DEFAULT 0`)
+
+	// Migration: add checksum columns if they don't exist
+	_, _ = db.Exec(`ALTER TABLE entries ADD COLUMN content_hash TEXT DEFAULT ''`)
+	_, _ = db.Exec(`ALTER TABLE history ADD COLUMN hash TEXT DEFAULT ''`)
+
+	// Migration: add history tags column if it doesn't exist
+	_, _ = db.Exec(`ALTER TABLE history ADD COLUMN tags TEXT DEFAULT ''`)
+
+	// Migration: add the tasks table if it doesn't exist. Saving entries
+	// writes to it, so it's needed before the schema migrations run.
+	_, _ = db.Exec(tasksSchema)
+
+	// Migration: add the attachment trash if it doesn't exist
+	_, _ = db.Exec(attachmentTrashSchema)
+
+	return runSchemaMigrations(db)
+}
+
+// tasksSchema holds the checkbox items of entry content, one row per line,
+// kept in sync by saveEntryTx
+const tasksSchema = `
+	CREATE TABLE IF NOT EXISTS tasks (
+		entry_id TEXT NOT NULL,
+		line INTEGER NOT NULL,
+		text TEXT NOT NULL,
+		done INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (entry_id, line)
+	) WITHOUT ROWID;
+`
+
+// schemaMigrations upgrade a journal database one version at a time. The
+// number applied is stored in the database's user_version, so each runs
+// once per database.
+var schemaMigrations = []func(tx *sql.Tx) error{
+	migrateIndexes,
+	migrateUniqueHistory,
+	fillTasks,
+	sealEntries,
+	protectHistory,
+	fillWordCounts,
+	migrateSearchIndex,
+	recountWords,
+}
+
+// indexSchema holds the secondary indexes used by entry queries. Tags are
+// stored "|"-joined in entries.tags, which an index can't search, so
+// entry_tags holds one row per tag and is kept in sync by saveEntryTx.
+// entries_fts indexes entry content and is kept in sync by triggers.
+const indexSchema = `
+	CREATE INDEX IF NOT EXISTS idx_entries_updated ON entries(updated_at);
+	CREATE INDEX IF NOT EXISTS idx_entries_mood ON entries(mood);
+
+	CREATE TABLE IF NOT EXISTS entry_tags (
+		entry_id TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (entry_id, tag)
+	) WITHOUT ROWID;
+	CREATE INDEX IF NOT EXISTS idx_entry_tags_tag ON entry_tags(tag, entry_id);
+
+	CREATE VIRTUAL TABLE IF NOT EXISTS entries_fts USING fts5(
+		content, content='entries', content_rowid='rowid'
+	);
+	CREATE TRIGGER IF NOT EXISTS entries_fts_insert AFTER INSERT ON entries BEGIN
+		INSERT INTO entries_fts(rowid, content) VALUES (new.rowid, new.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS entries_fts_delete AFTER DELETE ON entries BEGIN
+		INSERT INTO entries_fts(entries_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS entries_fts_update AFTER UPDATE OF content ON entries BEGIN
+		INSERT INTO entries_fts(entries_fts, rowid, content) VALUES ('delete', old.rowid, old.content);
+		INSERT INTO entries_fts(rowid, content) VALUES (new.rowid, new.content);
+	END;
+`
+
+// runSchemaMigrations applies the schema migrations a database hasn't had
+// yet, in a single transaction. When one fails none are applied, and the
+// error is returned.
+func runSchemaMigrations(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return fmt.Errorf("upgrading the journal database: %w", err)
+	}
+	if version >= len(schemaMigrations) {
+		return nil
+	}
+	var tables int
+	db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('entries', 'history')`).Scan(&tables)
+	if tables < 2 {
+		return nil // Not a journal database yet
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("upgrading the journal database: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i, migrate := range schemaMigrations[version:] {
+		if err := migrate(tx); err != nil {
+			return fmt.Errorf("upgrading the journal database to version %d: %w", version+i+1, err)
+		}
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, len(schemaMigrations))); err != nil {
+		return fmt.Errorf("upgrading the journal database: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("upgrading the journal database: %w", err)
+	}
+	return nil
+}
+
+// migrateIndexes creates the secondary indexes and fills them from the
+// entries already in the database
+func migrateIndexes(tx *sql.Tx) error {
+	if _, err := tx.Exec(indexSchema); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO entries_fts(entries_fts) VALUES ('rebuild')`); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT id, tags FROM entries WHERE COALESCE(tags, '') != ''`)
+	if err != nil {
+		return err
+	}
+	tagged := make(map[string][]string)
+	for rows.Next() {
+		var id, tags string
+		if rows.Scan(&id, &tags) == nil {
+			tagged[id] = strings.Split(tags, "|")
+		}
+	}
+	rows.Close()
+
+	w, err := newEntryWriter(tx, sqliteDialect)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for id, tags := range tagged {
+		if err := w.saveTags(id, tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillTasks fills the tasks table from the checkbox items of the entries
+// already stored, which were saved before it existed
+func fillTasks(tx *sql.Tx) error {
+	return fillTasksDialect(tx, sqliteDialect)
+}
+
+func fillTasksDialect(tx *sql.Tx, d dialect) error {
+	rows, err := tx.Query(`SELECT id, date, content FROM entries WHERE content LIKE '%[ ]%' OR content LIKE '%[x]%' OR content LIKE '%[X]%'`)
+	if err != nil {
+		return err
+	}
+	var entries []model.Entry
+	for rows.Next() {
+		var e model.Entry
+		if rows.Scan(&e.ID, &e.Date, &e.Content) == nil {
+			entries = append(entries, e)
+		}
+	}
+	rows.Close()
+
+	w, err := newEntryWriter(tx, d)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	for _, e := range entries {
+		if err := w.saveTasks(&e); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// sealEntries gives checksums to the entries and history saved before
+// they were kept
+func sealEntries(tx *sql.Tx) error {
+	return sealUnsealedEntries(tx, sqliteDialect)
+}
+
+// protectHistory makes history append-only
+func protectHistory(tx *sql.Tx) error {
+	_, err := tx.Exec(historyAppendOnlySchema)
+	return err
+}
+
+// searchIndexSchema holds the indexes behind the search view. They use the
+// trigram tokenizer, so any run of three or more characters can be looked
+// up, as the view's substring matching needs; entries_fts only finds whole
+// words. History is append-only, so its index only follows inserts and the
+// deletes of entries being removed.
+const searchIndexSchema = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS entries_search USING fts5(
+		content, content='entries', content_rowid='rowid', tokenize='trigram'
+	);
+	CREATE TRIGGER IF NOT EXISTS entries_search_insert AFTER INSERT ON entries BEGIN
+		INSERT INTO entries_search(rowid, content) VALUES (new.rowid, new.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS entries_search_delete AFTER DELETE ON entries BEGIN
+		INSERT INTO entries_search(entries_search, rowid, content) VALUES ('delete', old.rowid, old.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS entries_search_update AFTER UPDATE OF content ON entries BEGIN
+		INSERT INTO entries_search(entries_search, rowid, content) VALUES ('delete', old.rowid, old.content);
+		INSERT INTO entries_search(rowid, content) VALUES (new.rowid, new.content);
+	END;
+
+	CREATE VIRTUAL TABLE IF NOT EXISTS history_search USING fts5(
+		content, content='history', content_rowid='id', tokenize='trigram'
+	);
+	CREATE TRIGGER IF NOT EXISTS history_search_insert AFTER INSERT ON history BEGIN
+		INSERT INTO history_search(rowid, content) VALUES (new.id, new.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS history_search_delete AFTER DELETE ON history BEGIN
+		INSERT INTO history_search(history_search, rowid, content) VALUES ('delete', old.id, old.content);
+	END;
+`
+
+// migrateSearchIndex creates the search view's indexes and fills them from
+// the entries and history already in the database
+func migrateSearchIndex(tx *sql.Tx) error {
+	if _, err := tx.Exec(searchIndexSchema); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO entries_search(entries_search) VALUES ('rebuild')`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO history_search(history_search) VALUES ('rebuild')`)
+	return err
+}
+
+// fillWordCounts counts the words of the entries saved before word counts
+// were stored
+func fillWordCounts(tx *sql.Tx) error {
+	return fillWordCountsDialect(tx, sqliteDialect, `SELECT id, content FROM entries`)
+}
+
+// recountWords counts the words of every entry again, now that Chinese and
+// Japanese are counted by character
+func recountWords(tx *sql.Tx) error {
+	return fillWordCounts(tx)
+}
+
+// fillWordCountsDialect stores the word counts of the entries the query
+// selects, as id and content
+func fillWordCountsDialect(tx *sql.Tx, d dialect, query string) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]int)
+	for rows.Next() {
+		var id, content string
+		if err := rows.Scan(&id, &content); err != nil {
+			rows.Close()
+			return err
+		}
+		counts[id] = len(stats.Words(content))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(d.rebind(`UPDATE entries SET word_count = ? WHERE id = ?`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for id, count := range counts {
+		if _, err := stmt.Exec(count, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateUniqueHistory makes (entry_id, saved_at) unique in history, so
+// saving a record twice is a no-op. Duplicates left by earlier versions are
+// removed, keeping the first copy, and different versions saved at the
+// same time are moved apart.
+func migrateUniqueHistory(tx *sql.Tx) error {
+	// Versions stored twice are dropped
+	if _, err := tx.Exec(`
+		DELETE FROM history WHERE id NOT IN (
+			SELECT MIN(id) FROM history GROUP BY entry_id, saved_at, content
+		)
+	`); err != nil {
+		return err
+	}
+
+	// Different versions saved at the same time are kept, each moved a
+	// nanosecond past the one before
+	rows, err := tx.Query(`
+		SELECT h.id, h.entry_id, h.saved_at FROM history h
+		JOIN (SELECT entry_id, saved_at FROM history GROUP BY entry_id, saved_at HAVING COUNT(*) > 1) d
+			ON h.entry_id = d.entry_id AND h.saved_at = d.saved_at
+		ORDER BY h.entry_id, h.saved_at, h.id
+	`)
+	if err != nil {
+		return err
+	}
+	type version struct {
+		id      int64
+		entryID string
+		savedAt time.Time
+	}
+	var colliding []version
+	for rows.Next() {
+		var v version
+		if err := rows.Scan(&v.id, &v.entryID, &v.savedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		colliding = append(colliding, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i, v := range colliding {
+		if i == 0 || colliding[i-1].entryID != v.entryID || !colliding[i-1].savedAt.Equal(v.savedAt) {
+			continue // The first of its time keeps it
+		}
+		savedAt := v.savedAt
+		for {
+			savedAt = savedAt.Add(time.Nanosecond)
+			var taken int
+			if err := tx.QueryRow(`SELECT COUNT(*) FROM history WHERE entry_id = ? AND saved_at = ?`, v.entryID, savedAt).Scan(&taken); err != nil {
+				return err
+			}
+			if taken == 0 {
+				break
+			}
+		}
+		if _, err := tx.Exec(`UPDATE history SET saved_at = ? WHERE id = ?`, savedAt, v.id); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		DROP INDEX IF EXISTS idx_history_entry_saved;
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_history_entry_saved ON history(entry_id, saved_at DESC);
+	`)
+	return err
+}
+
 // LoadJournal loads the journal from a SQLite database
-func LoadJournal(path string) (*model.Journal, error) {
+func LoadJournal(path string) (_ *model.Journal, err error) {
+	defer trackOp("LoadJournal", path)(&err)
+
 	expandedPath, err := ExpandPath(path)
 	if err != nil {
 		return nil, err
@@ -254,57 +655,127 @@ func LoadJournal(path string) (*model.Journal, error) {
 }
 
 func loadJournalFromDB(db *sql.DB) (*model.Journal, error) {
+	// Older databases may be missing newer columns; add them before querying
+	if err := migrateSchema(db); err != nil {
+		return nil, err
+	}
+
+	// A new database has no tables yet
+	var tables int
+	db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'entries'`).Scan(&tables)
+	if tables == 0 {
+		return &model.Journal{Entries: []model.Entry{}}, nil
+	}
+
+	return loadAllEntries(db, sqliteDialect)
+}
+
+// loadAllEntries reads every entry, newest first, with its history and
+// attachment metadata
+func loadAllEntries(db *sql.DB, d dialect) (*model.Journal, error) {
 	journal := &model.Journal{Entries: []model.Entry{}}
 
-	rows, err := db.Query(`SELECT id, date, content, created_at, updated_at FROM entries ORDER BY date DESC`)
+	rows, err := db.Query(`SELECT id, date, content, COALESCE(tags, ''), COALESCE(mood, ''), COALESCE(writing_seconds, 0), COALESCE(typing, ''), created_at, updated_at FROM entries ORDER BY date DESC`)
 	if err != nil {
-		return journal, nil // Table might not exist yet
+		return nil, err
 	}
 	defer rows.Close()
 
+	index := make(map[string]int)
 	for rows.Next() {
 		var entry model.Entry
-		if err := rows.Scan(&entry.ID, &entry.Date, &entry.Content, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
+		var tags string
+		var writingSeconds int64
+		var typing string
+		if err := rows.Scan(&entry.ID, &entry.Date, &entry.Content, &tags, &entry.Mood, &writingSeconds, &typing, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
 			return nil, err
 		}
+		entry.WritingTime = time.Duration(writingSeconds) * time.Second
+		entry.Typing = model.ParseTyping(typing)
+		if tags != "" {
+			entry.Tags = strings.Split(tags, "|")
+		}
 
-		// Load history for this entry
-		historyRows, err := db.Query(`SELECT content, saved_at, COALESCE(attachment_names, '') FROM history WHERE entry_id = ? ORDER BY saved_at DESC`, entry.ID)
+		index[entry.ID] = len(journal.Entries)
+		journal.Entries = append(journal.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// History and attachments are read in one pass each rather than one
+	// query per entry
+	loadDetails(db, d, "", true, func(entryID string) *model.Entry {
+		if i, ok := index[entryID]; ok {
+			return &journal.Entries[i]
+		}
+		return nil
+	})
+
+	return journal, nil
+}
+
+// loadEntryDetails loads the attachment metadata of an entry, and its
+// history when history is set
+func loadEntryDetails(db *sql.DB, d dialect, entry *model.Entry, history bool) {
+	loadDetails(db, d, entry.ID, history, func(entryID string) *model.Entry {
+		return entry
+	})
+}
+
+// loadDetails loads attachment metadata (not data), and history when
+// history is set, for the entry with ID entryID, or for every entry when
+// entryID is empty. find returns the entry a row belongs to, or nil to
+// skip it.
+func loadDetails(db *sql.DB, d dialect, entryID string, history bool, find func(entryID string) *model.Entry) {
+	where := ""
+	var args []any
+	if entryID != "" {
+		where = "WHERE entry_id = ?"
+		args = append(args, entryID)
+	}
+
+	if history {
+		historyRows, err := db.Query(d.rebind(`SELECT entry_id, content, saved_at, COALESCE(attachment_names, ''), COALESCE(label, ''), COALESCE(hash, ''), COALESCE(tags, '') FROM history `+where+` ORDER BY entry_id, saved_at DESC`), args...)
 		if err == nil {
 			for historyRows.Next() {
+				var id string
 				var record model.SaveRecord
-				var attachmentNames string
-				if err := historyRows.Scan(&record.Content, &record.SavedAt, &attachmentNames); err == nil {
+				var attachmentNames, tags string
+				if err := historyRows.Scan(&id, &record.Content, &record.SavedAt, &attachmentNames, &record.Label, &record.Hash, &tags); err == nil {
 					if attachmentNames != "" {
 						record.Attachments = strings.Split(attachmentNames, "|")
 					}
-					entry.History = append(entry.History, record)
+					if tags != "" {
+						record.Tags = strings.Split(tags, "|")
+					}
+					if entry := find(id); entry != nil {
+						entry.History = append(entry.History, record)
+					}
 				}
 			}
 			historyRows.Close()
 		}
+	}
 
-		// Load attachments metadata (not data) for this entry
-		attachRows, err := db.Query(`SELECT id, filename, mime_type, size, created_at FROM attachments WHERE entry_id = ?`, entry.ID)
-		if err == nil {
-			for attachRows.Next() {
-				var att model.Attachment
-				att.EntryID = entry.ID
-				if err := attachRows.Scan(&att.ID, &att.Filename, &att.MimeType, &att.Size, &att.CreatedAt); err == nil {
+	attachRows, err := db.Query(d.rebind(`SELECT id, entry_id, filename, mime_type, size, created_at FROM attachments `+where+` ORDER BY created_at, id`), args...)
+	if err == nil {
+		for attachRows.Next() {
+			var att model.Attachment
+			if err := attachRows.Scan(&att.ID, &att.EntryID, &att.Filename, &att.MimeType, &att.Size, &att.CreatedAt); err == nil {
+				if entry := find(att.EntryID); entry != nil {
 					entry.Attachments = append(entry.Attachments, att)
 				}
 			}
-			attachRows.Close()
 		}
-
-		journal.Entries = append(journal.Entries, entry)
+		attachRows.Close()
 	}
-
-	return journal, nil
 }
 
 // SaveJournal saves the journal to a SQLite database
-func SaveJournal(journal *model.Journal, path string) error {
+func SaveJournal(journal *model.Journal, path string) (err error) {
+	defer trackOp("SaveJournal", path, "entries", len(journal.Entries))(&err)
+
 	db, err := openDB(path)
 	if err != nil {
 		return err
@@ -319,69 +790,217 @@ func SaveJournal(journal *model.Journal, path string) error {
 }
 
 func saveJournalToDB(db *sql.DB, journal *model.Journal) error {
+	return saveEntriesDB(db, journal.Entries)
+}
+
+// saveEntriesDB saves entries in a single transaction
+func saveEntriesDB(db *sql.DB, entries []model.Entry) error {
+	return writeEntries(db, sqliteDialect, entries)
+}
+
+// writeEntries saves entries in a single transaction
+func writeEntries(db *sql.DB, d dialect, entries []model.Entry) error {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	for _, entry := range journal.Entries {
-		_, err := tx.Exec(`
-			INSERT OR REPLACE INTO entries (id, date, content, created_at, updated_at)
-			VALUES (?, ?, ?, ?, ?)
-		`, entry.ID, entry.Date, entry.Content, entry.CreatedAt, entry.UpdatedAt)
+	w, err := newEntryWriter(tx, d)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	for i := range entries {
+		if err := w.save(&entries[i]); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// saveEntryTx upserts an entry and inserts history records not yet stored
+func saveEntryTx(tx *sql.Tx, entry *model.Entry) error {
+	w, err := newEntryWriter(tx, sqliteDialect)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return w.save(entry)
+}
+
+// insertHistoryQuery adds a history record unless one saved at the same
+// time is already stored for the entry
+const insertHistoryQuery = `INSERT INTO history (entry_id, content, saved_at, attachment_names, label, tags) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`
+
+// entryWriter saves entries inside a transaction, preparing each statement
+// once rather than once per entry, tag, or history record
+type entryWriter struct {
+	tx            *sql.Tx
+	d             dialect
+	upsertEntry   *sql.Stmt
+	deleteTags    *sql.Stmt
+	insertTag     *sql.Stmt
+	deleteTasks   *sql.Stmt
+	insertTask    *sql.Stmt
+	insertHistory *sql.Stmt
+}
+
+func newEntryWriter(tx *sql.Tx, d dialect) (*entryWriter, error) {
+	w := &entryWriter{tx: tx, d: d}
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		// An upsert rather than INSERT OR REPLACE, which would delete the
+		// row without firing the full-text index triggers
+		{&w.upsertEntry, `
+			INSERT INTO entries (id, date, content, tags, mood, writing_seconds, typing, word_count, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET
+				date = excluded.date, content = excluded.content, tags = excluded.tags,
+				mood = excluded.mood, writing_seconds = excluded.writing_seconds, typing = excluded.typing, word_count = excluded.word_count,
+				created_at = excluded.created_at, updated_at = excluded.updated_at`},
+		{&w.deleteTags, `DELETE FROM entry_tags WHERE entry_id = ?`},
+		{&w.insertTag, `INSERT INTO entry_tags (entry_id, tag) VALUES (?, ?) ON CONFLICT DO NOTHING`},
+		{&w.deleteTasks, `DELETE FROM tasks WHERE entry_id = ?`},
+		{&w.insertTask, `INSERT INTO tasks (entry_id, line, text, done) VALUES (?, ?, ?, ?)`},
+		// Records already stored are skipped by the unique index on
+		// (entry_id, saved_at)
+		{&w.insertHistory, insertHistoryQuery},
+	}
+	for _, s := range statements {
+		stmt, err := tx.Prepare(d.rebind(s.query))
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		*s.stmt = stmt
+	}
+	return w, nil
+}
+
+func (w *entryWriter) Close() {
+	for _, stmt := range []*sql.Stmt{w.upsertEntry, w.deleteTags, w.insertTag, w.deleteTasks, w.insertTask, w.insertHistory} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+func (w *entryWriter) save(entry *model.Entry) error {
+	_, err := w.upsertEntry.Exec(entry.ID, entry.Date, entry.Content, strings.Join(entry.Tags, "|"), entry.Mood, int64(entry.WritingTime/time.Second), entry.Typing.String(), len(stats.Words(entry.Content)), entry.CreatedAt, entry.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := w.saveTags(entry.ID, entry.Tags); err != nil {
+		return err
+	}
+	if err := w.saveTasks(entry); err != nil {
+		return err
+	}
+
+	// Save history
+	for _, record := range entry.History {
+		attachmentNames := strings.Join(record.Attachments, "|")
+		_, err := w.insertHistory.Exec(entry.ID, record.Content, record.SavedAt, attachmentNames, record.Label, strings.Join(record.Tags, "|"))
 		if err != nil {
 			return err
 		}
+	}
 
-		// Save history
-		for _, record := range entry.History {
-			// Check if this history record already exists
-			var count int
-			tx.QueryRow(`SELECT COUNT(*) FROM history WHERE entry_id = ? AND saved_at = ?`,
-				entry.ID, record.SavedAt).Scan(&count)
-			if count == 0 {
-				attachmentNames := strings.Join(record.Attachments, "|")
-				_, err := tx.Exec(`INSERT INTO history (entry_id, content, saved_at, attachment_names) VALUES (?, ?, ?, ?)`,
-					entry.ID, record.Content, record.SavedAt, attachmentNames)
-				if err != nil {
-					return err
-				}
-			}
+	return sealEntry(w.tx, w.d, entry.ID)
+}
+
+// saveTags replaces the entry_tags rows of an entry
+func (w *entryWriter) saveTags(entryID string, tags []string) error {
+	if _, err := w.deleteTags.Exec(entryID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if tag == "" {
+			continue
+		}
+		if _, err := w.insertTag.Exec(entryID, tag); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	return tx.Commit()
+// saveTasks replaces the tasks rows of an entry with its checkbox items
+func (w *entryWriter) saveTasks(entry *model.Entry) error {
+	if _, err := w.deleteTasks.Exec(entry.ID); err != nil {
+		return err
+	}
+	for _, task := range entry.Tasks() {
+		done := 0
+		if task.Done {
+			done = 1
+		}
+		if _, err := w.insertTask.Exec(entry.ID, task.Line, task.Text, done); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // DeleteEntry deletes an entry and its attachments from the database
-func DeleteEntry(path string, entryID string) error {
+func DeleteEntry(path string, entryID string) (err error) {
+	defer trackOp("DeleteEntry", path)(&err)
+
 	db, err := openDB(path)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
+	return deleteEntryDB(db, entryID)
+}
+
+func deleteEntryDB(db *sql.DB, entryID string) error {
+	if err := migrateSchema(db); err != nil {
+		return err
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	// Delete entry first, since its history can't be removed while it exists
+	_, err = tx.Exec(`DELETE FROM entries WHERE id = ?`, entryID)
+	if err != nil {
+		return err
+	}
+
 	// Delete history
 	_, err = tx.Exec(`DELETE FROM history WHERE entry_id = ?`, entryID)
 	if err != nil {
 		return err
 	}
 
-	// Delete attachments
+	// Delete attachments, including deleted ones
 	_, err = tx.Exec(`DELETE FROM attachments WHERE entry_id = ?`, entryID)
 	if err != nil {
 		return err
 	}
+	_, err = tx.Exec(`DELETE FROM attachment_trash WHERE entry_id = ?`, entryID)
+	if err != nil {
+		return err
+	}
 
-	// Delete entry
-	_, err = tx.Exec(`DELETE FROM entries WHERE id = ?`, entryID)
+	// Delete tags
+	_, err = tx.Exec(`DELETE FROM entry_tags WHERE entry_id = ?`, entryID)
+	if err != nil {
+		return err
+	}
+
+	// Delete tasks
+	_, err = tx.Exec(`DELETE FROM tasks WHERE entry_id = ?`, entryID)
 	if err != nil {
 		return err
 	}
@@ -392,7 +1011,9 @@ func DeleteEntry(path string, entryID string) error {
 // History operations
 
 // AddHistoryRecord adds a history record for an entry
-func AddHistoryRecord(path string, entryID string, record model.SaveRecord, password string) error {
+func AddHistoryRecord(path string, entryID string, record model.SaveRecord, password string) (err error) {
+	defer trackOp("AddHistoryRecord", path)(&err)
+
 	if password != "" {
 		return addHistoryRecordEncrypted(path, entryID, record, password)
 	}
@@ -408,10 +1029,13 @@ func AddHistoryRecord(path string, entryID string, record model.SaveRecord, pass
 	}
 
 	attachmentNames := strings.Join(record.Attachments, "|")
-	_, err = db.Exec(`INSERT INTO history (entry_id, content, saved_at, attachment_names) VALUES (?, ?, ?, ?)`,
-		entryID, record.Content, record.SavedAt, attachmentNames)
+	_, err = db.Exec(insertHistoryQuery,
+		entryID, record.Content, record.SavedAt, attachmentNames, record.Label, strings.Join(record.Tags, "|"))
+	if err != nil {
+		return err
+	}
 
-	return err
+	return sealEntry(db, sqliteDialect, entryID)
 }
 
 func addHistoryRecordEncrypted(path string, entryID string, record model.SaveRecord, password string) error {
@@ -420,66 +1044,118 @@ func addHistoryRecordEncrypted(path string, entryID string, record model.SaveRec
 		return err
 	}
 
-	encryptedData, err := os.ReadFile(expandedPath)
+	db, err := decryptToMemory(expandedPath, password)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	decryptedData, err := decrypt(encryptedData, password)
-	if err != nil {
+	if err := initSchema(db); err != nil {
 		return err
 	}
 
-	tmpFile, err := os.CreateTemp("", "journal-*.db")
+	attachmentNames := strings.Join(record.Attachments, "|")
+	_, err = db.Exec(insertHistoryQuery,
+		entryID, record.Content, record.SavedAt, attachmentNames, record.Label, strings.Join(record.Tags, "|"))
+	if err == nil {
+		err = sealEntry(db, sqliteDialect, entryID)
+	}
 	if err != nil {
 		return err
 	}
-	tmpPath := tmpFile.Name()
-	defer os.Remove(tmpPath)
 
-	if _, err := tmpFile.Write(decryptedData); err != nil {
-		tmpFile.Close()
+	// Re-encrypt and save
+	return encryptFromMemory(db, expandedPath, password)
+}
+
+// UpdateHistoryLabel sets the label of the history record saved at savedAt.
+// Password is empty for plaintext journals.
+func UpdateHistoryLabel(path string, entryID string, savedAt time.Time, label string, password string) (err error) {
+	defer trackOp("UpdateHistoryLabel", path)(&err)
+
+	return withDB(path, password, func(db *sql.DB) error {
+		if err := initSchema(db); err != nil {
+			return err
+		}
+		_, err := db.Exec(`UPDATE history SET label = ? WHERE entry_id = ? AND saved_at = ?`, label, entryID, savedAt)
 		return err
-	}
-	tmpFile.Close()
+	})
+}
 
-	db, err := sql.Open("sqlite", tmpPath)
+// errSkipWrite stops withDB from writing back an encrypted journal
+var errSkipWrite = errors.New("skip write")
+
+// viewDB runs fn against the journal database without saving changes. A
+// journal file that doesn't exist yet reads as an empty journal and isn't
+// created.
+func viewDB(path string, password string, fn func(db *sql.DB) error) error {
+	expandedPath, err := ExpandPath(path)
 	if err != nil {
 		return err
 	}
+	if info, err := os.Stat(expandedPath); os.IsNotExist(err) || (err == nil && info.Size() == 0) {
+		db, err := openMemoryDB()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		if err := initSchema(db); err != nil {
+			return err
+		}
+		return fn(db)
+	}
 
-	if err := initSchema(db); err != nil {
-		db.Close()
-		return err
+	var fnErr error
+	err = withDB(path, password, func(db *sql.DB) error {
+		fnErr = fn(db)
+		return errSkipWrite
+	})
+	if err == errSkipWrite {
+		return fnErr
 	}
+	return err
+}
 
-	attachmentNames := strings.Join(record.Attachments, "|")
-	_, err = db.Exec(`INSERT INTO history (entry_id, content, saved_at, attachment_names) VALUES (?, ?, ?, ?)`,
-		entryID, record.Content, record.SavedAt, attachmentNames)
-	db.Close()
+// withDB runs fn against the journal database. Encrypted journals are
+// decrypted into memory and re-encrypted after fn succeeds.
+func withDB(path string, password string, fn func(db *sql.DB) error) error {
+	if password == "" {
+		db, err := openDB(path)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return fn(db)
+	}
 
+	expandedPath, err := ExpandPath(path)
 	if err != nil {
 		return err
 	}
+	if s := lookupSession(expandedPath, password); s != nil {
+		return s.run(expandedPath, fn)
+	}
 
-	// Re-encrypt and save
-	sqliteData, err := os.ReadFile(tmpPath)
+	db, err := decryptToMemory(expandedPath, password)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	encryptedData, err = encrypt(sqliteData, password)
-	if err != nil {
+	if err := fn(db); err != nil {
 		return err
 	}
 
-	return os.WriteFile(expandedPath, encryptedData, 0644)
+	// Re-encrypt and save
+	return encryptFromMemory(db, expandedPath, password)
 }
 
 // Attachment operations
 
 // AddAttachment adds an attachment to an entry
-func AddAttachment(path string, attachment *model.Attachment) error {
+func AddAttachment(path string, attachment *model.Attachment) (err error) {
+	defer trackOp("AddAttachment", path, "size", attachment.Size)(&err)
+
 	db, err := openDB(path)
 	if err != nil {
 		return err
@@ -500,7 +1176,9 @@ func AddAttachment(path string, attachment *model.Attachment) error {
 }
 
 // GetAttachment retrieves an attachment with its data
-func GetAttachment(path string, attachmentID string) (*model.Attachment, error) {
+func GetAttachment(path string, attachmentID string) (_ *model.Attachment, err error) {
+	defer trackOp("GetAttachment", path)(&err)
+
 	db, err := openDB(path)
 	if err != nil {
 		return nil, err
@@ -522,19 +1200,25 @@ func GetAttachment(path string, attachmentID string) (*model.Attachment, error)
 }
 
 // DeleteAttachment deletes an attachment
-func DeleteAttachment(path string, attachmentID string) error {
+func DeleteAttachment(path string, attachmentID string) (err error) {
+	defer trackOp("DeleteAttachment", path)(&err)
+
 	db, err := openDB(path)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	_, err = db.Exec(`DELETE FROM attachments WHERE id = ?`, attachmentID)
-	return err
+	if err := migrateSchema(db); err != nil {
+		return err
+	}
+	return trashAttachmentDB(db, sqliteDialect, attachmentID, time.Now())
 }
 
 // GetEntryAttachments gets all attachments for an entry (with data)
-func GetEntryAttachments(path string, entryID string) ([]model.Attachment, error) {
+func GetEntryAttachments(path string, entryID string) (_ []model.Attachment, err error) {
+	defer trackOp("GetEntryAttachments", path)(&err)
+
 	db, err := openDB(path)
 	if err != nil {
 		return nil, err
@@ -564,260 +1248,162 @@ func GetEntryAttachments(path string, entryID string) ([]model.Attachment, error
 }
 
 // ExportAttachment exports an attachment to a file
-func ExportAttachment(dbPath string, attachmentID string, destPath string) error {
+func ExportAttachment(dbPath string, attachmentID string, destPath string) (err error) {
+	defer trackOp("ExportAttachment", dbPath)(&err)
+
 	att, err := GetAttachment(dbPath, attachmentID)
 	if err != nil {
 		return err
 	}
+	return writeAttachment(att, destPath)
+}
 
+// writeAttachment saves an attachment's data to destPath, or into it under
+// the original filename when destPath is a directory
+func writeAttachment(att *model.Attachment, destPath string) error {
 	expandedDest, err := ExpandPath(destPath)
 	if err != nil {
 		return err
 	}
 
-	// If destPath is a directory, use the original filename
 	info, err := os.Stat(expandedDest)
 	if err == nil && info.IsDir() {
 		expandedDest = filepath.Join(expandedDest, att.Filename)
 	}
 
-	return os.WriteFile(expandedDest, att.Data, 0644)
+	return os.WriteFile(expandedDest, att.Data, filePerm())
 }
 
 // Encrypted database operations
 // For encrypted databases, we encrypt the entire SQLite file
 
 // LoadJournalEncrypted loads an encrypted journal
-func LoadJournalEncrypted(path string, password string) (*model.Journal, error) {
+func LoadJournalEncrypted(path string, password string) (_ *model.Journal, err error) {
+	defer trackOp("LoadJournalEncrypted", path)(&err)
+
 	expandedPath, err := ExpandPath(path)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := os.Stat(expandedPath); os.IsNotExist(err) {
+	info, err := os.Stat(expandedPath)
+	if os.IsNotExist(err) || (err == nil && info.Size() == 0) {
 		return &model.Journal{Entries: []model.Entry{}}, nil
 	}
-
-	// Read encrypted file
-	encryptedData, err := os.ReadFile(expandedPath)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(encryptedData) == 0 {
-		return &model.Journal{Entries: []model.Entry{}}, nil
-	}
-
-	// Decrypt to temporary file
-	decryptedData, err := decrypt(encryptedData, password)
+	db, err := decryptToMemory(expandedPath, password)
 	if err != nil {
 		return nil, err
 	}
 
-	// Write to temp file
-	tmpFile, err := os.CreateTemp("", "journal-*.db")
-	if err != nil {
-		return nil, err
+	// Attachments from older files move into the attachment store; if that
+	// fails they stay where they are and are still readable, and the move
+	// is tried again next time
+	moved, moveErr := moveAttachmentsToStore(db, path, password)
+	if moveErr != nil {
+		logger.Error("moving attachments into the attachment store", "path", expandedPath, "error", moveErr.Error())
 	}
-	tmpPath := tmpFile.Name()
-	defer os.Remove(tmpPath)
 
-	if _, err := tmpFile.Write(decryptedData); err != nil {
-		tmpFile.Close()
-		return nil, err
+	journal, err := loadJournalFromDB(db)
+	if err == nil && moved && moveErr == nil {
+		if writeErr := encryptFromMemory(db, expandedPath, password); writeErr != nil {
+			logger.Error("removing moved attachments from the journal file", "path", expandedPath, "error", writeErr.Error())
+		}
 	}
-	tmpFile.Close()
-
-	// Load from temp SQLite file
-	db, err := sql.Open("sqlite", tmpPath)
+	db.Close()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
-	return loadJournalFromDB(db)
+	if err := mergeStoredAttachments(journal, path, password); err != nil {
+		return nil, err
+	}
+	return journal, nil
 }
 
 // SaveJournalEncrypted saves the journal encrypted
-func SaveJournalEncrypted(journal *model.Journal, path string, password string) error {
+func SaveJournalEncrypted(journal *model.Journal, path string, password string) (err error) {
+	defer trackOp("SaveJournalEncrypted", path, "entries", len(journal.Entries))(&err)
+
 	expandedPath, err := ExpandPath(path)
 	if err != nil {
 		return err
 	}
 
-	if err := os.MkdirAll(filepath.Dir(expandedPath), 0755); err != nil {
-		return err
-	}
-
-	// Create temp SQLite file
-	tmpFile, err := os.CreateTemp("", "journal-*.db")
-	if err != nil {
+	if err := os.MkdirAll(filepath.Dir(expandedPath), dirPerm()); err != nil {
 		return err
 	}
-	tmpPath := tmpFile.Name()
-	tmpFile.Close()
-	defer os.Remove(tmpPath)
 
-	// Save to temp SQLite file
-	db, err := sql.Open("sqlite", tmpPath)
+	db, err := openMemoryDB()
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
 	if err := initSchema(db); err != nil {
-		db.Close()
 		return err
 	}
-
 	if err := saveJournalToDB(db, journal); err != nil {
-		db.Close()
-		return err
-	}
-	db.Close()
-
-	// Read the SQLite file
-	sqliteData, err := os.ReadFile(tmpPath)
-	if err != nil {
-		return err
-	}
-
-	// Encrypt
-	encryptedData, err := encrypt(sqliteData, password)
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(expandedPath, encryptedData, 0644)
-}
-
-// AddAttachmentEncrypted adds an attachment to an encrypted journal
-func AddAttachmentEncrypted(path string, password string, attachment *model.Attachment) error {
-	journal, err := LoadJournalEncrypted(path, password)
-	if err != nil {
-		return err
-	}
-
-	// Find the entry and add attachment
-	for i := range journal.Entries {
-		if journal.Entries[i].ID == attachment.EntryID {
-			journal.Entries[i].Attachments = append(journal.Entries[i].Attachments, *attachment)
-			break
-		}
-	}
-
-	// For encrypted, we need to handle attachments differently
-	// We'll save the attachment data directly in a temp db then encrypt
-	expandedPath, err := ExpandPath(path)
-	if err != nil {
 		return err
 	}
-
-	// Decrypt existing data to temp file
-	var tmpPath string
-	if _, err := os.Stat(expandedPath); err == nil {
-		encryptedData, err := os.ReadFile(expandedPath)
-		if err != nil {
-			return err
-		}
-
-		if len(encryptedData) > 0 {
-			decryptedData, err := decrypt(encryptedData, password)
-			if err != nil {
-				return err
-			}
-
-			tmpFile, err := os.CreateTemp("", "journal-*.db")
-			if err != nil {
-				return err
-			}
-			tmpPath = tmpFile.Name()
-			defer os.Remove(tmpPath)
-
-			if _, err := tmpFile.Write(decryptedData); err != nil {
-				tmpFile.Close()
-				return err
-			}
-			tmpFile.Close()
-		}
-	}
-
-	if tmpPath == "" {
-		tmpFile, err := os.CreateTemp("", "journal-*.db")
-		if err != nil {
-			return err
-		}
-		tmpPath = tmpFile.Name()
-		tmpFile.Close()
-		defer os.Remove(tmpPath)
-	}
-
-	// Open temp db and add attachment
-	db, err := sql.Open("sqlite", tmpPath)
-	if err != nil {
+	if err := encryptFromMemory(db, expandedPath, password); err != nil {
 		return err
 	}
 
-	if err := initSchema(db); err != nil {
-		db.Close()
+	// Drop stored attachments of deleted entries
+	store, err := openAttachmentStore(path, password, false)
+	if err != nil || store == nil {
 		return err
 	}
-
-	_, err = db.Exec(`
-		INSERT INTO attachments (id, entry_id, filename, mime_type, size, data, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, attachment.ID, attachment.EntryID, attachment.Filename, attachment.MimeType,
-		attachment.Size, attachment.Data, attachment.CreatedAt)
-	db.Close()
-
-	if err != nil {
-		return err
+	defer store.Close()
+	entryIDs := make(map[string]bool, len(journal.Entries))
+	for _, e := range journal.Entries {
+		entryIDs[e.ID] = true
 	}
+	return store.prune(entryIDs)
+}
 
-	// Re-encrypt and save
-	sqliteData, err := os.ReadFile(tmpPath)
-	if err != nil {
-		return err
-	}
+// AddAttachmentEncrypted adds an attachment to an encrypted journal's
+// attachment store
+func AddAttachmentEncrypted(path string, password string, attachment *model.Attachment) (err error) {
+	defer trackOp("AddAttachmentEncrypted", path, "size", attachment.Size)(&err)
 
-	encryptedData, err := encrypt(sqliteData, password)
+	store, err := openAttachmentStore(path, password, true)
 	if err != nil {
 		return err
 	}
+	defer store.Close()
 
-	return os.WriteFile(expandedPath, encryptedData, 0644)
+	return store.put(attachment)
 }
 
-// GetAttachmentEncrypted retrieves an attachment from an encrypted journal
-func GetAttachmentEncrypted(path string, password string, attachmentID string) (*model.Attachment, error) {
-	expandedPath, err := ExpandPath(path)
-	if err != nil {
-		return nil, err
-	}
+// GetAttachmentEncrypted retrieves an attachment from an encrypted journal.
+// Only the requested attachment is decrypted.
+func GetAttachmentEncrypted(path string, password string, attachmentID string) (_ *model.Attachment, err error) {
+	defer trackOp("GetAttachmentEncrypted", path)(&err)
 
-	encryptedData, err := os.ReadFile(expandedPath)
+	store, err := openAttachmentStore(path, password, false)
 	if err != nil {
 		return nil, err
 	}
-
-	decryptedData, err := decrypt(encryptedData, password)
-	if err != nil {
-		return nil, err
+	if store != nil {
+		att, err := store.get(attachmentID)
+		store.Close()
+		if err != sql.ErrNoRows {
+			return att, err
+		}
 	}
 
-	tmpFile, err := os.CreateTemp("", "journal-*.db")
+	// Attachments not yet moved out of the journal file
+	expandedPath, err := ExpandPath(path)
 	if err != nil {
 		return nil, err
 	}
-	tmpPath := tmpFile.Name()
-	defer os.Remove(tmpPath)
-
-	if _, err := tmpFile.Write(decryptedData); err != nil {
-		tmpFile.Close()
-		return nil, err
-	}
-	tmpFile.Close()
 
-	db, err := sql.Open("sqlite", tmpPath)
+	db, err := decryptToMemory(expandedPath, password)
 	if err != nil {
 		return nil, err
 	}
@@ -838,100 +1424,143 @@ func GetAttachmentEncrypted(path string, password string, attachmentID string) (
 }
 
 // ExportAttachmentEncrypted exports an attachment from an encrypted journal
-func ExportAttachmentEncrypted(dbPath string, password string, attachmentID string, destPath string) error {
+func ExportAttachmentEncrypted(dbPath string, password string, attachmentID string, destPath string) (err error) {
+	defer trackOp("ExportAttachmentEncrypted", dbPath)(&err)
+
 	att, err := GetAttachmentEncrypted(dbPath, password, attachmentID)
 	if err != nil {
 		return err
 	}
+	return writeAttachment(att, destPath)
+}
 
-	expandedDest, err := ExpandPath(destPath)
+// DeleteAttachmentEncrypted deletes an attachment from an encrypted journal
+func DeleteAttachmentEncrypted(path string, password string, attachmentID string) (err error) {
+	defer trackOp("DeleteAttachmentEncrypted", path)(&err)
+
+	store, err := openAttachmentStore(path, password, false)
 	if err != nil {
 		return err
 	}
-
-	info, err := os.Stat(expandedDest)
-	if err == nil && info.IsDir() {
-		expandedDest = filepath.Join(expandedDest, att.Filename)
+	if store != nil {
+		found, err := store.delete(attachmentID)
+		store.Close()
+		if err != nil || found {
+			return err
+		}
 	}
 
-	return os.WriteFile(expandedDest, att.Data, 0644)
-}
-
-// DeleteAttachmentEncrypted deletes an attachment from an encrypted journal
-func DeleteAttachmentEncrypted(path string, password string, attachmentID string) error {
+	// Attachments not yet moved out of the journal file
 	expandedPath, err := ExpandPath(path)
 	if err != nil {
 		return err
 	}
 
-	encryptedData, err := os.ReadFile(expandedPath)
+	db, err := decryptToMemory(expandedPath, password)
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	decryptedData, err := decrypt(encryptedData, password)
-	if err != nil {
+	if _, err := db.Exec(`DELETE FROM attachments WHERE id = ?`, attachmentID); err != nil {
 		return err
 	}
 
-	tmpFile, err := os.CreateTemp("", "journal-*.db")
+	// Re-encrypt and save
+	return encryptFromMemory(db, expandedPath, password)
+}
+
+// CreateEmptyJournal creates an empty journal database
+func CreateEmptyJournal(path string) (err error) {
+	defer trackOp("CreateEmptyJournal", path)(&err)
+
+	db, err := openDB(path)
 	if err != nil {
 		return err
 	}
-	tmpPath := tmpFile.Name()
-	defer os.Remove(tmpPath)
+	defer db.Close()
 
-	if _, err := tmpFile.Write(decryptedData); err != nil {
-		tmpFile.Close()
-		return err
-	}
-	tmpFile.Close()
+	return initSchema(db)
+}
+
+// CreateEmptyJournalEncrypted creates an empty encrypted journal
+func CreateEmptyJournalEncrypted(path string, password string) (err error) {
+	defer trackOp("CreateEmptyJournalEncrypted", path)(&err)
 
-	db, err := sql.Open("sqlite", tmpPath)
+	expandedPath, err := ExpandPath(path)
 	if err != nil {
 		return err
 	}
+	resetKDFSession(expandedPath, password)
+	journal := &model.Journal{Entries: []model.Entry{}}
+	return SaveJournalEncrypted(journal, path, password)
+}
 
-	_, err = db.Exec(`DELETE FROM attachments WHERE id = ?`, attachmentID)
-	db.Close()
+// sqliteHeader starts every SQLite database file
+var sqliteHeader = []byte("SQLite format 3\x00")
 
+// InspectJournalFile reports whether a journal file with content exists at
+// path, and whether it is encrypted. Any file that isn't a SQLite database
+// is taken to be encrypted, so a file that is neither fails its password
+// check rather than being opened.
+func InspectJournalFile(path string) (exists, encrypted bool, err error) {
+	defer trackOp("InspectJournalFile", path)(&err)
+
+	expandedPath, err := ExpandPath(path)
 	if err != nil {
-		return err
+		return false, false, err
+	}
+	f, err := os.Open(expandedPath)
+	if os.IsNotExist(err) {
+		return false, false, nil
 	}
-
-	// Re-encrypt and save
-	sqliteData, err := os.ReadFile(tmpPath)
 	if err != nil {
-		return err
+		return false, false, err
 	}
+	defer f.Close()
 
-	encryptedData, err = encrypt(sqliteData, password)
+	info, err := f.Stat()
 	if err != nil {
-		return err
+		return false, false, err
 	}
-
-	return os.WriteFile(expandedPath, encryptedData, 0644)
+	if info.IsDir() {
+		return false, false, fmt.Errorf("%s is a folder", path)
+	}
+	header := make([]byte, len(sqliteHeader))
+	n, _ := io.ReadFull(f, header)
+	if n == 0 {
+		// An empty file is where a journal was never written
+		return false, false, nil
+	}
+	return true, !bytes.Equal(header[:n], sqliteHeader), nil
 }
 
-// CreateEmptyJournal creates an empty journal database
-func CreateEmptyJournal(path string) error {
-	db, err := openDB(path)
+// OverwriteJournal removes the journal file at path and its attachment
+// store so a new journal can be created there. The file is backed up
+// first, so overwriting by mistake can be undone from the backups.
+func OverwriteJournal(path string, now time.Time) (err error) {
+	defer trackOp("OverwriteJournal", path)(&err)
+
+	expandedPath, err := ExpandPath(path)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
-
-	return initSchema(db)
-}
-
-// CreateEmptyJournalEncrypted creates an empty encrypted journal
-func CreateEmptyJournalEncrypted(path string, password string) error {
-	journal := &model.Journal{Entries: []model.Entry{}}
-	return SaveJournalEncrypted(journal, path, password)
+	if _, err := BackupJournal(path, now); err != nil {
+		return fmt.Errorf("backing up current journal: %w", err)
+	}
+	if err := os.Remove(expandedPath); err != nil {
+		return err
+	}
+	if err := removePrevious(expandedPath); err != nil {
+		return err
+	}
+	return removeAttachmentStore(expandedPath)
 }
 
 // MigrateJournal copies journal data from old path to new path
-func MigrateJournal(oldPath, newPath string) error {
+func MigrateJournal(oldPath, newPath string) (err error) {
+	defer trackOp("MigrateJournal", oldPath, "to", newPath)(&err)
+
 	journal, err := LoadJournal(oldPath)
 	if err != nil {
 		return err
@@ -940,12 +1569,26 @@ func MigrateJournal(oldPath, newPath string) error {
 }
 
 // MigrateJournalEncrypted copies encrypted journal data
-func MigrateJournalEncrypted(oldPath, newPath string, password string) error {
+func MigrateJournalEncrypted(oldPath, newPath string, password string) (err error) {
+	defer trackOp("MigrateJournalEncrypted", oldPath, "to", newPath)(&err)
+
 	journal, err := LoadJournalEncrypted(oldPath, password)
 	if err != nil {
 		return err
 	}
-	return SaveJournalEncrypted(journal, newPath, password)
+	if err := SaveJournalEncrypted(journal, newPath, password); err != nil {
+		return err
+	}
+
+	expandedOld, err := ExpandPath(oldPath)
+	if err != nil {
+		return err
+	}
+	expandedNew, err := ExpandPath(newPath)
+	if err != nil {
+		return err
+	}
+	return copyAttachmentStore(expandedOld, expandedNew)
 }
 
 // MigrateConfigToNewFormat migrates old config format to new format
@@ -1001,6 +1644,30 @@ func FindJournal(config *model.Config, path string) *model.JournalDB {
 	return nil
 }
 
+// RemoveJournal removes the journal at path from the config, leaving its
+// files alone
+func RemoveJournal(config *model.Config, path string) {
+	config.Journals = slices.DeleteFunc(config.Journals, func(j model.JournalDB) bool {
+		return j.Path == path
+	})
+	if config.ActiveJournal == path {
+		config.ActiveJournal = ""
+	}
+}
+
+// RelocateJournal points the journal at oldPath to its files at newPath,
+// after they were moved outside the app
+func RelocateJournal(config *model.Config, oldPath, newPath string, encrypted bool) {
+	if j := FindJournal(config, oldPath); j != nil {
+		j.Path = newPath
+		j.Encrypted = encrypted
+		j.Stats = nil
+	}
+	if config.ActiveJournal == oldPath {
+		config.ActiveJournal = newPath
+	}
+}
+
 // UpdateJournalLastOpened updates the last opened time for a journal
 func UpdateJournalLastOpened(config *model.Config, path string, t time.Time) {
 	for i := range config.Journals {
@@ -1043,6 +1710,12 @@ func DetectMimeType(filename string) string {
 	return "application/octet-stream"
 }
 
+// IsTextMimeType reports whether attachments of a MIME type are text that
+// can be shown as it is, such as plain text, Markdown, or JSON
+func IsTextMimeType(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/") || mimeType == "application/json" || mimeType == "application/xml"
+}
+
 // FormatFileSize formats bytes as human readable string
 func FormatFileSize(size int64) string {
 	const unit = 1024
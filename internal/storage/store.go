@@ -0,0 +1,803 @@
+package storage
+
+import (
+	"database/sql"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"journal/internal/clock"
+	"journal/internal/model"
+	"journal/internal/stats"
+)
+
+// Store is the storage of one open journal. The application talks to a
+// journal through it rather than the path-based functions, so UI flows can
+// run against an in-memory journal and other backends can be added.
+// Whole-file operations such as backups and encryption stay functions.
+type Store interface {
+	// Path is the journal file, or empty when the store has none
+	Path() string
+
+	// Load reads every entry with its history and attachment metadata
+	Load() (*model.Journal, error)
+	// Save writes every entry of journal, as SaveEntries does
+	Save(journal *model.Journal) error
+
+	CountEntries(filter model.EntryFilter) (int, error)
+	ListEntries(offset, limit int, filter model.EntryFilter) ([]model.EntrySummary, error)
+	GetEntry(entryID string) (*model.Entry, error)
+	// GetEntryContent reads an entry like GetEntry, but without its
+	// history, for views that don't show previous versions
+	GetEntryContent(entryID string) (*model.Entry, error)
+	FindEntryByDate(date string) (string, error)
+	// ListTasks returns the checkbox items of every entry, newest entry
+	// first, or only the open ones
+	ListTasks(openOnly bool) ([]model.Task, error)
+	// ListLoops returns the open loops (">>" lines) of every entry,
+	// newest entry first, and the closed ones too unless openOnly is set
+	ListLoops(openOnly bool) ([]model.Loop, error)
+	// ListTags returns every tag with the number of entries that have it,
+	// most used first
+	ListTags() ([]model.TagCount, error)
+	// EntryUpdateTimes returns when each entry was last saved, by entry
+	// ID, to tell which entries changed since they were last read
+	EntryUpdateTimes() (map[string]time.Time, error)
+	EntryPosition(entryID string, filter model.EntryFilter) (int, error)
+	// SimilarEntries returns the entries other than entryID whose content
+	// is nearly identical to content
+	SimilarEntries(entryID, content string) ([]model.EntrySummary, error)
+	// SearchEntries returns the IDs of the entries whose content, or with
+	// history one of their versions, may contain every term, ignoring
+	// case. Callers match the entries exactly.
+	SearchEntries(terms []string, history bool) ([]string, error)
+	SaveEntries(entries []model.Entry) error
+	DeleteEntry(entryID string) error
+
+	AddHistoryRecord(entryID string, record model.SaveRecord) error
+	UpdateHistoryLabel(entryID string, savedAt time.Time, label string) error
+	// Verify checks every entry and history version against the checksum
+	// stored when it was saved
+	Verify() ([]IntegrityProblem, error)
+
+	AddAttachment(attachment *model.Attachment) error
+	GetAttachment(attachmentID string) (*model.Attachment, error)
+	// DeleteAttachment moves an attachment to the trash, where it is kept
+	// for AttachmentTrashAge
+	DeleteAttachment(attachmentID string) error
+	ExportAttachment(attachmentID, destPath string) error
+	// TrashedAttachments returns the metadata of an entry's deleted
+	// attachments that are still kept, most recently deleted first
+	TrashedAttachments(entryID string) ([]model.Attachment, error)
+	// RestoreAttachment brings back a deleted attachment that is still kept
+	RestoreAttachment(attachmentID string) error
+}
+
+// OpenStore returns the store of a configured journal. Password is ignored
+// for plaintext journals.
+func OpenStore(journal *model.JournalDB, password string) Store {
+	switch journal.Format {
+	case model.FormatMarkdown:
+		return NewMarkdownStore(journal.Path, clock.System, clock.UUID)
+	case model.FormatPostgres:
+		return NewPostgresStore(journal.Path)
+	}
+	if journal.Encrypted {
+		setSessionMode(journal.Path, password, journal.EncryptionMode == model.EncryptionSession)
+		return NewEncryptedStore(journal.Path, password)
+	}
+	return NewSQLiteStore(journal.Path)
+}
+
+// sqliteStore is a plaintext SQLite journal file
+type sqliteStore struct {
+	path string
+}
+
+// NewSQLiteStore returns the store of the plaintext journal at path
+func NewSQLiteStore(path string) Store {
+	return sqliteStore{path: path}
+}
+
+func (s sqliteStore) Path() string { return s.path }
+
+func (s sqliteStore) Load() (*model.Journal, error) { return LoadJournal(s.path) }
+
+func (s sqliteStore) Save(journal *model.Journal) error { return SaveJournal(journal, s.path) }
+
+func (s sqliteStore) CountEntries(filter model.EntryFilter) (int, error) {
+	return CountEntries(s.path, "", filter)
+}
+
+func (s sqliteStore) ListEntries(offset, limit int, filter model.EntryFilter) ([]model.EntrySummary, error) {
+	return ListEntries(s.path, "", offset, limit, filter)
+}
+
+func (s sqliteStore) GetEntry(entryID string) (*model.Entry, error) {
+	return GetEntry(s.path, "", entryID)
+}
+
+func (s sqliteStore) GetEntryContent(entryID string) (*model.Entry, error) {
+	return GetEntryContent(s.path, "", entryID)
+}
+
+func (s sqliteStore) FindEntryByDate(date string) (string, error) {
+	return FindEntryByDate(s.path, "", date)
+}
+
+func (s sqliteStore) ListTasks(openOnly bool) ([]model.Task, error) {
+	return ListTasks(s.path, "", openOnly)
+}
+
+func (s sqliteStore) ListLoops(openOnly bool) ([]model.Loop, error) {
+	return ListLoops(s.path, "", openOnly)
+}
+
+func (s sqliteStore) ListTags() ([]model.TagCount, error) {
+	return ListTags(s.path, "")
+}
+
+func (s sqliteStore) EntryUpdateTimes() (map[string]time.Time, error) {
+	return EntryUpdateTimes(s.path, "")
+}
+
+func (s sqliteStore) EntryPosition(entryID string, filter model.EntryFilter) (int, error) {
+	return EntryPosition(s.path, "", entryID, filter)
+}
+
+func (s sqliteStore) SimilarEntries(entryID, content string) ([]model.EntrySummary, error) {
+	return SimilarEntries(s.path, "", entryID, content)
+}
+
+func (s sqliteStore) SearchEntries(terms []string, history bool) ([]string, error) {
+	return SearchEntries(s.path, "", terms, history)
+}
+
+func (s sqliteStore) SaveEntries(entries []model.Entry) error {
+	return SaveEntries(s.path, "", entries)
+}
+
+func (s sqliteStore) DeleteEntry(entryID string) error { return DeleteEntry(s.path, entryID) }
+
+func (s sqliteStore) AddHistoryRecord(entryID string, record model.SaveRecord) error {
+	return AddHistoryRecord(s.path, entryID, record, "")
+}
+
+func (s sqliteStore) UpdateHistoryLabel(entryID string, savedAt time.Time, label string) error {
+	return UpdateHistoryLabel(s.path, entryID, savedAt, label, "")
+}
+
+func (s sqliteStore) Verify() ([]IntegrityProblem, error) {
+	return VerifyJournal(s.path, "")
+}
+
+func (s sqliteStore) AddAttachment(attachment *model.Attachment) error {
+	return AddAttachment(s.path, attachment)
+}
+
+func (s sqliteStore) GetAttachment(attachmentID string) (*model.Attachment, error) {
+	return GetAttachment(s.path, attachmentID)
+}
+
+func (s sqliteStore) DeleteAttachment(attachmentID string) error {
+	return DeleteAttachment(s.path, attachmentID)
+}
+
+func (s sqliteStore) ExportAttachment(attachmentID, destPath string) error {
+	return ExportAttachment(s.path, attachmentID, destPath)
+}
+
+func (s sqliteStore) TrashedAttachments(entryID string) ([]model.Attachment, error) {
+	return TrashedAttachments(s.path, entryID)
+}
+
+func (s sqliteStore) RestoreAttachment(attachmentID string) error {
+	return RestoreAttachment(s.path, attachmentID)
+}
+
+// encryptedStore is an encrypted SQLite journal file
+type encryptedStore struct {
+	path     string
+	password string
+}
+
+// NewEncryptedStore returns the store of the encrypted journal at path
+func NewEncryptedStore(path, password string) Store {
+	return encryptedStore{path: path, password: password}
+}
+
+func (s encryptedStore) Path() string { return s.path }
+
+func (s encryptedStore) Load() (*model.Journal, error) {
+	return LoadJournalEncrypted(s.path, s.password)
+}
+
+func (s encryptedStore) Save(journal *model.Journal) error {
+	return SaveJournalEncrypted(journal, s.path, s.password)
+}
+
+// Outside session mode, each read below decrypts the whole journal, so
+// paging doesn't bound the work as it does for other stores
+
+func (s encryptedStore) CountEntries(filter model.EntryFilter) (int, error) {
+	return CountEntries(s.path, s.password, filter)
+}
+
+func (s encryptedStore) ListEntries(offset, limit int, filter model.EntryFilter) ([]model.EntrySummary, error) {
+	return ListEntries(s.path, s.password, offset, limit, filter)
+}
+
+func (s encryptedStore) GetEntry(entryID string) (*model.Entry, error) {
+	return GetEntry(s.path, s.password, entryID)
+}
+
+func (s encryptedStore) GetEntryContent(entryID string) (*model.Entry, error) {
+	return GetEntryContent(s.path, s.password, entryID)
+}
+
+func (s encryptedStore) FindEntryByDate(date string) (string, error) {
+	return FindEntryByDate(s.path, s.password, date)
+}
+
+func (s encryptedStore) ListTasks(openOnly bool) ([]model.Task, error) {
+	return ListTasks(s.path, s.password, openOnly)
+}
+
+func (s encryptedStore) ListLoops(openOnly bool) ([]model.Loop, error) {
+	return ListLoops(s.path, s.password, openOnly)
+}
+
+func (s encryptedStore) ListTags() ([]model.TagCount, error) {
+	return ListTags(s.path, s.password)
+}
+
+func (s encryptedStore) EntryUpdateTimes() (map[string]time.Time, error) {
+	return EntryUpdateTimes(s.path, s.password)
+}
+
+func (s encryptedStore) EntryPosition(entryID string, filter model.EntryFilter) (int, error) {
+	return EntryPosition(s.path, s.password, entryID, filter)
+}
+
+func (s encryptedStore) SimilarEntries(entryID, content string) ([]model.EntrySummary, error) {
+	return SimilarEntries(s.path, s.password, entryID, content)
+}
+
+func (s encryptedStore) SearchEntries(terms []string, history bool) ([]string, error) {
+	return SearchEntries(s.path, s.password, terms, history)
+}
+
+func (s encryptedStore) SaveEntries(entries []model.Entry) error {
+	return SaveEntries(s.path, s.password, entries)
+}
+
+func (s encryptedStore) DeleteEntry(entryID string) error {
+	return DeleteEntryEncrypted(s.path, s.password, entryID)
+}
+
+func (s encryptedStore) AddHistoryRecord(entryID string, record model.SaveRecord) error {
+	return AddHistoryRecord(s.path, entryID, record, s.password)
+}
+
+func (s encryptedStore) UpdateHistoryLabel(entryID string, savedAt time.Time, label string) error {
+	return UpdateHistoryLabel(s.path, entryID, savedAt, label, s.password)
+}
+
+func (s encryptedStore) Verify() ([]IntegrityProblem, error) {
+	return VerifyJournal(s.path, s.password)
+}
+
+func (s encryptedStore) AddAttachment(attachment *model.Attachment) error {
+	return AddAttachmentEncrypted(s.path, s.password, attachment)
+}
+
+func (s encryptedStore) GetAttachment(attachmentID string) (*model.Attachment, error) {
+	return GetAttachmentEncrypted(s.path, s.password, attachmentID)
+}
+
+func (s encryptedStore) DeleteAttachment(attachmentID string) error {
+	return DeleteAttachmentEncrypted(s.path, s.password, attachmentID)
+}
+
+func (s encryptedStore) ExportAttachment(attachmentID, destPath string) error {
+	return ExportAttachmentEncrypted(s.path, s.password, attachmentID, destPath)
+}
+
+func (s encryptedStore) TrashedAttachments(entryID string) ([]model.Attachment, error) {
+	return TrashedAttachmentsEncrypted(s.path, s.password, entryID)
+}
+
+func (s encryptedStore) RestoreAttachment(attachmentID string) error {
+	return RestoreAttachmentEncrypted(s.path, s.password, attachmentID)
+}
+
+// MemoryStore keeps a journal in memory only. It behaves like the SQLite
+// stores, including filtering and ordering, and is meant for tests and
+// throwaway journals.
+type MemoryStore struct {
+	mu          sync.Mutex
+	entries     map[string]model.Entry      // By ID, without attachments
+	attachments map[string]model.Attachment // By ID, with data
+	trash       map[string]trashedAttachment
+}
+
+// trashedAttachment is a deleted attachment of a MemoryStore
+type trashedAttachment struct {
+	model.Attachment
+	deletedAt time.Time
+}
+
+// NewMemoryStore returns an empty in-memory journal
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:     make(map[string]model.Entry),
+		attachments: make(map[string]model.Attachment),
+		trash:       make(map[string]trashedAttachment),
+	}
+}
+
+func (s *MemoryStore) Path() string { return "" }
+
+func (s *MemoryStore) Load() (*model.Journal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	journal := &model.Journal{}
+	for _, entry := range s.sorted(model.EntryFilter{}) {
+		journal.Entries = append(journal.Entries, s.withAttachments(entry))
+	}
+	return journal, nil
+}
+
+func (s *MemoryStore) Save(journal *model.Journal) error {
+	return s.SaveEntries(journal.Entries)
+}
+
+func (s *MemoryStore) CountEntries(filter model.EntryFilter) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.sorted(filter)), nil
+}
+
+func (s *MemoryStore) ListEntries(offset, limit int, filter model.EntryFilter) ([]model.EntrySummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.sorted(filter)
+	if offset >= len(entries) {
+		return nil, nil
+	}
+	entries = entries[offset:]
+	if limit >= 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+
+	summaries := make([]model.EntrySummary, len(entries))
+	for i, entry := range entries {
+		excerpt := entry.Content
+		if runes := []rune(excerpt); len(runes) > entryExcerptLen {
+			excerpt = string(runes[:entryExcerptLen])
+		}
+		summaries[i] = model.EntrySummary{
+			ID:              entry.ID,
+			Date:            entry.Date,
+			Excerpt:         excerpt,
+			Tags:            slices.Clone(entry.Tags),
+			Mood:            entry.Mood,
+			HistoryCount:    len(entry.History),
+			AttachmentCount: len(s.entryAttachments(entry.ID)),
+			WordCount:       len(stats.Words(entry.Content)),
+		}
+	}
+	return summaries, nil
+}
+
+func (s *MemoryStore) GetEntry(entryID string) (*model.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[entryID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	entry = s.withAttachments(entry)
+	return &entry, nil
+}
+
+func (s *MemoryStore) GetEntryContent(entryID string) (*model.Entry, error) {
+	entry, err := s.GetEntry(entryID)
+	if err != nil {
+		return nil, err
+	}
+	entry.History = nil
+	return entry, nil
+}
+
+func (s *MemoryStore) FindEntryByDate(date string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range s.entries {
+		if entry.Date == date {
+			return entry.ID, nil
+		}
+	}
+	return "", nil
+}
+
+func (s *MemoryStore) ListTasks(openOnly bool) ([]model.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tasks []model.Task
+	for _, entry := range s.sorted(model.EntryFilter{}) {
+		for _, task := range entry.Tasks() {
+			if !openOnly || !task.Done {
+				tasks = append(tasks, task)
+			}
+		}
+	}
+	return tasks, nil
+}
+
+func (s *MemoryStore) ListLoops(openOnly bool) ([]model.Loop, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var loops []model.Loop
+	for _, entry := range s.sorted(model.EntryFilter{}) {
+		for _, loop := range entry.Loops() {
+			if !openOnly || !loop.Closed {
+				loops = append(loops, loop)
+			}
+		}
+	}
+	return loops, nil
+}
+
+func (s *MemoryStore) ListTags() ([]model.TagCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]int{}
+	for _, entry := range s.entries {
+		for _, tag := range entry.Tags {
+			counts[tag]++
+		}
+	}
+	tags := make([]model.TagCount, 0, len(counts))
+	for tag, n := range counts {
+		tags = append(tags, model.TagCount{Tag: tag, Entries: n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Entries != tags[j].Entries {
+			return tags[i].Entries > tags[j].Entries
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags, nil
+}
+
+func (s *MemoryStore) EntryUpdateTimes() (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	times := make(map[string]time.Time, len(s.entries))
+	for _, entry := range s.entries {
+		times[entry.ID] = entry.UpdatedAt
+	}
+	return times, nil
+}
+
+func (s *MemoryStore) EntryPosition(entryID string, filter model.EntryFilter) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, entry := range s.sorted(filter) {
+		if entry.ID == entryID {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+func (s *MemoryStore) SimilarEntries(entryID, content string) ([]model.EntrySummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var similar []model.EntrySummary
+	for _, entry := range s.sorted(model.EntryFilter{}) {
+		if entry.ID != entryID && stats.NearDuplicate(content, entry.Content) {
+			similar = append(similar, model.EntrySummary{ID: entry.ID, Date: entry.Date})
+		}
+	}
+	return similar, nil
+}
+
+func (s *MemoryStore) SearchEntries(terms []string, history bool) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	containsAll := func(content string) bool {
+		content = strings.ToLower(content)
+		for _, term := range terms {
+			if !strings.Contains(content, strings.ToLower(term)) {
+				return false
+			}
+		}
+		return true
+	}
+	var ids []string
+	for _, entry := range s.sorted(model.EntryFilter{}) {
+		found := containsAll(entry.Content)
+		for _, record := range entry.History {
+			found = found || (history && containsAll(record.Content))
+		}
+		if found {
+			ids = append(ids, entry.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemoryStore) SaveEntries(entries []model.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range entries {
+		// Like the SQLite stores, history is only ever added to
+		stored := copyEntry(entry)
+		if old, ok := s.entries[entry.ID]; ok {
+			stored.History = old.History
+			for _, record := range entry.History {
+				stored.History = addRecord(stored.History, record)
+			}
+		}
+		stored.Attachments = nil
+		s.entries[entry.ID] = stored
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteEntry(entryID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, entryID)
+	for id, att := range s.attachments {
+		if att.EntryID == entryID {
+			delete(s.attachments, id)
+		}
+	}
+	for id, att := range s.trash {
+		if att.EntryID == entryID {
+			delete(s.trash, id)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) AddHistoryRecord(entryID string, record model.SaveRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[entryID]
+	if !ok {
+		return nil
+	}
+	entry.History = addRecord(entry.History, record)
+	s.entries[entryID] = entry
+	return nil
+}
+
+func (s *MemoryStore) UpdateHistoryLabel(entryID string, savedAt time.Time, label string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[entryID]
+	if !ok {
+		return nil
+	}
+	for i := range entry.History {
+		if entry.History[i].SavedAt.Equal(savedAt) {
+			entry.History[i].Label = label
+		}
+	}
+	s.entries[entryID] = entry
+	return nil
+}
+
+// Verify finds nothing to check: entries in memory have no checksums
+func (s *MemoryStore) Verify() ([]IntegrityProblem, error) {
+	return nil, nil
+}
+
+func (s *MemoryStore) AddAttachment(attachment *model.Attachment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	att := *attachment
+	att.Data = slices.Clone(attachment.Data)
+	s.attachments[att.ID] = att
+	return nil
+}
+
+func (s *MemoryStore) GetAttachment(attachmentID string) (*model.Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	att, ok := s.attachments[attachmentID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	att.Data = slices.Clone(att.Data)
+	return &att, nil
+}
+
+func (s *MemoryStore) DeleteAttachment(attachmentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, att := range s.trash {
+		if now.Sub(att.deletedAt) > AttachmentTrashAge {
+			delete(s.trash, id)
+		}
+	}
+	if att, ok := s.attachments[attachmentID]; ok {
+		s.trash[attachmentID] = trashedAttachment{Attachment: att, deletedAt: now}
+		delete(s.attachments, attachmentID)
+	}
+	return nil
+}
+
+func (s *MemoryStore) ExportAttachment(attachmentID, destPath string) error {
+	att, err := s.GetAttachment(attachmentID)
+	if err != nil {
+		return err
+	}
+	return writeAttachment(att, destPath)
+}
+
+func (s *MemoryStore) TrashedAttachments(entryID string) ([]model.Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var trashed []trashedAttachment
+	for _, att := range s.trash {
+		if att.EntryID == entryID {
+			trashed = append(trashed, att)
+		}
+	}
+	sort.Slice(trashed, func(i, j int) bool {
+		return trashed[i].deletedAt.After(trashed[j].deletedAt)
+	})
+	attachments := make([]model.Attachment, len(trashed))
+	for i, att := range trashed {
+		attachments[i] = att.Attachment
+		attachments[i].Data = nil
+	}
+	return attachments, nil
+}
+
+func (s *MemoryStore) RestoreAttachment(attachmentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	att, ok := s.trash[attachmentID]
+	if !ok {
+		return errNotInTrash
+	}
+	s.attachments[attachmentID] = att.Attachment
+	delete(s.trash, attachmentID)
+	return nil
+}
+
+// sorted returns the entries matching filter, newest first
+func (s *MemoryStore) sorted(filter model.EntryFilter) []model.Entry {
+	var entries []model.Entry
+	for _, entry := range s.entries {
+		if s.matchesFilter(entry, filter) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Date > entries[j].Date
+	})
+	return entries
+}
+
+// entryAttachments returns the metadata of an entry's attachments
+func (s *MemoryStore) entryAttachments(entryID string) []model.Attachment {
+	var attachments []model.Attachment
+	for _, att := range s.attachments {
+		if att.EntryID == entryID {
+			att.Data = nil
+			attachments = append(attachments, att)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		return attachments[i].CreatedAt.Before(attachments[j].CreatedAt)
+	})
+	return attachments
+}
+
+// withAttachments returns a copy of entry with its attachment metadata
+func (s *MemoryStore) withAttachments(entry model.Entry) model.Entry {
+	entry = copyEntry(entry)
+	entry.Attachments = s.entryAttachments(entry.ID)
+	return entry
+}
+
+// copyEntry copies an entry so callers can't change stored slices
+func copyEntry(entry model.Entry) model.Entry {
+	entry.Tags = slices.Clone(entry.Tags)
+	entry.History = slices.Clone(entry.History)
+	entry.Attachments = slices.Clone(entry.Attachments)
+	return entry
+}
+
+// addRecord adds a history record newest first, ignoring one already saved
+// at the same time as the unique history index does
+func addRecord(history []model.SaveRecord, record model.SaveRecord) []model.SaveRecord {
+	for _, existing := range history {
+		if existing.SavedAt.Equal(record.SavedAt) {
+			return history
+		}
+	}
+	history = append(history, record)
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].SavedAt.After(history[j].SavedAt)
+	})
+	return history
+}
+
+// matchesFilter applies an entry filter the way filterClause does. Text
+// terms match whole words, case-insensitively, like the full-text index.
+// Stored entries hold no attachments; they are looked up by entry.
+func (s *MemoryStore) matchesFilter(entry model.Entry, filter model.EntryFilter) bool {
+	if filter.Tag != "" && !slices.Contains(entry.Tags, filter.Tag) {
+		return false
+	}
+	if filter.Mood != "" && entry.Mood != filter.Mood {
+		return false
+	}
+	if filter.Since != "" && entry.Date < filter.Since {
+		return false
+	}
+	if filter.Until != "" && entry.Date > filter.Until {
+		return false
+	}
+	if filter.Attachments && len(s.entryAttachments(entry.ID)) == 0 {
+		return false
+	}
+	if filter.WordsOver > 0 && len(stats.Words(entry.Content)) <= filter.WordsOver {
+		return false
+	}
+	if filter.Edited && len(entry.History) <= 1 {
+		return false
+	}
+	if filter.Text != "" {
+		words := make(map[string]bool)
+		for _, word := range textTokens(entry.Content) {
+			words[word] = true
+		}
+		for _, term := range textTokens(filter.Text) {
+			if !words[term] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// textTokens splits text into lowercase words
+func textTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,399 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Encrypted journal files are written as a stream of independently sealed
+// chunks, so encryption and decryption work a chunk at a time rather than
+// sealing the file as one block. The layout is:
+//
+//	header   fileMagic, 2-byte format version, 1-byte cipher, KDF
+//	         parameters and salt, 7-byte nonce prefix
+//	chunks   AES-GCM sealed segments of streamChunkSize plaintext bytes
+//
+// Each chunk's nonce is the prefix, a 4-byte chunk counter, and a final-chunk
+// flag, and the header is authenticated with every chunk, so reordering,
+// truncating, or editing chunks is detected.
+//
+// The magic tells a journal from any other file before a password is tried,
+// and the version and cipher tell a file from a newer release apart from a
+// wrong password. A new layout or cipher gets a new version or cipher
+// number; readers keep reading the older ones, which are rewritten in the
+// current format on the next save. Format 1 had streamMagic and no version
+// or cipher fields.
+
+var (
+	fileMagic   = []byte("JRNLFILE")
+	streamMagic = []byte("JRNLSTR1")
+)
+
+// streamFormatVersion is the format encrypted files are written in
+const streamFormatVersion = 2
+
+// Ciphers an encrypted file's chunks can be sealed with
+const (
+	cipherAESGCMChunks byte = 1 // AES-256-GCM over streamChunkSize chunks
+)
+
+const (
+	streamChunkSize   = 64 * 1024
+	streamNoncePrefix = 7
+	streamHeaderSize  = kdfHeaderSize + streamNoncePrefix // Format 1
+	kdfFieldsSize     = kdfHeaderSize - 8                 // The KDF header without its magic
+	fileHeaderSize    = 8 + 2 + 1 + kdfFieldsSize + streamNoncePrefix
+	streamTagSize     = 16
+)
+
+// ErrCorruptJournal is returned when an encrypted journal fails to
+// authenticate after its first chunk, i.e. the password was right but the
+// file has been damaged or truncated
+var ErrCorruptJournal = errors.New("encrypted journal is corrupted")
+
+// ErrNewerFormat is returned for an encrypted journal written in a format,
+// cipher, or key derivation this version doesn't know, by a newer release
+var ErrNewerFormat = errors.New("journal was saved by a newer version of journal")
+
+// ErrNotJournal is returned when a file opened as an encrypted journal is
+// plainly something else
+var ErrNotJournal = errors.New("not an encrypted journal file")
+
+func streamNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, 12)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// encryptStream reads plaintext from r and writes the chunked encrypted
+// format to w, for the file at expandedPath
+func encryptStream(w io.Writer, r io.Reader, expandedPath, password string) error {
+	header, err := sessionHeader(expandedPath, password)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(headerKey(password, header))
+	if err != nil {
+		return err
+	}
+
+	headerBytes := make([]byte, 0, fileHeaderSize)
+	headerBytes = append(headerBytes, fileMagic...)
+	headerBytes = binary.BigEndian.AppendUint16(headerBytes, streamFormatVersion)
+	headerBytes = append(headerBytes, cipherAESGCMChunks)
+	headerBytes = append(headerBytes, header.encode()[len(kdfMagic):]...)
+	prefix := make([]byte, streamNoncePrefix)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return err
+	}
+	headerBytes = append(headerBytes, prefix...)
+	if _, err := w.Write(headerBytes); err != nil {
+		return err
+	}
+
+	br := bufio.NewReaderSize(r, streamChunkSize)
+	plain := make([]byte, streamChunkSize)
+	sealed := make([]byte, 0, streamChunkSize+streamTagSize)
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(br, plain)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := n < streamChunkSize
+		if !last {
+			if _, err := br.Peek(1); err == io.EOF {
+				last = true
+			}
+		}
+
+		sealed = gcm.Seal(sealed[:0], streamNonce(prefix, counter, last), plain[:n], headerBytes)
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// decryptStream reads the encrypted file at expandedPath from r and writes
+// the plaintext to w. Files written in the older single-block formats are
+// decrypted in memory.
+func decryptStream(w io.Writer, r io.Reader, expandedPath, password string) error {
+	return decryptChunks(w, r, expandedPath, password, false)
+}
+
+// decryptChunks implements decryptStream, stopping after the first chunk
+// when firstOnly is set
+func decryptChunks(w io.Writer, r io.Reader, expandedPath, password string, firstOnly bool) error {
+	br := bufio.NewReaderSize(r, streamChunkSize+streamTagSize)
+	start, _ := br.Peek(len(sqliteHeader))
+
+	var headerBytes []byte
+	var header kdfHeader
+	var err error
+	switch {
+	case bytes.HasPrefix(start, fileMagic):
+		headerBytes, header, err = readFileHeader(br)
+	case bytes.HasPrefix(start, streamMagic):
+		headerBytes, header, err = readStreamHeader(br)
+	case bytes.HasPrefix(start, []byte("JRNL")) && !bytes.HasPrefix(start, kdfMagic):
+		// A format this version doesn't know yet
+		return ErrNewerFormat
+	case bytes.Equal(start, sqliteHeader):
+		return fmt.Errorf("%w: it is an unencrypted SQLite database", ErrNotJournal)
+	default:
+		return decryptSingle(w, br, expandedPath, password)
+	}
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(headerKey(password, header))
+	if err != nil {
+		return err
+	}
+	prefix := headerBytes[len(headerBytes)-streamNoncePrefix:]
+
+	sealed := make([]byte, streamChunkSize+streamTagSize)
+	plain := make([]byte, 0, streamChunkSize)
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(br, sealed)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := n < len(sealed)
+		if !last {
+			if _, err := br.Peek(1); err == io.EOF {
+				last = true
+			}
+		}
+
+		plain, err = gcm.Open(plain[:0], streamNonce(prefix, counter, last), sealed[:n], headerBytes)
+		if err != nil {
+			if counter == 0 {
+				return ErrInvalidPassword
+			}
+			return ErrCorruptJournal
+		}
+		if counter == 0 {
+			// Re-encrypting with this password keeps the salt and parameters
+			rememberHeader(expandedPath, password, header)
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		if last || firstOnly {
+			return nil
+		}
+	}
+}
+
+// readFileHeader reads the header of the current format, checking that
+// this version knows its format, cipher, and key derivation
+func readFileHeader(br *bufio.Reader) ([]byte, kdfHeader, error) {
+	headerBytes := make([]byte, fileHeaderSize)
+	if _, err := io.ReadFull(br, headerBytes); err != nil {
+		return nil, kdfHeader{}, ErrCorruptJournal
+	}
+	fields := headerBytes[len(fileMagic):]
+	version := binary.BigEndian.Uint16(fields)
+	switch {
+	case version > streamFormatVersion:
+		return nil, kdfHeader{}, fmt.Errorf("%w (format %d, this version reads up to %d)", ErrNewerFormat, version, streamFormatVersion)
+	case version < streamFormatVersion:
+		return nil, kdfHeader{}, ErrCorruptJournal
+	}
+	if cipher := fields[2]; cipher != cipherAESGCMChunks {
+		return nil, kdfHeader{}, fmt.Errorf("%w (unknown cipher %d)", ErrNewerFormat, cipher)
+	}
+	kdf := append(slices.Clone(kdfMagic), fields[3:3+kdfFieldsSize]...)
+	if algorithm := kdf[len(kdfMagic)]; algorithm != 1 && algorithm != 2 {
+		return nil, kdfHeader{}, fmt.Errorf("%w (unknown key derivation %d)", ErrNewerFormat, algorithm)
+	}
+	header, err := parseKDFFields(kdf)
+	if err != nil {
+		return nil, kdfHeader{}, err
+	}
+	return headerBytes, header, nil
+}
+
+// readStreamHeader reads the header of format 1, which had no version or
+// cipher fields
+func readStreamHeader(br *bufio.Reader) ([]byte, kdfHeader, error) {
+	headerBytes := make([]byte, streamHeaderSize)
+	if _, err := io.ReadFull(br, headerBytes); err != nil {
+		return nil, kdfHeader{}, ErrCorruptJournal
+	}
+	header, err := parseKDFFields(headerBytes[:kdfHeaderSize])
+	if err != nil {
+		return nil, kdfHeader{}, err
+	}
+	return headerBytes, header, nil
+}
+
+// decryptSingle decrypts a file in the single-block formats used before
+// chunked encryption. The oldest has no header at all, so any other file
+// fails here as a wrong password unless it is too short to be a journal.
+func decryptSingle(w io.Writer, br *bufio.Reader, expandedPath, password string) error {
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return err
+	}
+	if len(data) < singleBlockMinSize {
+		return ErrNotJournal
+	}
+	plaintext, err := decrypt(data, expandedPath, password)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(plaintext)
+	return err
+}
+
+// singleBlockMinSize is the size of a single-block file holding nothing:
+// a GCM nonce and tag
+const singleBlockMinSize = 12 + streamTagSize
+
+// encryptFile encrypts the SQLite file at srcPath into the journal file,
+// replacing it atomically. srcPath may be the journal file itself.
+func encryptFile(srcPath, expandedPath, password string) error {
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	tmpPath, err := encryptBeside(in, expandedPath, password)
+	// Close the source before renaming, which may replace it
+	in.Close()
+	if err != nil {
+		return err
+	}
+	return replaceJournalFile(tmpPath, expandedPath)
+}
+
+// PreviousSuffix is appended to an encrypted journal's path to name the
+// copy of the file as it was before the last write
+const PreviousSuffix = ".bak"
+
+// replaceJournalFile renames the new journal file at tmpPath over the
+// journal, keeping the file it replaces as the journal's previous copy.
+// The journal path holds a whole file throughout, so a crash leaves either
+// version in place.
+func replaceJournalFile(tmpPath, expandedPath string) error {
+	if err := keepPrevious(expandedPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("keeping the previous journal file: %w", err)
+	}
+	if err := os.Rename(tmpPath, expandedPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// keepPrevious links the journal file to its previous copy, replacing the
+// copy kept by the write before. Filesystems without hard links get a
+// copy of the file instead.
+func keepPrevious(expandedPath string) error {
+	info, err := os.Stat(expandedPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		// A journal that was never written has nothing to keep
+		return nil
+	}
+
+	previousPath := expandedPath + PreviousSuffix
+	tmp, err := os.CreateTemp(filepath.Dir(expandedPath), "."+filepath.Base(previousPath)+"-*")
+	if err != nil {
+		return err
+	}
+	linkPath := tmp.Name()
+	tmp.Close()
+	os.Remove(linkPath)
+	if err := os.Link(expandedPath, linkPath); err != nil {
+		logger.Debug("can't link the previous journal file, copying it", "path", expandedPath, "err", err)
+		return copyFile(expandedPath, previousPath)
+	}
+	if err := os.Rename(linkPath, previousPath); err != nil {
+		os.Remove(linkPath)
+		return err
+	}
+	return nil
+}
+
+// removePrevious securely deletes the journal's previous copy if present,
+// for when it mustn't outlive a change of the journal's encryption
+func removePrevious(expandedPath string) error {
+	err := secureRemove(expandedPath + PreviousSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// encryptBeside encrypts the SQLite database read from r into a new file
+// next to the journal file, returning its path for the caller to rename
+// over the journal
+func encryptBeside(r io.Reader, expandedPath, password string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(expandedPath), dirPerm()); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(expandedPath), ".encrypt-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+
+	start := time.Now()
+	bw := bufio.NewWriter(tmp)
+	err = encryptStream(bw, r, expandedPath, password)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		// The file must be on disk before it is renamed over the journal
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpPath, filePerm())
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	logger.Debug("encrypted journal", "path", expandedPath, "duration", time.Since(start))
+	return tmpPath, nil
+}
+
+// checkPassword verifies password against an encrypted journal file. For
+// the chunked format only the first chunk is decrypted.
+func checkPassword(expandedPath, password string) error {
+	in, err := os.Open(expandedPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return decryptChunks(io.Discard, in, expandedPath, password, true)
+}
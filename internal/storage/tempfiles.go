@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Encrypted journals used to be decrypted to journal-*.db files in the
+// temp directory while they were open; they are now decrypted into memory.
+// A crash or kill of an older version left these plaintext copies behind,
+// so they are still swept on startup.
+
+// StaleTempAge is how old a temporary journal file must be before it is
+// treated as left over. Older versions running alongside only keep them
+// for the length of a single storage operation, so this leaves them alone.
+const StaleTempAge = 10 * time.Minute
+
+// tempDBPrefix and tempDBSuffix match the names given by os.CreateTemp to
+// decrypted journal copies
+const (
+	tempDBPrefix = "journal-"
+	tempDBSuffix = ".db"
+)
+
+// sqliteSidecarSuffixes are the files SQLite creates next to a database
+var sqliteSidecarSuffixes = []string{"", "-journal", "-wal", "-shm"}
+
+// CleanStaleTempFiles securely deletes decrypted journal copies older than
+// olderThan from the temp directory, returning how many were removed. Only
+// files of the user running the app are touched, and only when the file
+// opened is still the one listed.
+func CleanStaleTempFiles(olderThan time.Duration) (_ int, err error) {
+	defer trackOp("CleanStaleTempFiles", os.TempDir())(&err)
+
+	dir := os.TempDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	var firstErr error
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isTempDBName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) || !ownedByUser(info) {
+			continue
+		}
+		if err := overwriteAndRemove(filepath.Join(dir, entry.Name()), info); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		removed++
+	}
+	return removed, firstErr
+}
+
+// isTempDBName reports whether name is a decrypted journal copy, or one of
+// its SQLite sidecar files
+func isTempDBName(name string) bool {
+	for _, suffix := range sqliteSidecarSuffixes {
+		base, ok := strings.CutSuffix(name, tempDBSuffix+suffix)
+		if !ok {
+			continue
+		}
+		random, ok := strings.CutPrefix(base, tempDBPrefix)
+		if !ok || random == "" {
+			return false
+		}
+		for _, r := range random {
+			if r < '0' || r > '9' {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// secureRemove overwrites a file with zeros before deleting it. On
+// copy-on-write filesystems and SSDs the old blocks may survive, so this
+// is best effort.
+func secureRemove(path string) error {
+	return overwriteAndRemove(path, nil)
+}
+
+// overwriteAndRemove is secureRemove of a file found by listing its
+// directory, leaving it alone when listed is set and path no longer names
+// the file listed, e.g. because it was replaced by a link since
+func overwriteAndRemove(path string, listed fs.FileInfo) error {
+	f, err := openForOverwrite(path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if !info.Mode().IsRegular() || (listed != nil && !os.SameFile(info, listed)) {
+		f.Close()
+		return fmt.Errorf("%s changed while it was being removed", path)
+	}
+
+	zeros := make([]byte, 64*1024)
+	for remaining := info.Size(); remaining > 0; {
+		n := int64(len(zeros))
+		if remaining < n {
+			n = remaining
+		}
+		if _, err := f.Write(zeros[:n]); err != nil {
+			f.Close()
+			return err
+		}
+		remaining -= n
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
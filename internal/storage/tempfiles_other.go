@@ -0,0 +1,19 @@
+//go:build !unix
+
+package storage
+
+import (
+	"io/fs"
+	"os"
+)
+
+// ownedByUser reports whether the file was created by the user running the
+// app. The temp directory is private to each user on Windows.
+func ownedByUser(info fs.FileInfo) bool {
+	return true
+}
+
+// openForOverwrite opens path for writing
+func openForOverwrite(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY, 0)
+}
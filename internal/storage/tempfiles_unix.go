@@ -0,0 +1,22 @@
+//go:build unix
+
+package storage
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// ownedByUser reports whether the file was created by the user running the
+// app, rather than another user sharing the temp directory
+func ownedByUser(info fs.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(st.Uid) == os.Getuid()
+}
+
+// openForOverwrite opens path for writing without following a symlink in
+// its place, or blocking on a FIFO
+func openForOverwrite(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+}
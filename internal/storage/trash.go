@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"journal/internal/model"
+)
+
+// Deleted attachments are moved to a trash rather than removed, so
+// restoring an old version of an entry can bring back the files it had.
+// They are removed for good once they are older than AttachmentTrashAge,
+// when another attachment is deleted, or with their entry.
+
+// AttachmentTrashAge is how long a deleted attachment is kept
+const AttachmentTrashAge = 30 * 24 * time.Hour
+
+// errNotInTrash is returned when restoring an attachment that isn't kept
+var errNotInTrash = errors.New("the attachment is no longer kept")
+
+// attachmentTrashSchema holds the deleted attachments of a journal database
+const attachmentTrashSchema = `
+	CREATE TABLE IF NOT EXISTS attachment_trash (
+		id TEXT PRIMARY KEY,
+		entry_id TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		mime_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		data BLOB NOT NULL,
+		created_at DATETIME NOT NULL,
+		deleted_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_attachment_trash_entry ON attachment_trash(entry_id);
+`
+
+// trashAttachmentDB moves an attachment to the trash, and removes the
+// attachments deleted more than AttachmentTrashAge before now
+func trashAttachmentDB(db *sql.DB, d dialect, attachmentID string, now time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now = now.UTC()
+	if _, err := tx.Exec(d.rebind(`DELETE FROM attachment_trash WHERE id = ? OR deleted_at < ?`), attachmentID, now.Add(-AttachmentTrashAge)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(d.rebind(`
+		INSERT INTO attachment_trash (id, entry_id, filename, mime_type, size, data, created_at, deleted_at)
+		SELECT id, entry_id, filename, mime_type, size, data, created_at, ? FROM attachments WHERE id = ?
+	`), now, attachmentID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(d.rebind(`DELETE FROM attachments WHERE id = ?`), attachmentID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// trashedAttachmentsDB returns the metadata of an entry's deleted
+// attachments, most recently deleted first
+func trashedAttachmentsDB(db *sql.DB, d dialect, entryID string) ([]model.Attachment, error) {
+	rows, err := db.Query(d.rebind(`
+		SELECT id, entry_id, filename, mime_type, size, created_at
+		FROM attachment_trash WHERE entry_id = ?
+		ORDER BY deleted_at DESC
+	`), entryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []model.Attachment
+	for rows.Next() {
+		var att model.Attachment
+		if err := rows.Scan(&att.ID, &att.EntryID, &att.Filename, &att.MimeType, &att.Size, &att.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, att)
+	}
+	return attachments, rows.Err()
+}
+
+// restoreAttachmentDB moves an attachment from the trash back to its entry
+func restoreAttachmentDB(db *sql.DB, d dialect, attachmentID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(d.rebind(`
+		INSERT INTO attachments (id, entry_id, filename, mime_type, size, data, created_at)
+		SELECT id, entry_id, filename, mime_type, size, data, created_at FROM attachment_trash WHERE id = ?
+	`), attachmentID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errNotInTrash
+	}
+	if _, err := tx.Exec(d.rebind(`DELETE FROM attachment_trash WHERE id = ?`), attachmentID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// TrashedAttachments returns the metadata of an entry's deleted attachments
+// that are still kept, most recently deleted first
+func TrashedAttachments(path, entryID string) (_ []model.Attachment, err error) {
+	defer trackOp("TrashedAttachments", path)(&err)
+
+	var attachments []model.Attachment
+	err = viewDB(path, "", func(db *sql.DB) error {
+		if err := migrateSchema(db); err != nil {
+			return err
+		}
+		attachments, err = trashedAttachmentsDB(db, sqliteDialect, entryID)
+		return err
+	})
+	return attachments, err
+}
+
+// RestoreAttachment brings back a deleted attachment that is still kept
+func RestoreAttachment(path, attachmentID string) (err error) {
+	defer trackOp("RestoreAttachment", path)(&err)
+
+	db, err := openDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := migrateSchema(db); err != nil {
+		return err
+	}
+	return restoreAttachmentDB(db, sqliteDialect, attachmentID)
+}
+
+// TrashedAttachmentsEncrypted returns the metadata of an entry's deleted
+// attachments that are still kept in an encrypted journal's attachment
+// store, most recently deleted first
+func TrashedAttachmentsEncrypted(path, password, entryID string) (_ []model.Attachment, err error) {
+	defer trackOp("TrashedAttachmentsEncrypted", path)(&err)
+
+	store, err := openAttachmentStore(path, password, false)
+	if err != nil || store == nil {
+		return nil, err
+	}
+	defer store.Close()
+	return store.trashed(entryID)
+}
+
+// RestoreAttachmentEncrypted brings back a deleted attachment that is
+// still kept in an encrypted journal's attachment store
+func RestoreAttachmentEncrypted(path, password, attachmentID string) (err error) {
+	defer trackOp("RestoreAttachmentEncrypted", path)(&err)
+
+	store, err := openAttachmentStore(path, password, false)
+	if err != nil {
+		return err
+	}
+	if store == nil {
+		return errNotInTrash
+	}
+	defer store.Close()
+	return store.restore(attachmentID)
+}
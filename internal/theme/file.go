@@ -0,0 +1,43 @@
+package theme
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// File is a theme as written to share with other users: one of the
+// built-in themes with colors laid over it, like the theme and
+// theme_colors settings of the config file
+type File struct {
+	Theme  string            `json:"theme"`
+	Colors map[string]string `json:"colors,omitempty"`
+}
+
+// ParseFile reads a theme file, checking its theme name and colors
+func ParseFile(data []byte) (File, error) {
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{}, fmt.Errorf("not a theme file: %w", err)
+	}
+	if f.Theme == "" {
+		f.Theme = "monochrome"
+	}
+	if !slices.Contains(List(), f.Theme) {
+		return File{}, fmt.Errorf("unknown theme %q, expected one of %s", f.Theme, strings.Join(List(), ", "))
+	}
+	if err := ValidateColors(f.Colors); err != nil {
+		return File{}, err
+	}
+	return f, nil
+}
+
+// Marshal formats f as written to a theme file
+func (f File) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
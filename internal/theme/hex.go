@@ -0,0 +1,45 @@
+package theme
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ansiColors are the xterm colors of the 16 basic ANSI color numbers
+var ansiColors = [16]string{
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// cubeLevels are the channel values of the 6x6x6 color cube, ANSI colors
+// 16 to 231
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// Hex returns a theme color, as in Colors, as a #rrggbb color for use
+// outside the terminal, such as in HTML. ANSI color numbers are given
+// their xterm colors; a color that can't be read returns "".
+func Hex(color string) string {
+	if hex, ok := strings.CutPrefix(color, "#"); ok {
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return ""
+		}
+		return "#" + strings.ToLower(hex)
+	}
+
+	n, err := strconv.Atoi(color)
+	switch {
+	case err != nil || n < 0 || n > 255:
+		return ""
+	case n < 16:
+		return ansiColors[n]
+	case n < 232:
+		n -= 16
+		return fmt.Sprintf("#%02x%02x%02x", cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6])
+	}
+	gray := 8 + (n-232)*10
+	return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+}
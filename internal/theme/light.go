@@ -0,0 +1,139 @@
+package theme
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Terminal backgrounds a theme can be rendered for. The built-in themes
+// are designed for dark backgrounds; each has a light variant with darker
+// colors that read well on light ones.
+const (
+	BackgroundAuto  = "auto" // Detected from the terminal
+	BackgroundLight = "light"
+	BackgroundDark  = "dark"
+)
+
+// light is whether the light variants of themes are used
+var light bool
+
+var lightThemes = map[string]Theme{
+	"default": {
+		Name:     "default",
+		Title:    lipgloss.Color("127"),
+		Accent:   lipgloss.Color("163"),
+		Selected: lipgloss.Color("162"),
+		Muted:    lipgloss.Color("244"),
+		Text:     lipgloss.Color("235"),
+		TextDim:  lipgloss.Color("240"),
+		Success:  lipgloss.Color("28"),
+		Error:    lipgloss.Color("160"),
+		Warning:  lipgloss.Color("130"),
+		Info:     lipgloss.Color("31"),
+		Disabled: lipgloss.Color("250"),
+	},
+	"ocean": {
+		Name:     "ocean",
+		Title:    lipgloss.Color("25"),
+		Accent:   lipgloss.Color("31"),
+		Selected: lipgloss.Color("24"),
+		Muted:    lipgloss.Color("244"),
+		Text:     lipgloss.Color("234"),
+		TextDim:  lipgloss.Color("240"),
+		Success:  lipgloss.Color("29"),
+		Error:    lipgloss.Color("161"),
+		Warning:  lipgloss.Color("136"),
+		Info:     lipgloss.Color("32"),
+		Disabled: lipgloss.Color("250"),
+	},
+	"forest": {
+		Name:     "forest",
+		Title:    lipgloss.Color("22"),
+		Accent:   lipgloss.Color("28"),
+		Selected: lipgloss.Color("28"),
+		Muted:    lipgloss.Color("244"),
+		Text:     lipgloss.Color("235"),
+		TextDim:  lipgloss.Color("240"),
+		Success:  lipgloss.Color("34"),
+		Error:    lipgloss.Color("160"),
+		Warning:  lipgloss.Color("136"),
+		Info:     lipgloss.Color("65"),
+		Disabled: lipgloss.Color("250"),
+	},
+	"sunset": {
+		Name:     "sunset",
+		Title:    lipgloss.Color("166"),
+		Accent:   lipgloss.Color("130"),
+		Selected: lipgloss.Color("160"),
+		Muted:    lipgloss.Color("244"),
+		Text:     lipgloss.Color("236"),
+		TextDim:  lipgloss.Color("241"),
+		Success:  lipgloss.Color("64"),
+		Error:    lipgloss.Color("124"),
+		Warning:  lipgloss.Color("172"),
+		Info:     lipgloss.Color("173"),
+		Disabled: lipgloss.Color("250"),
+	},
+	"monochrome": {
+		Name:     "monochrome",
+		Title:    lipgloss.Color("232"),
+		Accent:   lipgloss.Color("238"),
+		Selected: lipgloss.Color("232"),
+		Muted:    lipgloss.Color("244"),
+		Text:     lipgloss.Color("235"),
+		TextDim:  lipgloss.Color("240"),
+		Success:  lipgloss.Color("232"),
+		Error:    lipgloss.Color("232"),
+		Warning:  lipgloss.Color("238"),
+		Info:     lipgloss.Color("240"),
+		Disabled: lipgloss.Color("250"),
+	},
+	"dracula": {
+		Name:     "dracula",
+		Title:    lipgloss.Color("61"),
+		Accent:   lipgloss.Color("162"),
+		Selected: lipgloss.Color("29"),
+		Muted:    lipgloss.Color("103"),
+		Text:     lipgloss.Color("236"),
+		TextDim:  lipgloss.Color("242"),
+		Success:  lipgloss.Color("29"),
+		Error:    lipgloss.Color("167"),
+		Warning:  lipgloss.Color("136"),
+		Info:     lipgloss.Color("31"),
+		Disabled: lipgloss.Color("252"),
+	},
+}
+
+// SetBackground chooses the theme variants for the terminal background:
+// BackgroundLight, BackgroundDark, or BackgroundAuto (or empty) to detect
+// it. Detection asks the terminal, through termenv, so it must happen
+// before the program takes over the terminal.
+func SetBackground(mode string) error {
+	switch mode {
+	case "", BackgroundAuto:
+		light = !lipgloss.HasDarkBackground()
+	case BackgroundLight:
+		light = true
+	case BackgroundDark:
+		light = false
+	default:
+		return fmt.Errorf("invalid background %q, expected %q, %q, or %q", mode, BackgroundAuto, BackgroundLight, BackgroundDark)
+	}
+	current = withOverrides(variant(current.Name))
+	return nil
+}
+
+// IsLight reports whether the light variants of themes are in use
+func IsLight() bool {
+	return light
+}
+
+// variant returns the theme called name in the variant for the terminal
+// background
+func variant(name string) Theme {
+	if t, ok := lightThemes[name]; ok && light {
+		return t
+	}
+	return Get(name)
+}
@@ -0,0 +1,89 @@
+package theme
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ColorNames are the theme colors that can be overridden, as written in
+// the config file
+var ColorNames = []string{
+	"title", "accent", "selected", "muted", "text", "text_dim",
+	"success", "error", "warning", "info", "disabled",
+}
+
+// overrides are the colors laid over every theme, by name
+var overrides map[string]lipgloss.Color
+
+// colorValue matches a hex color (#rgb or #rrggbb) or an ANSI color number
+var colorValue = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[0-9]{1,3})$`)
+
+// SetOverrides sets colors laid over the base theme, keyed by the names in
+// ColorNames, e.g. {"accent": "#ff8800"}. Values are hex colors or ANSI
+// color numbers. The overrides apply to the current theme and every theme
+// set afterwards.
+func SetOverrides(colors map[string]string) error {
+	if err := ValidateColors(colors); err != nil {
+		return err
+	}
+	parsed := make(map[string]lipgloss.Color, len(colors))
+	for name, value := range colors {
+		parsed[name] = lipgloss.Color(value)
+	}
+	overrides = parsed
+	current = withOverrides(variant(current.Name))
+	return nil
+}
+
+// ValidateColors checks that colors are keyed by the names in ColorNames
+// and hold hex colors or ANSI color numbers
+func ValidateColors(colors map[string]string) error {
+	for name, value := range colors {
+		if !slices.Contains(ColorNames, name) {
+			return fmt.Errorf("unknown theme color %q, expected one of %s", name, strings.Join(ColorNames, ", "))
+		}
+		if !colorValue.MatchString(value) {
+			return fmt.Errorf("invalid value %q for theme color %q, use a hex color like #ff8800 or an ANSI color number", value, name)
+		}
+	}
+	return nil
+}
+
+// withOverrides returns t with the overridden colors replaced
+func withOverrides(t Theme) Theme {
+	fields := colorFields(&t)
+	for name, color := range overrides {
+		*fields[name] = color
+	}
+	return t
+}
+
+// colorFields returns t's colors by their names in ColorNames
+func colorFields(t *Theme) map[string]*lipgloss.Color {
+	return map[string]*lipgloss.Color{
+		"title":    &t.Title,
+		"accent":   &t.Accent,
+		"selected": &t.Selected,
+		"muted":    &t.Muted,
+		"text":     &t.Text,
+		"text_dim": &t.TextDim,
+		"success":  &t.Success,
+		"error":    &t.Error,
+		"warning":  &t.Warning,
+		"info":     &t.Info,
+		"disabled": &t.Disabled,
+	}
+}
+
+// Colors returns every color of t by its name in ColorNames
+func Colors(t Theme) map[string]string {
+	colors := make(map[string]string, len(ColorNames))
+	for name, color := range colorFields(&t) {
+		colors[name] = string(*color)
+	}
+	return colors
+}
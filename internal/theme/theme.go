@@ -122,9 +122,10 @@ func Current() Theme {
 	return current
 }
 
-// Set sets the current theme by name
+// Set sets the current theme by name, in the variant for the terminal
+// background and with any color overrides laid over it
 func Set(name string) {
-	current = Get(name)
+	current = withOverrides(variant(name))
 }
 
 // List returns all available theme names
@@ -1,15 +1,22 @@
 package ui
 
 import (
+	"fmt"
+	"slices"
 	"sort"
-	"time"
+	"strings"
 
+	"journal/internal/clock"
+	"journal/internal/dates"
 	"journal/internal/model"
+	"journal/internal/search"
+	"journal/internal/share"
 	"journal/internal/storage"
 	"journal/internal/theme"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 )
 
 // ViewState represents the current view
@@ -21,20 +28,43 @@ const (
 	ViewPassword
 	ViewList
 	ViewEditor
+	ViewReader
 	ViewSettings
 	ViewDeleteConfirm
 	ViewHistory
 	ViewAttachments
 	ViewExport
+	ViewWords
+	ViewTasks
+	ViewTags
+	ViewSearch
+	ViewRestore
+	ViewEncryption
+	ViewThemes
+	ViewEntryExport
+	ViewCalendar
+	ViewLoops
+	ViewStats
+	ViewShare
 )
 
 // App is the main application model
 type App struct {
 	config        *model.Config
-	journal       *model.Journal
 	activeJournal *model.JournalDB
 	currentView   ViewState
 	password      string
+	store         storage.Store // Storage of the active journal
+	lock          *entryLock    // Entries of the active journal locked against edits
+	searchOptions search.Options
+
+	// openStore opens the storage of a journal. It is storage.OpenStore
+	// unless the app is run against another backend
+	openStore func(journal *model.JournalDB, password string) storage.Store
+
+	// clock and ids stamp and name new entries, history, and attachments
+	clock clock.Clock
+	ids   clock.IDGenerator
 
 	// Sub-models
 	selectorModel    SelectorModel
@@ -42,23 +72,63 @@ type App struct {
 	passwordModel    PasswordModel
 	listModel        ListModel
 	editorModel      EditorModel
+	readerModel      ReaderModel
 	settingsModel    SettingsModel
 	historyModel     HistoryModel
 	attachmentModel  AttachmentModel
 	exportModel      ExportModel
+	entryExportModel EntryExportModel
+	wordReportModel  WordReportModel
+	tasksModel       TasksModel
+	tagsModel        TagsModel
+	calendarModel    CalendarModel
+	loopsModel       LoopsModel
+	statsModel       StatsModel
+	shareModel       ShareModel
+	searchModel      SearchModel
+	restoreModel     RestoreModel
+	encryptionModel  EncryptionModel
+	themeModel       ThemeModel
+
+	// Error screen. retryFrom is the state before the update that failed,
+	// and retryMsg the message it was handling; nil for startup errors.
+	errorModel         ErrorModel
+	retryFrom          *App
+	retryMsg           tea.Msg
+	restoreFromError   bool // The restore wizard was opened from the error screen
+	exportFromSettings bool // The entry export was opened from settings, for the whole journal
 
 	// State
-	width  int
-	height int
-	err    error
+	width     int
+	height    int
+	err       error
+	openToday bool // Open today's entry once the active journal is open
+	lowMemory bool // Keep as little of the journal in memory as possible
 }
 
 // InitialModel creates the initial application model
 func InitialModel() App {
+	app := initialModel()
+	if app.err != nil {
+		path, _ := storage.GetConfigPath()
+		app.errorModel = NewErrorModel("Loading the configuration", path, app.err,
+			[]errorAction{errorActionRetry, errorActionQuit})
+	}
+	return app
+}
+
+func initialModel() App {
 	app := App{
 		currentView: ViewSetup,
+		openStore:   storage.OpenStore,
+		clock:       clock.System,
+		ids:         clock.UUID,
 	}
 
+	// Remove decrypted copies left in the temp directory by sessions that
+	// crashed or were killed
+	staleTemps, _ := storage.CleanStaleTempFiles(storage.StaleTempAge)
+
 	// Check if config exists
 	exists, err := storage.ConfigExists()
 	if err != nil {
@@ -80,53 +150,326 @@ func InitialModel() App {
 		}
 
 		// Set theme from config
+		if err := theme.SetBackground(config.Background); err != nil {
+			app.err = err
+			return app
+		}
 		if config.Theme != "" {
 			theme.Set(config.Theme)
 		}
+		if err := theme.SetOverrides(config.ThemeColors); err != nil {
+			app.err = err
+			return app
+		}
+		if err := config.CheckTemplates(); err != nil {
+			app.err = err
+			return app
+		}
+		if err := config.CheckListBadges(); err != nil {
+			app.err = err
+			return app
+		}
+
+		if err := storage.SetKDFParams(config.KDF); err != nil {
+			app.err = err
+			return app
+		}
+		if err := storage.SetFileMode(config.FileMode); err != nil {
+			app.err = err
+			return app
+		}
+
+		// Take any scheduled backups that have come due
+		backups, backupErr := storage.RunScheduledBackups(config, app.clock.Now())
+		if backups > 0 {
+			storage.SaveConfig(config)
+		}
 
 		// If there are journals, show selector
 		if len(config.Journals) > 0 {
 			journals := storage.GetSortedJournals(config)
 			app.selectorModel = NewSelectorModel(journals, config.Theme)
+			app.selectorModel.SetSize(app.contentSize())
+			var notices []string
+			if staleTemps > 0 {
+				notices = append(notices, fmt.Sprintf("Removed %d leftover temporary file(s) from an earlier session", staleTemps))
+			}
+			if backupErr != nil {
+				notices = append(notices, backupErr.Error())
+			} else if backups > 0 {
+				notices = append(notices, fmt.Sprintf("Scheduled backup saved for %d journal(s)", backups))
+			}
+			app.selectorModel.Notice = strings.Join(notices, "\n")
 			app.currentView = ViewSelector
 		} else {
 			app.setupModel = NewSetupModel()
 			app.currentView = ViewSetup
 		}
 	} else {
+		theme.SetBackground(theme.BackgroundAuto)
 		app.setupModel = NewSetupModel()
 	}
 
 	return app
 }
 
-func sortEntriesNewestFirst(journal *model.Journal) {
-	sort.Slice(journal.Entries, func(i, j int) bool {
-		return journal.Entries[i].Date > journal.Entries[j].Date
-	})
+// OpenToday makes the app open the active journal straight into today's
+// entry, or a new entry for today, instead of showing the journal selector
+func (a App) OpenToday() App {
+	a.openToday = true
+	return a
+}
+
+// LowMemory makes the app read entries without their history, and turns
+// off the search cache and attachment previews, for running on small
+// servers or old hardware
+func (a App) LowMemory() App {
+	a.lowMemory = true
+	return a
 }
 
+// openTodayMsg opens the active journal for OpenToday
+type openTodayMsg struct{}
+
 func (a App) Init() tea.Cmd {
+	if a.currentView == ViewSelector {
+		if a.openToday {
+			return tea.Batch(a.countStaleStats(), func() tea.Msg { return openTodayMsg{} })
+		}
+		return a.countStaleStats()
+	}
 	return nil
 }
 
 func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if a.err != nil {
+		return a.updateError(msg)
+	}
+
+	next, cmd := a.update(msg)
+	if next.currentView == ViewSelector && a.currentView != ViewSelector {
+		cmd = tea.Batch(cmd, next.countStaleStats())
+		// Server journals are counted again on leaving them, which
+		// contacts the server while it was in use anyway
+		if j := a.activeJournal; j != nil && j.Format == model.FormatPostgres {
+			cmd = tea.Batch(cmd, countStats(*j, ""))
+		}
+	}
+	if next.err != nil {
+		prev := a
+		next.retryFrom = &prev
+		next.retryMsg = msg
+		next.errorModel = NewErrorModel(viewOperation(a.currentView), next.errorPath(), next.err, next.errorActions())
+	}
+	return next, cmd
+}
+
+// viewOperation describes what a view was doing when it failed
+func viewOperation(view ViewState) string {
+	switch view {
+	case ViewSelector:
+		return "Opening the journal"
+	case ViewSetup:
+		return "Creating the journal"
+	case ViewPassword:
+		return "Unlocking the journal"
+	case ViewList:
+		return "Loading entries"
+	case ViewEditor:
+		return "Saving the entry"
+	case ViewReader:
+		return "Loading the entry"
+	case ViewSettings:
+		return "Saving settings"
+	case ViewDeleteConfirm:
+		return "Deleting the entry"
+	case ViewHistory:
+		return "Updating version history"
+	case ViewAttachments:
+		return "Updating attachments"
+	case ViewExport:
+		return "Exporting"
+	case ViewEntryExport:
+		return "Exporting entries"
+	case ViewWords:
+		return "Building the word report"
+	case ViewTasks:
+		return "Updating tasks"
+	case ViewTags:
+		return "Listing tags"
+	case ViewCalendar:
+		return "Loading the month"
+	case ViewLoops:
+		return "Updating open loops"
+	case ViewStats:
+		return "Counting entries"
+	case ViewShare:
+		return "Sharing the entry"
+	case ViewSearch:
+		return "Searching"
+	case ViewRestore:
+		return "Restoring a backup"
+	case ViewEncryption:
+		return "Changing encryption"
+	case ViewThemes:
+		return "Saving the theme"
+	}
+	return ""
+}
+
+// errorPath returns the file an error most likely concerns
+func (a App) errorPath() string {
+	if a.activeJournal != nil {
+		return displayPath(*a.activeJournal)
+	}
+	if a.currentView == ViewSetup {
+		return displayPath(model.JournalDB{Path: a.setupModel.DBPath, Format: a.setupModel.Format})
+	}
+	return ""
+}
+
+// errorActions lists the recovery actions available in the current state
+func (a App) errorActions() []errorAction {
+	actions := []errorAction{errorActionRetry}
+	if a.config != nil && len(a.config.Journals) > 0 {
+		actions = append(actions, errorActionSelector)
+	}
+	// Backups of encrypted journals can only be listed once unlocked
+	if a.config != nil && a.activeJournal != nil && (!a.activeJournal.Encrypted || a.password != "") {
+		actions = append(actions, errorActionBackups)
+	}
+	return append(actions, errorActionQuit)
+}
+
+// updateError handles the error screen
+func (a App) updateError(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		a.width = msg.Width
 		a.height = msg.Height
-		switch a.currentView {
-		case ViewList:
-			a.listModel.SetSize(msg.Width, msg.Height)
-		case ViewEditor:
-			a.editorModel.SetSize(msg.Width, msg.Height)
-		case ViewHistory:
-			a.historyModel.SetSize(msg.Width, msg.Height)
-		case ViewAttachments:
-			a.attachmentModel.SetSize(msg.Width, msg.Height)
+		a.sizeViews()
+		return a, nil
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return a, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	a.errorModel, cmd = a.errorModel.Update(msg)
+	if !a.errorModel.Done {
+		return a, cmd
+	}
+
+	switch a.errorModel.Chosen {
+	case errorActionRetry:
+		if a.retryFrom == nil {
+			app := InitialModel()
+			return app.Update(tea.WindowSizeMsg{Width: a.width, Height: a.height})
+		}
+		prev, _ := a.retryFrom.update(tea.WindowSizeMsg{Width: a.width, Height: a.height})
+		return prev.Update(a.retryMsg)
+
+	case errorActionSelector:
+		a.clearError()
+		a.selectorModel = NewSelectorModel(storage.GetSortedJournals(a.config), a.config.Theme)
+		a.selectorModel.SetSize(a.contentSize())
+		a.currentView = ViewSelector
+		a.activeJournal = nil
+		storage.CloseSessions()
+		a.password = ""
+		return a, a.countStaleStats()
+
+	case errorActionBackups:
+		a.clearError()
+		a.restoreModel = NewRestoreModel(a.activeJournal, a.journalPassword())
+		a.restoreFromError = true
+		a.currentView = ViewRestore
+
+	case errorActionQuit:
+		return a, tea.Quit
+	}
+	return a, nil
+}
+
+// duplicateWarning warns when entry's content is nearly identical to an
+// entry on another date, which is usually the same text saved twice
+func (a App) duplicateWarning(entry model.Entry) string {
+	similar, err := a.store.SimilarEntries(entry.ID, entry.Content)
+	if err != nil || len(similar) == 0 {
+		// The check is advisory; a failure leaves the save as it is
+		return ""
+	}
+	others := make([]string, len(similar))
+	for i, s := range similar {
+		others[i] = s.Date
+	}
+	return "This entry is nearly identical to the entry of " + strings.Join(others, ", ")
+}
+
+func (a *App) clearError() {
+	a.err = nil
+	a.retryFrom = nil
+	a.retryMsg = nil
+}
+
+// statsMsg carries the newly counted stats of the journal at path
+type statsMsg struct {
+	path  string
+	stats model.Stats
+}
+
+// countStats counts the stats of journal in the background. Failures are
+// left for opening the journal to report.
+func countStats(journal model.JournalDB, password string) tea.Cmd {
+	return func() tea.Msg {
+		stats, err := storage.CountStats(&journal, password)
+		if err != nil {
+			return nil
+		}
+		return statsMsg{path: journal.Path, stats: stats}
+	}
+}
+
+// countStaleStats counts the stats shown in the selector of journals whose
+// files changed since they were last counted. Encrypted journals are
+// counted when they are unlocked instead, and server journals when they
+// are left.
+func (a App) countStaleStats() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, j := range a.config.Journals {
+		if !j.Encrypted && storage.StatsStale(&j) {
+			cmds = append(cmds, countStats(j, ""))
 		}
+	}
+	return tea.Batch(cmds...)
+}
+
+func (a App) update(msg tea.Msg) (App, tea.Cmd) {
+	switch msg := msg.(type) {
+	case statsMsg:
+		storage.UpdateJournalStats(a.config, msg.path, msg.stats)
+		a.selectorModel.SetStats(msg.path, msg.stats)
+		storage.SaveConfig(a.config)
+		return a, nil
+
+	case tea.WindowSizeMsg:
+		a.width = msg.Width
+		a.height = msg.Height
+		a.sizeViews()
 		return a, nil
 
+	case openTodayMsg:
+		if a.currentView != ViewSelector {
+			return a, nil
+		}
+		journal := storage.FindJournal(a.config, a.config.ActiveJournal)
+		if journal == nil {
+			// The most recently opened journal
+			journal = &storage.GetSortedJournals(a.config)[0]
+		}
+		return a, a.openJournal(journal)
+
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c":
@@ -146,39 +489,22 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				storage.SaveConfig(a.config)
 			}
 
-			if a.selectorModel.CreateNew {
+			if a.selectorModel.Fix != FixNone {
+				return a, a.fixJournal()
+			}
+			if a.selectorModel.PickTheme {
+				a.themeModel = NewThemeModel(theme.Current().Name)
+				a.currentView = ViewThemes
+				return a, nil
+			}
+			if a.selectorModel.CreateNew || a.selectorModel.OpenExisting {
 				a.setupModel = NewSetupModel(a.existingJournalPaths()...)
+				if a.selectorModel.OpenExisting {
+					a.setupModel.OpenExisting()
+				}
 				a.currentView = ViewSetup
 			} else if a.selectorModel.Selected != nil {
-				// Find the journal in config to get a pointer into config.Journals
-				// (selectorModel.Selected is a copy, not a reference into config)
-				selected := a.selectorModel.Selected
-				a.activeJournal = storage.FindJournal(a.config, selected.Path)
-				if a.activeJournal == nil {
-					// Fallback: use the selector's copy
-					a.activeJournal = selected
-				}
-
-				// Update last opened time
-				storage.UpdateJournalLastOpened(a.config, a.activeJournal.Path, time.Now())
-				a.config.ActiveJournal = a.activeJournal.Path
-				storage.SaveConfig(a.config)
-
-				if a.activeJournal.Encrypted {
-					a.passwordModel = NewPasswordModel()
-					a.currentView = ViewPassword
-				} else {
-					journal, err := storage.LoadJournal(a.activeJournal.Path)
-					if err != nil {
-						a.err = err
-						return a, nil
-					}
-					a.journal = journal
-					sortEntriesNewestFirst(a.journal)
-					a.currentView = ViewList
-					a.listModel = NewListModel(a.journal)
-					a.listModel.SetSize(a.width, a.height)
-				}
+				cmd = tea.Batch(cmd, a.openJournal(a.selectorModel.Selected))
 			}
 		}
 
@@ -189,25 +515,69 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				a.config = &model.Config{}
 			}
 
+			// Shown in the list once the journal is open
+			var notice string
+
 			// Add new journal to config
 			storage.AddJournal(a.config, a.setupModel.Name, a.setupModel.DBPath, a.setupModel.Encrypt)
+			storage.FindJournal(a.config, a.setupModel.DBPath).Format = a.setupModel.Format
+			storage.FindJournal(a.config, a.setupModel.DBPath).PasswordHint = a.setupModel.PasswordHint
+			storage.FindJournal(a.config, a.setupModel.DBPath).EncryptionMode = a.setupModel.EncryptionMode
 			a.config.ActiveJournal = a.setupModel.DBPath
 
+			// Calibrate key derivation for this machine the first time a
+			// journal is encrypted
+			if a.setupModel.Encrypt && a.config.KDF == nil {
+				params := storage.CalibrateKDF(storage.KDFCalibrationTarget)
+				a.config.KDF = &params
+				if err := storage.SetKDFParams(a.config.KDF); err != nil {
+					a.err = err
+					return a, nil
+				}
+			}
+
 			// Find the journal we just added
 			a.activeJournal = storage.FindJournal(a.config, a.setupModel.DBPath)
-			storage.UpdateJournalLastOpened(a.config, a.setupModel.DBPath, time.Now())
+			storage.UpdateJournalLastOpened(a.config, a.setupModel.DBPath, a.clock.Now())
 
 			if err := storage.SaveConfig(a.config); err != nil {
 				a.err = err
 				return a, nil
 			}
 
-			if a.setupModel.Encrypt {
+			if a.setupModel.Overwrite {
+				if err := storage.OverwriteJournal(a.setupModel.DBPath, a.clock.Now()); err != nil {
+					a.err = err
+					return a, nil
+				}
+			}
+
+			if a.setupModel.Existing {
+				// Opened as it is; an encrypted one's password was checked
+				a.password = a.setupModel.Password
+			} else if a.setupModel.Format == model.FormatMarkdown {
+				if err := storage.CreateMarkdownJournal(a.setupModel.DBPath); err != nil {
+					a.err = err
+					return a, nil
+				}
+			} else if a.setupModel.Format == model.FormatPostgres {
+				if err := storage.CreatePostgresJournal(a.setupModel.DBPath); err != nil {
+					a.err = err
+					return a, nil
+				}
+			} else if a.setupModel.Encrypt {
 				a.password = a.setupModel.Password
 				if err := storage.CreateEmptyJournalEncrypted(a.setupModel.DBPath, a.password); err != nil {
 					a.err = err
 					return a, nil
 				}
+				if sheet := a.setupModel.RecoverySheet; sheet != "" {
+					if err := storage.WriteRecoverySheet(sheet, a.activeJournal, a.config.KDF, a.clock.Now()); err != nil {
+						notice = "Couldn't save the recovery sheet: " + err.Error()
+					} else {
+						notice = "Recovery sheet saved to " + sheet + ". Print it or copy it somewhere safe, then delete the file"
+					}
+				}
 			} else {
 				if err := storage.CreateEmptyJournal(a.setupModel.DBPath); err != nil {
 					a.err = err
@@ -215,10 +585,12 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 
-			a.journal = &model.Journal{Entries: []model.Entry{}}
+			if err := a.openList(); err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.listModel.Warning = notice
 			a.currentView = ViewList
-			a.listModel = NewListModel(a.journal)
-			a.listModel.SetSize(a.width, a.height)
 		}
 
 	case ViewPassword:
@@ -227,18 +599,18 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Go back to selector
 			journals := storage.GetSortedJournals(a.config)
 			a.selectorModel = NewSelectorModel(journals, a.config.Theme)
+			a.selectorModel.SetSize(a.contentSize())
 			a.currentView = ViewSelector
 			a.activeJournal = nil
+			storage.CloseSessions()
 			a.password = ""
+			a.openToday = false
 			return a, nil
 		}
 		if a.passwordModel.Done {
-			journal, err := storage.LoadJournalEncrypted(a.activeJournal.Path, a.passwordModel.Password)
-			if err != nil {
+			if err := storage.UnlockJournal(a.activeJournal.Path, a.passwordModel.Password); err != nil {
 				if err == storage.ErrInvalidPassword {
-					a.passwordModel.Error = "Invalid password"
-					a.passwordModel.Done = false
-					a.passwordModel.Password = ""
+					a.passwordModel.Failed()
 				} else {
 					a.err = err
 				}
@@ -246,31 +618,47 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 			a.password = a.passwordModel.Password
-			a.journal = journal
-			sortEntriesNewestFirst(a.journal)
+			if err := a.openList(); err != nil {
+				a.err = err
+				return a, nil
+			}
 			a.currentView = ViewList
-			a.listModel = NewListModel(a.journal)
-			a.listModel.SetSize(a.width, a.height)
+			if storage.StatsStale(a.activeJournal) {
+				cmd = countStats(*a.activeJournal, a.password)
+			}
+			if a.openToday {
+				cmd = tea.Batch(cmd, a.openTodayEditor())
+			}
 		}
 
 	case ViewList:
+		if _, ok := msg.(tea.FocusMsg); ok {
+			// Back from another window, where the journal may have been
+			// synced or its files edited
+			if err := a.listModel.CheckExternalChanges(); err != nil {
+				a.err = err
+				return a, nil
+			}
+		}
 		a.listModel, cmd = a.listModel.Update(msg)
 
 		switch a.listModel.Action {
 		case ActionNewEntry:
-			a.editorModel = NewEditorModel(nil)
-			a.editorModel.SetSize(a.width, a.height)
+			a.editorModel = NewEditorModel(nil, a.config, a.clock, a.ids, storeDayWords(a.store))
+			a.editorModel.SetSize(a.contentSize())
 			a.currentView = ViewEditor
 			a.listModel.Action = ActionNone
 			return a, a.editorModel.Init()
 
 		case ActionEditEntry:
-			if a.listModel.SelectedIndex >= 0 && a.listModel.SelectedIndex < len(a.journal.Entries) {
-				entry := &a.journal.Entries[a.listModel.SelectedIndex]
-				a.editorModel = NewEditorModel(entry)
-				a.editorModel.SetSize(a.width, a.height)
+			a.listModel.Action = ActionNone
+			if entry, err := a.selectedEntry(); err != nil {
+				a.err = err
+				return a, nil
+			} else if entry != nil {
+				a.editorModel = NewEditorModel(entry, a.config, a.clock, a.ids, storeDayWords(a.store))
+				a.editorModel.SetSize(a.contentSize())
 				a.currentView = ViewEditor
-				a.listModel.Action = ActionNone
 				return a, a.editorModel.Init()
 			}
 
@@ -278,143 +666,364 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			a.currentView = ViewDeleteConfirm
 			a.listModel.Action = ActionNone
 
+		case ActionDuplicateEntry:
+			a.listModel.Action = ActionNone
+			entry, err := a.selectedEntry()
+			if err != nil {
+				a.err = err
+				return a, nil
+			} else if entry == nil {
+				return a, nil
+			}
+			// The copy starts its own history; attachments stay with the
+			// original
+			now := a.clock.Now()
+			duplicate := model.Entry{
+				ID:        a.ids.NewID(),
+				Date:      a.listModel.DuplicateDate,
+				Content:   entry.Content,
+				Tags:      entry.Tags,
+				Mood:      entry.Mood,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if err := a.store.SaveEntries([]model.Entry{duplicate}); err != nil {
+				a.err = err
+				return a, nil
+			}
+			if err := a.listModel.Reload(); err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.listModel.SelectEntry(duplicate.ID)
+
+		case ActionRead:
+			a.listModel.Action = ActionNone
+			reader, err := NewReaderModel(a.listModel.entries, a.listModel.SelectedIndex)
+			if err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.readerModel = reader
+			a.readerModel.SetSize(a.contentSize())
+			a.currentView = ViewReader
+
 		case ActionViewHistory:
-			if a.listModel.SelectedIndex >= 0 && a.listModel.SelectedIndex < len(a.journal.Entries) {
-				entry := &a.journal.Entries[a.listModel.SelectedIndex]
-				a.historyModel = NewHistoryModel(entry)
-				a.historyModel.SetSize(a.width, a.height)
+			a.listModel.Action = ActionNone
+			if summary, ok := a.listModel.Selected(); ok {
+				entry, err := getEntryWithHistory(a.store, summary.ID)
+				if err != nil {
+					a.err = err
+					return a, nil
+				}
+				a.historyModel = NewHistoryModel(entry, a.store, a.clock)
+				if a.lock.Locked(entry.ID, entry.Date) {
+					a.historyModel.Lock(a.lock.Reason(entry.Date))
+				}
+				a.historyModel.SetSize(a.contentSize())
 				a.currentView = ViewHistory
-				a.listModel.Action = ActionNone
 			}
 
 		case ActionViewAttachments:
-			if a.listModel.SelectedIndex >= 0 && a.listModel.SelectedIndex < len(a.journal.Entries) {
-				entry := &a.journal.Entries[a.listModel.SelectedIndex]
-				a.attachmentModel = NewAttachmentModel(entry, a.activeJournal.Path, a.activeJournal.Encrypted, a.password)
-				a.attachmentModel.SetSize(a.width, a.height)
+			a.listModel.Action = ActionNone
+			if entry, err := a.selectedEntry(); err != nil {
+				a.err = err
+				return a, nil
+			} else if entry != nil {
+				a.attachmentModel = NewAttachmentModel(entry, a.store, a.searchOptions, a.clock, a.ids)
+				if a.lock.Locked(entry.ID, entry.Date) {
+					a.attachmentModel.Lock(a.lock.Reason(entry.Date))
+				}
+				if a.lowMemory {
+					a.attachmentModel.DisablePreview()
+				}
+				a.attachmentModel.SetCheck(a.config.AttachmentCheck)
+				a.attachmentModel.SetSize(a.contentSize())
 				a.currentView = ViewAttachments
-				a.listModel.Action = ActionNone
 			}
 
-		case ActionSettings:
-			a.settingsModel = NewSettingsModel(a.config, a.activeJournal)
-			a.currentView = ViewSettings
+		case ActionSearch:
+			a.searchModel = NewSearchModel(a.store, a.searchOptions)
+			a.searchModel.SetSize(a.contentSize())
+			a.currentView = ViewSearch
 			a.listModel.Action = ActionNone
+			return a, a.searchModel.Init()
 
-		case ActionQuit:
-			return a, tea.Quit
-		}
-
-	case ViewEditor:
-		a.editorModel, cmd = a.editorModel.Update(msg)
-
-		if a.editorModel.Cancelled {
-			a.currentView = ViewList
-			a.editorModel.Cancelled = false
-		} else if a.editorModel.Saved {
-			newDate := a.editorModel.GetDate()
-			duplicate := false
-			for _, e := range a.journal.Entries {
-				if e.Date == newDate {
-					if a.editorModel.EditingEntry != nil && e.ID == a.editorModel.EditingEntry.ID {
-						continue
-					}
-					duplicate = true
-					break
-				}
+		case ActionWordReport:
+			// The word report reads every entry, so it loads the whole
+			// journal while it is open
+			journal, err := a.loadJournal()
+			if err != nil {
+				a.err = err
+				return a, nil
 			}
+			a.wordReportModel = NewWordReportModel(journal)
+			a.wordReportModel.SetSize(a.contentSize())
+			a.currentView = ViewWords
+			a.listModel.Action = ActionNone
 
-			if duplicate {
-				a.editorModel.Error = "An entry for " + newDate + " already exists"
-				a.editorModel.Saved = false
+		case ActionStats:
+			// Counted from the loaded journal, which the store decrypts
+			// for encrypted journals
+			a.listModel.Action = ActionNone
+			journal, err := a.loadJournal()
+			if err != nil {
+				a.err = err
 				return a, nil
 			}
+			a.statsModel = NewStatsModel(journal, a.clock.Now())
+			a.statsModel.SetSize(a.contentSize())
+			a.currentView = ViewStats
 
-			entry := a.editorModel.GetEntry()
-			if a.editorModel.EditingEntry != nil {
-				for i, e := range a.journal.Entries {
-					if e.ID == entry.ID {
-						if e.Content != entry.Content {
-							historyRecord := model.SaveRecord{
-								Content:     e.Content,
-								SavedAt:     e.UpdatedAt,
-								Attachments: e.AttachmentFilenames(),
-							}
-							entry.History = append(e.History, historyRecord)
-						} else {
-							entry.History = e.History
-						}
-						entry.Attachments = e.Attachments
-						a.journal.Entries[i] = entry
-						break
-					}
+		case ActionExportEntries:
+			a.listModel.Action = ActionNone
+			var entries []model.Entry
+			for _, id := range a.listModel.MarkedIDs() {
+				entry, err := getEntryWithHistory(a.store, id)
+				if err != nil {
+					a.err = err
+					return a, nil
 				}
-			} else {
-				a.journal.Entries = append(a.journal.Entries, entry)
+				entries = append(entries, *entry)
 			}
+			sort.Slice(entries, func(i, j int) bool { return entries[i].Date < entries[j].Date })
+			a.entryExportModel = NewEntryExportModel(entries, a.store, a.activeJournal.Name)
+			a.exportFromSettings = false
+			a.currentView = ViewEntryExport
+			return a, a.entryExportModel.Init()
 
-			sortEntriesNewestFirst(a.journal)
-			if err := a.saveJournal(); err != nil {
+		case ActionTasks:
+			a.listModel.Action = ActionNone
+			tasks, err := NewTasksModel(a.store, a.clock, a.lock)
+			if err != nil {
 				a.err = err
 				return a, nil
 			}
+			a.tasksModel = tasks
+			a.tasksModel.SetSize(a.contentSize())
+			a.currentView = ViewTasks
 
-			a.listModel = NewListModel(a.journal)
-			a.listModel.SetSize(a.width, a.height)
-			a.currentView = ViewList
-			a.editorModel.Saved = false
-		}
+		case ActionLoops:
+			a.listModel.Action = ActionNone
+			loops, err := NewLoopsModel(a.store, a.clock, a.lock)
+			if err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.loopsModel = loops
+			a.loopsModel.SetSize(a.contentSize())
+			a.currentView = ViewLoops
 
-	case ViewDeleteConfirm:
-		if keyMsg, ok := msg.(tea.KeyMsg); ok {
-			switch keyMsg.String() {
-			case "y", "Y":
-				if a.listModel.SelectedIndex >= 0 && a.listModel.SelectedIndex < len(a.journal.Entries) {
-					entryID := a.journal.Entries[a.listModel.SelectedIndex].ID
-					a.journal.Entries = append(
-						a.journal.Entries[:a.listModel.SelectedIndex],
-						a.journal.Entries[a.listModel.SelectedIndex+1:]...,
-					)
-					// Delete from database (handles attachments too)
-					if a.activeJournal.Encrypted {
-						a.saveJournal()
-					} else {
-						storage.DeleteEntry(a.activeJournal.Path, entryID)
-					}
-					a.listModel = NewListModel(a.journal)
-					a.listModel.SetSize(a.width, a.height)
-				}
-				a.currentView = ViewList
-			case "n", "N", "esc":
-				a.currentView = ViewList
+		case ActionHighlight:
+			a.listModel.Action = ActionNone
+			today := a.clock.Now().Format(dates.Layout)
+			entry, err := storage.AddHighlight(a.store, today, a.listModel.Highlight, a.config.TemplateFor(today), a.clock, a.ids)
+			if err != nil {
+				a.err = err
+				return a, nil
 			}
-		}
+			if err := a.listModel.Reload(); err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.listModel.SelectEntry(entry.ID)
+			a.listModel.Message = "Added to today's highlights"
 
-	case ViewHistory:
-		a.historyModel, cmd = a.historyModel.Update(msg)
+		case ActionGoals:
+			a.listModel.Action = ActionNone
+			return a, a.openGoalsEditor()
+
+		case ActionShare:
+			a.listModel.Action = ActionNone
+			entry, err := a.selectedEntry()
+			if err != nil {
+				a.err = err
+				return a, nil
+			} else if entry == nil {
+				return a, nil
+			}
+			page, err := storage.EntryHTML(*entry, a.store, storage.HTMLOptions{
+				Title: a.activeJournal.Name,
+				Theme: theme.Current(),
+				Light: theme.IsLight(),
+			})
+			if err != nil {
+				a.err = err
+				return a, nil
+			}
+			server, err := share.Start(page, shareDuration)
+			if err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.shareModel = NewShareModel(server, entry.Date)
+			a.currentView = ViewShare
+			return a, a.shareModel.Init()
+
+		case ActionCalendar:
+			a.listModel.Action = ActionNone
+			today := a.clock.Now().Format(dates.Layout)
+			calendar, err := NewCalendarModel(a.store, a.clock, a.lock, today, a.config.RejectFutureDates, a.config.DayWordGoal())
+			if err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.calendarModel = calendar
+			a.calendarModel.SetSize(a.contentSize())
+			a.currentView = ViewCalendar
+
+		case ActionTags:
+			a.listModel.Action = ActionNone
+			tags, err := NewTagsModel(a.store, a.listModel.entries.filter.Tag)
+			if err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.tagsModel = tags
+			a.tagsModel.SetSize(a.contentSize())
+			a.currentView = ViewTags
+
+		case ActionSettings:
+			a.settingsModel = NewSettingsModel(a.config, a.activeJournal)
+			a.currentView = ViewSettings
+			a.listModel.Action = ActionNone
+
+		case ActionQuit:
+			return a, tea.Quit
+		}
+
+	case ViewEditor:
+		a.editorModel, cmd = a.editorModel.Update(msg)
+
+		if a.editorModel.Cancelled {
+			if a.editorModel.Autosaved() {
+				// The list doesn't show the saves made while editing yet
+				if err := a.listModel.Reload(); err != nil {
+					a.err = err
+					return a, nil
+				}
+				a.listModel.SelectEntry(a.editorModel.EditingEntry.ID)
+			}
+			a.currentView = ViewList
+			a.editorModel.Cancelled = false
+		} else if a.editorModel.Snapshot {
+			a.editorModel.Snapshot = false
+			if a.editorModel.EditingEntry == nil {
+				a.editorModel.Error = "Save the entry before taking a snapshot"
+				return a, nil
+			}
+			entry := a.editorModel.EditingEntry
+			record := model.SaveRecord{
+				Content:     a.editorModel.GetEntry().Content,
+				SavedAt:     a.clock.Now(),
+				Attachments: entry.AttachmentFilenames(),
+				Tags:        entry.Tags,
+				Label:       "Manual snapshot",
+			}
+			if err := a.store.AddHistoryRecord(entry.ID, record); err != nil {
+				a.editorModel.Error = err.Error()
+				return a, nil
+			}
+			entry.History = append(entry.History, record)
+			a.editorModel.Message = "Snapshot saved to history"
+		} else if a.editorModel.Autosave {
+			a.editorModel.Autosave = false
+			entry, ok := a.saveEditorEntry()
+			if a.err != nil {
+				return a, nil
+			}
+			if ok {
+				a.editorModel.Committed(entry)
+			}
+		} else if a.editorModel.Saved {
+			entry, ok := a.saveEditorEntry()
+			if !ok {
+				a.editorModel.Saved = false
+				return a, nil
+			}
+
+			if err := a.listModel.Reload(); err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.listModel.SelectEntry(entry.ID)
+			a.listModel.Warning = a.duplicateWarning(entry)
+			a.currentView = ViewList
+			a.editorModel.Saved = false
+		}
+
+	case ViewDeleteConfirm:
+		if keyMsg, ok := msg.(tea.KeyMsg); ok {
+			switch keyMsg.String() {
+			case "y", "Y":
+				if summary, ok := a.listModel.Selected(); ok {
+					// Delete from database (handles attachments too)
+					err := a.store.DeleteEntry(summary.ID)
+					if err == nil {
+						a.listModel.Unmark(summary.ID)
+						err = a.listModel.Reload()
+					}
+					if err != nil {
+						a.err = err
+						return a, nil
+					}
+				}
+				a.currentView = ViewList
+			case "n", "N", "esc":
+				a.currentView = ViewList
+			}
+		}
+
+	case ViewReader:
+		a.readerModel, cmd = a.readerModel.Update(msg)
+
+		if a.readerModel.Back {
+			a.readerModel.Back = false
+			a.listModel.SelectEntry(a.readerModel.Entry().ID)
+			a.currentView = ViewList
+		} else if a.readerModel.Edit {
+			a.readerModel.Edit = false
+			entry := a.readerModel.Entry()
+			if a.lock.Locked(entry.ID, entry.Date) {
+				a.readerModel.Error = a.lock.Reason(entry.Date)
+				return a, nil
+			}
+			a.listModel.SelectEntry(entry.ID)
+			a.editorModel = NewEditorModel(entry, a.config, a.clock, a.ids, storeDayWords(a.store))
+			a.editorModel.SetSize(a.contentSize())
+			a.currentView = ViewEditor
+			return a, a.editorModel.Init()
+		}
+
+	case ViewHistory:
+		a.historyModel, cmd = a.historyModel.Update(msg)
 
 		if a.historyModel.Back {
+			// Refresh history counts, and the preview of a restored entry
+			if err := a.listModel.Reload(); err != nil {
+				a.err = err
+				return a, nil
+			}
 			a.currentView = ViewList
 			a.historyModel.Back = false
 		}
 
 	case ViewAttachments:
 		a.attachmentModel, cmd = a.attachmentModel.Update(msg)
+		a.searchOptions = a.attachmentModel.SearchOptions()
 
 		if a.attachmentModel.Back {
-			// Reload entry attachments
-			if a.listModel.SelectedIndex >= 0 && a.listModel.SelectedIndex < len(a.journal.Entries) {
-				entry := &a.journal.Entries[a.listModel.SelectedIndex]
-				entry.Attachments = a.attachmentModel.entry.Attachments
+			// Refresh attachment and history counts
+			if err := a.listModel.Reload(); err != nil {
+				a.err = err
+				return a, nil
 			}
 			a.currentView = ViewList
 			a.attachmentModel.Back = false
 		} else if a.attachmentModel.ExportSelected {
 			a.exportModel = NewExportModel(
 				a.attachmentModel.SelectedAttachment(),
-				a.activeJournal.Path,
-				a.activeJournal.Encrypted,
-				a.password,
+				a.store,
 			)
 			a.currentView = ViewExport
 			a.attachmentModel.ExportSelected = false
@@ -429,13 +1038,224 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			a.exportModel.Cancelled = false
 		}
 
+	case ViewEntryExport:
+		a.entryExportModel, cmd = a.entryExportModel.Update(msg)
+
+		if a.entryExportModel.Back {
+			a.currentView = ViewList
+			if a.exportFromSettings {
+				a.currentView = ViewSettings
+			}
+			a.entryExportModel.Back = false
+		}
+
+	case ViewWords:
+		a.wordReportModel, cmd = a.wordReportModel.Update(msg)
+
+		if a.wordReportModel.Back {
+			a.currentView = ViewList
+			a.wordReportModel.Back = false
+		}
+
+	case ViewStats:
+		a.statsModel, cmd = a.statsModel.Update(msg)
+
+		if a.statsModel.Back {
+			a.currentView = ViewList
+			a.statsModel.Back = false
+		}
+
+	case ViewShare:
+		a.shareModel, cmd = a.shareModel.Update(msg)
+
+		if a.shareModel.Back {
+			a.currentView = ViewList
+			a.shareModel.Back = false
+		}
+
+	case ViewTasks:
+		a.tasksModel, cmd = a.tasksModel.Update(msg)
+
+		if a.tasksModel.Back {
+			// Ticking tasks off changes entries
+			if err := a.listModel.Reload(); err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.currentView = ViewList
+			a.tasksModel.Back = false
+		} else if a.tasksModel.Open {
+			a.tasksModel.Open = false
+			if task, ok := a.tasksModel.Selected(); ok {
+				if a.lock.Locked(task.EntryID, task.Date) {
+					a.tasksModel.Error = a.lock.Reason(task.Date)
+					return a, nil
+				}
+				entry, err := a.store.GetEntry(task.EntryID)
+				if err != nil {
+					a.err = err
+					return a, nil
+				}
+				if err := a.listModel.Reload(); err != nil {
+					a.err = err
+					return a, nil
+				}
+				a.listModel.SelectEntry(entry.ID)
+				a.listModel.entries.external.viewed(entry.ID)
+				a.editorModel = NewEditorModel(entry, a.config, a.clock, a.ids, storeDayWords(a.store))
+				a.editorModel.SetSize(a.contentSize())
+				a.currentView = ViewEditor
+				return a, a.editorModel.Init()
+			}
+		}
+
+	case ViewLoops:
+		a.loopsModel, cmd = a.loopsModel.Update(msg)
+
+		if a.loopsModel.Back {
+			// Closing loops changes entries
+			if err := a.listModel.Reload(); err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.currentView = ViewList
+			a.loopsModel.Back = false
+		} else if a.loopsModel.Open {
+			a.loopsModel.Open = false
+			if loop, ok := a.loopsModel.Selected(); ok {
+				if a.lock.Locked(loop.EntryID, loop.Date) {
+					a.loopsModel.Error = a.lock.Reason(loop.Date)
+					return a, nil
+				}
+				entry, err := a.store.GetEntry(loop.EntryID)
+				if err != nil {
+					a.err = err
+					return a, nil
+				}
+				if err := a.listModel.Reload(); err != nil {
+					a.err = err
+					return a, nil
+				}
+				a.listModel.SelectEntry(entry.ID)
+				a.listModel.entries.external.viewed(entry.ID)
+				a.editorModel = NewEditorModel(entry, a.config, a.clock, a.ids, storeDayWords(a.store))
+				a.editorModel.SetSize(a.contentSize())
+				a.currentView = ViewEditor
+				return a, a.editorModel.Init()
+			}
+		}
+
+	case ViewCalendar:
+		a.calendarModel, cmd = a.calendarModel.Update(msg)
+
+		if a.calendarModel.Back {
+			a.currentView = ViewList
+			a.calendarModel.Back = false
+		} else if a.calendarModel.Open {
+			a.calendarModel.Open = false
+			return a, a.openDateEditor(a.calendarModel.Date())
+		}
+
+	case ViewTags:
+		a.tagsModel, cmd = a.tagsModel.Update(msg)
+
+		if a.tagsModel.Back {
+			a.currentView = ViewList
+			a.tagsModel.Back = false
+		} else if a.tagsModel.Chosen {
+			a.tagsModel.Chosen = false
+			if tag, ok := a.tagsModel.Selected(); ok {
+				a.listModel.FilterTag(tag)
+			}
+			a.currentView = ViewList
+		}
+
+	case ViewSearch:
+		a.searchModel, cmd = a.searchModel.Update(msg)
+		a.searchOptions = a.searchModel.Options()
+
+		if a.searchModel.Back {
+			a.currentView = ViewList
+			a.searchModel.Back = false
+		} else if a.searchModel.Open {
+			a.searchModel.Open = false
+			if entry := a.searchModel.SelectedEntry(); entry != nil {
+				a.listModel.SelectEntry(entry.ID)
+				if a.lock.Locked(entry.ID, entry.Date) {
+					// Locked entries are unlocked from the list
+					a.listModel.Warning = a.lock.Reason(entry.Date)
+					a.currentView = ViewList
+					return a, nil
+				}
+				a.listModel.entries.external.viewed(entry.ID)
+				a.editorModel = NewEditorModel(entry, a.config, a.clock, a.ids, storeDayWords(a.store))
+				a.editorModel.SetSize(a.contentSize())
+				a.currentView = ViewEditor
+				return a, a.editorModel.Init()
+			}
+		} else if a.searchModel.OpenHistory {
+			a.searchModel.OpenHistory = false
+			if entry := a.searchModel.SelectedEntry(); entry != nil {
+				a.listModel.SelectEntry(entry.ID)
+				if isLowMemory(a.store) {
+					full, err := getEntryWithHistory(a.store, entry.ID)
+					if err != nil {
+						a.err = err
+						return a, nil
+					}
+					entry = full
+				}
+				a.historyModel = NewHistoryModel(entry, a.store, a.clock)
+				if a.lock.Locked(entry.ID, entry.Date) {
+					a.historyModel.Lock(a.lock.Reason(entry.Date))
+				}
+				a.historyModel.SetSize(a.contentSize())
+				a.historyModel.SelectVersion(a.searchModel.SelectedVersion())
+				a.currentView = ViewHistory
+			}
+		}
+
 	case ViewSettings:
 		a.settingsModel, cmd = a.settingsModel.Update(msg)
 
 		if a.settingsModel.Cancelled {
 			a.currentView = ViewList
 			a.settingsModel.Cancelled = false
+		} else if a.settingsModel.OpenRestore {
+			a.settingsModel.OpenRestore = false
+			a.restoreModel = NewRestoreModel(a.activeJournal, a.journalPassword())
+			a.currentView = ViewRestore
+		} else if a.settingsModel.OpenExport {
+			a.settingsModel.OpenExport = false
+			journal, err := a.loadJournal()
+			if err != nil {
+				a.err = err
+				return a, nil
+			}
+			sort.Slice(journal.Entries, func(i, j int) bool { return journal.Entries[i].Date < journal.Entries[j].Date })
+			a.entryExportModel = NewEntryExportModel(journal.Entries, a.store, a.activeJournal.Name)
+			a.entryExportModel.ExportFolder()
+			a.exportFromSettings = true
+			a.currentView = ViewEntryExport
+			return a, a.entryExportModel.Init()
+		} else if a.settingsModel.OpenEncryption {
+			a.settingsModel.OpenEncryption = false
+			a.encryptionModel = NewEncryptionModel(a.activeJournal, a.journalPassword())
+			a.currentView = ViewEncryption
+			return a, a.encryptionModel.Init()
 		} else if a.settingsModel.Saved {
+			a.config.MoodTracking = a.settingsModel.MoodTracking
+			a.config.BackupSchedule = a.settingsModel.BackupSchedule
+			a.config.AutosaveMinutes = a.settingsModel.AutosaveMinutes
+			a.config.AwaySaveMinutes = a.settingsModel.AwaySaveMinutes
+			if a.activeJournal != nil {
+				a.activeJournal.LockAfterDays = a.settingsModel.LockAfterDays
+				if j := storage.FindJournal(a.config, a.activeJournal.Path); j != nil {
+					j.LockAfterDays = a.settingsModel.LockAfterDays
+				}
+				a.lock.days = a.settingsModel.LockAfterDays
+			}
+
 			oldPath := a.config.ActiveJournal
 			newPath := a.settingsModel.DBPath
 
@@ -471,21 +1291,10 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					a.activeJournal.Path = newPath
 				}
 
-				var journal *model.Journal
-				var err error
-				if a.activeJournal != nil && a.activeJournal.Encrypted {
-					journal, err = storage.LoadJournalEncrypted(newPath, a.password)
-				} else {
-					journal, err = storage.LoadJournal(newPath)
-				}
-				if err != nil {
+				if err := a.openList(); err != nil {
 					a.err = err
 					return a, nil
 				}
-				a.journal = journal
-				sortEntriesNewestFirst(a.journal)
-				a.listModel = NewListModel(a.journal)
-				a.listModel.SetSize(a.width, a.height)
 			}
 
 			if err := storage.SaveConfig(a.config); err != nil {
@@ -496,11 +1305,136 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			a.currentView = ViewList
 			a.settingsModel.Saved = false
 		}
+
+	case ViewThemes:
+		a.themeModel, cmd = a.themeModel.Update(msg)
+
+		if a.themeModel.Done || a.themeModel.Cancelled {
+			if a.themeModel.Done {
+				a.config.Theme = a.themeModel.Chosen
+				if err := storage.SaveConfig(a.config); err != nil {
+					a.err = err
+					return a, nil
+				}
+			}
+			a.selectorModel = NewSelectorModel(storage.GetSortedJournals(a.config), a.config.Theme)
+			a.selectorModel.SetSize(a.contentSize())
+			a.currentView = ViewSelector
+		}
+
+	case ViewEncryption:
+		a.encryptionModel, cmd = a.encryptionModel.Update(msg)
+
+		if a.encryptionModel.Cancelled {
+			a.currentView = ViewSettings
+		} else if a.encryptionModel.Done {
+			a.activeJournal.Encrypted = !a.activeJournal.Encrypted
+			a.activeJournal.PasswordHint = a.encryptionModel.PasswordHint
+			if j := storage.FindJournal(a.config, a.activeJournal.Path); j != nil {
+				j.Encrypted = a.activeJournal.Encrypted
+				j.PasswordHint = a.activeJournal.PasswordHint
+			}
+			a.password = a.encryptionModel.Password
+			// The entry list reads the file through the store it was
+			// opened with, so it is opened again for the new format
+			if err := a.openList(); err != nil {
+				a.err = err
+				return a, nil
+			}
+			if err := storage.SaveConfig(a.config); err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.settingsModel = NewSettingsModel(a.config, a.activeJournal)
+			a.currentView = ViewSettings
+		}
+
+	case ViewRestore:
+		a.restoreModel, cmd = a.restoreModel.Update(msg)
+
+		if a.restoreModel.Cancelled && a.restoreFromError {
+			a.restoreFromError = false
+			a.selectorModel = NewSelectorModel(storage.GetSortedJournals(a.config), a.config.Theme)
+			a.selectorModel.SetSize(a.contentSize())
+			a.currentView = ViewSelector
+			a.activeJournal = nil
+			storage.CloseSessions()
+			a.password = ""
+		} else if a.restoreModel.Cancelled {
+			a.currentView = ViewSettings
+		} else if a.restoreModel.Done && a.restoreModel.Replaced {
+			if err := a.openList(); err != nil {
+				a.err = err
+				return a, nil
+			}
+			a.currentView = ViewList
+		} else if a.restoreModel.Done {
+			storage.AddJournal(a.config, a.restoreModel.NewName, a.restoreModel.NewPath, a.activeJournal.Encrypted)
+			if err := storage.SaveConfig(a.config); err != nil {
+				a.err = err
+				return a, nil
+			}
+
+			// Return to the selector so the restored journal can be opened
+			journals := storage.GetSortedJournals(a.config)
+			a.selectorModel = NewSelectorModel(journals, a.config.Theme)
+			a.selectorModel.SetSize(a.contentSize())
+			a.selectorModel.Notice = "Backup restored as \"" + a.restoreModel.NewName + "\""
+			a.currentView = ViewSelector
+			a.activeJournal = nil
+			storage.CloseSessions()
+			a.password = ""
+		}
 	}
 
 	return a, cmd
 }
 
+// fixJournal applies the fix chosen in the selector for a journal whose
+// files couldn't be read, then shows the selector again
+func (a *App) fixJournal() tea.Cmd {
+	journal := *a.selectorModel.Selected
+	var notice string
+	switch a.selectorModel.Fix {
+	case FixLocate:
+		storage.RelocateJournal(a.config, journal.Path, a.selectorModel.NewPath, a.selectorModel.NewEncrypted)
+		notice = "\"" + journal.Name + "\" found at " + a.selectorModel.NewPath
+	case FixRemove:
+		storage.RemoveJournal(a.config, journal.Path)
+		notice = "\"" + journal.Name + "\" removed from the list"
+	case FixRecreate:
+		var err error
+		if journal.Format == model.FormatMarkdown {
+			err = storage.CreateMarkdownJournal(journal.Path)
+		} else {
+			err = storage.CreateEmptyJournal(journal.Path)
+		}
+		if err != nil {
+			a.err = err
+			return nil
+		}
+		notice = "\"" + journal.Name + "\" recreated empty"
+		if j := storage.FindJournal(a.config, journal.Path); j != nil {
+			j.Stats = nil
+			if j.Encrypted {
+				// There is no password to encrypt the new file with yet
+				j.Encrypted = false
+				j.PasswordHint = ""
+				notice += ", without encryption; it can be encrypted again in its settings"
+			}
+		}
+	}
+	if err := storage.SaveConfig(a.config); err != nil {
+		a.err = err
+		return nil
+	}
+
+	a.selectorModel = NewSelectorModel(storage.GetSortedJournals(a.config), a.config.Theme)
+	a.selectorModel.SetSize(a.contentSize())
+	a.selectorModel.Notice = notice
+	return a.countStaleStats()
+}
+
 func (a App) existingJournalPaths() []string {
 	if a.config == nil {
 		return nil
@@ -512,20 +1446,300 @@ func (a App) existingJournalPaths() []string {
 	return paths
 }
 
-func (a App) saveJournal() error {
-	path := a.config.ActiveJournal
-	if a.activeJournal != nil {
-		path = a.activeJournal.Path
-	}
+// journalPassword returns the password of the active journal, or an empty
+// string when it is not encrypted
+func (a App) journalPassword() string {
 	if a.activeJournal != nil && a.activeJournal.Encrypted {
-		return storage.SaveJournalEncrypted(a.journal, path, a.password)
+		return a.password
 	}
-	return storage.SaveJournal(a.journal, path)
+	return ""
 }
 
+// openList opens the store of the active journal and builds its entry list.
+// Entries are paged in from storage as they scroll into view, so the full
+// journal is never held in memory
+// openJournal makes journal the active journal and shows its entries, or
+// asks for its password first when it's encrypted
+func (a *App) openJournal(journal *model.JournalDB) tea.Cmd {
+	// Find the journal in config to get a pointer into config.Journals
+	// (the selector's journal is a copy, not a reference into config)
+	a.activeJournal = storage.FindJournal(a.config, journal.Path)
+	if a.activeJournal == nil {
+		// Fallback: use the copy
+		a.activeJournal = journal
+	}
+
+	// Update last opened time
+	storage.UpdateJournalLastOpened(a.config, a.activeJournal.Path, a.clock.Now())
+	a.config.ActiveJournal = a.activeJournal.Path
+	storage.SaveConfig(a.config)
+
+	if a.activeJournal.Encrypted {
+		a.passwordModel = NewPasswordModel(a.activeJournal.PasswordHint)
+		a.currentView = ViewPassword
+		return nil
+	}
+	if err := a.openList(); err != nil {
+		a.err = err
+		return nil
+	}
+	a.currentView = ViewList
+	if a.openToday {
+		return a.openTodayEditor()
+	}
+	return nil
+}
+
+// openTodayEditor opens today's entry in the editor, or a new entry for
+// today when there isn't one yet
+func (a *App) openTodayEditor() tea.Cmd {
+	a.openToday = false
+	return a.openDateEditor(a.clock.Now().Format(dates.Layout))
+}
+
+// openDateEditor opens the entry dated date in the editor, or a new entry
+// for that date when there isn't one yet
+func (a *App) openDateEditor(date string) tea.Cmd {
+	id, err := a.store.FindEntryByDate(date)
+	if err != nil {
+		a.err = err
+		return nil
+	}
+	var entry *model.Entry
+	if id != "" {
+		if entry, err = a.store.GetEntry(id); err != nil {
+			a.err = err
+			return nil
+		}
+		a.listModel.SelectEntry(id)
+		a.listModel.entries.external.viewed(id)
+	}
+	a.editorModel = NewEditorModel(entry, a.config, a.clock, a.ids, storeDayWords(a.store))
+	if entry == nil {
+		a.editorModel.SetDate(date)
+	}
+	a.editorModel.SetSize(a.contentSize())
+	a.currentView = ViewEditor
+	return a.editorModel.Init()
+}
+
+// openGoalsEditor opens this month's goals entry in the editor, or starts
+// one dated the first of the month
+func (a *App) openGoalsEditor() tea.Cmd {
+	now := a.clock.Now()
+	first := now.AddDate(0, 0, 1-now.Day())
+	goals, err := a.store.ListEntries(0, 1, model.EntryFilter{
+		Tag:   model.GoalsTag,
+		Since: first.Format(dates.Layout),
+		Until: first.AddDate(0, 1, -1).Format(dates.Layout),
+	})
+	if err != nil {
+		a.err = err
+		return nil
+	}
+	if len(goals) == 0 {
+		a.editorModel = NewEditorModel(nil, a.config, a.clock, a.ids, storeDayWords(a.store))
+		a.editorModel.StartGoals(first.Format(dates.Layout))
+		a.editorModel.SetSize(a.contentSize())
+		a.currentView = ViewEditor
+		return a.editorModel.Init()
+	}
+
+	if a.lock.Locked(goals[0].ID, goals[0].Date) {
+		a.listModel.Warning = a.lock.Reason(goals[0].Date)
+		return nil
+	}
+	entry, err := a.store.GetEntry(goals[0].ID)
+	if err != nil {
+		a.err = err
+		return nil
+	}
+	a.listModel.SelectEntry(entry.ID)
+	a.listModel.entries.external.viewed(entry.ID)
+	a.editorModel = NewEditorModel(entry, a.config, a.clock, a.ids, storeDayWords(a.store))
+	a.editorModel.SetSize(a.contentSize())
+	a.currentView = ViewEditor
+	return a.editorModel.Init()
+}
+
+func (a *App) openList() error {
+	store := newWriteTracker(a.openStore(a.activeJournal, a.journalPassword()))
+	a.store = store
+	if a.lowMemory {
+		a.store = lowMemoryStore{store}
+	}
+	external, err := newExternalChanges(store)
+	if err != nil {
+		return err
+	}
+	entries, err := newEntryPager(a.store, a.clock, external)
+	if err != nil {
+		return err
+	}
+	a.lock = newEntryLock(a.activeJournal.LockAfterDays, a.clock)
+	a.listModel = NewListModel(entries, a.config.PreviewLen(), a.config.ListTitles, a.config.Badges(), a.lock)
+	a.listModel.SetSize(a.contentSize())
+	return nil
+}
+
+// loadJournal reads every entry of the active journal, newest first. Only
+// views that need the whole journal at once use it
+func (a App) loadJournal() (*model.Journal, error) {
+	journal, err := a.store.Load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(journal.Entries, func(i, j int) bool {
+		return journal.Entries[i].Date > journal.Entries[j].Date
+	})
+	return journal, nil
+}
+
+// saveEditorEntry saves the entry being edited, recording the version it
+// replaces in its history. A date taken by another entry is reported in
+// the editor, and a storage error in a.err; either way nothing is saved.
+func (a *App) saveEditorEntry() (model.Entry, bool) {
+	newDate := a.editorModel.GetDate()
+	existingID, err := a.store.FindEntryByDate(newDate)
+	if err != nil {
+		a.err = err
+		return model.Entry{}, false
+	}
+	if existingID != "" && (a.editorModel.EditingEntry == nil || existingID != a.editorModel.EditingEntry.ID) {
+		a.editorModel.Error = "An entry for " + newDate + " already exists"
+		return model.Entry{}, false
+	}
+
+	entry := a.editorModel.GetEntry()
+	if e := a.editorModel.EditingEntry; e != nil {
+		entry.History = e.History
+		if e.Content != entry.Content || !slices.Equal(e.Tags, entry.Tags) {
+			entry.History = append(entry.History, model.SaveRecord{
+				Content:     e.Content,
+				SavedAt:     e.UpdatedAt,
+				Attachments: e.AttachmentFilenames(),
+				Tags:        e.Tags,
+			})
+		}
+	}
+
+	if err := a.store.SaveEntries([]model.Entry{entry}); err != nil {
+		a.err = err
+		return model.Entry{}, false
+	}
+	return entry, true
+}
+
+// selectedEntry fetches the full selected entry, including its content,
+// history and attachments. It returns nil when nothing is selected. In
+// low-memory mode the history is left out.
+func (a App) selectedEntry() (*model.Entry, error) {
+	summary, ok := a.listModel.Selected()
+	if !ok {
+		return nil, nil
+	}
+	return a.store.GetEntry(summary.ID)
+}
+
+// The frame drawn around every view: a rounded border, with a column of
+// padding inside it on each side
+const (
+	frameWidth  = 4
+	frameHeight = 2
+)
+
+// contentSize returns the width and height inside the frame, which views
+// are sized to
+func (a App) contentSize() (int, int) {
+	return max(a.width-frameWidth, 0), max(a.height-frameHeight, 0)
+}
+
+// sizeViews sizes every view to the window, not only the one shown, so a
+// view returned to after a resize isn't drawn at the old size. Views are
+// also sized when they are created.
+func (a *App) sizeViews() {
+	width, height := a.contentSize()
+	a.selectorModel.SetSize(width, height)
+	a.listModel.SetSize(width, height)
+	// The editor's text area can't be sized until the editor is created
+	if a.editorModel.clock != nil {
+		a.editorModel.SetSize(width, height)
+	}
+	a.readerModel.SetSize(width, height)
+	a.historyModel.SetSize(width, height)
+	a.attachmentModel.SetSize(width, height)
+	a.wordReportModel.SetSize(width, height)
+	a.tasksModel.SetSize(width, height)
+	a.tagsModel.SetSize(width, height)
+	a.calendarModel.SetSize(width, height)
+	a.loopsModel.SetSize(width, height)
+	a.statsModel.SetSize(width, height)
+	a.searchModel.SetSize(width, height)
+}
+
+// View draws the current view in a frame filling the window, centred
+// across it. Until the window size is known the view is drawn unframed.
 func (a App) View() string {
+	if a.width > 0 && a.height > 0 && (a.width < minWindowWidth || a.height < minWindowHeight) {
+		return a.renderTooSmall()
+	}
+	body := a.viewBody()
+	if a.width == 0 || a.height == 0 {
+		return body
+	}
+	if a.err == nil && a.currentView == ViewEditor && a.editorModel.Zen() {
+		// Zen mode is drawn without the frame, centred in the window
+		return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, body)
+	}
+	t := theme.Current()
+	width, height := a.contentSize()
+
+	// Wrap lines that are too wide and clip what is too tall, so the frame
+	// is never broken
+	lines := strings.Split(ansi.Wrap(body, width, ""), "\n")
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	// Lines are padded to the widest, so the view is centred as a block
+	blockWidth := 0
+	for _, line := range lines {
+		blockWidth = max(blockWidth, ansi.StringWidth(line))
+	}
+	for i, line := range lines {
+		lines[i] = line + strings.Repeat(" ", blockWidth-ansi.StringWidth(line))
+	}
+	body = lipgloss.Place(width, height, lipgloss.Center, lipgloss.Top, strings.Join(lines, "\n"))
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.Muted).
+		Padding(0, 1).
+		Render(body)
+}
+
+// renderTooSmall asks for a larger window when no view fits. Keys still
+// reach the view underneath, so q still quits.
+func (a App) renderTooSmall() string {
+	t := theme.Current()
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Warning)
+	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+
+	lines := []string{
+		titleStyle.Render("Terminal too small"),
+		mutedStyle.Render(fmt.Sprintf("%d×%d, needs %d×%d", a.width, a.height, minWindowWidth, minWindowHeight)),
+		mutedStyle.Render("Enlarge the window"),
+	}
+	for i, line := range lines {
+		lines[i] = fitWidth(line, a.width)
+	}
+	body := strings.Join(lines[:min(len(lines), a.height)], "\n")
+	return lipgloss.Place(a.width, a.height, lipgloss.Center, lipgloss.Center, body)
+}
+
+// viewBody renders the current view
+func (a App) viewBody() string {
 	if a.err != nil {
-		return "Error: " + a.err.Error() + "\n\nPress Ctrl+C to quit."
+		return a.errorModel.View()
 	}
 
 	switch a.currentView {
@@ -539,6 +1753,8 @@ func (a App) View() string {
 		return a.listModel.View()
 	case ViewEditor:
 		return a.editorModel.View()
+	case ViewReader:
+		return a.readerModel.View()
 	case ViewSettings:
 		return a.settingsModel.View()
 	case ViewDeleteConfirm:
@@ -549,6 +1765,30 @@ func (a App) View() string {
 		return a.attachmentModel.View()
 	case ViewExport:
 		return a.exportModel.View()
+	case ViewEntryExport:
+		return a.entryExportModel.View()
+	case ViewWords:
+		return a.wordReportModel.View()
+	case ViewTasks:
+		return a.tasksModel.View()
+	case ViewTags:
+		return a.tagsModel.View()
+	case ViewCalendar:
+		return a.calendarModel.View()
+	case ViewLoops:
+		return a.loopsModel.View()
+	case ViewStats:
+		return a.statsModel.View()
+	case ViewShare:
+		return a.shareModel.View()
+	case ViewSearch:
+		return a.searchModel.View()
+	case ViewRestore:
+		return a.restoreModel.View()
+	case ViewEncryption:
+		return a.encryptionModel.View()
+	case ViewThemes:
+		return a.themeModel.View()
 	}
 
 	return ""
@@ -557,12 +1797,11 @@ func (a App) View() string {
 func (a App) renderDeleteConfirm() string {
 	t := theme.Current()
 
-	if a.listModel.SelectedIndex < 0 || a.listModel.SelectedIndex >= len(a.journal.Entries) {
+	entry, ok := a.listModel.Selected()
+	if !ok {
 		return "No entry selected"
 	}
 
-	entry := a.journal.Entries[a.listModel.SelectedIndex]
-
 	promptStyle := lipgloss.NewStyle().Foreground(t.Warning).Bold(true)
 	labelStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
 	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
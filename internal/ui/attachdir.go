@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"journal/internal/model"
+	"journal/internal/storage"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// attachDirRun is a folder whose files are being attached one at a time,
+// so the progress can be drawn between them
+type attachDirRun struct {
+	dir       string
+	files     []string // Names of the files to attach, in order
+	next      int      // Index of the next file to attach
+	added     int
+	failed    []string          // "name: error" for each file that couldn't be attached
+	record    *model.SaveRecord // Version before the first file, saved with it
+	cancelled bool
+}
+
+// attachDirStepMsg attaches the next file of the folder being attached
+type attachDirStepMsg struct{}
+
+func attachDirStep() tea.Msg {
+	return attachDirStepMsg{}
+}
+
+// attachDirFailuresShown is how many failed files are named after a
+// folder is attached
+const attachDirFailuresShown = 3
+
+// folderFiles returns the names of the files in dir matching the glob
+// pattern, in name order. Subfolders and hidden files are left out.
+func folderFiles(dir, pattern string) ([]string, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q", pattern)
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, de := range dirEntries {
+		if !de.Type().IsRegular() || strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, de.Name()); ok {
+			files = append(files, de.Name())
+		}
+	}
+	return files, nil
+}
+
+// startAttachDir begins attaching the files of the folder and pattern in
+// the inputs
+func (m *AttachmentModel) startAttachDir() tea.Cmd {
+	dir, err := storage.ExpandPath(m.dirInput.Value())
+	if err != nil {
+		m.Error = err.Error()
+		return nil
+	}
+	files, err := folderFiles(dir, m.globInput.Value())
+	if err != nil {
+		m.Error = err.Error()
+		return nil
+	}
+	if len(files) == 0 {
+		m.Error = "No files in " + dir + " match " + m.globInput.Value()
+		return nil
+	}
+
+	// One version records the attachments from before the whole folder
+	m.dirRun = &attachDirRun{
+		dir:   dir,
+		files: files,
+		record: &model.SaveRecord{
+			Content:     m.entry.Content,
+			SavedAt:     m.clock.Now(),
+			Attachments: m.entry.AttachmentFilenames(),
+			Tags:        m.entry.Tags,
+		},
+	}
+	m.closeDirMode()
+	return attachDirStep
+}
+
+// stepAttachDir attaches the next file of the folder, returning the
+// command for the one after, or finishes the run
+func (m *AttachmentModel) stepAttachDir() tea.Cmd {
+	run := m.dirRun
+	if !run.cancelled && run.next < len(run.files) {
+		name := run.files[run.next]
+		run.next++
+		if err := m.attachFile(filepath.Join(run.dir, name), run.record); err != nil {
+			run.failed = append(run.failed, name+": "+err.Error())
+		} else {
+			run.added++
+			run.record = nil
+		}
+		if run.next < len(run.files) {
+			return attachDirStep
+		}
+	}
+
+	m.dirRun = nil
+	m.Message = fmt.Sprintf("Attached %d of %d files from %s", run.added, len(run.files), run.dir)
+	if run.cancelled {
+		m.Message += " (stopped)"
+	}
+	if len(run.failed) > 0 {
+		shown := run.failed[:min(len(run.failed), attachDirFailuresShown)]
+		m.Error = "Couldn't attach " + strings.Join(shown, "; ")
+		if more := len(run.failed) - len(shown); more > 0 {
+			m.Error += fmt.Sprintf(" and %d more", more)
+		}
+	}
+	return nil
+}
+
+// closeDirMode leaves choosing a folder, clearing it for next time
+func (m *AttachmentModel) closeDirMode() {
+	m.dirMode = false
+	m.dirInput.SetValue("")
+	m.globInput.SetValue("")
+	m.dirInput.Blur()
+	m.globInput.Blur()
+}
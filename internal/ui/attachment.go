@@ -1,52 +1,141 @@
 package ui
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
+	"unicode/utf8"
 
+	"journal/internal/clock"
 	"journal/internal/model"
+	"journal/internal/search"
 	"journal/internal/storage"
 	"journal/internal/theme"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/google/uuid"
+	"github.com/charmbracelet/x/ansi"
 )
 
 type AttachmentModel struct {
 	entry          *model.Entry
-	dbPath         string
-	encrypted      bool
-	password       string
+	store          storage.Store
 	selectedIndex  int
 	Back           bool
 	ExportSelected bool
 	addMode        bool
 	pathInput      textinput.Model
+	dirMode        bool // Choosing a folder to attach every file of
+	dirInput       textinput.Model
+	globInput      textinput.Model
+	dirRun         *attachDirRun // Folder being attached, nil when none
+	filterMode     bool
+	filterInput    textinput.Model
+	filter         search.Query
+	searchOptions  search.Options
 	Error          string
 	Message        string
 	width          int
 	height         int
 	HistoryAdded   bool // Flag to indicate history was modified
+	preview        *attachmentPreview
+	noPreview      bool   // Previews are off in low-memory mode
+	locked         string // Why attachments can't be added or deleted, when the entry is locked
+	check          string // Command files are checked with before they are attached
+	clock          clock.Clock
+	ids            clock.IDGenerator
 }
 
-func NewAttachmentModel(entry *model.Entry, dbPath string, encrypted bool, password string) AttachmentModel {
+func NewAttachmentModel(entry *model.Entry, store storage.Store, searchOptions search.Options, clk clock.Clock, ids clock.IDGenerator) AttachmentModel {
 	ti := textinput.New()
 	ti.Placeholder = "Enter file path to attach..."
 	ti.CharLimit = 512
 	ti.Width = 50
 
+	fi := textinput.New()
+	fi.Placeholder = "Filter by filename..."
+	fi.CharLimit = 256
+	fi.Width = 40
+
+	di := textinput.New()
+	di.Placeholder = "Enter folder to attach..."
+	di.CharLimit = 512
+	di.Width = 50
+
+	gi := textinput.New()
+	gi.Placeholder = "* (every file)"
+	gi.CharLimit = 256
+	gi.Width = 30
+
 	return AttachmentModel{
 		entry:         entry,
-		dbPath:        dbPath,
-		encrypted:     encrypted,
-		password:      password,
+		store:         store,
 		selectedIndex: 0,
 		pathInput:     ti,
+		dirInput:      di,
+		globInput:     gi,
+		filterInput:   fi,
+		searchOptions: searchOptions,
+		clock:         clk,
+		ids:           ids,
+	}
+}
+
+// Lock keeps attachments from being added to or deleted from the entry,
+// giving the reason when it is tried
+func (m *AttachmentModel) Lock(reason string) {
+	m.locked = reason
+}
+
+// SetCheck sets the command each file is checked with before it is
+// attached, such as a virus scanner
+func (m *AttachmentModel) SetCheck(command string) {
+	m.check = command
+}
+
+// DisablePreview turns off text previews, which read the whole attachment
+// into memory
+func (m *AttachmentModel) DisablePreview() {
+	m.noPreview = true
+}
+
+// SearchOptions returns the current filename filter mode toggles
+func (m AttachmentModel) SearchOptions() search.Options {
+	return m.searchOptions
+}
+
+// visibleAttachments returns indexes of attachments matching the filename filter
+func (m AttachmentModel) visibleAttachments() []int {
+	var visible []int
+	for i, att := range m.entry.Attachments {
+		if m.filter.Empty() || m.filter.Matches(att.Filename) {
+			visible = append(visible, i)
+		}
+	}
+	return visible
+}
+
+// selectedAttachmentIndex returns the index into entry.Attachments of the
+// selected row, or -1 when nothing is selected
+func (m AttachmentModel) selectedAttachmentIndex() int {
+	visible := m.visibleAttachments()
+	if m.selectedIndex >= 0 && m.selectedIndex < len(visible) {
+		return visible[m.selectedIndex]
+	}
+	return -1
+}
+
+func (m *AttachmentModel) applyFilter() {
+	query, err := search.ParseWith(m.filterInput.Value(), m.searchOptions)
+	if err != nil {
+		m.Error = "Invalid pattern: " + err.Error()
+		return
 	}
+	m.Error = ""
+	m.filter = query
+	m.selectedIndex = 0
 }
 
 func (m *AttachmentModel) SetSize(width, height int) {
@@ -59,8 +148,8 @@ func (m AttachmentModel) Init() tea.Cmd {
 }
 
 func (m AttachmentModel) SelectedAttachment() *model.Attachment {
-	if m.selectedIndex >= 0 && m.selectedIndex < len(m.entry.Attachments) {
-		return &m.entry.Attachments[m.selectedIndex]
+	if idx := m.selectedAttachmentIndex(); idx >= 0 {
+		return &m.entry.Attachments[idx]
 	}
 	return nil
 }
@@ -68,6 +157,58 @@ func (m AttachmentModel) SelectedAttachment() *model.Attachment {
 func (m AttachmentModel) Update(msg tea.Msg) (AttachmentModel, tea.Cmd) {
 	var cmd tea.Cmd
 
+	if m.dirRun != nil {
+		switch msg := msg.(type) {
+		case attachDirStepMsg:
+			return m, m.stepAttachDir()
+		case tea.KeyMsg:
+			if msg.String() == "esc" {
+				m.dirRun.cancelled = true
+			}
+		}
+		return m, nil
+	}
+
+	if m.dirMode {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "enter":
+				if m.dirInput.Value() == "" {
+					return m, nil
+				}
+				return m, m.startAttachDir()
+			case "tab", "shift+tab":
+				if m.dirInput.Focused() {
+					m.dirInput.Blur()
+					m.globInput.Focus()
+				} else {
+					m.globInput.Blur()
+					m.dirInput.Focus()
+				}
+				return m, textinput.Blink
+			case "esc":
+				m.closeDirMode()
+				return m, nil
+			}
+		}
+		m.Error = ""
+		if m.dirInput.Focused() {
+			m.dirInput, cmd = m.dirInput.Update(msg)
+		} else {
+			m.globInput, cmd = m.globInput.Update(msg)
+		}
+		return m, cmd
+	}
+
+	if m.preview != nil {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			if m.preview.update(msg.String(), m.previewHeight(), m.width) {
+				m.preview = nil
+			}
+		}
+		return m, nil
+	}
+
 	if m.addMode {
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
@@ -98,6 +239,31 @@ func (m AttachmentModel) Update(msg tea.Msg) (AttachmentModel, tea.Cmd) {
 		return m, cmd
 	}
 
+	if m.filterMode {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "enter":
+				m.filterMode = false
+				m.filterInput.Blur()
+				return m, nil
+			case "esc":
+				m.filterMode = false
+				m.filterInput.SetValue("")
+				m.filterInput.Blur()
+				m.applyFilter()
+				return m, nil
+			default:
+				if toggleSearchOption(&m.searchOptions, msg.String()) {
+					m.applyFilter()
+					return m, nil
+				}
+			}
+		}
+		m.filterInput, cmd = m.filterInput.Update(msg)
+		m.applyFilter()
+		return m, cmd
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		m.Error = ""
@@ -109,30 +275,64 @@ func (m AttachmentModel) Update(msg tea.Msg) (AttachmentModel, tea.Cmd) {
 				m.selectedIndex--
 			}
 		case "down", "j":
-			if m.selectedIndex < len(m.entry.Attachments)-1 {
+			if m.selectedIndex < len(m.visibleAttachments())-1 {
 				m.selectedIndex++
 			}
+		case "/":
+			if len(m.entry.Attachments) > 0 {
+				m.filterMode = true
+				m.filterInput.Focus()
+				return m, textinput.Blink
+			}
 		case "a":
+			if m.locked != "" {
+				m.Error = m.locked
+				return m, nil
+			}
 			m.addMode = true
 			m.pathInput.Focus()
 			return m, textinput.Blink
+		case "A":
+			if m.locked != "" {
+				m.Error = m.locked
+				return m, nil
+			}
+			m.dirMode = true
+			m.globInput.Blur()
+			m.dirInput.Focus()
+			return m, textinput.Blink
 		case "e":
-			if len(m.entry.Attachments) > 0 {
+			if m.selectedAttachmentIndex() >= 0 {
 				m.ExportSelected = true
 			}
+		case "p":
+			if m.noPreview {
+				m.Error = "Previews are off in low-memory mode; press e to export the attachment instead"
+			} else if att := m.SelectedAttachment(); att != nil {
+				if err := m.openPreview(att); err != nil {
+					m.Error = err.Error()
+				}
+			}
 		case "d":
-			if len(m.entry.Attachments) > 0 && m.selectedIndex < len(m.entry.Attachments) {
+			if m.locked != "" {
+				m.Error = m.locked
+			} else if m.selectedAttachmentIndex() >= 0 {
 				err := m.deleteAttachment()
 				if err != nil {
 					m.Error = err.Error()
 				} else {
 					m.Message = "Attachment deleted"
-					if m.selectedIndex >= len(m.entry.Attachments) && m.selectedIndex > 0 {
+					if m.selectedIndex >= len(m.visibleAttachments()) && m.selectedIndex > 0 {
 						m.selectedIndex--
 					}
 				}
 			}
 		case "esc", "q":
+			if !m.filter.Empty() {
+				m.filterInput.SetValue("")
+				m.applyFilter()
+				return m, nil
+			}
 			m.Back = true
 		}
 	}
@@ -146,27 +346,40 @@ func (m *AttachmentModel) addAttachment(path string) error {
 		return err
 	}
 
-	data, err := os.ReadFile(expandedPath)
+	// Create a history record capturing the current state BEFORE adding the attachment
+	historyRecord := &model.SaveRecord{
+		Content:     m.entry.Content,
+		SavedAt:     m.clock.Now(),
+		Attachments: m.entry.AttachmentFilenames(),
+		Tags:        m.entry.Tags,
+	}
+	return m.attachFile(expandedPath, historyRecord)
+}
+
+// attachFile stores the file at path as an attachment of the entry. When
+// historyRecord is set, it is saved to the entry's history along with the
+// file, dated as when the file was added.
+func (m *AttachmentModel) attachFile(path string, historyRecord *model.SaveRecord) error {
+	if err := storage.CheckAttachment(m.check, path); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
-	filename := filepath.Base(expandedPath)
+	filename := filepath.Base(path)
 	mimeType := storage.DetectMimeType(filename)
-	now := time.Now()
-
-	// Create a history record capturing the current state BEFORE adding the attachment
-	historyRecord := model.SaveRecord{
-		Content:     m.entry.Content,
-		SavedAt:     now,
-		Attachments: m.entry.AttachmentFilenames(),
+	now := m.clock.Now()
+	if historyRecord != nil {
+		now = historyRecord.SavedAt
+		m.entry.History = append(m.entry.History, *historyRecord)
+		m.entry.UpdatedAt = now
+		m.HistoryAdded = true
 	}
-	m.entry.History = append(m.entry.History, historyRecord)
-	m.entry.UpdatedAt = now
-	m.HistoryAdded = true
 
 	attachment := &model.Attachment{
-		ID:        uuid.New().String(),
+		ID:        m.ids.NewID(),
 		EntryID:   m.entry.ID,
 		Filename:  filename,
 		MimeType:  mimeType,
@@ -175,16 +388,12 @@ func (m *AttachmentModel) addAttachment(path string) error {
 		CreatedAt: now,
 	}
 
-	if m.encrypted {
-		err = storage.AddAttachmentEncrypted(m.dbPath, m.password, attachment)
-	} else {
-		err = storage.AddAttachment(m.dbPath, attachment)
-	}
-
-	if err != nil {
+	if err := m.store.AddAttachment(attachment); err != nil {
 		// Rollback history addition on error
-		m.entry.History = m.entry.History[:len(m.entry.History)-1]
-		m.HistoryAdded = false
+		if historyRecord != nil {
+			m.entry.History = m.entry.History[:len(m.entry.History)-1]
+			m.HistoryAdded = false
+		}
 		return err
 	}
 
@@ -193,43 +402,151 @@ func (m *AttachmentModel) addAttachment(path string) error {
 	m.entry.Attachments = append(m.entry.Attachments, *attachment)
 
 	// Save the history record to the database
-	if m.encrypted {
-		err = storage.AddHistoryRecord(m.dbPath, m.entry.ID, historyRecord, m.password)
-	} else {
-		err = storage.AddHistoryRecord(m.dbPath, m.entry.ID, historyRecord, "")
+	if historyRecord != nil {
+		return m.store.AddHistoryRecord(m.entry.ID, *historyRecord)
 	}
+	return nil
+}
+
+// attachmentPreviewMax is the largest attachment shown in the preview
+const attachmentPreviewMax = 1 << 20
 
-	return err
+// attachmentPreview is a text attachment shown in a scrollable pager
+type attachmentPreview struct {
+	filename string
+	content  string
+	offset   int // First line shown
 }
 
-func (m *AttachmentModel) deleteAttachment() error {
-	if m.selectedIndex >= len(m.entry.Attachments) {
-		return nil
+// openPreview loads a text attachment into the preview pager
+func (m *AttachmentModel) openPreview(att *model.Attachment) error {
+	if !storage.IsTextMimeType(att.MimeType) {
+		return fmt.Errorf("%s isn't a text file; press e to export it instead", att.Filename)
+	}
+	if att.Size > attachmentPreviewMax {
+		return fmt.Errorf("%s is too large to preview (%s); press e to export it instead", att.Filename, storage.FormatFileSize(att.Size))
 	}
+	full, err := m.store.GetAttachment(att.ID)
+	if err != nil {
+		return err
+	}
+	if !utf8.Valid(full.Data) {
+		return fmt.Errorf("%s isn't valid UTF-8 text; press e to export it instead", att.Filename)
+	}
+	content := strings.ReplaceAll(string(full.Data), "\r\n", "\n")
+	m.preview = &attachmentPreview{filename: att.Filename, content: strings.ReplaceAll(content, "\t", "    ")}
+	return nil
+}
 
-	att := m.entry.Attachments[m.selectedIndex]
+// previewHeight returns how many lines of the preview fit on the screen
+func (m AttachmentModel) previewHeight() int {
+	return max(m.height-8, 3)
+}
 
-	var err error
-	if m.encrypted {
-		err = storage.DeleteAttachmentEncrypted(m.dbPath, m.password, att.ID)
-	} else {
-		err = storage.DeleteAttachment(m.dbPath, att.ID)
+// lines returns the preview's content wrapped to width
+func (p *attachmentPreview) lines(width int) []string {
+	content := p.content
+	if width > 0 {
+		content = ansi.Wrap(content, width, "")
 	}
+	return strings.Split(strings.TrimRight(content, "\n"), "\n")
+}
 
-	if err != nil {
+// update scrolls the preview of height lines wrapped to width by key,
+// reporting whether it was closed
+func (p *attachmentPreview) update(key string, height, width int) bool {
+	switch key {
+	case "up", "k":
+		p.offset--
+	case "down", "j":
+		p.offset++
+	case "pgup", "b":
+		p.offset -= height
+	case "pgdown", " ", "f":
+		p.offset += height
+	case "home", "g":
+		p.offset = 0
+	case "end", "G":
+		p.offset = len(p.lines(width))
+	case "esc", "q", "p":
+		return true
+	}
+	p.offset = max(min(p.offset, len(p.lines(width))-height), 0)
+	return false
+}
+
+func (m AttachmentModel) viewPreview() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	nameStyle := lipgloss.NewStyle().Foreground(t.Info).Bold(true)
+	textStyle := lipgloss.NewStyle().Foreground(t.Text)
+	scrollStyle := lipgloss.NewStyle().Foreground(t.Muted).Italic(true)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	dividerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Attachment Preview"))
+	b.WriteString("  ")
+	b.WriteString(nameStyle.Render(m.preview.filename))
+	b.WriteString("\n")
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", 60)))
+	b.WriteString("\n")
+
+	lines := m.preview.lines(m.width)
+	height := m.previewHeight()
+	// The window may have grown since the preview was scrolled
+	offset := min(m.preview.offset, max(len(lines)-height, 0))
+	end := min(offset+height, len(lines))
+	for _, line := range lines[offset:end] {
+		b.WriteString(textStyle.Render(line))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", 60)))
+	b.WriteString("\n")
+	if len(lines) > height {
+		b.WriteString(scrollStyle.Render(fmt.Sprintf("lines %d-%d of %d", offset+1, end, len(lines))))
+		b.WriteString("\n")
+	}
+
+	parts := []string{
+		keyStyle.Render("Up/Down") + " scroll",
+		keyStyle.Render("PgUp/PgDn") + " page",
+		keyStyle.Render("g/G") + " top/bottom",
+		keyStyle.Render("Esc/q") + " close",
+	}
+	b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
+	return b.String()
+}
+
+func (m *AttachmentModel) deleteAttachment() error {
+	idx := m.selectedAttachmentIndex()
+	if idx < 0 {
+		return nil
+	}
+
+	att := m.entry.Attachments[idx]
+
+	if err := m.store.DeleteAttachment(att.ID); err != nil {
 		return err
 	}
 
 	// Remove from local entry
 	m.entry.Attachments = append(
-		m.entry.Attachments[:m.selectedIndex],
-		m.entry.Attachments[m.selectedIndex+1:]...,
+		m.entry.Attachments[:idx],
+		m.entry.Attachments[idx+1:]...,
 	)
 
 	return nil
 }
 
 func (m AttachmentModel) View() string {
+	if m.preview != nil {
+		return m.viewPreview()
+	}
 	t := theme.Current()
 	var b strings.Builder
 
@@ -244,6 +561,7 @@ func (m AttachmentModel) View() string {
 	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
 	successStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
 	dividerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	highlightStyle := lipgloss.NewStyle().Foreground(t.Warning).Bold(true).Underline(true)
 
 	b.WriteString("\n")
 	b.WriteString(titleStyle.Render("Attachments"))
@@ -270,12 +588,71 @@ func (m AttachmentModel) View() string {
 		return b.String()
 	}
 
+	if m.dirMode {
+		b.WriteString("Attach every file in a folder:\n\n")
+		b.WriteString("  Folder:  ")
+		b.WriteString(m.dirInput.View())
+		b.WriteString("\n  Pattern: ")
+		b.WriteString(m.globInput.View())
+		b.WriteString("\n\n")
+
+		if m.Error != "" {
+			b.WriteString("  ")
+			b.WriteString(errorStyle.Render(m.Error))
+			b.WriteString("\n\n")
+		}
+
+		b.WriteString(helpStyle.Render(keyStyle.Render("Enter") + " attach | " + keyStyle.Render("Tab") + " folder/pattern | " + keyStyle.Render("Esc") + " cancel"))
+		return b.String()
+	}
+
+	if run := m.dirRun; run != nil {
+		const barWidth = 30
+		done := barWidth * run.next / len(run.files)
+		b.WriteString(fmt.Sprintf("Attaching files from %s\n\n", run.dir))
+		b.WriteString("  ")
+		b.WriteString(successStyle.Render(strings.Repeat("█", done)))
+		b.WriteString(dividerStyle.Render(strings.Repeat("░", barWidth-done)))
+		b.WriteString(fmt.Sprintf(" %d of %d", run.next, len(run.files)))
+		b.WriteString("\n")
+		if run.next < len(run.files) {
+			b.WriteString(sizeStyle.Render("  " + run.files[run.next]))
+		}
+		b.WriteString("\n")
+		if len(run.failed) > 0 {
+			b.WriteString(errorStyle.Render(fmt.Sprintf("  %d failed", len(run.failed))))
+		}
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render(keyStyle.Render("Esc") + " stop"))
+		return b.String()
+	}
+
+	if m.filterMode || !m.filter.Empty() {
+		b.WriteString("  Filter: ")
+		if m.filterMode {
+			b.WriteString(m.filterInput.View())
+		} else {
+			b.WriteString(m.filterInput.Value())
+		}
+		b.WriteString("\n  ")
+		b.WriteString(renderSearchOptions(m.searchOptions))
+		b.WriteString("\n\n")
+	}
+
+	visible := m.visibleAttachments()
 	if len(m.entry.Attachments) == 0 {
 		b.WriteString(itemStyle.Render("No attachments"))
 		b.WriteString("\n\n")
+	} else if len(visible) == 0 {
+		b.WriteString(itemStyle.Render("No attachments match the filter"))
+		b.WriteString("\n\n")
 	} else {
-		for i, att := range m.entry.Attachments {
+		for i, idx := range visible {
+			att := m.entry.Attachments[idx]
 			line := att.Filename
+			if !m.filter.Empty() {
+				line = highlight(att.Filename, m.filter.Find(att.Filename), lipgloss.NewStyle(), highlightStyle)
+			}
 			line += " " + sizeStyle.Render("("+storage.FormatFileSize(att.Size)+")")
 			line += " " + typeStyle.Render("["+att.MimeType+"]")
 
@@ -303,10 +680,25 @@ func (m AttachmentModel) View() string {
 	b.WriteString("\n")
 
 	var parts []string
-	parts = append(parts, keyStyle.Render("a")+" add")
+	if m.filterMode {
+		parts = append(parts, keyStyle.Render("Enter")+" apply")
+		parts = append(parts, keyStyle.Render("Esc")+" clear")
+		b.WriteString(helpStyle.Render(strings.Join(parts, " | ")))
+		return b.String()
+	}
+	if m.locked == "" {
+		parts = append(parts, keyStyle.Render("a")+" add")
+		parts = append(parts, keyStyle.Render("A")+" add folder")
+	}
 	if len(m.entry.Attachments) > 0 {
+		if !m.noPreview {
+			parts = append(parts, keyStyle.Render("p")+" preview")
+		}
 		parts = append(parts, keyStyle.Render("e")+" export")
-		parts = append(parts, keyStyle.Render("d")+" delete")
+		if m.locked == "" {
+			parts = append(parts, keyStyle.Render("d")+" delete")
+		}
+		parts = append(parts, keyStyle.Render("/")+" filter")
 	}
 	parts = append(parts, keyStyle.Render("Esc/q")+" back")
 	b.WriteString(helpStyle.Render(strings.Join(parts, " | ")))
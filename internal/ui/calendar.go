@@ -0,0 +1,256 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"journal/internal/clock"
+	"journal/internal/model"
+	"journal/internal/storage"
+	"journal/internal/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// CalendarModel shows the journal a month at a time, with the days that
+// have an entry highlighted, for moving to a day and opening its entry or
+// starting one
+type CalendarModel struct {
+	picker  datePicker // Moves the cursor; its lookup is unused
+	store   storage.Store
+	lock    *entryLock // Locked entries can't be opened for editing
+	shown   string     // Month whose entries are in days, as YYYY-MM
+	days    map[string][]model.EntrySummary
+	width   int
+	height  int
+	Back    bool
+	Open    bool // Open the selected day's entry, or a new one for it
+	Error   string
+	Warning string // Shown until the next key, such as why an entry can't be opened
+}
+
+func NewCalendarModel(store storage.Store, clk clock.Clock, lock *entryLock, date string, rejectFuture bool, goal int) (CalendarModel, error) {
+	m := CalendarModel{
+		picker: newDatePicker(date, clk.Now(), rejectFuture, goal, nil),
+		store:  store,
+		lock:   lock,
+	}
+	return m, m.loadMonth()
+}
+
+func (m *CalendarModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+func (m CalendarModel) Init() tea.Cmd {
+	return nil
+}
+
+// Date returns the selected day in YYYY-MM-DD form
+func (m CalendarModel) Date() string {
+	return m.picker.Date()
+}
+
+// Selected returns the entries of the selected day, usually one
+func (m CalendarModel) Selected() []model.EntrySummary {
+	return m.days[m.Date()]
+}
+
+// loadMonth reads the entries of the selected month when it changes
+func (m *CalendarModel) loadMonth() error {
+	month := m.picker.cursor.Format("2006-01")
+	if month == m.shown {
+		return nil
+	}
+	first := time.Date(m.picker.cursor.Year(), m.picker.cursor.Month(), 1, 0, 0, 0, 0, time.UTC)
+	// A month has room for a few days with more than one entry
+	entries, err := m.store.ListEntries(0, 100, model.EntryFilter{
+		Since: first.Format(entryDateLayout),
+		Until: first.AddDate(0, 1, -1).Format(entryDateLayout),
+	})
+	if err != nil {
+		return err
+	}
+	m.days = make(map[string][]model.EntrySummary, len(entries))
+	for _, e := range entries {
+		m.days[e.Date] = append(m.days[e.Date], e)
+	}
+	m.shown = month
+	return nil
+}
+
+// Reload reads the shown month again, after its entries were changed
+func (m *CalendarModel) Reload() error {
+	m.shown = ""
+	return m.loadMonth()
+}
+
+func (m CalendarModel) Update(msg tea.Msg) (CalendarModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	m.Error = ""
+	m.Warning = ""
+
+	switch keyMsg.String() {
+	case "enter":
+		m.Open = true
+		for _, e := range m.Selected() {
+			if m.lock.Locked(e.ID, e.Date) {
+				m.Warning = m.lock.Reason(e.Date)
+				m.Open = false
+			}
+		}
+	case "esc", "q":
+		m.Back = true
+	default:
+		m.picker = m.picker.Update(keyMsg)
+		if err := m.loadMonth(); err != nil {
+			m.Error = err.Error()
+		}
+	}
+	return m, nil
+}
+
+// calendarCell is the width of a day in the grid
+const calendarCell = 5
+
+func (m CalendarModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	monthStyle := lipgloss.NewStyle().Foreground(t.Info).Bold(true)
+	headerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	dayStyle := lipgloss.NewStyle().Foreground(t.Text)
+	todayStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true).Reverse(true)
+	disabledStyle := lipgloss.NewStyle().Foreground(t.Disabled)
+	excerptStyle := lipgloss.NewStyle().Foreground(t.Text).PaddingLeft(2)
+	emptyStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true).PaddingLeft(2)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	warningStyle := lipgloss.NewStyle().Foreground(t.Warning).Bold(true)
+
+	p := m.picker
+	month := time.Date(p.cursor.Year(), p.cursor.Month(), 1, 0, 0, 0, 0, time.UTC)
+	written := 0
+	for _, entries := range m.days {
+		written += len(entries)
+	}
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Calendar"))
+	b.WriteString("\n\n")
+	b.WriteString(monthStyle.Render(month.Format("January 2006")))
+	noun := "entries"
+	if written == 1 {
+		noun = "entry"
+	}
+	b.WriteString(headerStyle.Render(fmt.Sprintf("  %d %s", written, noun)))
+	b.WriteString("\n\n")
+
+	var header strings.Builder
+	for _, name := range []string{"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"} {
+		header.WriteString(fmt.Sprintf("%*s", calendarCell, name))
+	}
+	b.WriteString(headerStyle.Render(header.String()))
+	b.WriteString("\n")
+
+	// Weeks start on Monday, as in the editor's date picker. Days with an
+	// entry are shaded toward the word goal and marked, so they can be
+	// told apart without colors.
+	offset := (int(month.Weekday()) + 6) % 7
+	b.WriteString(strings.Repeat(" ", offset*calendarCell))
+	for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+		date := day.Format(entryDateLayout)
+		entries := m.days[date]
+		label := fmt.Sprintf("%2d", day.Day())
+		if len(entries) > 0 {
+			label += "*"
+		} else {
+			label += " "
+		}
+		style := dayStyle
+		switch {
+		case day.Equal(p.cursor):
+			style = selectedStyle
+		case p.rejectFuture && day.After(p.today):
+			style = disabledStyle
+		case len(entries) > 0 && day.Equal(p.today):
+			style = p.heatStyle(entries[0].WordCount).Bold(true).Underline(true)
+		case len(entries) > 0:
+			style = p.heatStyle(entries[0].WordCount).Bold(true)
+		case day.Equal(p.today):
+			style = todayStyle
+		}
+		b.WriteString(strings.Repeat(" ", calendarCell-3))
+		b.WriteString(style.Render(label))
+		if day.Weekday() == time.Sunday {
+			b.WriteString("\n")
+		}
+	}
+	if month.AddDate(0, 1, -1).Weekday() != time.Sunday {
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	// The selected day's entries
+	b.WriteString(monthStyle.Render(p.cursor.Format("Monday, January 2")))
+	b.WriteString("\n")
+	entries := m.Selected()
+	if len(entries) == 0 {
+		if p.cursor.Equal(p.today) {
+			b.WriteString(emptyStyle.Render("No entry yet today"))
+		} else {
+			b.WriteString(emptyStyle.Render("No entry"))
+		}
+		b.WriteString("\n")
+	}
+	for _, e := range entries {
+		info := fmt.Sprintf("%d words", e.WordCount)
+		if e.Mood != "" {
+			info += "  " + e.Mood
+		}
+		if len(e.Tags) > 0 {
+			info += "  #" + strings.Join(e.Tags, " #")
+		}
+		b.WriteString(headerStyle.Render("  " + fitWidth(info, m.width-2)))
+		b.WriteString("\n")
+		b.WriteString(excerptStyle.Render(fitWidth(truncate(e.Excerpt, 200), m.width-2)))
+		b.WriteString("\n")
+	}
+	if len(entries) > 1 {
+		b.WriteString(emptyStyle.Render("Enter opens one of them; the list shows all"))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	if m.Error != "" {
+		b.WriteString(errorStyle.Render("Error: " + m.Error))
+		b.WriteString("\n\n")
+	}
+	if m.Warning != "" {
+		b.WriteString(warningStyle.Render(m.Warning))
+		b.WriteString("\n\n")
+	}
+
+	open := " new entry"
+	if len(entries) > 0 {
+		open = " open"
+	}
+	parts := []string{
+		keyStyle.Render("Arrows") + " day/week",
+		keyStyle.Render("PgUp/PgDn") + " month",
+		keyStyle.Render("t") + " today",
+		keyStyle.Render("Enter") + open,
+		keyStyle.Render("Esc/q") + " back",
+	}
+	b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
+	return b.String()
+}
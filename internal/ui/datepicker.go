@@ -0,0 +1,229 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"journal/internal/dates"
+	"journal/internal/model"
+	"journal/internal/storage"
+	"journal/internal/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// entryDateLayout is the form entry dates are written in
+const entryDateLayout = dates.Layout
+
+// validateEntryDate checks that value is a real date in YYYY-MM-DD form,
+// and not after today when future dates are rejected
+func validateEntryDate(value string, today time.Time, rejectFuture bool) error {
+	date, err := time.Parse(entryDateLayout, value)
+	if err != nil {
+		return fmt.Errorf("%q is not a date, use YYYY-MM-DD", value)
+	}
+	if rejectFuture && date.Format(entryDateLayout) > today.Format(entryDateLayout) {
+		return fmt.Errorf("%s is in the future", value)
+	}
+	return nil
+}
+
+// dayWords returns the word count of each entry dated from since to until
+// (inclusive, YYYY-MM-DD), by date
+type dayWords func(since, until string) (map[string]int, error)
+
+// storeDayWords looks up the word counts of a journal's entries
+func storeDayWords(store storage.Store) dayWords {
+	return func(since, until string) (map[string]int, error) {
+		// At most one entry a day, so a month fits in one page
+		entries, err := store.ListEntries(0, 31, model.EntryFilter{Since: since, Until: until})
+		if err != nil {
+			return nil, err
+		}
+		words := make(map[string]int, len(entries))
+		for _, e := range entries {
+			words[e.Date] = e.WordCount
+		}
+		return words, nil
+	}
+}
+
+// datePicker is a month calendar for choosing an entry date. Days with an
+// entry are shaded by how far it got toward the word goal, or all alike
+// when there's no goal.
+type datePicker struct {
+	cursor       time.Time
+	today        time.Time
+	rejectFuture bool     // Days after today can't be chosen
+	goal         int      // Words a day's entry aims for, 0 for none
+	lookup       dayWords // Nil when entries aren't shown
+	shown        string   // Month whose entries are in words, as YYYY-MM
+	words        map[string]int
+	err          error // Looking up the shown month's entries failed
+	Chosen       bool
+	Cancelled    bool
+}
+
+// newDatePicker opens a calendar on the date in value, or on today when
+// value isn't a date
+func newDatePicker(value string, today time.Time, rejectFuture bool, goal int, lookup dayWords) datePicker {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	cursor, err := time.Parse(entryDateLayout, value)
+	if err != nil {
+		cursor = today
+	}
+	p := datePicker{cursor: cursor, today: today, rejectFuture: rejectFuture, goal: goal, lookup: lookup}
+	p.clamp()
+	p.loadMonth()
+	return p
+}
+
+// Date returns the date under the cursor in YYYY-MM-DD form
+func (p datePicker) Date() string {
+	return p.cursor.Format(entryDateLayout)
+}
+
+// clamp keeps the cursor on a day that can be chosen
+func (p *datePicker) clamp() {
+	if p.rejectFuture && p.cursor.After(p.today) {
+		p.cursor = p.today
+	}
+}
+
+// loadMonth looks up the entries of the cursor's month when it changes
+func (p *datePicker) loadMonth() {
+	month := p.cursor.Format("2006-01")
+	if p.lookup == nil || month == p.shown {
+		return
+	}
+	first := time.Date(p.cursor.Year(), p.cursor.Month(), 1, 0, 0, 0, 0, time.UTC)
+	p.shown = month
+	p.words, p.err = p.lookup(first.Format(entryDateLayout), first.AddDate(0, 1, -1).Format(entryDateLayout))
+}
+
+// heatStyle returns the color of a day whose entry has words words: how
+// far it got toward the goal, or a single color with no goal
+func (p datePicker) heatStyle(words int) lipgloss.Style {
+	t := theme.Current()
+	switch {
+	case p.goal == 0:
+		return lipgloss.NewStyle().Foreground(t.Info)
+	case words >= p.goal:
+		return lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	case words*2 >= p.goal:
+		return lipgloss.NewStyle().Foreground(t.Info)
+	}
+	return lipgloss.NewStyle().Foreground(t.Warning)
+}
+
+func (p datePicker) Update(msg tea.Msg) datePicker {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "left", "h":
+			p.cursor = p.cursor.AddDate(0, 0, -1)
+		case "right", "l":
+			p.cursor = p.cursor.AddDate(0, 0, 1)
+		case "up", "k":
+			p.cursor = p.cursor.AddDate(0, 0, -7)
+		case "down", "j":
+			p.cursor = p.cursor.AddDate(0, 0, 7)
+		case "pgup", "[":
+			p.cursor = p.cursor.AddDate(0, -1, 0)
+		case "pgdown", "]":
+			p.cursor = p.cursor.AddDate(0, 1, 0)
+		case "t":
+			p.cursor = p.today
+		case "enter":
+			p.Chosen = true
+		case "esc", "q":
+			p.Cancelled = true
+		}
+		p.clamp()
+		p.loadMonth()
+	}
+	return p
+}
+
+func (p datePicker) View() string {
+	t := theme.Current()
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	headerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	dayStyle := lipgloss.NewStyle().Foreground(t.Text)
+	todayStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true).Reverse(true)
+	disabledStyle := lipgloss.NewStyle().Foreground(t.Disabled)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error)
+
+	var b strings.Builder
+	month := time.Date(p.cursor.Year(), p.cursor.Month(), 1, 0, 0, 0, 0, time.UTC)
+	b.WriteString(titleStyle.Render(month.Format("January 2006")))
+	b.WriteString("\n")
+	b.WriteString(headerStyle.Render("Mo Tu We Th Fr Sa Su"))
+	b.WriteString("\n")
+
+	// Weeks start on Monday
+	var grid strings.Builder
+	offset := (int(month.Weekday()) + 6) % 7
+	grid.WriteString(strings.Repeat("   ", offset))
+	for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+		style := dayStyle
+		words, written := p.words[day.Format(entryDateLayout)]
+		switch {
+		case day.Equal(p.cursor):
+			style = selectedStyle
+		case p.rejectFuture && day.After(p.today):
+			style = disabledStyle
+		case written && day.Equal(p.today):
+			style = p.heatStyle(words).Underline(true)
+		case written:
+			style = p.heatStyle(words)
+		case day.Equal(p.today):
+			style = todayStyle
+		}
+		grid.WriteString(style.Render(fmt.Sprintf("%2d", day.Day())))
+		if day.Weekday() == time.Sunday {
+			grid.WriteString("\n")
+		} else {
+			grid.WriteString(" ")
+		}
+	}
+	b.WriteString(strings.TrimRight(grid.String(), " \n"))
+	b.WriteString("\n\n")
+
+	// The selected day's entry, and what the shades mean
+	if p.lookup != nil {
+		words, written := p.words[p.Date()]
+		switch {
+		case p.err != nil:
+			b.WriteString(errorStyle.Render("Entries unavailable"))
+		case !written:
+			b.WriteString(headerStyle.Render("No entry"))
+		case p.goal > 0:
+			b.WriteString(p.heatStyle(words).Render(fmt.Sprintf("%d of %d words", words, p.goal)))
+		default:
+			b.WriteString(p.heatStyle(words).Render(fmt.Sprintf("%d words", words)))
+		}
+		b.WriteString("\n")
+		if p.goal > 0 {
+			b.WriteString(p.heatStyle(0).Render("■") + headerStyle.Render(" started ") +
+				p.heatStyle((p.goal+1)/2).Render("■") + headerStyle.Render(" half ") +
+				p.heatStyle(p.goal).Render("■") + headerStyle.Render(" goal"))
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(helpStyle.Render(keyStyle.Render("Arrows") + " day/week | " + keyStyle.Render("PgUp/PgDn") + " month"))
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render(keyStyle.Render("t") + " today | " + keyStyle.Render("Enter") + " pick | " + keyStyle.Render("Esc") + " close"))
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.Muted).
+		Padding(0, 1).
+		Render(b.String())
+}
@@ -1,66 +1,120 @@
 package ui
 
 import (
+	"fmt"
+	"slices"
 	"strings"
 	"time"
 
+	"journal/internal/clock"
+	"journal/internal/dates"
 	"journal/internal/model"
+	"journal/internal/stats"
+	"journal/internal/storage"
 	"journal/internal/theme"
 
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/google/uuid"
+	"github.com/charmbracelet/x/ansi"
 )
 
 type editorField int
 
 const (
 	fieldDate editorField = iota
+	fieldTags
 	fieldContent
+	editorFields // Number of fields, which Tab cycles through
 )
 
 type EditorModel struct {
 	dateInput    textinput.Model
+	tagsInput    textinput.Model
 	contentArea  textarea.Model
 	focusedField editorField
+	moods        []string // Mood set, nil when mood tracking is disabled
+	mood         string
+	rejectFuture bool // Dates after today can't be saved
+	config       *model.Config
+	template     string         // Template text a new entry was started with
+	templateTags []string       // Tags a new entry was started with
+	picker       *datePicker    // Calendar for the date, while open
+	dayWords     dayWords       // Word counts of the entries shown in the calendar
+	measured     string         // Content the language and word count are of
+	language     stats.Language // Language the content is written in, Code "" when it can't be told
+	words        int            // Words in the content
+	misspelled   []string       // Words the last spellcheck didn't know that are still in the content
+	typing       stats.TypingSession
 	EditingEntry *model.Entry
 	Saved        bool
 	Cancelled    bool
+	Snapshot     bool // Store the current text as a named history version
+	Autosave     bool // Save the entry without leaving the editor
 	Error        string
+	Message      string
 	width        int
 	height       int
+	zen          bool          // Distraction-free: only the content, in a centred column
+	started      time.Time     // When the editor was opened
+	elapsed      time.Duration // Time open so far, updated once a second
+	committed    time.Time     // When the entry was last saved without leaving, or the editor opened
+	awaySince    time.Time     // When the terminal lost focus, zero while it has it
+	autosaveTry  time.Time     // When an autosave was last asked for
+	autosaved    bool          // The entry has been saved without leaving the editor
+	clock        clock.Clock
+	ids          clock.IDGenerator
 }
 
-func NewEditorModel(entry *model.Entry) EditorModel {
+func NewEditorModel(entry *model.Entry, config *model.Config, clk clock.Clock, ids clock.IDGenerator, words dayWords) EditorModel {
 	ti := textinput.New()
 	ti.Placeholder = "YYYY-MM-DD"
-	ti.CharLimit = 10
-	ti.Width = 12
+	ti.CharLimit = 32
+	ti.Width = 16
+	ti.Focus()
 
-	ta := textarea.New()
-	ta.Placeholder = "Write your journal entry..."
-	ta.CharLimit = 0
+	tags := textinput.New()
+	tags.Placeholder = "e.g. work, travel"
+	tags.CharLimit = 256
+	tags.Width = 40
+
+	ta := newContentArea()
 	ta.SetWidth(60)
 	ta.SetHeight(10)
 
+	now := clk.Now()
 	m := EditorModel{
 		dateInput:    ti,
+		tagsInput:    tags,
 		contentArea:  ta,
 		focusedField: fieldDate,
+		moods:        config.MoodSet(),
+		rejectFuture: config != nil && config.RejectFutureDates,
+		config:       config,
 		EditingEntry: entry,
+		started:      now,
+		committed:    now,
+		clock:        clk,
+		ids:          ids,
+		dayWords:     words,
 	}
 
 	if entry != nil {
+		m.mood = entry.Mood
+		m.tagsInput.SetValue(strings.Join(entry.Tags, ", "))
 		ti.SetValue(entry.Date)
 		ta.SetValue(entry.Content)
 		m.dateInput = ti
 		m.contentArea = ta
 	} else {
-		ti.SetValue(time.Now().Format("2006-01-02"))
+		ti.SetValue(clk.Now().Format(entryDateLayout))
 		m.dateInput = ti
+		m.template = config.TemplateFor(ti.Value())
+		ta.SetValue(m.template)
+		m.contentArea = ta
 	}
+	m.measure()
 
 	return m
 }
@@ -68,6 +122,10 @@ func NewEditorModel(entry *model.Entry) EditorModel {
 func (m *EditorModel) SetSize(width, height int) {
 	m.width = width
 	m.height = height
+	if m.zen {
+		m.sizeZen()
+		return
+	}
 
 	contentWidth := width - 6
 	if contentWidth < 20 {
@@ -77,7 +135,7 @@ func (m *EditorModel) SetSize(width, height int) {
 		contentWidth = 100
 	}
 
-	contentHeight := height - 14
+	contentHeight := height - 16
 	if contentHeight < 5 {
 		contentHeight = 5
 	}
@@ -86,80 +144,527 @@ func (m *EditorModel) SetSize(width, height int) {
 	m.contentArea.SetHeight(contentHeight)
 }
 
+// newContentArea returns the text area entry content is written in
+func newContentArea() textarea.Model {
+	ta := textarea.New()
+	ta.Placeholder = "Write your journal entry..."
+	ta.CharLimit = 0
+	return ta
+}
+
+// zenWidth is the width of the writing column in zen mode
+const zenWidth = 72
+
+// Zen reports whether the editor is in zen mode, drawn without a frame
+func (m EditorModel) Zen() bool {
+	return m.zen
+}
+
+// toggleZen switches zen mode, which hides everything but the content, in
+// a column that keeps the line being written in the middle of the screen
+func (m *EditorModel) toggleZen() tea.Cmd {
+	m.zen = !m.zen
+
+	// The text area is made afresh, as its scroll position can't be reset,
+	// with the cursor put back where it was
+	old := m.contentArea
+	info := old.LineInfo()
+	m.contentArea = newContentArea()
+	if m.zen {
+		m.contentArea.Prompt = ""
+		m.contentArea.ShowLineNumbers = false
+	}
+	m.contentArea.SetValue(old.Value())
+	for m.contentArea.Line() > old.Line() {
+		m.contentArea.CursorUp()
+	}
+	m.contentArea.SetCursor(info.StartColumn + info.ColumnOffset)
+	m.SetSize(m.width, m.height)
+
+	if !m.zen && m.focusedField != fieldContent {
+		return nil
+	}
+	return m.focus(fieldContent)
+}
+
+// focus moves the cursor to field
+func (m *EditorModel) focus(field editorField) tea.Cmd {
+	m.focusedField = field
+	m.dateInput.Blur()
+	m.tagsInput.Blur()
+	m.contentArea.Blur()
+	switch field {
+	case fieldDate:
+		m.dateInput.Focus()
+		return textinput.Blink
+	case fieldTags:
+		m.tagsInput.Focus()
+		return textinput.Blink
+	}
+	m.contentArea.Focus()
+	return textarea.Blink
+}
+
+// tags returns the tags typed in the tags field
+func (m EditorModel) tags() []string {
+	return model.ParseTags(m.tagsInput.Value())
+}
+
+// sizeZen sizes the text area for zen mode. It is made tall enough to
+// hold the whole entry, so it never scrolls itself; View shows the rows
+// around the cursor instead.
+func (m *EditorModel) sizeZen() {
+	m.contentArea.SetWidth(min(zenWidth, m.width))
+	m.contentArea.SetHeight(m.zenRows(m.contentArea.LineCount()) + m.height)
+}
+
+// zenRows estimates how many screen rows the first n lines of the content
+// wrap to in the zen column
+func (m EditorModel) zenRows(n int) int {
+	rows := 0
+	for _, line := range strings.Split(m.contentArea.Value(), "\n")[:n] {
+		rows += strings.Count(ansi.Wordwrap(line, m.contentArea.Width(), ""), "\n") + 1
+	}
+	return rows
+}
+
+// measure detects the language of the content and counts its words, when
+// it has changed since they were last. Words the spellcheck didn't know
+// are dropped once they are corrected.
+func (m *EditorModel) measure() {
+	content := m.contentArea.Value()
+	if content == m.measured {
+		return
+	}
+	m.measured = content
+	m.language, _ = stats.DetectLanguage(content)
+	words := stats.Words(content)
+	m.words = len(words)
+
+	if len(m.misspelled) > 0 {
+		present := make(map[string]bool, len(words))
+		for _, w := range words {
+			present[w] = true
+		}
+		m.misspelled = slices.DeleteFunc(m.misspelled, func(w string) bool {
+			for _, part := range stats.Words(w) {
+				if !present[part] {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// spellCheckMsg carries the words of the content that the spellcheck
+// didn't know
+type spellCheckMsg struct {
+	words []string
+	err   error
+}
+
+// checkSpelling checks the content's spelling with the dictionary of the
+// language it is written in
+func (m *EditorModel) checkSpelling() tea.Cmd {
+	if m.language.Code == "" {
+		m.Error = "Can't tell which language this entry is written in yet, so its spelling can't be checked"
+		return nil
+	}
+	dictionary := m.config.SpellDictionary(m.language.Code)
+	if dictionary == "" {
+		m.Error = "No spellcheck dictionary is set for " + m.language.Name + "; add one to spell_dictionaries in the config"
+		return nil
+	}
+	m.Message = "Checking spelling (" + m.language.Name + ", " + dictionary + ")..."
+	content := m.contentArea.Value()
+	return func() tea.Msg {
+		words, err := storage.SpellCheck(content, dictionary)
+		return spellCheckMsg{words: words, err: err}
+	}
+}
+
+// writingTickMsg updates the session timer of the editor opened at started
+type writingTickMsg struct {
+	started time.Time
+}
+
+// externalEditMsg reports that the external editor editing the entry's
+// content in path has exited, with err when it failed
+type externalEditMsg struct {
+	path string
+	err  error
+}
+
+// openExternalEditor suspends the app and opens the content in the
+// external editor, to be read back when it exits
+func (m *EditorModel) openExternalEditor() tea.Cmd {
+	path, err := storage.CreateEditFile(m.dateInput.Value(), m.contentArea.Value())
+	if err != nil {
+		m.Error = err.Error()
+		return nil
+	}
+	editor := ""
+	if m.config != nil {
+		editor = m.config.Editor
+	}
+	return tea.ExecProcess(storage.EditorCommand(editor, path), func(err error) tea.Msg {
+		return externalEditMsg{path: path, err: err}
+	})
+}
+
+// tickWriting schedules the next session timer update. Ticks carry the
+// time the editor was opened, so those meant for an editor since closed
+// are dropped rather than starting a second timer.
+func (m EditorModel) tickWriting() tea.Cmd {
+	started := m.started
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return writingTickMsg{started: started}
+	})
+}
+
+// formatWritingTime renders a writing time to the second, e.g. "12m 05s"
+func formatWritingTime(d time.Duration) string {
+	d = d.Round(time.Second)
+	hours := int(d / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	seconds := int(d % time.Minute / time.Second)
+	switch {
+	case hours > 0:
+		return fmt.Sprintf("%dh %02dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm %02ds", minutes, seconds)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
 func (m EditorModel) Init() tea.Cmd {
-	m.dateInput.Focus()
-	return textinput.Blink
+	return tea.Batch(textinput.Blink, m.tickWriting())
 }
 
 func (m EditorModel) Update(msg tea.Msg) (EditorModel, tea.Cmd) {
 	var cmd tea.Cmd
 
 	switch msg := msg.(type) {
+	case writingTickMsg:
+		if !msg.started.Equal(m.started) {
+			return m, nil
+		}
+		m.elapsed = m.clock.Now().Sub(m.started)
+		if m.autosaveDue() {
+			m.Autosave = true
+			m.autosaveTry = m.clock.Now()
+		}
+		return m, m.tickWriting()
+
+	case tea.BlurMsg:
+		m.awaySince = m.clock.Now()
+		m.typing.Away()
+		return m, nil
+
+	case tea.FocusMsg:
+		m.awaySince = time.Time{}
+		return m, nil
+
+	case externalEditMsg:
+		content, err := storage.FinishEditFile(msg.path)
+		switch {
+		case msg.err != nil:
+			// Editors exit with an error to abandon the edit, e.g. :cq in vim
+			m.Error = "The external editor failed, so the entry is unchanged: " + msg.err.Error()
+		case err != nil:
+			m.Error = err.Error()
+		default:
+			// Editors end the file with a newline the entry didn't have
+			if !strings.HasSuffix(m.contentArea.Value(), "\n") {
+				content = strings.TrimSuffix(strings.TrimSuffix(content, "\n"), "\r")
+			}
+			m.contentArea.SetValue(content)
+			m.measure()
+			m.Message = "Updated from the external editor"
+		}
+		if m.zen {
+			m.sizeZen()
+		}
+		return m, m.focus(fieldContent)
+
+	case spellCheckMsg:
+		m.Message = ""
+		if msg.err != nil {
+			m.Error = msg.err.Error()
+			return m, nil
+		}
+		// The content may have changed while it was checked
+		m.misspelled = msg.words
+		m.measured = ""
+		m.measure()
+		if len(m.misspelled) == 0 {
+			m.Message = "No spelling mistakes found"
+		}
+		return m, nil
+
 	case tea.KeyMsg:
+		if m.picker != nil {
+			return m.updatePicker(msg), nil
+		}
+
 		switch msg.String() {
+		case "alt+z":
+			return m, m.toggleZen()
+
+		case "alt+e":
+			return m, m.openExternalEditor()
+
+		case "alt+k":
+			return m, m.checkSpelling()
+
 		case "tab", "shift+tab":
-			if m.focusedField == fieldDate {
-				m.focusedField = fieldContent
-				m.dateInput.Blur()
-				m.contentArea.Focus()
-				return m, textarea.Blink
-			} else {
-				m.focusedField = fieldDate
-				m.contentArea.Blur()
-				m.dateInput.Focus()
-				return m, textinput.Blink
+			if m.zen {
+				// The date field is hidden
+				break
+			}
+			switch m.focusedField {
+			case fieldDate:
+				m.normalizeDate()
+			case fieldTags:
+				m.tagsInput.SetValue(strings.Join(m.tags(), ", "))
+			}
+			step := editorField(1)
+			if msg.String() == "shift+tab" {
+				step = editorFields - 1
 			}
+			return m, m.focus((m.focusedField + step) % editorFields)
 
 		case "esc":
+			if m.zen {
+				return m, m.toggleZen()
+			}
 			m.Cancelled = true
 			return m, nil
 
 		case "ctrl+s":
 			if m.dateInput.Value() != "" && m.contentArea.Value() != "" {
+				m.normalizeDate()
+				if err := validateEntryDate(m.dateInput.Value(), m.clock.Now(), m.rejectFuture); err != nil {
+					m.Error = err.Error()
+					return m, nil
+				}
 				m.Saved = true
 			}
 			return m, nil
+
+		case "alt+c":
+			if !m.zen {
+				m.normalizeDate()
+				picker := newDatePicker(m.dateInput.Value(), m.clock.Now(), m.rejectFuture, m.config.DayWordGoal(), m.dayWords)
+				m.picker = &picker
+				m.Error = ""
+				return m, nil
+			}
+
+		case "alt+s":
+			m.Snapshot = true
+			return m, nil
+
+		case "alt+0", "alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9":
+			if len(m.moods) > 0 {
+				n := int(msg.String()[len(msg.String())-1] - '0')
+				if n == 0 {
+					m.mood = ""
+				} else if n <= len(m.moods) {
+					m.mood = m.moods[n-1]
+				}
+				return m, nil
+			}
 		}
 	}
 
-	m.Error = ""
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.Error = ""
+		m.Message = ""
+	}
 
-	if m.focusedField == fieldDate {
+	switch m.focusedField {
+	case fieldDate:
 		m.dateInput, cmd = m.dateInput.Update(msg)
-	} else {
+	case fieldTags:
+		m.tagsInput, cmd = m.tagsInput.Update(msg)
+	default:
+		before := m.contentArea.Value()
 		m.contentArea, cmd = m.contentArea.Update(msg)
+		if after := m.contentArea.Value(); after != before {
+			if _, ok := msg.(tea.KeyMsg); ok {
+				m.typing.Key(m.clock.Now(), len(after) < len(before))
+			}
+		}
 	}
+	if m.zen {
+		// Grow with the entry, so the text area never scrolls itself
+		m.sizeZen()
+	}
+	m.measure()
 
 	return m, cmd
 }
 
+// autosaveDue reports whether the entry should be saved without leaving
+// the editor: it has unsaved changes that can be saved, and either the
+// autosave interval has passed since it was last saved, or the terminal
+// has been out of focus for the configured time
+func (m EditorModel) autosaveDue() bool {
+	if m.config == nil || !m.Dirty() || m.contentArea.Value() == "" || m.picker != nil {
+		return false
+	}
+	if validateEntryDate(m.dateInput.Value(), m.clock.Now(), m.rejectFuture) != nil {
+		return false
+	}
+	now := m.clock.Now()
+	if now.Sub(m.autosaveTry) < time.Minute {
+		return false // An autosave that failed, e.g. on a taken date, isn't retried at once
+	}
+	if minutes := m.config.AwaySaveMinutes; minutes > 0 && !m.awaySince.IsZero() &&
+		now.Sub(m.awaySince) >= time.Duration(minutes)*time.Minute {
+		return true
+	}
+	minutes := m.config.AutosaveMinutes
+	return minutes > 0 && now.Sub(m.committed) >= time.Duration(minutes)*time.Minute
+}
+
+// Dirty reports whether the entry has changes that haven't been saved
+func (m EditorModel) Dirty() bool {
+	if e := m.EditingEntry; e != nil {
+		return e.Content != m.contentArea.Value() || e.Date != m.dateInput.Value() || e.Mood != m.mood ||
+			!slices.Equal(e.Tags, m.tags())
+	}
+	return m.contentArea.Value() != m.template || !slices.Equal(m.tags(), m.templateTags)
+}
+
+// Autosaved reports whether the entry has been saved without leaving the
+// editor
+func (m EditorModel) Autosaved() bool {
+	return m.autosaved
+}
+
+// Committed records that entry was saved without leaving the editor, so
+// later saves update it and count only the writing time since
+func (m *EditorModel) Committed(entry model.Entry) {
+	if m.EditingEntry != nil {
+		entry.Attachments = m.EditingEntry.Attachments
+	}
+	m.EditingEntry = &entry
+	m.committed = entry.UpdatedAt
+	m.typing.Saved()
+	m.autosaved = true
+	if !m.awaySince.IsZero() {
+		m.Message = "Saved while you were away"
+	} else {
+		m.Message = "Autosaved at " + entry.UpdatedAt.Format("15:04")
+	}
+}
+
+// normalizeDate rewrites a date typed as a phrase, such as "yesterday" or
+// "last friday", as YYYY-MM-DD. Text that isn't a date is left for saving
+// to report.
+func (m *EditorModel) normalizeDate() {
+	if date, err := dates.Parse(m.dateInput.Value(), m.clock.Now()); err == nil {
+		m.dateInput.SetValue(date)
+		m.applyTemplate()
+	}
+}
+
+// SetDate dates a new entry, starting it with the template for that day
+func (m *EditorModel) SetDate(date string) {
+	m.dateInput.SetValue(date)
+	m.applyTemplate()
+}
+
+// applyTemplate starts a new entry with the template for its date's
+// weekday, replacing the template it had unless it has been written in
+func (m *EditorModel) applyTemplate() {
+	if m.EditingEntry != nil || m.contentArea.Value() != m.template {
+		return
+	}
+	m.template = m.templateFor(m.dateInput.Value())
+	m.contentArea.SetValue(m.template)
+	m.measure()
+}
+
+// templateFor returns the text a new entry dated date starts with: its
+// month's goals list for a goals entry, or else the template for its
+// weekday
+func (m EditorModel) templateFor(date string) string {
+	if slices.Contains(m.templateTags, model.GoalsTag) {
+		return model.GoalsTemplate(date)
+	}
+	return m.config.TemplateFor(date)
+}
+
+// StartGoals makes a new entry the goals of the month of date, tagged as
+// goals and started with a checklist
+func (m *EditorModel) StartGoals(date string) {
+	m.templateTags = []string{model.GoalsTag}
+	m.tagsInput.SetValue(model.GoalsTag)
+	m.SetDate(date)
+}
+
+// updatePicker passes a key to the open calendar, filling in the date
+// when one is picked
+func (m EditorModel) updatePicker(msg tea.KeyMsg) EditorModel {
+	picker := m.picker.Update(msg)
+	switch {
+	case picker.Chosen:
+		m.dateInput.SetValue(picker.Date())
+		m.applyTemplate()
+		m.picker = nil
+	case picker.Cancelled:
+		m.picker = nil
+	default:
+		m.picker = &picker
+	}
+	return m
+}
+
 func (m EditorModel) GetDate() string {
 	return m.dateInput.Value()
 }
 
+// GetEntry returns the entry as edited, with the time the editor has been
+// open added to its writing time
 func (m EditorModel) GetEntry() model.Entry {
-	now := time.Now()
+	now := m.clock.Now()
+	session := now.Sub(m.committed).Truncate(time.Second)
 
 	if m.EditingEntry != nil {
 		return model.Entry{
-			ID:        m.EditingEntry.ID,
-			Date:      m.dateInput.Value(),
-			Content:   m.contentArea.Value(),
-			CreatedAt: m.EditingEntry.CreatedAt,
-			UpdatedAt: now,
+			ID:          m.EditingEntry.ID,
+			Date:        m.dateInput.Value(),
+			Content:     m.contentArea.Value(),
+			Tags:        m.tags(),
+			Mood:        m.mood,
+			CreatedAt:   m.EditingEntry.CreatedAt,
+			UpdatedAt:   now,
+			WritingTime: m.EditingEntry.WritingTime + session,
+			Typing:      m.EditingEntry.Typing.Add(m.typing.Counts()),
 		}
 	}
 
 	return model.Entry{
-		ID:        uuid.New().String(),
-		Date:      m.dateInput.Value(),
-		Content:   m.contentArea.Value(),
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:          m.ids.NewID(),
+		Date:        m.dateInput.Value(),
+		Content:     m.contentArea.Value(),
+		Tags:        m.tags(),
+		Mood:        m.mood,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		WritingTime: session,
+		Typing:      m.typing.Counts(),
 	}
 }
 
 func (m EditorModel) View() string {
+	if m.zen {
+		return m.zenView()
+	}
 	t := theme.Current()
 	var b strings.Builder
 
@@ -169,6 +674,8 @@ func (m EditorModel) View() string {
 	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
 	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
 	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	successStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	warningStyle := lipgloss.NewStyle().Foreground(t.Warning)
 	hintStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true)
 
 	b.WriteString("\n")
@@ -180,6 +687,11 @@ func (m EditorModel) View() string {
 	b.WriteString(titleStyle.Render(title))
 	b.WriteString("\n\n")
 
+	if len(m.moods) > 0 {
+		b.WriteString(m.renderMoodRow())
+		b.WriteString("\n\n")
+	}
+
 	dateLabel := "Date:"
 	if m.focusedField == fieldDate {
 		b.WriteString(labelActiveStyle.Render("> " + dateLabel))
@@ -189,9 +701,25 @@ func (m EditorModel) View() string {
 	b.WriteString(" ")
 	b.WriteString(m.dateInput.View())
 	b.WriteString("  ")
-	b.WriteString(hintStyle.Render("(YYYY-MM-DD)"))
+	b.WriteString(hintStyle.Render("(YYYY-MM-DD, or e.g. yesterday, last friday, 3 days ago)"))
+	b.WriteString("\n")
+
+	tagsLabel := "Tags:"
+	if m.focusedField == fieldTags {
+		b.WriteString(labelActiveStyle.Render("> " + tagsLabel))
+	} else {
+		b.WriteString(labelStyle.Render("  " + tagsLabel))
+	}
+	b.WriteString(" ")
+	b.WriteString(m.tagsInput.View())
 	b.WriteString("\n\n")
 
+	if m.picker != nil {
+		// The calendar stands in for the content while it is open
+		b.WriteString(m.picker.View())
+		return b.String()
+	}
+
 	contentLabel := "Content:"
 	if m.focusedField == fieldContent {
 		b.WriteString(labelActiveStyle.Render("> " + contentLabel))
@@ -208,13 +736,112 @@ func (m EditorModel) View() string {
 		b.WriteString("\n")
 	}
 
+	if m.Message != "" {
+		b.WriteString("\n")
+		b.WriteString(successStyle.Render(m.Message))
+		b.WriteString("\n")
+	}
+
+	if len(m.misspelled) > 0 {
+		b.WriteString("\n")
+		b.WriteString(warningStyle.Render("Spelling: " + strings.Join(m.misspelled, ", ")))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render(m.writingStatus()))
 	b.WriteString("\n")
 
 	var parts []string
 	parts = append(parts, keyStyle.Render("Tab")+" switch fields")
+	parts = append(parts, keyStyle.Render("Alt+C")+" calendar")
 	parts = append(parts, keyStyle.Render("Ctrl+S")+" save")
+	if m.EditingEntry != nil {
+		parts = append(parts, keyStyle.Render("Alt+S")+" snapshot")
+	}
+	if len(m.moods) > 0 {
+		parts = append(parts, keyStyle.Render(fmt.Sprintf("Alt+1-%d", len(m.moods)))+" mood")
+	}
+	parts = append(parts, keyStyle.Render("Alt+Z")+" zen")
+	parts = append(parts, keyStyle.Render("Alt+E")+" external editor")
+	parts = append(parts, keyStyle.Render("Alt+K")+" spelling")
 	parts = append(parts, keyStyle.Render("Esc")+" cancel")
 	b.WriteString(helpStyle.Render(strings.Join(parts, " | ")))
 
 	return b.String()
 }
+
+// writingStatus describes the time spent writing this session and, for an
+// entry written before, in total, and the entry's length and language
+func (m EditorModel) writingStatus() string {
+	status := "Writing for " + formatWritingTime(m.elapsed)
+	if m.EditingEntry != nil && m.EditingEntry.WritingTime > 0 {
+		total := m.EditingEntry.WritingTime + m.started.Add(m.elapsed).Sub(m.committed)
+		status += ", " + formatWritingTime(total) + " on this entry in total"
+	}
+	status += fmt.Sprintf(" · %d words", m.words)
+	if m.language.Code != "" {
+		status += " in " + m.language.Name
+	}
+	return status
+}
+
+// zenView draws the content column alone, typewriter style: the row being
+// written stays in the middle of the screen and the text scrolls past it
+func (m EditorModel) zenView() string {
+	t := theme.Current()
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	successStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+
+	height := m.height
+	status := ""
+	if m.Error != "" {
+		status = errorStyle.Render("Error: " + m.Error)
+	} else if m.Message != "" {
+		status = successStyle.Render(m.Message)
+	}
+	if status != "" {
+		height--
+	}
+
+	rows := strings.Split(m.contentArea.View(), "\n")
+	cursor := m.zenRows(m.contentArea.Line()) + m.contentArea.LineInfo().RowOffset
+	top := cursor - height/2
+
+	lines := make([]string, 0, height)
+	for i := top; i < top+height; i++ {
+		if i >= 0 && i < len(rows) {
+			lines = append(lines, rows[i])
+		} else {
+			lines = append(lines, "")
+		}
+	}
+	if status != "" {
+		lines = append(lines, status)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m EditorModel) renderMoodRow() string {
+	t := theme.Current()
+
+	labelStyle := lipgloss.NewStyle().Foreground(t.Text).Bold(true)
+	moodStyle := lipgloss.NewStyle().Foreground(t.TextDim)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Muted)
+
+	var parts []string
+	for i, mood := range m.moods {
+		if i >= 9 {
+			break
+		}
+		key := keyStyle.Render(fmt.Sprintf("%d", i+1))
+		if mood == m.mood {
+			parts = append(parts, key+selectedStyle.Render("["+mood+"]"))
+		} else {
+			parts = append(parts, key+moodStyle.Render(" "+mood+" "))
+		}
+	}
+
+	return labelStyle.Render("  Mood: ") + strings.Join(parts, " ")
+}
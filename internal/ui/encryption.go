@@ -0,0 +1,262 @@
+package ui
+
+import (
+	"strings"
+
+	"journal/internal/model"
+	"journal/internal/storage"
+	"journal/internal/theme"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type encryptionStep int
+
+const (
+	encryptionWarning encryptionStep = iota
+	encryptionEnterPassword
+	encryptionConfirmPassword
+	encryptionHint
+)
+
+// EncryptionModel converts the active journal between encrypted and
+// plaintext storage
+type EncryptionModel struct {
+	journal       *model.JournalDB
+	password      string // Current password when decrypting
+	step          encryptionStep
+	passwordInput textinput.Model
+	confirmInput  textinput.Model
+	hintInput     textinput.Model
+	Password      string // New password after encrypting
+	PasswordHint  string // Hint for the new password, if one was given
+	Done          bool
+	Cancelled     bool
+	Error         string
+}
+
+func NewEncryptionModel(journal *model.JournalDB, password string) EncryptionModel {
+	pi := textinput.New()
+	pi.Placeholder = "Enter password"
+	pi.EchoMode = textinput.EchoPassword
+	pi.EchoCharacter = '*'
+	pi.CharLimit = 256
+	pi.Width = 30
+
+	ci := textinput.New()
+	ci.Placeholder = "Confirm password"
+	ci.EchoMode = textinput.EchoPassword
+	ci.EchoCharacter = '*'
+	ci.CharLimit = 256
+	ci.Width = 30
+
+	hi := textinput.New()
+	hi.Placeholder = "e.g. the song from the wedding"
+	hi.CharLimit = 100
+	hi.Width = 50
+
+	return EncryptionModel{
+		journal:       journal,
+		password:      password,
+		passwordInput: pi,
+		confirmInput:  ci,
+		hintInput:     hi,
+	}
+}
+
+func (m EncryptionModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m EncryptionModel) Update(msg tea.Msg) (EncryptionModel, tea.Cmd) {
+	var cmd tea.Cmd
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch m.step {
+	case encryptionWarning:
+		switch keyMsg.String() {
+		case "y", "Y":
+			m.step = encryptionEnterPassword
+			m.passwordInput.Focus()
+			return m, textinput.Blink
+		case "n", "N", "esc":
+			m.Cancelled = true
+		}
+		return m, nil
+
+	case encryptionEnterPassword:
+		switch keyMsg.String() {
+		case "enter":
+			value := m.passwordInput.Value()
+			if value == "" {
+				return m, nil
+			}
+			if m.journal.Encrypted {
+				// Re-entering the password confirms the decryption
+				if value != m.password {
+					m.Error = "Incorrect password"
+					m.passwordInput.SetValue("")
+					return m, nil
+				}
+				if err := storage.DecryptJournal(m.journal.Path, m.password); err != nil {
+					m.Error = err.Error()
+					return m, nil
+				}
+				m.Done = true
+				return m, nil
+			}
+			m.step = encryptionConfirmPassword
+			m.passwordInput.Blur()
+			m.confirmInput.Focus()
+			return m, textinput.Blink
+		case "esc":
+			m.Cancelled = true
+			return m, nil
+		}
+		m.Error = ""
+		m.passwordInput, cmd = m.passwordInput.Update(msg)
+		return m, cmd
+
+	case encryptionConfirmPassword:
+		switch keyMsg.String() {
+		case "enter":
+			if m.confirmInput.Value() != m.passwordInput.Value() {
+				m.Error = "Passwords do not match"
+				m.confirmInput.SetValue("")
+				return m, nil
+			}
+			m.step = encryptionHint
+			m.confirmInput.Blur()
+			m.hintInput.Focus()
+			return m, textinput.Blink
+		case "esc":
+			m.step = encryptionEnterPassword
+			m.confirmInput.SetValue("")
+			m.confirmInput.Blur()
+			m.passwordInput.Focus()
+			return m, textinput.Blink
+		}
+		m.Error = ""
+		m.confirmInput, cmd = m.confirmInput.Update(msg)
+		return m, cmd
+
+	case encryptionHint:
+		switch keyMsg.String() {
+		case "enter":
+			password := m.passwordInput.Value()
+			hint := strings.TrimSpace(m.hintInput.Value())
+			if hint != "" && strings.Contains(strings.ToLower(hint), strings.ToLower(password)) {
+				m.Error = "The hint can't contain the password"
+				return m, nil
+			}
+			if err := storage.EncryptJournal(m.journal.Path, password); err != nil {
+				m.Error = err.Error()
+				return m, nil
+			}
+			m.Password = password
+			m.PasswordHint = hint
+			m.Done = true
+			return m, nil
+		case "esc":
+			m.step = encryptionConfirmPassword
+			m.confirmInput.SetValue("")
+			m.hintInput.Blur()
+			m.confirmInput.Focus()
+			return m, textinput.Blink
+		}
+		m.Error = ""
+		m.hintInput, cmd = m.hintInput.Update(msg)
+		return m, cmd
+	}
+
+	return m, nil
+}
+
+func (m EncryptionModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	promptStyle := lipgloss.NewStyle().Foreground(t.Text)
+	warningStyle := lipgloss.NewStyle().Foreground(t.Warning).Bold(true)
+	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+
+	title := "Encrypt Journal"
+	if m.journal.Encrypted {
+		title = "Decrypt Journal"
+	}
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n\n")
+	b.WriteString(mutedStyle.Render("Journal: " + m.journal.Name))
+	b.WriteString("\n\n")
+
+	switch m.step {
+	case encryptionWarning:
+		if m.journal.Encrypted {
+			b.WriteString(warningStyle.Render("Warning: this permanently removes encryption from the journal."))
+			b.WriteString("\n\n")
+			b.WriteString(promptStyle.Render("Entries, history, and attachments will be stored as a plain SQLite"))
+			b.WriteString("\n")
+			b.WriteString(promptStyle.Render("file that anyone with access to the file can read."))
+		} else {
+			b.WriteString(warningStyle.Render("Warning: the journal cannot be opened without the password."))
+			b.WriteString("\n\n")
+			b.WriteString(promptStyle.Render("There is no way to recover a forgotten password. Existing backups"))
+			b.WriteString("\n")
+			b.WriteString(promptStyle.Render("of this journal are not encrypted and should be deleted."))
+		}
+		b.WriteString("\n\n")
+		b.WriteString(promptStyle.Render("Continue?"))
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render("Press " + keyStyle.Render("y") + " to continue, " + keyStyle.Render("n") + " or " + keyStyle.Render("Esc") + " to cancel"))
+		return b.String()
+
+	case encryptionEnterPassword:
+		if m.journal.Encrypted {
+			b.WriteString(promptStyle.Render("Enter the current password to confirm:"))
+		} else {
+			b.WriteString(promptStyle.Render("Enter a password for encryption:"))
+		}
+		b.WriteString("\n\n  ")
+		b.WriteString(m.passwordInput.View())
+		b.WriteString("\n")
+
+	case encryptionConfirmPassword:
+		b.WriteString(promptStyle.Render("Confirm your password:"))
+		b.WriteString("\n\n  ")
+		b.WriteString(m.confirmInput.View())
+		b.WriteString("\n")
+
+	case encryptionHint:
+		b.WriteString(promptStyle.Render("Add a password hint (optional):"))
+		b.WriteString("\n")
+		b.WriteString(mutedStyle.Render("Shown after 3 wrong passwords. It is saved unencrypted in the config file,"))
+		b.WriteString("\n")
+		b.WriteString(mutedStyle.Render("so make it something only you would understand"))
+		b.WriteString("\n\n  ")
+		b.WriteString(m.hintInput.View())
+		b.WriteString("\n")
+	}
+
+	if m.Error != "" {
+		b.WriteString("\n  ")
+		b.WriteString(errorStyle.Render(m.Error))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(helpStyle.Render(keyStyle.Render("Enter") + " continue | " + keyStyle.Render("Esc") + " back"))
+
+	return b.String()
+}
@@ -0,0 +1,228 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"journal/internal/model"
+	"journal/internal/storage"
+	"journal/internal/theme"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// entryExportFormat is a format the entries marked in the list can be
+// exported to. Formats without an extension write a folder.
+type entryExportFormat struct {
+	id   string
+	name string
+	ext  string
+}
+
+var entryExportFormats = []entryExportFormat{
+	{"markdown", "Markdown", ".md"},
+	{"json", "JSON", ".json"},
+	{"print", "Printable HTML (print or save as PDF)", ".html"},
+	{"html", "HTML page in the theme's colors, newest first", ".html"},
+	{"html-oldest", "HTML page in the theme's colors, oldest first", ".html"},
+	{"folder", "Markdown files, one per entry (folder)", ""},
+}
+
+// entryExportListed is how many of the exported entries' dates are shown
+const entryExportListed = 6
+
+// EntryExportModel exports the entries marked in the list, or the
+// selected one, to a single file or a folder of Markdown files
+type EntryExportModel struct {
+	journal   *model.Journal
+	store     storage.Store
+	title     string
+	format    int
+	pathInput textinput.Model
+	Back      bool
+	Error     string
+	Message   string
+}
+
+func NewEntryExportModel(entries []model.Entry, store storage.Store, title string) EntryExportModel {
+	ti := textinput.New()
+	ti.Placeholder = "Enter destination..."
+	ti.CharLimit = 512
+	ti.Width = 50
+	ti.Focus()
+
+	if home, _ := storage.ExpandPath("~/"); home != "" {
+		ti.SetValue(filepath.Join(home, "journal-entries"+entryExportFormats[0].ext))
+	}
+
+	return EntryExportModel{
+		journal:   &model.Journal{Entries: entries},
+		store:     store,
+		title:     title,
+		pathInput: ti,
+	}
+}
+
+func (m EntryExportModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m EntryExportModel) Update(msg tea.Msg) (EntryExportModel, tea.Cmd) {
+	var cmd tea.Cmd
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "enter":
+			if m.pathInput.Value() == "" {
+				return m, nil
+			}
+			if err := m.export(m.pathInput.Value()); err != nil {
+				m.Error = err.Error()
+				m.Message = ""
+			} else {
+				m.Error = ""
+				m.Message = fmt.Sprintf("Exported %d entries to %s", len(m.journal.Entries), m.pathInput.Value())
+				if entryExportFormats[m.format].id == "folder" {
+					m.Message += " as Markdown files"
+				} else if entryExportFormats[m.format].id == "markdown" && m.hasAttachments() {
+					m.Message += ", with their attachments in " + filepath.Join(filepath.Dir(m.pathInput.Value()), storage.MarkdownAssetsDir)
+				}
+			}
+			return m, nil
+		case "tab", "shift+tab":
+			step := 1
+			if msg.String() == "shift+tab" {
+				step = len(entryExportFormats) - 1
+			}
+			m.setFormat((m.format + step) % len(entryExportFormats))
+			return m, nil
+		case "esc":
+			m.Back = true
+			return m, nil
+		}
+	}
+
+	m.Error = ""
+	m.pathInput, cmd = m.pathInput.Update(msg)
+	return m, cmd
+}
+
+// setFormat picks the format to export to, giving the destination the
+// format's extension if it has the previous one's
+func (m *EntryExportModel) setFormat(format int) {
+	path := m.pathInput.Value()
+	if old := entryExportFormats[m.format].ext; strings.HasSuffix(path, old) {
+		m.pathInput.SetValue(strings.TrimSuffix(path, old) + entryExportFormats[format].ext)
+		m.pathInput.CursorEnd()
+	}
+	m.format = format
+}
+
+// ExportFolder picks the folder of Markdown files as the format, for
+// exporting the whole journal
+func (m *EntryExportModel) ExportFolder() {
+	for i, f := range entryExportFormats {
+		if f.id == "folder" {
+			m.setFormat(i)
+		}
+	}
+}
+
+// hasAttachments reports whether any of the entries has attachments
+func (m EntryExportModel) hasAttachments() bool {
+	for _, e := range m.journal.Entries {
+		if len(e.Attachments) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (m EntryExportModel) export(path string) error {
+	switch id := entryExportFormats[m.format].id; id {
+	case "markdown":
+		return storage.ExportMarkdown(storage.DiskFS, m.journal, m.store, path, m.title)
+	case "json":
+		return storage.ExportJSON(storage.DiskFS, m.journal, path)
+	case "html", "html-oldest":
+		return storage.ExportHTML(storage.DiskFS, m.journal, m.store, path, storage.HTMLOptions{
+			Title:       m.title,
+			NewestFirst: id == "html",
+			Theme:       theme.Current(),
+			Light:       theme.IsLight(),
+		})
+	case "folder":
+		return storage.ExportMarkdownFolder(storage.DiskFS, m.journal, m.store, path)
+	default:
+		return storage.ExportPrintHTML(storage.DiskFS, m.journal, m.store, path, m.title)
+	}
+}
+
+func (m EntryExportModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	labelStyle := lipgloss.NewStyle().Foreground(t.Text).Bold(true)
+	valueStyle := lipgloss.NewStyle().Foreground(t.Info)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true)
+	itemStyle := lipgloss.NewStyle().Foreground(t.TextDim)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	successStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Export Entries"))
+	b.WriteString("\n\n")
+
+	var dates []string
+	for _, e := range m.journal.Entries[:min(len(m.journal.Entries), entryExportListed)] {
+		dates = append(dates, e.Date)
+	}
+	if more := len(m.journal.Entries) - len(dates); more > 0 {
+		dates = append(dates, fmt.Sprintf("and %d more", more))
+	}
+	b.WriteString(labelStyle.Render(fmt.Sprintf("Entries (%d): ", len(m.journal.Entries))))
+	b.WriteString(valueStyle.Render(strings.Join(dates, ", ")))
+	b.WriteString("\n\n")
+
+	b.WriteString(labelStyle.Render("Format:"))
+	b.WriteString("\n")
+	for i, f := range entryExportFormats {
+		if i == m.format {
+			b.WriteString(selectedStyle.Render("  > " + f.name))
+		} else {
+			b.WriteString(itemStyle.Render("    " + f.name))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString(labelStyle.Render("Destination:"))
+	b.WriteString("\n\n")
+	b.WriteString("  ")
+	b.WriteString(m.pathInput.View())
+	b.WriteString("\n\n")
+
+	if m.Error != "" {
+		b.WriteString(errorStyle.Render("Error: " + m.Error))
+		b.WriteString("\n\n")
+	}
+	if m.Message != "" {
+		b.WriteString(successStyle.Render(m.Message))
+		b.WriteString("\n\n")
+	}
+
+	parts := []string{
+		keyStyle.Render("Enter") + " export",
+		keyStyle.Render("Tab") + " format",
+		keyStyle.Render("Esc") + " back",
+	}
+	b.WriteString(helpStyle.Render(strings.Join(parts, " | ")))
+
+	return b.String()
+}
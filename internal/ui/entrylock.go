@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"fmt"
+
+	"journal/internal/clock"
+	"journal/internal/dates"
+)
+
+// entryLock seals the entries of a journal that are older than its
+// lock_after_days setting, so they can be read but not edited, deleted, or
+// given attachments by accident. An entry unlocked with U in the list stays
+// editable until the journal is closed.
+type entryLock struct {
+	days     int // 0 when entries are never locked
+	clock    clock.Clock
+	unlocked map[string]bool // IDs of the entries unlocked this session
+}
+
+func newEntryLock(days int, clk clock.Clock) *entryLock {
+	return &entryLock{days: days, clock: clk, unlocked: make(map[string]bool)}
+}
+
+// old reports whether an entry of the date is old enough to be locked
+func (l *entryLock) old(date string) bool {
+	if l == nil || l.days <= 0 {
+		return false
+	}
+	cutoff := l.clock.Now().AddDate(0, 0, -l.days).Format(dates.Layout)
+	return date < cutoff
+}
+
+// Locked reports whether the entry is locked: old enough, and not unlocked
+func (l *entryLock) Locked(id, date string) bool {
+	return l.old(date) && !l.unlocked[id]
+}
+
+// Toggle unlocks the entry for the session, or locks it again when it was
+// unlocked, and describes what it did. Entries that are too recent to be
+// locked are left alone.
+func (l *entryLock) Toggle(id, date string) string {
+	if !l.old(date) {
+		if l == nil || l.days <= 0 {
+			return "Entries aren't locked in this journal; set it up in settings (s)"
+		}
+		return fmt.Sprintf("The entry for %s isn't locked; only entries older than %s are", date, formatDays(l.days))
+	}
+	if l.unlocked[id] {
+		delete(l.unlocked, id)
+		return "Locked the entry for " + date + " again"
+	}
+	l.unlocked[id] = true
+	return "Unlocked the entry for " + date + " until the journal is closed"
+}
+
+// Reason explains why an entry of the date can't be changed
+func (l *entryLock) Reason(date string) string {
+	return fmt.Sprintf("The entry for %s is locked, as it is older than %s. Press U in the list to unlock it", date, formatDays(l.days))
+}
+
+// lockChoices are the days the lock setting cycles through, 0 for off
+var lockChoices = []int{0, 7, 30, 90, 365}
+
+// formatDays renders a setting in days, 0 being off
+func formatDays(days int) string {
+	switch days {
+	case 0:
+		return "off"
+	case 1:
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
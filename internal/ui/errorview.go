@@ -0,0 +1,190 @@
+package ui
+
+import (
+	"errors"
+	"strings"
+
+	"journal/internal/storage"
+	"journal/internal/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// errorAction is a way out of the error screen
+type errorAction int
+
+const (
+	errorActionRetry errorAction = iota
+	errorActionSelector
+	errorActionBackups
+	errorActionQuit
+)
+
+var errorActionLabels = map[errorAction]string{
+	errorActionRetry:    "Retry",
+	errorActionSelector: "Back to journal selector",
+	errorActionBackups:  "Restore from a backup",
+	errorActionQuit:     "Quit",
+}
+
+var errorActionKeys = map[errorAction]string{
+	errorActionRetry:    "r",
+	errorActionSelector: "s",
+	errorActionBackups:  "b",
+	errorActionQuit:     "q",
+}
+
+// ErrorModel explains a failed operation and offers ways to recover
+type ErrorModel struct {
+	Operation string // What was being done, e.g. "Saving the entry"
+	Path      string // File involved, if any
+	Err       error
+	actions   []errorAction
+	selected  int
+	Chosen    errorAction
+	Done      bool
+}
+
+// errorHint suggests what to do about a storage error, if its kind is known
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrCorruptDB):
+		return "The file is damaged or isn't a journal. Restoring a backup is the safest fix."
+	case errors.Is(err, storage.ErrNewerFormat):
+		return "Update journal to open this file. It is left as it is until then."
+	case errors.Is(err, storage.ErrNotJournal):
+		return "Check that the journal's path points at its encrypted file, not another file."
+	case errors.Is(err, storage.ErrLocked):
+		return "Another program, or another copy of journal, is using the file. Close it, then retry."
+	case errors.Is(err, storage.ErrPermission):
+		return "Check that you can read and write the file and its folder."
+	case errors.Is(err, storage.ErrPathNotFound):
+		return "The file or its folder was moved, renamed, or deleted, or is on a drive that isn't connected."
+	}
+	return ""
+}
+
+// preferredAction is the recovery action to offer first for err
+func preferredAction(err error) (errorAction, bool) {
+	switch {
+	case errors.Is(err, storage.ErrCorruptDB):
+		return errorActionBackups, true
+	case errors.Is(err, storage.ErrPathNotFound):
+		return errorActionSelector, true
+	}
+	return 0, false
+}
+
+func NewErrorModel(operation, path string, err error, actions []errorAction) ErrorModel {
+	// Move the action most likely to help to the top
+	if preferred, ok := preferredAction(err); ok {
+		for i, action := range actions {
+			if action == preferred {
+				actions = append([]errorAction{action}, append(actions[:i:i], actions[i+1:]...)...)
+				break
+			}
+		}
+	}
+
+	return ErrorModel{
+		Operation: operation,
+		Path:      path,
+		Err:       err,
+		actions:   actions,
+	}
+}
+
+func (m ErrorModel) Update(msg tea.Msg) (ErrorModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch keyMsg.String() {
+	case "up", "k":
+		if m.selected > 0 {
+			m.selected--
+		}
+	case "down", "j":
+		if m.selected < len(m.actions)-1 {
+			m.selected++
+		}
+	case "enter":
+		m.Chosen = m.actions[m.selected]
+		m.Done = true
+	default:
+		for _, action := range m.actions {
+			if keyMsg.String() == errorActionKeys[action] {
+				m.Chosen = action
+				m.Done = true
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func (m ErrorModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Error)
+	hintStyle := lipgloss.NewStyle().Foreground(t.Warning)
+	labelStyle := lipgloss.NewStyle().Foreground(t.Text).Bold(true)
+	valueStyle := lipgloss.NewStyle().Foreground(t.Info)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error)
+	itemStyle := lipgloss.NewStyle().Foreground(t.Text).PaddingLeft(2)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true).PaddingLeft(2)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+
+	b.WriteString("\n")
+	title := "Something went wrong"
+	var storageErr *storage.Error
+	if errors.As(m.Err, &storageErr) {
+		title = storageErr.Kind.Error()
+		title = strings.ToUpper(title[:1]) + title[1:]
+	}
+	b.WriteString(titleStyle.Render(title))
+	b.WriteString("\n\n")
+
+	if m.Operation != "" {
+		b.WriteString(labelStyle.Render("While: "))
+		b.WriteString(valueStyle.Render(m.Operation))
+		b.WriteString("\n")
+	}
+	if m.Path != "" {
+		b.WriteString(labelStyle.Render("File:  "))
+		b.WriteString(valueStyle.Render(m.Path))
+		b.WriteString("\n")
+	}
+	b.WriteString(labelStyle.Render("Error: "))
+	b.WriteString(errorStyle.Render(m.Err.Error()))
+	b.WriteString("\n\n")
+
+	if hint := errorHint(m.Err); hint != "" {
+		b.WriteString(hintStyle.Render(hint))
+		b.WriteString("\n\n")
+	}
+
+	for i, action := range m.actions {
+		label := "[" + errorActionKeys[action] + "] " + errorActionLabels[action]
+		if i == m.selected {
+			b.WriteString(selectedStyle.Render("> " + label))
+		} else {
+			b.WriteString(itemStyle.Render("  " + label))
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	var parts []string
+	parts = append(parts, keyStyle.Render("Up/Down")+" navigate")
+	parts = append(parts, keyStyle.Render("Enter")+" choose")
+	parts = append(parts, keyStyle.Render("Ctrl+C")+" quit")
+
+	b.WriteString(helpStyle.Render(strings.Join(parts, " | ")))
+
+	return b.String()
+}
@@ -14,9 +14,7 @@ import (
 
 type ExportModel struct {
 	attachment *model.Attachment
-	dbPath     string
-	encrypted  bool
-	password   string
+	store      storage.Store
 	pathInput  textinput.Model
 	Done       bool
 	Cancelled  bool
@@ -24,7 +22,7 @@ type ExportModel struct {
 	Message    string
 }
 
-func NewExportModel(attachment *model.Attachment, dbPath string, encrypted bool, password string) ExportModel {
+func NewExportModel(attachment *model.Attachment, store storage.Store) ExportModel {
 	ti := textinput.New()
 	ti.Placeholder = "Enter destination path or directory..."
 	ti.CharLimit = 512
@@ -39,9 +37,7 @@ func NewExportModel(attachment *model.Attachment, dbPath string, encrypted bool,
 
 	return ExportModel{
 		attachment: attachment,
-		dbPath:     dbPath,
-		encrypted:  encrypted,
-		password:   password,
+		store:      store,
 		pathInput:  ti,
 	}
 }
@@ -59,14 +55,7 @@ func (m ExportModel) Update(msg tea.Msg) (ExportModel, tea.Cmd) {
 		case "enter":
 			destPath := m.pathInput.Value()
 			if destPath != "" {
-				var err error
-				if m.encrypted {
-					err = storage.ExportAttachmentEncrypted(m.dbPath, m.password, m.attachment.ID, destPath)
-				} else {
-					err = storage.ExportAttachment(m.dbPath, m.attachment.ID, destPath)
-				}
-
-				if err != nil {
+				if err := m.store.ExportAttachment(m.attachment.ID, destPath); err != nil {
 					m.Error = err.Error()
 				} else {
 					m.Message = "Exported successfully"
@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"time"
+
+	"journal/internal/model"
+	"journal/internal/storage"
+)
+
+// writeTracker is the store of the active journal, noting the entries the
+// app saves so they aren't taken for changes made outside it
+type writeTracker struct {
+	storage.Store
+	written map[string]bool // IDs of the entries saved since the last check
+}
+
+func newWriteTracker(store storage.Store) writeTracker {
+	return writeTracker{Store: store, written: make(map[string]bool)}
+}
+
+func (s writeTracker) Save(journal *model.Journal) error {
+	for _, entry := range journal.Entries {
+		s.written[entry.ID] = true
+	}
+	return s.Store.Save(journal)
+}
+
+func (s writeTracker) SaveEntries(entries []model.Entry) error {
+	for _, entry := range entries {
+		s.written[entry.ID] = true
+	}
+	return s.Store.SaveEntries(entries)
+}
+
+// externalChanges tells which entries were added or changed outside the
+// app since it last looked, e.g. by a sync client, another computer sharing
+// the journal, or an editor on a Markdown journal's files. They stay marked
+// until they are opened.
+type externalChanges struct {
+	store   writeTracker
+	known   map[string]time.Time // When each entry was last saved, as of the last check
+	changed map[string]bool      // IDs of the entries changed and not opened since
+}
+
+func newExternalChanges(store writeTracker) (*externalChanges, error) {
+	known, err := store.EntryUpdateTimes()
+	if err != nil {
+		return nil, err
+	}
+	return &externalChanges{store: store, known: known, changed: make(map[string]bool)}, nil
+}
+
+// check compares the entries with the last check and returns how many were
+// added or changed outside the app in between
+func (c *externalChanges) check() (int, error) {
+	times, err := c.store.EntryUpdateTimes()
+	if err != nil {
+		return 0, err
+	}
+	found := 0
+	for id, updated := range times {
+		if c.store.written[id] {
+			continue
+		}
+		if known, ok := c.known[id]; !ok || !known.Equal(updated) {
+			c.changed[id] = true
+			found++
+		}
+	}
+	for id := range c.changed {
+		if _, ok := times[id]; !ok {
+			delete(c.changed, id)
+		}
+	}
+	c.known = times
+	clear(c.store.written)
+	return found, nil
+}
+
+// Changed reports whether the entry was changed outside the app and hasn't
+// been opened since
+func (c *externalChanges) Changed(id string) bool {
+	return c.changed[id]
+}
+
+// viewed clears the mark of an entry that has been opened
+func (c *externalChanges) viewed(id string) {
+	delete(c.changed, id)
+}
@@ -2,34 +2,212 @@ package ui
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
+	"journal/internal/clock"
 	"journal/internal/model"
+	"journal/internal/storage"
 	"journal/internal/theme"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
+type labelMode int
+
+const (
+	labelNone     labelMode = iota
+	labelVersion            // Editing the label of the selected version
+	labelSnapshot           // Naming a new snapshot of the current content
+)
+
 type HistoryModel struct {
 	entry         *model.Entry
+	store         storage.Store
 	selectedIndex int
 	expanded      bool
+	labelMode     labelMode
+	labelInput    textinput.Model
 	Back          bool
+	Error         string
+	Message       string
 	width         int
 	height        int
 	offset        int
+	timeline      bool               // Showing when files were added and removed instead of the versions
+	timelineTop   int                // First timeline event shown
+	restoring     bool               // Asking to confirm restoring the selected version
+	restorable    []model.Attachment // Deleted attachments of the version being restored that are still kept
+	diffing       bool               // Comparing the selected version with the current content
+	diffTop       int                // First diff line shown
+	locked        string             // Why versions can't be restored, when the entry is locked
+	clock         clock.Clock
 }
 
-func NewHistoryModel(entry *model.Entry) HistoryModel {
+func NewHistoryModel(entry *model.Entry, store storage.Store, clk clock.Clock) HistoryModel {
+	ti := textinput.New()
+	ti.Placeholder = "e.g. before rewrite"
+	ti.CharLimit = 80
+	ti.Width = 40
+
 	return HistoryModel{
 		entry:         entry,
+		store:         store,
+		clock:         clk,
 		selectedIndex: 0,
 		expanded:      false,
+		labelInput:    ti,
+	}
+}
+
+// selectedRecord returns the history record for the selected row, or nil
+// when the current version is selected
+func (m HistoryModel) selectedRecord() *model.SaveRecord {
+	if m.selectedIndex == 0 {
+		return nil
+	}
+	sorted := m.sortedHistory()
+	if m.selectedIndex-1 >= len(sorted) {
+		return nil
+	}
+	savedAt := sorted[m.selectedIndex-1].SavedAt
+	for i := range m.entry.History {
+		if m.entry.History[i].SavedAt.Equal(savedAt) {
+			return &m.entry.History[i]
+		}
+	}
+	return nil
+}
+
+// setLabel persists a new label for the selected history record
+func (m *HistoryModel) setLabel(label string) error {
+	record := m.selectedRecord()
+	if record == nil {
+		return nil
+	}
+	if err := m.store.UpdateHistoryLabel(m.entry.ID, record.SavedAt, label); err != nil {
+		return err
+	}
+	record.Label = label
+	return nil
+}
+
+// snapshot records the current content as a labelled history version
+func (m *HistoryModel) snapshot(label string) error {
+	record := model.SaveRecord{
+		Content:     m.entry.Content,
+		SavedAt:     m.clock.Now(),
+		Attachments: m.entry.AttachmentFilenames(),
+		Tags:        m.entry.Tags,
+		Label:       label,
+	}
+	if err := m.store.AddHistoryRecord(m.entry.ID, record); err != nil {
+		return err
+	}
+	m.entry.History = append(m.entry.History, record)
+	return nil
+}
+
+// keptAttachments returns the attachments the version had that were
+// deleted from the entry since, as far as they are still kept
+func (m HistoryModel) keptAttachments(record *model.SaveRecord) ([]model.Attachment, error) {
+	missing := map[string]int{}
+	removed := 0
+	for _, name := range record.Attachments {
+		missing[name]++
+		removed++
+	}
+	for _, att := range m.entry.Attachments {
+		if missing[att.Filename] > 0 {
+			missing[att.Filename]--
+			removed--
+		}
+	}
+	if removed == 0 {
+		return nil, nil
+	}
+
+	trashed, err := m.store.TrashedAttachments(m.entry.ID)
+	if err != nil {
+		return nil, err
+	}
+	var kept []model.Attachment
+	for _, att := range trashed {
+		if missing[att.Filename] > 0 {
+			missing[att.Filename]--
+			kept = append(kept, att)
+		}
+	}
+	return kept, nil
+}
+
+// restore makes the selected version the entry's current content, first
+// recording the current content in its history, and brings back the
+// deleted attachments given. Tags and other attachments are left as they
+// are.
+func (m *HistoryModel) restore(attachments []model.Attachment) error {
+	record := m.selectedRecord()
+	if record == nil {
+		return nil
+	}
+	entry := *m.entry
+	entry.History = append(slices.Clone(m.entry.History), model.SaveRecord{
+		Content:     m.entry.Content,
+		SavedAt:     m.entry.UpdatedAt,
+		Attachments: m.entry.AttachmentFilenames(),
+		Tags:        m.entry.Tags,
+	})
+	entry.Content = record.Content
+	entry.UpdatedAt = m.clock.Now()
+	if err := m.store.SaveEntries([]model.Entry{entry}); err != nil {
+		return err
+	}
+	for _, att := range attachments {
+		if err := m.store.RestoreAttachment(att.ID); err != nil {
+			return fmt.Errorf("restoring %s: %w", att.Filename, err)
+		}
+	}
+	// Read it back for the checksum of the version just recorded
+	saved, err := getEntryWithHistory(m.store, entry.ID)
+	if err != nil {
+		return err
+	}
+	*m.entry = *saved
+	return nil
+}
+
+// Lock keeps versions from being restored, giving the reason when it is
+// tried
+func (m *HistoryModel) Lock(reason string) {
+	m.locked = reason
+}
+
+// SelectVersion selects and expands the history record saved at savedAt
+func (m *HistoryModel) SelectVersion(savedAt time.Time) {
+	for i, record := range m.sortedHistory() {
+		if record.SavedAt.Equal(savedAt) {
+			m.selectedIndex = i + 1 // Index 0 is the current version
+			m.expanded = true
+			m.adjustScroll()
+			return
+		}
 	}
 }
 
+// sortedHistory returns the entry's history sorted most recent first
+func (m HistoryModel) sortedHistory() []model.SaveRecord {
+	sorted := make([]model.SaveRecord, len(m.entry.History))
+	copy(sorted, m.entry.History)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].SavedAt.After(sorted[j].SavedAt)
+	})
+	return sorted
+}
+
 func (m *HistoryModel) SetSize(width, height int) {
 	m.width = width
 	m.height = height
@@ -55,8 +233,110 @@ func (m *HistoryModel) adjustScroll() {
 func (m HistoryModel) Update(msg tea.Msg) (HistoryModel, tea.Cmd) {
 	totalItems := len(m.entry.History) + 1
 
+	if m.labelMode != labelNone {
+		var cmd tea.Cmd
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "enter":
+				label := strings.TrimSpace(m.labelInput.Value())
+				var err error
+				if m.labelMode == labelSnapshot {
+					if label == "" {
+						label = "Manual snapshot"
+					}
+					err = m.snapshot(label)
+					m.Message = "Snapshot saved"
+					m.selectedIndex = 1
+					m.offset = 0
+				} else {
+					err = m.setLabel(label)
+					m.Message = "Label updated"
+				}
+				if err != nil {
+					m.Error = err.Error()
+					m.Message = ""
+				}
+				m.labelMode = labelNone
+				m.labelInput.Blur()
+				return m, nil
+			case "esc":
+				m.labelMode = labelNone
+				m.labelInput.Blur()
+				return m, nil
+			}
+		}
+		m.labelInput, cmd = m.labelInput.Update(msg)
+		return m, cmd
+	}
+
+	if m.restoring {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			key := msg.String()
+			switch {
+			case key == "y" || key == "Y" || (key == "c" && len(m.restorable) > 0):
+				m.restoring = false
+				attachments := m.restorable
+				if key == "c" {
+					attachments = nil
+				}
+				savedAt := m.selectedRecord().SavedAt
+				if err := m.restore(attachments); err != nil {
+					m.Error = err.Error()
+					return m, nil
+				}
+				m.selectedIndex = 0
+				m.expanded = false
+				m.offset = 0
+				m.Message = "Restored the version of " + savedAt.Format("2006-01-02 15:04:05")
+				if len(attachments) > 0 {
+					m.Message += fmt.Sprintf(" with %d attachments", len(attachments))
+				}
+				m.Message += "; the replaced content is kept in history"
+			case key == "n" || key == "N" || key == "esc":
+				m.restoring = false
+			}
+		}
+		return m, nil
+	}
+
+	if m.timeline {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "up", "k":
+				m.timelineTop = max(m.timelineTop-1, 0)
+			case "down", "j":
+				m.timelineTop = max(min(m.timelineTop+1, len(m.entry.AttachmentTimeline())-m.timelineRows()), 0)
+			case "f", "esc", "q":
+				m.timeline = false
+			}
+		}
+		return m, nil
+	}
+
+	if m.diffing {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			last := max(len(m.diffLines())-m.diffRows(), 0)
+			switch msg.String() {
+			case "up", "k":
+				m.diffTop = max(min(m.diffTop, last)-1, 0)
+			case "down", "j":
+				m.diffTop = min(m.diffTop+1, last)
+			case "pgup":
+				m.diffTop = max(min(m.diffTop, last)-m.diffRows(), 0)
+			case "pgdown":
+				m.diffTop = min(m.diffTop+m.diffRows(), last)
+			case "d", "esc", "q":
+				m.diffing = false
+			}
+		}
+		return m, nil
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.Error = ""
+		m.Message = ""
+
 		switch msg.String() {
 		case "up", "k":
 			if m.selectedIndex > 0 {
@@ -72,6 +352,42 @@ func (m HistoryModel) Update(msg tea.Msg) (HistoryModel, tea.Cmd) {
 			}
 		case "enter":
 			m.expanded = !m.expanded
+		case "f":
+			m.timeline = true
+			m.timelineTop = 0
+		case "d":
+			if m.selectedRecord() == nil {
+				m.Error = "Select a previous version to compare with the current content"
+			} else {
+				m.diffing = true
+				m.diffTop = 0
+			}
+		case "r":
+			if m.selectedRecord() == nil {
+				m.Error = "Select a previous version to restore"
+			} else if m.locked != "" {
+				m.Error = m.locked
+			} else if kept, err := m.keptAttachments(m.selectedRecord()); err != nil {
+				m.Error = err.Error()
+			} else {
+				m.restorable = kept
+				m.restoring = true
+			}
+		case "l":
+			if record := m.selectedRecord(); record != nil {
+				m.labelMode = labelVersion
+				m.labelInput.SetValue(record.Label)
+				m.labelInput.CursorEnd()
+				m.labelInput.Focus()
+				return m, textinput.Blink
+			}
+			// Labelling the current version takes a named snapshot of it
+			fallthrough
+		case "s":
+			m.labelMode = labelSnapshot
+			m.labelInput.SetValue("")
+			m.labelInput.Focus()
+			return m, textinput.Blink
 		case "esc", "q":
 			m.Back = true
 		}
@@ -80,7 +396,104 @@ func (m HistoryModel) Update(msg tea.Msg) (HistoryModel, tea.Cmd) {
 	return m, nil
 }
 
+// timelineRows returns how many attachment timeline events fit on the screen
+func (m HistoryModel) timelineRows() int {
+	return max(m.height-10, 3)
+}
+
+// timelineTime formats a time in the attachment timeline
+func timelineTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// describeAttachmentEvent says when an attachment event happened, as
+// closely as the versions tell
+func describeAttachmentEvent(ev model.AttachmentEvent) string {
+	verb := "removed"
+	if ev.Added {
+		verb = "added"
+	}
+	switch {
+	case !ev.At.IsZero():
+		return verb + " " + timelineTime(ev.At)
+	case ev.After.IsZero() && ev.Before.IsZero():
+		return verb + " before the first version"
+	case ev.After.IsZero():
+		return verb + " by " + timelineTime(ev.Before)
+	case ev.Before.IsZero():
+		return verb + " after " + timelineTime(ev.After) + ", since the latest version"
+	}
+	return verb + " between " + timelineTime(ev.After) + " and " + timelineTime(ev.Before)
+}
+
+func (m HistoryModel) viewTimeline() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	dateStyle := lipgloss.NewStyle().Foreground(t.Info).Bold(true)
+	addedStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	removedStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	fileStyle := lipgloss.NewStyle().Foreground(t.Accent)
+	whenStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	emptyStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true).PaddingLeft(2)
+	scrollStyle := lipgloss.NewStyle().Foreground(t.Muted).Italic(true)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	dividerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+
+	dividerWidth := 60
+	if isCompact(m.width) {
+		dividerWidth = m.width
+	}
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("File Timeline"))
+	b.WriteString("\n\n")
+	b.WriteString(dateStyle.Render("Entry: " + m.entry.Date))
+	b.WriteString("\n")
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", dividerWidth)))
+	b.WriteString("\n\n")
+
+	events := m.entry.AttachmentTimeline()
+	if len(events) == 0 {
+		b.WriteString(emptyStyle.Render("No files have been attached to this entry"))
+		b.WriteString("\n")
+	}
+	top := min(m.timelineTop, max(len(events)-m.timelineRows(), 0))
+	end := min(top+m.timelineRows(), len(events))
+	for _, ev := range events[top:end] {
+		sign := removedStyle.Render("- ")
+		if ev.Added {
+			sign = addedStyle.Render("+ ")
+		}
+		line := joinWrapped([]string{sign + fileStyle.Render(ev.Filename), whenStyle.Render(fitWidth(describeAttachmentEvent(ev), m.width-6))}, "  ", m.width-2, "    ")
+		b.WriteString("  " + line)
+		b.WriteString("\n")
+	}
+	if len(events) > m.timelineRows() {
+		b.WriteString(scrollStyle.Render(fmt.Sprintf("  (%d-%d of %d)", top+1, end, len(events))))
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", dividerWidth)))
+	b.WriteString("\n")
+	parts := []string{
+		keyStyle.Render("Up/Down") + " scroll",
+		keyStyle.Render("f/Esc") + " versions",
+	}
+	b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
+	return b.String()
+}
+
 func (m HistoryModel) View() string {
+	if m.timeline {
+		return m.viewTimeline()
+	}
+	if m.diffing {
+		return m.viewDiff()
+	}
 	t := theme.Current()
 	var b strings.Builder
 
@@ -90,13 +503,23 @@ func (m HistoryModel) View() string {
 	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true).PaddingLeft(2)
 	timestampStyle := lipgloss.NewStyle().Foreground(t.Warning).Bold(true)
 	contentStyle := lipgloss.NewStyle().Foreground(t.Text).PaddingLeft(4)
-	expandedContentStyle := lipgloss.NewStyle().Foreground(t.Text).PaddingLeft(4).Width(70)
+	compact := isCompact(m.width)
+	contentWidth, dividerWidth := 70, 60
+	if compact {
+		contentWidth, dividerWidth = m.width-4, m.width
+	}
+	expandedContentStyle := lipgloss.NewStyle().Foreground(t.Text).PaddingLeft(4).Width(contentWidth)
 	currentBadge := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
 	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
 	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
 	dividerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	hashStyle := lipgloss.NewStyle().Foreground(t.Muted)
 	fileStyle := lipgloss.NewStyle().Foreground(t.Accent).Italic(true)
 	fileLabelStyle := lipgloss.NewStyle().Foreground(t.Muted).PaddingLeft(4)
+	snapshotLabelStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	tagStyle := lipgloss.NewStyle().Foreground(t.Accent)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	successStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
 
 	b.WriteString("\n")
 	b.WriteString(titleStyle.Render("Save History"))
@@ -104,20 +527,16 @@ func (m HistoryModel) View() string {
 
 	b.WriteString(dateStyle.Render("Entry: " + m.entry.Date))
 	b.WriteString("\n")
-	b.WriteString(dividerStyle.Render(strings.Repeat("-", 60)))
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", dividerWidth)))
 	b.WriteString("\n\n")
 
 	// Sort history by most recent first (create a sorted copy)
-	sortedHistory := make([]model.SaveRecord, len(m.entry.History))
-	copy(sortedHistory, m.entry.History)
-	sort.Slice(sortedHistory, func(i, j int) bool {
-		return sortedHistory[i].SavedAt.After(sortedHistory[j].SavedAt)
-	})
+	sortedHistory := m.sortedHistory()
 
 	// Build all items: current + history
 	type historyItem struct {
 		index       int
-		label       string
+		label       []string // Stacked in the compact layout
 		content     string
 		attachments string
 	}
@@ -125,8 +544,13 @@ func (m HistoryModel) View() string {
 	var items []historyItem
 
 	// Current version (index 0)
-	currentLabel := timestampStyle.Render(m.entry.UpdatedAt.Format("2006-01-02 15:04:05"))
-	currentLabel += " " + currentBadge.Render("[Current]")
+	currentLabel := []string{
+		timestampStyle.Render(m.entry.UpdatedAt.Format("2006-01-02 15:04:05")),
+		currentBadge.Render("[Current]"),
+	}
+	if len(m.entry.Tags) > 0 {
+		currentLabel = append(currentLabel, tagStyle.Render("#"+strings.Join(m.entry.Tags, " #")))
+	}
 	var currentFiles string
 	if len(m.entry.Attachments) > 0 {
 		var fileNames []string
@@ -141,8 +565,20 @@ func (m HistoryModel) View() string {
 
 	// Historical versions
 	for i, record := range sortedHistory {
-		label := timestampStyle.Render(record.SavedAt.Format("2006-01-02 15:04:05"))
-		label += fmt.Sprintf(" (v%d)", len(sortedHistory)-i)
+		label := []string{
+			timestampStyle.Render(record.SavedAt.Format("2006-01-02 15:04:05")),
+			fmt.Sprintf("(v%d)", len(sortedHistory)-i),
+		}
+		if len(record.Hash) >= 12 {
+			// The start of the version's checksum, to tell versions apart in an audit
+			label = append(label, hashStyle.Render(record.Hash[:12]))
+		}
+		if record.Label != "" {
+			label = append(label, snapshotLabelStyle.Render("\""+record.Label+"\""))
+		}
+		if len(record.Tags) > 0 {
+			label = append(label, tagStyle.Render("#"+strings.Join(record.Tags, " #")))
+		}
 		files := "(none)"
 		if len(record.Attachments) > 0 {
 			files = strings.Join(record.Attachments, ", ")
@@ -161,22 +597,37 @@ func (m HistoryModel) View() string {
 	}
 
 	for _, item := range items[m.offset:end] {
+		labelWidth := 0
+		if compact {
+			labelWidth = m.width - 2
+		}
+		cursor := "  "
+		if m.selectedIndex == item.index {
+			cursor = "> "
+		}
+		label := joinWrapped(append([]string{cursor + item.label[0]}, item.label[1:]...), " ", labelWidth, "  ")
 		if m.selectedIndex == item.index {
-			b.WriteString(selectedStyle.Render("> " + item.label))
+			b.WriteString(selectedStyle.Render(label))
 		} else {
-			b.WriteString(itemStyle.Render("  " + item.label))
+			b.WriteString(itemStyle.Render(label))
 		}
 		b.WriteString("\n")
 
 		if m.selectedIndex == item.index && m.expanded {
 			b.WriteString(expandedContentStyle.Render(item.content))
+		} else if compact {
+			b.WriteString(contentStyle.Render(fitWidth(truncate(item.content, 100), m.width-4)))
 		} else {
 			b.WriteString(contentStyle.Render(truncate(item.content, 100)))
 		}
 		b.WriteString("\n")
 
 		b.WriteString(fileLabelStyle.Render("Files: "))
-		b.WriteString(fileStyle.Render(item.attachments))
+		if compact {
+			b.WriteString(fileStyle.Render(fitWidth(item.attachments, m.width-11)))
+		} else {
+			b.WriteString(fileStyle.Render(item.attachments))
+		}
 		b.WriteString("\n\n")
 	}
 
@@ -187,14 +638,61 @@ func (m HistoryModel) View() string {
 		b.WriteString("\n")
 	}
 
-	b.WriteString(dividerStyle.Render(strings.Repeat("-", 60)))
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", dividerWidth)))
 	b.WriteString("\n")
 
+	if m.labelMode != labelNone {
+		prompt := "Label for this version:"
+		if m.labelMode == labelSnapshot {
+			prompt = "Name for the new snapshot:"
+		}
+		b.WriteString(prompt + "\n\n  ")
+		b.WriteString(m.labelInput.View())
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render(keyStyle.Render("Enter") + " save | " + keyStyle.Render("Esc") + " cancel"))
+		return b.String()
+	}
+
+	if m.restoring {
+		record := m.selectedRecord()
+		b.WriteString("Restore the version of " + record.SavedAt.Format("2006-01-02 15:04:05") + " as the current content?\n")
+		b.WriteString("The current content is kept in history first.\n\n")
+		if len(m.restorable) == 0 {
+			b.WriteString(helpStyle.Render("Press " + keyStyle.Render("y") + " to restore, " + keyStyle.Render("n") + " or " + keyStyle.Render("Esc") + " to cancel"))
+			return b.String()
+		}
+		names := make([]string, len(m.restorable))
+		for i, att := range m.restorable {
+			names[i] = att.Filename
+		}
+		b.WriteString(fmt.Sprintf("It had %d attachments deleted since that can be restored too: %s\n\n", len(m.restorable), strings.Join(names, ", ")))
+		b.WriteString(helpStyle.Render("Press " + keyStyle.Render("y") + " to restore with them, " + keyStyle.Render("c") + " for the content only, " + keyStyle.Render("n") + " or " + keyStyle.Render("Esc") + " to cancel"))
+		return b.String()
+	}
+
+	if m.Error != "" {
+		b.WriteString(errorStyle.Render("Error: " + m.Error))
+		b.WriteString("\n")
+	}
+	if m.Message != "" {
+		b.WriteString(successStyle.Render(m.Message))
+		b.WriteString("\n")
+	}
+
 	var parts []string
 	parts = append(parts, keyStyle.Render("Up/Down")+" navigate")
 	parts = append(parts, keyStyle.Render("Enter")+" expand/collapse")
+	if m.selectedIndex > 0 {
+		parts = append(parts, keyStyle.Render("d")+" diff")
+	}
+	if m.selectedIndex > 0 && m.locked == "" {
+		parts = append(parts, keyStyle.Render("r")+" restore")
+	}
+	parts = append(parts, keyStyle.Render("l")+" label")
+	parts = append(parts, keyStyle.Render("s")+" snapshot")
+	parts = append(parts, keyStyle.Render("f")+" file timeline")
 	parts = append(parts, keyStyle.Render("Esc/q")+" back")
-	b.WriteString(helpStyle.Render(strings.Join(parts, " | ")))
+	b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
 
 	return b.String()
 }
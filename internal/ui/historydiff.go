@@ -0,0 +1,236 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"journal/internal/diff"
+	"journal/internal/theme"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// diffGutter separates the two columns of the side-by-side diff
+const diffGutter = " | "
+
+// diffLines returns the screen lines comparing the selected version with
+// the current content: side by side in two columns, or one after the other
+// on a compact screen
+func (m HistoryModel) diffLines() []string {
+	record := m.selectedRecord()
+	if record == nil {
+		return nil
+	}
+	t := theme.Current()
+	styles := map[diff.Op]lipgloss.Style{
+		diff.Equal:  lipgloss.NewStyle().Foreground(t.Text),
+		diff.Delete: lipgloss.NewStyle().Foreground(t.Error).Bold(true),
+		diff.Insert: lipgloss.NewStyle().Foreground(t.Success).Bold(true),
+	}
+	removedSign := styles[diff.Delete].Render("-")
+	addedSign := styles[diff.Insert].Render("+")
+
+	rows := diff.Rows(record.Content, m.entry.Content)
+	var lines []string
+	if isCompact(m.width) {
+		width := max(m.width-2, 10)
+		for _, row := range rows {
+			if !row.Changed() {
+				for _, line := range wrapSpans(row.New, width, styles) {
+					lines = append(lines, "  "+line)
+				}
+				continue
+			}
+			if row.InOld {
+				for _, line := range wrapSpans(row.Old, width, styles) {
+					lines = append(lines, removedSign+" "+line)
+				}
+			}
+			if row.InNew {
+				for _, line := range wrapSpans(row.New, width, styles) {
+					lines = append(lines, addedSign+" "+line)
+				}
+			}
+		}
+		return lines
+	}
+
+	column := (m.width - len(diffGutter)) / 2
+	for _, row := range rows {
+		changed := row.Changed()
+		var left, right []string
+		if row.InOld {
+			left = signLines(wrapSpans(row.Old, column-2, styles), changed, removedSign)
+		}
+		if row.InNew {
+			right = signLines(wrapSpans(row.New, column-2, styles), changed, addedSign)
+		}
+		for i := 0; i < max(len(left), len(right)); i++ {
+			var l, r string
+			if i < len(left) {
+				l = left[i]
+			}
+			if i < len(right) {
+				r = right[i]
+			}
+			lines = append(lines, padWidth(l, column)+diffGutter+r)
+		}
+	}
+	return lines
+}
+
+// signLines puts sign before each line of a changed row, or a space before
+// those of an unchanged one
+func signLines(lines []string, changed bool, sign string) []string {
+	if !changed {
+		sign = " "
+	}
+	for i, line := range lines {
+		lines[i] = sign + " " + line
+	}
+	return lines
+}
+
+// wrapSpans renders spans in their styles, wrapped between words to width.
+// A line with no text still takes one screen line.
+func wrapSpans(spans []diff.Span, width int, styles map[diff.Op]lipgloss.Style) []string {
+	width = max(width, 1)
+	var lines []string
+	var line strings.Builder
+	lineWidth := 0
+	flush := func() {
+		lines = append(lines, line.String())
+		line.Reset()
+		lineWidth = 0
+	}
+	for _, span := range spans {
+		style := styles[span.Op]
+		for _, token := range diff.Tokens(strings.ReplaceAll(span.Text, "\t", "    ")) {
+			tokenWidth := ansi.StringWidth(token)
+			if lineWidth > 0 && lineWidth+tokenWidth > width {
+				flush()
+				if strings.TrimSpace(token) == "" {
+					// Spaces at the wrap are dropped, unless they were removed or added
+					if span.Op == diff.Equal {
+						continue
+					}
+				}
+			}
+			// A word longer than the line is broken up
+			for tokenWidth > width {
+				part := ansi.Truncate(token, width, "")
+				if part == "" {
+					_, size := utf8.DecodeRuneInString(token)
+					part = token[:size]
+				}
+				line.WriteString(style.Render(part))
+				flush()
+				token = token[len(part):]
+				tokenWidth = ansi.StringWidth(token)
+			}
+			if token != "" {
+				line.WriteString(style.Render(token))
+				lineWidth += tokenWidth
+			}
+		}
+	}
+	if lineWidth > 0 || len(lines) == 0 {
+		flush()
+	}
+	return lines
+}
+
+// padWidth pads s with spaces to width screen columns
+func padWidth(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-ansi.StringWidth(s), 0))
+}
+
+// diffRows returns how many lines of the diff fit on the screen
+func (m HistoryModel) diffRows() int {
+	return max(m.height-12, 3)
+}
+
+func (m HistoryModel) viewDiff() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	dateStyle := lipgloss.NewStyle().Foreground(t.Info).Bold(true)
+	addedStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	removedStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	columnStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	emptyStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true).PaddingLeft(2)
+	scrollStyle := lipgloss.NewStyle().Foreground(t.Muted).Italic(true)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	dividerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+
+	// The columns take the whole width, and so does the divider
+	dividerWidth := m.width
+
+	record := m.selectedRecord()
+	version := len(m.entry.History) - m.selectedIndex + 1
+	saved := record.SavedAt.Local().Format("2006-01-02 15:04:05")
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Version Diff"))
+	b.WriteString("\n\n")
+	b.WriteString(dateStyle.Render("Entry: " + m.entry.Date))
+	b.WriteString("\n")
+
+	rows := diff.Rows(record.Content, m.entry.Content)
+	removed, added := 0, 0
+	for _, row := range rows {
+		if !row.Changed() {
+			continue
+		}
+		if row.InOld {
+			removed++
+		}
+		if row.InNew {
+			added++
+		}
+	}
+	counts := addedStyle.Render(fmt.Sprintf("+%d", added)) + " " + removedStyle.Render(fmt.Sprintf("-%d", removed)) + " lines"
+	b.WriteString(joinWrapped([]string{fmt.Sprintf("From v%d (%s) to the current content:", version, saved), counts}, " ", m.width, ""))
+	b.WriteString("\n")
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", dividerWidth)))
+	b.WriteString("\n")
+
+	if !isCompact(m.width) {
+		column := (m.width - len(diffGutter)) / 2
+		b.WriteString(padWidth(columnStyle.Render(fitWidth(fmt.Sprintf("v%d  %s", version, saved), column)), column) + diffGutter + columnStyle.Render("Current"))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	lines := m.diffLines()
+	if removed == 0 && added == 0 {
+		b.WriteString(emptyStyle.Render("This version is the same as the current content"))
+		b.WriteString("\n")
+	} else {
+		top := min(m.diffTop, max(len(lines)-m.diffRows(), 0))
+		end := min(top+m.diffRows(), len(lines))
+		for _, line := range lines[top:end] {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+		if len(lines) > m.diffRows() {
+			b.WriteString(scrollStyle.Render(fmt.Sprintf("  (%d-%d of %d)", top+1, end, len(lines))))
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n")
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", dividerWidth)))
+	b.WriteString("\n")
+	parts := []string{
+		keyStyle.Render("Up/Down") + " scroll",
+		keyStyle.Render("PgUp/PgDn") + " page",
+		keyStyle.Render("d/Esc") + " versions",
+	}
+	b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
+	return b.String()
+}
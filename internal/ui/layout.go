@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Below compactWidth columns the list, history, and selector switch to
+// compact layouts that stack what doesn't fit on one line, rather than
+// leaving the frame to wrap lines in the middle of a badge. Below the
+// minimum size no view fits, and the app asks for a larger window.
+const (
+	compactWidth    = 80
+	minWindowWidth  = 40
+	minWindowHeight = 12
+)
+
+// isCompact reports whether a view sized to width inside the frame is
+// drawn in its compact layout. Views not yet sized aren't compact.
+func isCompact(width int) bool {
+	return width > 0 && width+frameWidth < compactWidth
+}
+
+// joinWrapped joins pieces with sep, starting a new line in place of sep
+// rather than splitting a piece when a line would be wider than width.
+// Lines after the first are indented by indent. A width of 0 doesn't wrap.
+func joinWrapped(pieces []string, sep string, width int, indent string) string {
+	var b strings.Builder
+	lineWidth := 0
+	for i, piece := range pieces {
+		w := ansi.StringWidth(piece)
+		switch {
+		case i == 0:
+		case width > 0 && lineWidth+ansi.StringWidth(sep)+w > width:
+			b.WriteString("\n" + indent)
+			lineWidth = ansi.StringWidth(indent)
+		default:
+			b.WriteString(sep)
+			lineWidth += ansi.StringWidth(sep)
+		}
+		b.WriteString(piece)
+		lineWidth += w
+	}
+	return b.String()
+}
+
+// fitWidth cuts s to width columns with an ellipsis; a width of 0 leaves
+// it as it is
+func fitWidth(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	return ansi.Truncate(s, width, "…")
+}
@@ -2,12 +2,17 @@ package ui
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"journal/internal/dates"
 	"journal/internal/model"
 	"journal/internal/theme"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -18,30 +23,88 @@ const (
 	ActionNone ListAction = iota
 	ActionNewEntry
 	ActionEditEntry
+	ActionRead
 	ActionDeleteEntry
+	ActionDuplicateEntry
 	ActionSettings
 	ActionViewHistory
 	ActionViewAttachments
+	ActionWordReport
+	ActionTasks
+	ActionTags
+	ActionCalendar
+	ActionLoops
+	ActionStats
+	ActionGoals
+	ActionHighlight
+	ActionShare
+	ActionSearch
+	ActionExportEntries
 	ActionQuit
 )
 
 type ListModel struct {
-	journal       *model.Journal
-	SelectedIndex int
-	Action        ListAction
-	width         int
-	height        int
-	offset        int
+	entries        *entryPager
+	SelectedIndex  int
+	Action         ListAction
+	width          int
+	height         int
+	offset         int
+	filterInput    textinput.Model
+	filtering      bool // Typing a filter
+	filterError    string
+	quickFilters   bool // Toggling quick filters
+	quickWords     int  // Word count of the quick "more than N words" filter
+	dateInput      textinput.Model
+	duplicating    bool   // Typing the date to duplicate the selected entry to
+	DuplicateDate  string // Date chosen for the copy, YYYY-MM-DD
+	highlightInput textinput.Model
+	highlighting   bool            // Typing a highlight for today's entry
+	Highlight      string          // Highlight to add to today's entry
+	previewLen     int             // Characters of each entry shown
+	titles         bool            // Show each entry's title rather than its opening text
+	badges         []string        // Badges shown after each entry, in order
+	Warning        string          // Shown until the next key, such as a likely duplicate entry
+	Message        string          // Shown until the next key, such as what was saved
+	marked         map[string]bool // IDs of the entries marked for export
+	lock           *entryLock      // Which entries are locked against edits
 }
 
-func NewListModel(journal *model.Journal) ListModel {
+func NewListModel(entries *entryPager, previewLen int, titles bool, badges []string, lock *entryLock) ListModel {
+	fi := textinput.New()
+	fi.Placeholder = "words tag:work mood:🙂 since:2024-01-01 has:attachments words:500"
+	fi.CharLimit = 200
+	fi.Width = 60
+
+	di := textinput.New()
+	di.Placeholder = "YYYY-MM-DD, or e.g. next monday"
+	di.CharLimit = 32
+	di.Width = 32
+
+	hi := textinput.New()
+	hi.Placeholder = "something good about today"
+	hi.CharLimit = 500
+	hi.Width = 60
+
 	return ListModel{
-		journal:       journal,
-		SelectedIndex: 0,
-		Action:        ActionNone,
+		entries:        entries,
+		highlightInput: hi,
+		SelectedIndex:  0,
+		Action:         ActionNone,
+		filterInput:    fi,
+		dateInput:      di,
+		previewLen:     previewLen,
+		titles:         titles,
+		badges:         badges,
+		quickWords:     quickWordChoices[2],
+		marked:         make(map[string]bool),
+		lock:           lock,
 	}
 }
 
+// quickWordChoices are the word counts the quick filter cycles through
+var quickWordChoices = []int{100, 250, 500, 1000, 2000}
+
 func (m *ListModel) SetSize(width, height int) {
 	m.width = width
 	m.height = height
@@ -52,18 +115,306 @@ func (m ListModel) Init() tea.Cmd {
 }
 
 func (m ListModel) hasTodayEntry() bool {
-	today := time.Now().Format("2006-01-02")
-	for _, e := range m.journal.Entries {
-		if e.Date == today {
-			return true
+	return m.entries.hasToday
+}
+
+// Selected returns the summary of the selected entry
+func (m ListModel) Selected() (model.EntrySummary, bool) {
+	return m.entries.At(m.SelectedIndex)
+}
+
+// Reload re-reads the entries from storage after they changed, keeping the
+// selection in range
+func (m *ListModel) Reload() error {
+	if err := m.entries.reload(); err != nil {
+		return err
+	}
+	if m.SelectedIndex >= m.entries.Len() {
+		m.SelectedIndex = m.entries.Len() - 1
+	}
+	if m.SelectedIndex < 0 {
+		m.SelectedIndex = 0
+	}
+	m.adjustScroll()
+	return nil
+}
+
+// CheckExternalChanges looks for entries added or changed outside the app
+// since the last check, marking them and reloading the list if there are
+func (m *ListModel) CheckExternalChanges() error {
+	found, err := m.entries.external.check()
+	if err != nil || found == 0 {
+		return err
+	}
+	if found == 1 {
+		m.Warning = "1 entry was updated outside the app"
+	} else {
+		m.Warning = fmt.Sprintf("%d entries were updated outside the app", found)
+	}
+	return m.Reload()
+}
+
+// parseEntryFilter parses space-separated key:value terms. Keys are tag,
+// mood, since, until, has, and words; dates are YYYY-MM-DD or a one-word
+// date such as "yesterday" or "friday", relative to today. has is
+// attachments or edits, and words:N matches entries of more than N words.
+// Other words must occur in the content.
+func parseEntryFilter(raw string, today time.Time) (model.EntryFilter, error) {
+	var filter model.EntryFilter
+	var words []string
+	for _, term := range strings.Fields(raw) {
+		key, value, ok := strings.Cut(term, ":")
+		if !ok {
+			words = append(words, term)
+			continue
+		}
+		if value == "" {
+			return filter, fmt.Errorf("missing value for %q", key)
+		}
+		switch strings.ToLower(key) {
+		case "tag":
+			filter.Tag = value
+		case "mood":
+			filter.Mood = value
+		case "since", "until":
+			date, err := dates.Parse(value, today)
+			if err != nil {
+				return filter, fmt.Errorf("invalid date %q, use YYYY-MM-DD", value)
+			}
+			value = date
+			if strings.ToLower(key) == "since" {
+				filter.Since = value
+			} else {
+				filter.Until = value
+			}
+		case "has":
+			switch strings.ToLower(value) {
+			case "attachments":
+				filter.Attachments = true
+			case "edits":
+				filter.Edited = true
+			default:
+				return filter, fmt.Errorf("has:%s, use has:attachments or has:edits", value)
+			}
+		case "words":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return filter, fmt.Errorf("invalid word count %q", value)
+			}
+			filter.WordsOver = n
+		default:
+			return filter, fmt.Errorf("unknown filter %q", key)
+		}
+	}
+	filter.Text = strings.Join(words, " ")
+	return filter, nil
+}
+
+// formatEntryFilter is the inverse of parseEntryFilter
+func formatEntryFilter(filter model.EntryFilter) string {
+	var terms []string
+	if filter.Tag != "" {
+		terms = append(terms, "tag:"+filter.Tag)
+	}
+	if filter.Mood != "" {
+		terms = append(terms, "mood:"+filter.Mood)
+	}
+	if filter.Since != "" {
+		terms = append(terms, "since:"+filter.Since)
+	}
+	if filter.Until != "" {
+		terms = append(terms, "until:"+filter.Until)
+	}
+	if filter.Attachments {
+		terms = append(terms, "has:attachments")
+	}
+	if filter.Edited {
+		terms = append(terms, "has:edits")
+	}
+	if filter.WordsOver > 0 {
+		terms = append(terms, "words:"+strconv.Itoa(filter.WordsOver))
+	}
+	if filter.Text != "" {
+		terms = append(terms, filter.Text)
+	}
+	return strings.Join(terms, " ")
+}
+
+// applyFilter shows only the entries matching filter, keeping the selected
+// entry selected when it still matches
+func (m *ListModel) applyFilter(filter model.EntryFilter) {
+	selected, hadSelection := m.Selected()
+	if err := m.entries.setFilter(filter); err != nil {
+		m.filterError = err.Error()
+		return
+	}
+	m.filterError = ""
+	m.SelectedIndex = 0
+	m.offset = 0
+	if hadSelection {
+		m.SelectEntry(selected.ID)
+	}
+}
+
+// FilterTag shows only the entries with tag, keeping the rest of the filter
+func (m *ListModel) FilterTag(tag string) {
+	filter := m.entries.filter
+	filter.Tag = tag
+	m.applyFilter(filter)
+}
+
+// updateQuickFilters toggles the quick filters, applying each change
+// straight away
+func (m ListModel) updateQuickFilters(msg tea.Msg) (ListModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	filter := m.entries.filter
+	switch keyMsg.String() {
+	case "1":
+		filter.Attachments = !filter.Attachments
+	case "2":
+		if filter.WordsOver > 0 {
+			filter.WordsOver = 0
+		} else {
+			filter.WordsOver = m.quickWords
 		}
+	case "3":
+		filter.Edited = !filter.Edited
+	case "+", "=", "-":
+		// Counts typed as words:N step to the nearest choice
+		i, found := slices.BinarySearch(quickWordChoices, m.quickWords)
+		if keyMsg.String() == "-" {
+			i = max(i-1, 0)
+		} else if found {
+			i = min(i+1, len(quickWordChoices)-1)
+		} else {
+			i = min(i, len(quickWordChoices)-1)
+		}
+		m.quickWords = quickWordChoices[i]
+		if filter.WordsOver > 0 {
+			filter.WordsOver = m.quickWords
+		}
+	case "enter", "esc", "F":
+		m.quickFilters = false
+		return m, nil
+	}
+	if filter != m.entries.filter {
+		m.applyFilter(filter)
 	}
-	return false
+	return m, nil
+}
+
+func (m ListModel) updateFilterInput(msg tea.Msg) (ListModel, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			filter, err := parseEntryFilter(m.filterInput.Value(), m.entries.clock.Now())
+			if err != nil {
+				m.filterError = err.Error()
+				return m, nil
+			}
+			m.filtering = false
+			m.filterInput.Blur()
+			m.applyFilter(filter)
+			return m, nil
+		case "esc":
+			m.filtering = false
+			m.filterError = ""
+			m.filterInput.Blur()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.filterInput, cmd = m.filterInput.Update(msg)
+	return m, cmd
+}
+
+// updateDuplicateInput handles the date prompt for duplicating the
+// selected entry
+func (m ListModel) updateDuplicateInput(msg tea.Msg) (ListModel, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			date, err := dates.Parse(m.dateInput.Value(), m.entries.clock.Now())
+			if err != nil {
+				m.filterError = err.Error()
+				return m, nil
+			}
+			existingID, err := m.entries.store.FindEntryByDate(date)
+			if err != nil {
+				m.filterError = err.Error()
+				return m, nil
+			}
+			if existingID != "" {
+				m.filterError = "An entry for " + date + " already exists"
+				return m, nil
+			}
+			m.duplicating = false
+			m.filterError = ""
+			m.dateInput.Blur()
+			m.DuplicateDate = date
+			m.Action = ActionDuplicateEntry
+			return m, nil
+		case "esc":
+			m.duplicating = false
+			m.filterError = ""
+			m.dateInput.Blur()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.dateInput, cmd = m.dateInput.Update(msg)
+	return m, cmd
+}
+
+// updateHighlightInput handles the prompt for a highlight to add to
+// today's entry
+func (m ListModel) updateHighlightInput(msg tea.Msg) (ListModel, tea.Cmd) {
+	if keyMsg, ok := msg.(tea.KeyMsg); ok {
+		switch keyMsg.String() {
+		case "enter":
+			if strings.TrimSpace(m.highlightInput.Value()) == "" {
+				return m, nil
+			}
+			m.highlighting = false
+			m.highlightInput.Blur()
+			m.Highlight = m.highlightInput.Value()
+			m.Action = ActionHighlight
+			return m, nil
+		case "esc":
+			m.highlighting = false
+			m.highlightInput.Blur()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.highlightInput, cmd = m.highlightInput.Update(msg)
+	return m, cmd
 }
 
 func (m ListModel) Update(msg tea.Msg) (ListModel, tea.Cmd) {
+	if m.filtering {
+		return m.updateFilterInput(msg)
+	}
+	if m.duplicating {
+		return m.updateDuplicateInput(msg)
+	}
+	if m.highlighting {
+		return m.updateHighlightInput(msg)
+	}
+	if m.quickFilters {
+		return m.updateQuickFilters(msg)
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.Warning = ""
+		m.Message = ""
 		switch msg.String() {
 		case "up", "k":
 			if m.SelectedIndex > 0 {
@@ -71,30 +422,124 @@ func (m ListModel) Update(msg tea.Msg) (ListModel, tea.Cmd) {
 				m.adjustScroll()
 			}
 		case "down", "j":
-			if m.SelectedIndex < len(m.journal.Entries)-1 {
+			if m.SelectedIndex < m.entries.Len()-1 {
 				m.SelectedIndex++
 				m.adjustScroll()
 			}
 		case "enter":
-			if len(m.journal.Entries) > 0 {
-				m.Action = ActionEditEntry
+			if entry, ok := m.Selected(); ok {
+				if m.lock.Locked(entry.ID, entry.Date) {
+					m.Warning = m.lock.Reason(entry.Date)
+				} else {
+					m.entries.external.viewed(entry.ID)
+					m.Action = ActionEditEntry
+				}
 			}
 		case "n":
 			if !m.hasTodayEntry() {
 				m.Action = ActionNewEntry
 			}
 		case "d":
-			if len(m.journal.Entries) > 0 {
-				m.Action = ActionDeleteEntry
+			if entry, ok := m.Selected(); ok {
+				if m.lock.Locked(entry.ID, entry.Date) {
+					m.Warning = m.lock.Reason(entry.Date)
+				} else {
+					m.Action = ActionDeleteEntry
+				}
+			}
+		case "U":
+			if entry, ok := m.Selected(); ok {
+				m.Warning = m.lock.Toggle(entry.ID, entry.Date)
+			}
+		case "c":
+			if m.entries.Len() > 0 {
+				m.duplicating = true
+				m.filterError = ""
+				m.dateInput.SetValue(m.entries.clock.Now().Format(dates.Layout))
+				m.dateInput.CursorEnd()
+				m.dateInput.Focus()
+				return m, textinput.Blink
+			}
+		case "r":
+			if m.entries.Len() > 0 {
+				m.Action = ActionRead
 			}
 		case "h":
-			if len(m.journal.Entries) > 0 {
+			if m.entries.Len() > 0 {
 				m.Action = ActionViewHistory
 			}
 		case "a":
-			if len(m.journal.Entries) > 0 {
+			if m.entries.Len() > 0 {
 				m.Action = ActionViewAttachments
 			}
+		case "/":
+			if m.entries.Len() > 0 {
+				m.Action = ActionSearch
+			}
+		case "w":
+			if m.entries.Len() > 0 {
+				m.Action = ActionWordReport
+			}
+		case " ":
+			if entry, ok := m.Selected(); ok {
+				if m.marked[entry.ID] {
+					delete(m.marked, entry.ID)
+				} else {
+					m.marked[entry.ID] = true
+				}
+				if m.SelectedIndex < m.entries.Len()-1 {
+					m.SelectedIndex++
+					m.adjustScroll()
+				}
+			}
+		case "x":
+			if m.entries.Len() > 0 {
+				m.Action = ActionExportEntries
+			}
+		case "P":
+			if m.entries.Len() > 0 {
+				m.Action = ActionShare
+			}
+		case "t":
+			m.jumpToToday()
+		case "T":
+			m.Action = ActionTasks
+		case "#":
+			m.Action = ActionTags
+		case "C":
+			m.Action = ActionCalendar
+		case "L":
+			m.Action = ActionLoops
+		case "S":
+			if m.entries.Len() > 0 {
+				m.Action = ActionStats
+			}
+		case "G":
+			m.Action = ActionGoals
+		case "H":
+			m.highlighting = true
+			m.highlightInput.SetValue("")
+			m.highlightInput.Focus()
+			return m, textinput.Blink
+		case "f":
+			m.filtering = true
+			m.filterError = ""
+			m.filterInput.SetValue(formatEntryFilter(m.entries.filter))
+			m.filterInput.CursorEnd()
+			m.filterInput.Focus()
+			return m, textinput.Blink
+		case "F":
+			m.quickFilters = true
+			m.filterError = ""
+			if m.entries.filter.WordsOver > 0 {
+				m.quickWords = m.entries.filter.WordsOver
+			}
+		case "esc":
+			if len(m.marked) > 0 {
+				clear(m.marked)
+			} else if !m.entries.filter.IsZero() {
+				m.applyFilter(model.EntryFilter{})
+			}
 		case "s":
 			m.Action = ActionSettings
 		case "q":
@@ -105,11 +550,60 @@ func (m ListModel) Update(msg tea.Msg) (ListModel, tea.Cmd) {
 	return m, nil
 }
 
-func (m *ListModel) adjustScroll() {
-	visibleLines := m.height - 8
-	if visibleLines < 1 {
-		visibleLines = 10
+// MarkedIDs returns the IDs of the entries marked for export, or else the
+// selected entry's
+func (m ListModel) MarkedIDs() []string {
+	if len(m.marked) == 0 {
+		if entry, ok := m.Selected(); ok {
+			return []string{entry.ID}
+		}
+		return nil
 	}
+	return slices.Collect(maps.Keys(m.marked))
+}
+
+// Unmark unmarks an entry, such as one that was deleted
+func (m *ListModel) Unmark(id string) {
+	delete(m.marked, id)
+}
+
+// SelectEntry moves the selection to the entry with the given ID
+func (m *ListModel) SelectEntry(id string) {
+	if i := m.entries.IndexOf(id); i >= 0 {
+		m.SelectedIndex = i
+		m.adjustScroll()
+	}
+}
+
+// jumpToToday selects today's entry, clearing a filter that hides it, or
+// starts today's entry when there isn't one yet
+func (m *ListModel) jumpToToday() {
+	todayID := m.entries.todayID
+	if todayID == "" {
+		m.Action = ActionNewEntry
+		return
+	}
+	if m.entries.IndexOf(todayID) < 0 {
+		m.applyFilter(model.EntryFilter{})
+	}
+	m.SelectEntry(todayID)
+}
+
+// visibleEntries returns how many entries fit on the screen, two lines
+// each in the compact layout
+func (m ListModel) visibleEntries() int {
+	lines := m.height - 8
+	if lines < 1 {
+		return 10
+	}
+	if isCompact(m.width) {
+		return max(lines/2, 1)
+	}
+	return lines
+}
+
+func (m *ListModel) adjustScroll() {
+	visibleLines := m.visibleEntries()
 
 	if m.SelectedIndex < m.offset {
 		m.offset = m.SelectedIndex
@@ -134,60 +628,199 @@ func (m ListModel) View() string {
 	scrollStyle := lipgloss.NewStyle().Foreground(t.Muted).Italic(true)
 	badgeStyle := lipgloss.NewStyle().Foreground(t.Warning).Bold(true)
 	attachBadgeStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	wordsBadgeStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	externalBadgeStyle := lipgloss.NewStyle().Foreground(t.Info).Bold(true)
+	lockedBadgeStyle := lipgloss.NewStyle().Foreground(t.Muted).Italic(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	filterStyle := lipgloss.NewStyle().Foreground(t.Info)
 
 	b.WriteString("\n")
 	b.WriteString(titleStyle.Render("Journal Entries"))
+	if !m.entries.filter.IsZero() {
+		b.WriteString(filterStyle.Render("  [" + formatEntryFilter(m.entries.filter) + "]"))
+	}
+	if len(m.marked) > 0 {
+		b.WriteString(badgeStyle.Render(fmt.Sprintf("  %d marked", len(m.marked))))
+	}
 	b.WriteString("\n\n")
 
-	if len(m.journal.Entries) == 0 {
+	if m.filtering {
+		b.WriteString(keyStyle.Render("Filter: "))
+		b.WriteString(m.filterInput.View())
+		b.WriteString("\n\n")
+	}
+	if m.quickFilters {
+		toggle := func(key string, on bool, label string) string {
+			box := "☐"
+			if on {
+				box = "☑"
+			}
+			return keyStyle.Render(key) + " " + box + " " + label
+		}
+		filter := m.entries.filter
+		b.WriteString(keyStyle.Render("Quick filters: "))
+		b.WriteString(strings.Join([]string{
+			toggle("1", filter.Attachments, "with attachments"),
+			toggle("2", filter.WordsOver > 0, fmt.Sprintf("over %d words", m.quickWords)),
+			toggle("3", filter.Edited, "edited more than once"),
+		}, "   "))
+		b.WriteString("\n\n")
+	}
+	if m.duplicating {
+		b.WriteString(keyStyle.Render("Duplicate to: "))
+		b.WriteString(m.dateInput.View())
+		b.WriteString("\n\n")
+	}
+	if m.highlighting {
+		b.WriteString(keyStyle.Render("Highlight: "))
+		b.WriteString(m.highlightInput.View())
+		b.WriteString("\n\n")
+	}
+
+	if m.entries.Len() == 0 && !m.entries.filter.IsZero() {
+		b.WriteString(emptyStyle.Render("No entries match the filter. Press Esc to clear it."))
+		b.WriteString("\n")
+	} else if m.entries.Len() == 0 {
 		b.WriteString(emptyStyle.Render("No entries yet. Press 'n' to create one."))
 		b.WriteString("\n")
 	} else {
-		visibleLines := m.height - 8
-		if visibleLines < 1 {
-			visibleLines = 10
-		}
+		visibleLines := m.visibleEntries()
+		compact := isCompact(m.width)
 
 		end := m.offset + visibleLines
-		if end > len(m.journal.Entries) {
-			end = len(m.journal.Entries)
+		if end > m.entries.Len() {
+			end = m.entries.Len()
 		}
 
 		for i := m.offset; i < end; i++ {
-			entry := m.journal.Entries[i]
+			entry, ok := m.entries.At(i)
+			if !ok {
+				break
+			}
 			date := dateStyle.Render("[" + entry.Date + "]")
-			preview := previewStyle.Render(entry.Preview(40))
+			text := entry.Preview(m.previewLen)
+			if m.titles {
+				text = entry.Title(m.previewLen)
+			}
+
+			var badges []string
+			for _, name := range m.badges {
+				switch name {
+				case "mood":
+					if entry.Mood != "" {
+						badges = append(badges, entry.Mood)
+					}
+				case "saves":
+					if entry.HistoryCount > 0 {
+						badges = append(badges, badgeStyle.Render(fmt.Sprintf("[%d saves]", entry.HistoryCount+1)))
+					}
+				case "files":
+					if entry.AttachmentCount > 0 {
+						badges = append(badges, attachBadgeStyle.Render(fmt.Sprintf("[%d files]", entry.AttachmentCount)))
+					}
+				case "words":
+					badges = append(badges, wordsBadgeStyle.Render(fmt.Sprintf("[%d words]", entry.WordCount)))
+				case "tags":
+					for _, tag := range entry.Tags {
+						badges = append(badges, filterStyle.Render("#"+tag))
+					}
+				}
+			}
 
-			badges := ""
-			if len(entry.History) > 0 {
-				badges += badgeStyle.Render(fmt.Sprintf(" [%d saves]", len(entry.History)+1))
+			if m.entries.external.Changed(entry.ID) {
+				badges = append(badges, externalBadgeStyle.Render("[updated externally]"))
 			}
-			if len(entry.Attachments) > 0 {
-				badges += attachBadgeStyle.Render(fmt.Sprintf(" [%d files]", len(entry.Attachments)))
+			if m.lock.Locked(entry.ID, entry.Date) {
+				badges = append(badges, lockedBadgeStyle.Render("[locked]"))
 			}
 
-			line := fmt.Sprintf("%s %s%s", date, preview, badges)
+			cursor := "  "
+			if i == m.SelectedIndex {
+				cursor = "> "
+			}
+			if len(m.marked) > 0 {
+				if m.marked[entry.ID] {
+					cursor += "✓ "
+				} else {
+					cursor += "  "
+				}
+			}
+
+			// Badges that don't fit wrap between badges, under the date
+			indent := strings.Repeat(" ", len(cursor))
+			var line string
+			if compact {
+				// The date and badges over the preview
+				line = joinWrapped(append([]string{cursor + date}, badges...), " ", m.width-2, indent)
+				line += "\n" + indent + previewStyle.Render(fitWidth(text, m.width-2-len(indent)))
+			} else {
+				line = joinWrapped(append([]string{cursor + date + " " + previewStyle.Render(text)}, badges...), " ", m.width-2, indent)
+			}
 
 			if i == m.SelectedIndex {
-				b.WriteString(selectedStyle.Render("> " + line))
+				b.WriteString(selectedStyle.Render(line))
 			} else {
-				b.WriteString(itemStyle.Render("  " + line))
+				b.WriteString(itemStyle.Render(line))
 			}
 			b.WriteString("\n")
 		}
 
-		if len(m.journal.Entries) > visibleLines {
-			scrollInfo := fmt.Sprintf("(%d-%d of %d)", m.offset+1, end, len(m.journal.Entries))
+		if m.entries.Len() > visibleLines {
+			scrollInfo := fmt.Sprintf("(%d-%d of %d)", m.offset+1, end, m.entries.Len())
 			b.WriteString(scrollStyle.Render("  " + scrollInfo))
 			b.WriteString("\n")
 		}
 	}
 
+	if m.entries.err != nil {
+		b.WriteString(errorStyle.Render("  Error loading entries: " + m.entries.err.Error()))
+		b.WriteString("\n")
+	}
+	if m.filterError != "" {
+		b.WriteString(errorStyle.Render("  " + m.filterError))
+		b.WriteString("\n")
+	}
+	if m.Warning != "" {
+		b.WriteString(badgeStyle.Render("  " + m.Warning))
+		b.WriteString("\n")
+	}
+	if m.Message != "" {
+		b.WriteString(attachBadgeStyle.Render("  " + m.Message))
+		b.WriteString("\n")
+	}
+
 	b.WriteString("\n")
 
 	var parts []string
+	if m.filtering {
+		parts = append(parts, keyStyle.Render("Enter")+" apply filter")
+		parts = append(parts, keyStyle.Render("Esc")+" cancel")
+		b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
+		return b.String()
+	}
+	if m.quickFilters {
+		parts = append(parts, keyStyle.Render("1-3")+" toggle")
+		parts = append(parts, keyStyle.Render("+/-")+" word count")
+		parts = append(parts, keyStyle.Render("Enter/Esc")+" done")
+		b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
+		return b.String()
+	}
+	if m.duplicating {
+		parts = append(parts, keyStyle.Render("Enter")+" duplicate")
+		parts = append(parts, keyStyle.Render("Esc")+" cancel")
+		b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
+		return b.String()
+	}
+	if m.highlighting {
+		parts = append(parts, keyStyle.Render("Enter")+" add to today's highlights")
+		parts = append(parts, keyStyle.Render("Esc")+" cancel")
+		b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
+		return b.String()
+	}
+
 	parts = append(parts, keyStyle.Render("Up/Down")+" navigate")
 	parts = append(parts, keyStyle.Render("Enter")+" edit")
+	parts = append(parts, keyStyle.Render("/")+" search")
 
 	if m.hasTodayEntry() {
 		parts = append(parts, disabledStyle.Render("n new"))
@@ -195,13 +828,40 @@ func (m ListModel) View() string {
 		parts = append(parts, keyStyle.Render("n")+" new")
 	}
 
+	parts = append(parts, keyStyle.Render("t")+" today")
+	parts = append(parts, keyStyle.Render("r")+" read")
 	parts = append(parts, keyStyle.Render("a")+" attachments")
 	parts = append(parts, keyStyle.Render("h")+" history")
+	parts = append(parts, keyStyle.Render("c")+" duplicate")
 	parts = append(parts, keyStyle.Render("d")+" delete")
+	if m.lock.days > 0 {
+		parts = append(parts, keyStyle.Render("U")+" unlock")
+	}
+	parts = append(parts, keyStyle.Render("f")+" filter")
+	parts = append(parts, keyStyle.Render("F")+" quick filters")
+	parts = append(parts, keyStyle.Render("Space")+" mark")
+	if len(m.marked) > 0 {
+		parts = append(parts, keyStyle.Render("x")+" export marked")
+		parts = append(parts, keyStyle.Render("Esc")+" clear marks")
+	} else {
+		parts = append(parts, keyStyle.Render("x")+" export")
+		parts = append(parts, keyStyle.Render("P")+" share")
+		if !m.entries.filter.IsZero() {
+			parts = append(parts, keyStyle.Render("Esc")+" clear filter")
+		}
+	}
+	parts = append(parts, keyStyle.Render("w")+" words")
+	parts = append(parts, keyStyle.Render("T")+" tasks")
+	parts = append(parts, keyStyle.Render("L")+" loops")
+	parts = append(parts, keyStyle.Render("S")+" stats")
+	parts = append(parts, keyStyle.Render("G")+" goals")
+	parts = append(parts, keyStyle.Render("H")+" highlight")
+	parts = append(parts, keyStyle.Render("#")+" tags")
+	parts = append(parts, keyStyle.Render("C")+" calendar")
 	parts = append(parts, keyStyle.Render("s")+" settings")
 	parts = append(parts, keyStyle.Render("q")+" quit")
 
-	b.WriteString(helpStyle.Render(strings.Join(parts, " | ")))
+	b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
 
 	return b.String()
 }
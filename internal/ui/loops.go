@@ -0,0 +1,276 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"journal/internal/clock"
+	"journal/internal/model"
+	"journal/internal/storage"
+	"journal/internal/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// LoopsModel lists the open loops written in entries, lines starting ">>"
+// for threads to come back to, so unresolved ones from past days come up
+// again. Closing a loop rewrites its ">>" as "<<" in the entry.
+type LoopsModel struct {
+	store         storage.Store
+	clock         clock.Clock
+	lock          *entryLock // Loops of locked entries can't be closed
+	loops         []model.Loop
+	showClosed    bool
+	selectedIndex int
+	offset        int
+	width         int
+	height        int
+	Back          bool
+	Open          bool // Open the selected loop's entry in the editor
+	Error         string
+	Message       string
+}
+
+func NewLoopsModel(store storage.Store, clk clock.Clock, lock *entryLock) (LoopsModel, error) {
+	m := LoopsModel{store: store, clock: clk, lock: lock}
+	return m, m.reload()
+}
+
+func (m *LoopsModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+func (m LoopsModel) Init() tea.Cmd {
+	return nil
+}
+
+// reload reads the loops again, keeping the selection in range
+func (m *LoopsModel) reload() error {
+	loops, err := m.store.ListLoops(!m.showClosed)
+	if err != nil {
+		return err
+	}
+	m.loops = loops
+	if m.selectedIndex >= len(m.loops) {
+		m.selectedIndex = max(len(m.loops)-1, 0)
+	}
+	m.adjustScroll()
+	return nil
+}
+
+// Selected returns the selected loop
+func (m LoopsModel) Selected() (model.Loop, bool) {
+	if m.selectedIndex >= len(m.loops) {
+		return model.Loop{}, false
+	}
+	return m.loops[m.selectedIndex], true
+}
+
+func (m LoopsModel) visibleRows() int {
+	rows := m.height - 8
+	if rows < 5 {
+		rows = 10
+	}
+	return rows
+}
+
+func (m *LoopsModel) adjustScroll() {
+	visible := m.visibleRows()
+	if m.selectedIndex < m.offset {
+		m.offset = m.selectedIndex
+	} else if m.selectedIndex >= m.offset+visible {
+		m.offset = m.selectedIndex - visible + 1
+	}
+}
+
+func (m LoopsModel) Update(msg tea.Msg) (LoopsModel, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		m.Error = ""
+		m.Message = ""
+
+		switch msg.String() {
+		case "up", "k":
+			if m.selectedIndex > 0 {
+				m.selectedIndex--
+				m.adjustScroll()
+			}
+		case "down", "j":
+			if m.selectedIndex < len(m.loops)-1 {
+				m.selectedIndex++
+				m.adjustScroll()
+			}
+		case " ", "x":
+			if err := m.toggle(); err != nil {
+				m.Error = err.Error()
+			}
+		case "a":
+			m.showClosed = !m.showClosed
+			if err := m.reload(); err != nil {
+				m.Error = err.Error()
+			}
+		case "enter":
+			if len(m.loops) > 0 {
+				m.Open = true
+			}
+		case "esc", "q":
+			m.Back = true
+		}
+	}
+	return m, nil
+}
+
+// loopMarker matches the marker of an open or closed loop
+var loopMarker = regexp.MustCompile(`^(\s*)(>>|<<)(\s)`)
+
+// toggle closes the selected loop, or reopens it when closed, by rewriting
+// its line in the entry. The entry's previous content goes to its history,
+// as when it is saved from the editor.
+func (m *LoopsModel) toggle() error {
+	loop, ok := m.Selected()
+	if !ok {
+		return nil
+	}
+	if m.lock.Locked(loop.EntryID, loop.Date) {
+		return errors.New(m.lock.Reason(loop.Date))
+	}
+	entry, err := m.store.GetEntry(loop.EntryID)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(entry.Content, "\n")
+	if loop.Line >= len(lines) || !loopMarker.MatchString(lines[loop.Line]) {
+		return fmt.Errorf("the entry for %s has changed, so the loop couldn't be found", loop.Date)
+	}
+	marker := "<<"
+	if loop.Closed {
+		marker = ">>"
+	}
+	lines[loop.Line] = loopMarker.ReplaceAllString(lines[loop.Line], "${1}"+marker+"${3}")
+
+	entry.History = append(entry.History, model.SaveRecord{
+		Content:     entry.Content,
+		SavedAt:     entry.UpdatedAt,
+		Attachments: entry.AttachmentFilenames(),
+		Tags:        entry.Tags,
+	})
+	entry.Content = strings.Join(lines, "\n")
+	entry.UpdatedAt = m.clock.Now()
+	if err := m.store.SaveEntries([]model.Entry{*entry}); err != nil {
+		return err
+	}
+
+	if loop.Closed {
+		m.Message = "Reopened: " + loop.Text
+	} else {
+		m.Message = "Closed: " + loop.Text
+	}
+	return m.reload()
+}
+
+// loopAge says how long a loop has been open, from its entry's date
+func (m LoopsModel) loopAge(date string) string {
+	written, err := time.Parse(entryDateLayout, date)
+	if err != nil {
+		return ""
+	}
+	now := m.clock.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch days := int(today.Sub(written).Hours() / 24); {
+	case days < 0:
+		return ""
+	case days == 0:
+		return "today"
+	case days == 1:
+		return "yesterday"
+	default:
+		return fmt.Sprintf("%d days ago", days)
+	}
+}
+
+func (m LoopsModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	itemStyle := lipgloss.NewStyle().Foreground(t.Text).PaddingLeft(2)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true).PaddingLeft(2)
+	closedStyle := lipgloss.NewStyle().Foreground(t.TextDim).Strikethrough(true)
+	dateStyle := lipgloss.NewStyle().Foreground(t.Info)
+	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	emptyStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true).PaddingLeft(2)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	successStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Open Loops"))
+	if m.showClosed {
+		b.WriteString(mutedStyle.Render("  (all)"))
+	} else {
+		b.WriteString(mutedStyle.Render("  (open)"))
+	}
+	b.WriteString("\n\n")
+
+	if len(m.loops) == 0 {
+		if m.showClosed {
+			b.WriteString(emptyStyle.Render("No loops yet. Start a line with \">> \" for a thread to come back to."))
+		} else {
+			b.WriteString(emptyStyle.Render("No open loops."))
+		}
+		b.WriteString("\n")
+	} else {
+		end := min(m.offset+m.visibleRows(), len(m.loops))
+		for i := m.offset; i < end; i++ {
+			loop := m.loops[i]
+			marker, text := ">>", loop.Text
+			if loop.Closed {
+				marker, text = "<<", closedStyle.Render(loop.Text)
+			}
+			line := dateStyle.Render("["+loop.Date+"]") + " " + marker + " " + text
+			if age := m.loopAge(loop.Date); age != "" && !loop.Closed {
+				line += mutedStyle.Render("  " + age)
+			}
+			if i == m.selectedIndex {
+				b.WriteString(selectedStyle.Render("> " + line))
+			} else {
+				b.WriteString(itemStyle.Render("  " + line))
+			}
+			b.WriteString("\n")
+		}
+		if len(m.loops) > m.visibleRows() {
+			b.WriteString(mutedStyle.Render(fmt.Sprintf("  (%d-%d of %d)", m.offset+1, end, len(m.loops))))
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n")
+
+	if m.Error != "" {
+		b.WriteString(errorStyle.Render(m.Error))
+		b.WriteString("\n\n")
+	}
+	if m.Message != "" {
+		b.WriteString(successStyle.Render(m.Message))
+		b.WriteString("\n\n")
+	}
+
+	var parts []string
+	parts = append(parts, keyStyle.Render("Up/Down")+" navigate")
+	parts = append(parts, keyStyle.Render("Space")+" close/reopen")
+	parts = append(parts, keyStyle.Render("Enter")+" open entry")
+	if m.showClosed {
+		parts = append(parts, keyStyle.Render("a")+" open only")
+	} else {
+		parts = append(parts, keyStyle.Render("a")+" show closed")
+	}
+	parts = append(parts, keyStyle.Render("Esc/q")+" back")
+	b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
+
+	return b.String()
+}
@@ -0,0 +1,33 @@
+package ui
+
+import (
+	"journal/internal/model"
+	"journal/internal/storage"
+)
+
+// lowMemoryStore is the store of the active journal in low-memory mode
+// (--low-memory), for small servers and old hardware. Entries are read
+// without their history, which is only read for the views that show it:
+// the history view, searches of previous versions, and exports.
+type lowMemoryStore struct {
+	storage.Store
+}
+
+func (s lowMemoryStore) GetEntry(entryID string) (*model.Entry, error) {
+	return s.Store.GetEntryContent(entryID)
+}
+
+// isLowMemory reports whether store is in low-memory mode
+func isLowMemory(store storage.Store) bool {
+	_, ok := store.(lowMemoryStore)
+	return ok
+}
+
+// getEntryWithHistory reads an entry with its history, also in low-memory
+// mode
+func getEntryWithHistory(store storage.Store, entryID string) (*model.Entry, error) {
+	if s, ok := store.(lowMemoryStore); ok {
+		return s.Store.GetEntry(entryID)
+	}
+	return store.GetEntry(entryID)
+}
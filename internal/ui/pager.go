@@ -0,0 +1,103 @@
+package ui
+
+import (
+	"journal/internal/clock"
+	"journal/internal/model"
+	"journal/internal/storage"
+)
+
+// entryPageSize is how many entry summaries are fetched from storage at once
+const entryPageSize = 100
+
+// entryPager pages entry summaries in from storage as the list scrolls.
+// Only the page being viewed and its neighbours are kept in memory.
+type entryPager struct {
+	store    storage.Store
+	clock    clock.Clock // Decides which day is today
+	filter   model.EntryFilter
+	total    int
+	hasToday bool
+	todayID  string // Today's entry, whether or not it matches the filter
+	pages    map[int][]model.EntrySummary
+	external *externalChanges // Entries changed outside the app
+	err      error
+}
+
+func newEntryPager(store storage.Store, clk clock.Clock, external *externalChanges) (*entryPager, error) {
+	p := &entryPager{store: store, clock: clk, external: external}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// setFilter narrows the entries to those matching filter
+func (p *entryPager) setFilter(filter model.EntryFilter) error {
+	old := p.filter
+	p.filter = filter
+	if err := p.reload(); err != nil {
+		p.filter = old
+		return err
+	}
+	return nil
+}
+
+// reload drops cached pages and re-reads the entry count
+func (p *entryPager) reload() error {
+	total, err := p.store.CountEntries(p.filter)
+	if err != nil {
+		return err
+	}
+	todayID, err := p.store.FindEntryByDate(p.clock.Now().Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	p.total = total
+	p.hasToday = todayID != ""
+	p.todayID = todayID
+	p.pages = make(map[int][]model.EntrySummary)
+	p.err = nil
+	return nil
+}
+
+func (p *entryPager) Len() int {
+	return p.total
+}
+
+// At returns the summary at index i, fetching its page if needed
+func (p *entryPager) At(i int) (model.EntrySummary, bool) {
+	if i < 0 || i >= p.total {
+		return model.EntrySummary{}, false
+	}
+	page := i / entryPageSize
+	entries, ok := p.pages[page]
+	if !ok {
+		var err error
+		entries, err = p.store.ListEntries(page*entryPageSize, entryPageSize, p.filter)
+		if err != nil {
+			p.err = err
+			return model.EntrySummary{}, false
+		}
+		for n := range p.pages {
+			if n < page-1 || n > page+1 {
+				delete(p.pages, n)
+			}
+		}
+		p.pages[page] = entries
+	}
+	offset := i - page*entryPageSize
+	if offset >= len(entries) {
+		return model.EntrySummary{}, false
+	}
+	return entries[offset], true
+}
+
+// IndexOf returns the position of an entry, or -1
+func (p *entryPager) IndexOf(entryID string) int {
+	i, err := p.store.EntryPosition(entryID, p.filter)
+	if err != nil {
+		p.err = err
+		return -1
+	}
+	return i
+}
@@ -10,15 +10,21 @@ import (
 	"github.com/charmbracelet/lipgloss"
 )
 
+// passwordHintAfter is how many wrong passwords are entered before the
+// journal's password hint is shown
+const passwordHintAfter = 3
+
 type PasswordModel struct {
 	passwordInput textinput.Model
+	hint          string // The journal's password hint, if it has one
+	failures      int    // Wrong passwords entered so far
 	Password      string
 	Done          bool
 	Cancelled     bool
 	Error         string
 }
 
-func NewPasswordModel() PasswordModel {
+func NewPasswordModel(hint string) PasswordModel {
 	ti := textinput.New()
 	ti.Placeholder = "Enter password"
 	ti.EchoMode = textinput.EchoPassword
@@ -29,9 +35,19 @@ func NewPasswordModel() PasswordModel {
 
 	return PasswordModel{
 		passwordInput: ti,
+		hint:          hint,
 	}
 }
 
+// Failed records a wrong password, clearing the input for another try
+func (m *PasswordModel) Failed() {
+	m.failures++
+	m.Error = "Invalid password"
+	m.Done = false
+	m.Password = ""
+	m.passwordInput.SetValue("")
+}
+
 func (m PasswordModel) Init() tea.Cmd {
 	return textinput.Blink
 }
@@ -54,7 +70,9 @@ func (m PasswordModel) Update(msg tea.Msg) (PasswordModel, tea.Cmd) {
 		}
 	}
 
-	m.Error = ""
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.Error = ""
+	}
 	m.passwordInput, cmd = m.passwordInput.Update(msg)
 	return m, cmd
 }
@@ -66,6 +84,8 @@ func (m PasswordModel) View() string {
 	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
 	promptStyle := lipgloss.NewStyle().Foreground(t.Text)
 	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(t.Info)
+	noteStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true)
 	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
 	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
 
@@ -87,6 +107,17 @@ func (m PasswordModel) View() string {
 		b.WriteString("\n")
 	}
 
+	if m.failures >= passwordHintAfter {
+		b.WriteString("\n")
+		b.WriteString("  ")
+		if m.hint != "" {
+			b.WriteString(hintStyle.Render("Hint: " + m.hint))
+		} else {
+			b.WriteString(noteStyle.Render("No password hint was set for this journal"))
+		}
+		b.WriteString("\n")
+	}
+
 	b.WriteString("\n")
 	b.WriteString(helpStyle.Render(keyStyle.Render("Enter") + " unlock | " + keyStyle.Render("Esc") + " back"))
 
@@ -0,0 +1,272 @@
+package ui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"journal/internal/model"
+	"journal/internal/stats"
+	"journal/internal/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// readerMaxWidth keeps reading lines to a comfortable length on wide
+// terminals
+const readerMaxWidth = 80
+
+// ReaderModel pages through entries one at a time, read-only, like
+// flipping through a paper journal. It reads the entries shown in the
+// list, with its filter.
+type ReaderModel struct {
+	entries *entryPager
+	index   int // Position in entries, which are newest first
+	entry   *model.Entry
+	offset  int // First content line shown
+	width   int
+	height  int
+	Back    bool
+	Edit    bool // Open the entry being read in the editor
+	Error   string
+}
+
+// NewReaderModel opens the reader on the entry at index in entries
+func NewReaderModel(entries *entryPager, index int) (ReaderModel, error) {
+	m := ReaderModel{entries: entries}
+	return m, m.load(index)
+}
+
+// load shows the entry at index
+func (m *ReaderModel) load(index int) error {
+	summary, ok := m.entries.At(index)
+	if !ok {
+		if m.entries.err != nil {
+			return m.entries.err
+		}
+		return fmt.Errorf("no entry at position %d", index+1)
+	}
+	entry, err := m.entries.store.GetEntry(summary.ID)
+	if err != nil {
+		return err
+	}
+	m.index = index
+	m.entry = entry
+	m.offset = 0
+	m.entries.external.viewed(entry.ID)
+	return nil
+}
+
+// Entry returns the entry being read
+func (m ReaderModel) Entry() *model.Entry {
+	return m.entry
+}
+
+func (m *ReaderModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+func (m ReaderModel) Init() tea.Cmd {
+	return nil
+}
+
+// pageHeight is how many content lines fit between the header and help
+func (m ReaderModel) pageHeight() int {
+	return max(m.height-9, 5)
+}
+
+func (m ReaderModel) textWidth() int {
+	if m.width <= 0 {
+		return readerMaxWidth
+	}
+	return min(m.width, readerMaxWidth)
+}
+
+func (m ReaderModel) Update(msg tea.Msg) (ReaderModel, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		m.Error = ""
+		lines := len(m.contentLines())
+		maxOffset := max(lines-m.pageHeight(), 0)
+
+		switch msg.String() {
+		case "n", "right", "l":
+			// Entries are newest first, so the next day is the one before
+			if m.index > 0 {
+				if err := m.load(m.index - 1); err != nil {
+					m.Error = err.Error()
+				}
+			} else {
+				m.Error = "This is the latest entry"
+			}
+		case "p", "left", "h":
+			if m.index < m.entries.Len()-1 {
+				if err := m.load(m.index + 1); err != nil {
+					m.Error = err.Error()
+				}
+			} else {
+				m.Error = "This is the first entry"
+			}
+		case "down", "j":
+			m.offset = min(m.offset+1, maxOffset)
+		case "up", "k":
+			m.offset = max(m.offset-1, 0)
+		case "pgdown", " ":
+			m.offset = min(m.offset+m.pageHeight(), maxOffset)
+		case "pgup":
+			m.offset = max(m.offset-m.pageHeight(), 0)
+		case "e":
+			m.Edit = true
+		case "esc", "q":
+			m.Back = true
+		}
+	}
+	return m, nil
+}
+
+// contentLines renders the entry's content and wraps it to the page
+func (m ReaderModel) contentLines() []string {
+	if m.entry == nil {
+		return nil
+	}
+	rendered := renderEntryContent(m.entry.Content)
+	return strings.Split(ansi.Wrap(rendered, m.textWidth(), ""), "\n")
+}
+
+var (
+	readerHeading    = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	readerTask       = regexp.MustCompile(`^(\s*)[-*+] \[([ xX])\]\s+(.*)$`)
+	readerBullet     = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	readerQuote      = regexp.MustCompile(`^>\s?(.*)$`)
+	readerBold       = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	readerItalic     = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	readerInlineCode = regexp.MustCompile("`([^`]+)`")
+)
+
+// renderEntryContent styles the Markdown an entry is commonly written in:
+// headings, lists, checkboxes, quotes, code, and emphasis. Anything else is
+// shown as written.
+func renderEntryContent(content string) string {
+	t := theme.Current()
+	headingStyle := lipgloss.NewStyle().Foreground(t.Title).Bold(true)
+	bulletStyle := lipgloss.NewStyle().Foreground(t.Accent)
+	doneStyle := lipgloss.NewStyle().Foreground(t.TextDim).Strikethrough(true)
+	quoteStyle := lipgloss.NewStyle().Foreground(t.Muted).Italic(true)
+	codeStyle := lipgloss.NewStyle().Foreground(t.Info)
+	textStyle := lipgloss.NewStyle().Foreground(t.Text)
+
+	inline := func(s string) string {
+		s = readerInlineCode.ReplaceAllStringFunc(s, func(m string) string {
+			return codeStyle.Render(strings.Trim(m, "`"))
+		})
+		s = readerBold.ReplaceAllStringFunc(s, func(m string) string {
+			return lipgloss.NewStyle().Bold(true).Render(m[2 : len(m)-2])
+		})
+		s = readerItalic.ReplaceAllStringFunc(s, func(m string) string {
+			return lipgloss.NewStyle().Italic(true).Render(m[1 : len(m)-1])
+		})
+		return s
+	}
+
+	var lines []string
+	inCode := false
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inCode = !inCode
+			continue
+		}
+		switch {
+		case inCode:
+			lines = append(lines, codeStyle.Render("  "+line))
+		case readerHeading.MatchString(line):
+			lines = append(lines, headingStyle.Render(readerHeading.FindStringSubmatch(line)[2]))
+		case readerTask.MatchString(line):
+			m := readerTask.FindStringSubmatch(line)
+			if m[2] == " " {
+				lines = append(lines, m[1]+bulletStyle.Render("☐")+" "+textStyle.Render(inline(m[3])))
+			} else {
+				lines = append(lines, m[1]+bulletStyle.Render("☑")+" "+doneStyle.Render(m[3]))
+			}
+		case readerBullet.MatchString(line):
+			m := readerBullet.FindStringSubmatch(line)
+			lines = append(lines, m[1]+bulletStyle.Render("•")+" "+textStyle.Render(inline(m[2])))
+		case readerQuote.MatchString(line):
+			lines = append(lines, quoteStyle.Render("│ "+readerQuote.FindStringSubmatch(line)[1]))
+		default:
+			lines = append(lines, textStyle.Render(inline(line)))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m ReaderModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	dateStyle := lipgloss.NewStyle().Foreground(t.Title).Bold(true)
+	metaStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	tagStyle := lipgloss.NewStyle().Foreground(t.Accent)
+	dividerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	scrollStyle := lipgloss.NewStyle().Foreground(t.Muted).Italic(true)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+
+	b.WriteString("\n")
+	if m.entry == nil {
+		return b.String()
+	}
+
+	heading := m.entry.Date
+	if date, err := time.Parse(entryDateLayout, m.entry.Date); err == nil {
+		heading = date.Format("Monday, 2 January 2006")
+	}
+	if m.entry.Mood != "" {
+		heading += "  " + m.entry.Mood
+	}
+	b.WriteString(dateStyle.Render(heading))
+	b.WriteString("\n")
+
+	meta := fmt.Sprintf("%d words", len(stats.Words(m.entry.Content)))
+	if language, ok := stats.DetectLanguage(m.entry.Content); ok {
+		meta += " in " + language.Name
+	}
+	if n := len(m.entry.Attachments); n > 0 {
+		meta += fmt.Sprintf(" | %d attachments", n)
+	}
+	meta += fmt.Sprintf(" | entry %d of %d", m.entries.Len()-m.index, m.entries.Len())
+	b.WriteString(metaStyle.Render(meta))
+	if len(m.entry.Tags) > 0 {
+		b.WriteString("  " + tagStyle.Render("#"+strings.Join(m.entry.Tags, " #")))
+	}
+	b.WriteString("\n")
+	b.WriteString(dividerStyle.Render(strings.Repeat("─", m.textWidth())))
+	b.WriteString("\n\n")
+
+	lines := m.contentLines()
+	end := min(m.offset+m.pageHeight(), len(lines))
+	b.WriteString(strings.Join(lines[m.offset:end], "\n"))
+	b.WriteString("\n")
+	if len(lines) > m.pageHeight() {
+		b.WriteString(scrollStyle.Render(fmt.Sprintf("(lines %d-%d of %d)", m.offset+1, end, len(lines))))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	if m.Error != "" {
+		b.WriteString(errorStyle.Render(m.Error))
+		b.WriteString("\n")
+	}
+
+	var parts []string
+	parts = append(parts, keyStyle.Render("p/n")+" previous/next day")
+	parts = append(parts, keyStyle.Render("Up/Down")+" scroll")
+	parts = append(parts, keyStyle.Render("e")+" edit")
+	parts = append(parts, keyStyle.Render("Esc/q")+" back")
+	b.WriteString(helpStyle.Render(strings.Join(parts, " | ")))
+
+	return b.String()
+}
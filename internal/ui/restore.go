@@ -0,0 +1,268 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"journal/internal/model"
+	"journal/internal/storage"
+	"journal/internal/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type restoreStep int
+
+const (
+	restoreChooseBackup restoreStep = iota
+	restoreChooseMode
+	restoreConfirmReplace
+)
+
+type backupInfo struct {
+	backup  storage.Backup
+	entries int
+	err     error
+}
+
+type RestoreModel struct {
+	journal       *model.JournalDB
+	password      string // Empty for plaintext journals
+	backups       []backupInfo
+	step          restoreStep
+	selectedIndex int
+	modeIndex     int // 0 = restore as new journal, 1 = replace current
+	Done          bool
+	Cancelled     bool
+	Replaced      bool   // The current journal file was replaced
+	NewPath       string // Path of a journal restored as new
+	NewName       string
+	Error         string
+}
+
+func NewRestoreModel(journal *model.JournalDB, password string) RestoreModel {
+	m := RestoreModel{
+		journal:  journal,
+		password: password,
+	}
+
+	backups, err := storage.ListBackups(journal.Path)
+	if err != nil {
+		m.Error = err.Error()
+		return m
+	}
+	for _, b := range backups {
+		count, err := storage.CountEntries(b.Path, password, model.EntryFilter{})
+		m.backups = append(m.backups, backupInfo{backup: b, entries: count, err: err})
+	}
+	return m
+}
+
+func (m RestoreModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m RestoreModel) selected() *backupInfo {
+	if m.selectedIndex >= 0 && m.selectedIndex < len(m.backups) {
+		return &m.backups[m.selectedIndex]
+	}
+	return nil
+}
+
+// restoredPath returns a path next to the current journal for a restored copy
+func (m RestoreModel) restoredPath(b storage.Backup) string {
+	dir := filepath.Dir(m.journal.Path)
+	base := filepath.Base(m.journal.Path)
+	ext := filepath.Ext(base)
+	base = strings.TrimSuffix(base, ext)
+	return filepath.Join(dir, fmt.Sprintf("%s_restored_%s%s", base, b.CreatedAt.Format("20060102-150405"), ext))
+}
+
+func (m RestoreModel) Update(msg tea.Msg) (RestoreModel, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	m.Error = ""
+
+	switch m.step {
+	case restoreChooseBackup:
+		switch keyMsg.String() {
+		case "up", "k":
+			if m.selectedIndex > 0 {
+				m.selectedIndex--
+			}
+		case "down", "j":
+			if m.selectedIndex < len(m.backups)-1 {
+				m.selectedIndex++
+			}
+		case "enter":
+			if b := m.selected(); b != nil {
+				if b.err != nil {
+					m.Error = "Cannot read this backup: " + b.err.Error()
+					return m, nil
+				}
+				m.step = restoreChooseMode
+				m.modeIndex = 0
+			}
+		case "esc", "q":
+			m.Cancelled = true
+		}
+
+	case restoreChooseMode:
+		switch keyMsg.String() {
+		case "up", "k":
+			m.modeIndex = 0
+		case "down", "j":
+			m.modeIndex = 1
+		case "enter":
+			b := m.selected()
+			if m.modeIndex == 1 {
+				m.step = restoreConfirmReplace
+				return m, nil
+			}
+			newPath := m.restoredPath(b.backup)
+			if err := storage.RestoreBackupAsNew(b.backup.Path, newPath); err != nil {
+				m.Error = err.Error()
+				return m, nil
+			}
+			m.NewPath = newPath
+			m.NewName = fmt.Sprintf("%s (restored %s)", m.journal.Name, b.backup.CreatedAt.Format("2006-01-02"))
+			m.Done = true
+		case "esc":
+			m.step = restoreChooseBackup
+		}
+
+	case restoreConfirmReplace:
+		switch keyMsg.String() {
+		case "y", "Y":
+			b := m.selected()
+			if err := storage.RestoreBackup(b.backup.Path, m.journal.Path); err != nil {
+				m.Error = err.Error()
+				m.step = restoreChooseMode
+				return m, nil
+			}
+			m.Replaced = true
+			m.Done = true
+		case "n", "N", "esc":
+			m.step = restoreChooseMode
+		}
+	}
+
+	return m, nil
+}
+
+func (m RestoreModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	itemStyle := lipgloss.NewStyle().Foreground(t.Text).PaddingLeft(2)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true).PaddingLeft(2)
+	timestampStyle := lipgloss.NewStyle().Foreground(t.Warning).Bold(true)
+	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	pathStyle := lipgloss.NewStyle().Foreground(t.Info).Italic(true)
+	emptyStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true).PaddingLeft(2)
+	promptStyle := lipgloss.NewStyle().Foreground(t.Warning).Bold(true)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	dividerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Restore from Backup"))
+	b.WriteString("\n\n")
+	b.WriteString(mutedStyle.Render("Journal: " + m.journal.Name))
+	b.WriteString("\n")
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", 60)))
+	b.WriteString("\n\n")
+
+	switch m.step {
+	case restoreChooseBackup:
+		if len(m.backups) == 0 {
+			b.WriteString(emptyStyle.Render("No backups found. Enable automatic backups in settings."))
+			b.WriteString("\n\n")
+		}
+		for i, info := range m.backups {
+			line := timestampStyle.Render(info.backup.CreatedAt.Format("2006-01-02 15:04:05"))
+			line += " " + mutedStyle.Render(relativeAge(info.backup.CreatedAt))
+			if info.err != nil {
+				line += " " + errorStyle.Render("[unreadable]")
+			} else {
+				line += fmt.Sprintf("  %d entries", info.entries)
+			}
+			line += " " + mutedStyle.Render("("+storage.FormatFileSize(info.backup.Size)+")")
+
+			if i == m.selectedIndex {
+				b.WriteString(selectedStyle.Render("> " + line))
+			} else {
+				b.WriteString(itemStyle.Render("  " + line))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+
+	case restoreChooseMode, restoreConfirmReplace:
+		info := m.selected()
+		b.WriteString("Backup from ")
+		b.WriteString(timestampStyle.Render(info.backup.CreatedAt.Format("2006-01-02 15:04:05")))
+		b.WriteString(fmt.Sprintf(" (%d entries)", info.entries))
+		b.WriteString("\n\n")
+
+		options := []string{
+			"Restore as a new journal",
+			"Replace the current journal",
+		}
+		for i, opt := range options {
+			if i == m.modeIndex {
+				b.WriteString(selectedStyle.Render("> " + opt))
+			} else {
+				b.WriteString(itemStyle.Render("  " + opt))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    ")
+		if m.modeIndex == 0 {
+			b.WriteString(pathStyle.Render(m.restoredPath(info.backup)))
+		} else {
+			b.WriteString(mutedStyle.Render("The current file is backed up before it is replaced"))
+		}
+		b.WriteString("\n\n")
+
+		if m.step == restoreConfirmReplace {
+			b.WriteString(promptStyle.Render("Replace the current journal with this backup?"))
+			b.WriteString("\n\n")
+		}
+	}
+
+	if m.Error != "" {
+		b.WriteString(errorStyle.Render("Error: " + m.Error))
+		b.WriteString("\n\n")
+	}
+
+	switch m.step {
+	case restoreChooseBackup:
+		b.WriteString(helpStyle.Render(keyStyle.Render("Up/Down") + " navigate | " + keyStyle.Render("Enter") + " select | " + keyStyle.Render("Esc") + " back"))
+	case restoreChooseMode:
+		b.WriteString(helpStyle.Render(keyStyle.Render("Up/Down") + " choose | " + keyStyle.Render("Enter") + " restore | " + keyStyle.Render("Esc") + " back"))
+	case restoreConfirmReplace:
+		b.WriteString(helpStyle.Render("Press " + keyStyle.Render("y") + " to confirm, " + keyStyle.Render("n") + " or " + keyStyle.Render("Esc") + " to cancel"))
+	}
+
+	return b.String()
+}
+
+// relativeAge describes how long ago t was in days
+func relativeAge(t time.Time) string {
+	days := int(time.Since(t).Hours() / 24)
+	switch {
+	case days <= 0:
+		return "(today)"
+	case days == 1:
+		return "(yesterday)"
+	}
+	return fmt.Sprintf("(%d days ago)", days)
+}
@@ -0,0 +1,329 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"journal/internal/clock"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Scripts drive the app without a terminal, for end-to-end tests and
+// demos. A script has one action per line; blank lines and lines starting
+// with # are ignored:
+//
+//	key <key> [<key>...]   press keys, named as in the help (enter, esc, ctrl+s, down, n)
+//	type <text>            type text one character at a time
+//	wait <duration>        pause, e.g. 500ms
+//	expect <text>          fail unless the screen shows text
+//	resize <width> <height>
+//	screen                 print the screen
+//	focus, blur            report the terminal gaining or losing focus
+//	clock <time> [<step>]  stamp new entries from an RFC 3339 time, advancing by step
+//	ids <prefix>           name new entries and attachments prefix-1, prefix-2, ...
+//
+// clock and ids must come before the other actions.
+
+// defaultScriptWidth and defaultScriptHeight are the screen size of a
+// script until it resizes it
+const (
+	defaultScriptWidth  = 100
+	defaultScriptHeight = 30
+)
+
+type scriptAction int
+
+const (
+	scriptKeys scriptAction = iota
+	scriptWait
+	scriptExpect
+	scriptResize
+	scriptScreen
+	scriptSend
+)
+
+type scriptStep struct {
+	line   int
+	action scriptAction
+	keys   []tea.KeyMsg
+	msg    tea.Msg // Sent by scriptSend
+	text   string
+	wait   time.Duration
+	width  int
+	height int
+}
+
+// Script is a parsed script
+type Script struct {
+	clock     clock.Clock // nil for the system clock
+	ids       clock.IDGenerator
+	today     bool // Start in today's entry, as with --today
+	lowMemory bool // As with --low-memory
+	steps     []scriptStep
+}
+
+// keyTypes maps key names to bubbletea key types
+var keyTypes = func() map[string]tea.KeyType {
+	types := map[string]tea.KeyType{"space": tea.KeySpace}
+	for k := tea.KeyType(-100); k <= 127; k++ {
+		if name := k.String(); name != "" {
+			types[name] = k
+		}
+	}
+	return types
+}()
+
+// parseKey turns a key name into the message a terminal would send
+func parseKey(name string) (tea.KeyMsg, error) {
+	key := tea.Key{}
+	if rest, ok := strings.CutPrefix(name, "alt+"); ok && rest != "" {
+		key.Alt = true
+		name = rest
+	}
+	if t, ok := keyTypes[name]; ok {
+		key.Type = t
+		return tea.KeyMsg(key), nil
+	}
+	if runes := []rune(name); len(runes) == 1 {
+		key.Type = tea.KeyRunes
+		key.Runes = runes
+		return tea.KeyMsg(key), nil
+	}
+	return tea.KeyMsg{}, fmt.Errorf("unknown key %q", name)
+}
+
+// typeKeys returns the key presses that type text
+func typeKeys(text string) []tea.KeyMsg {
+	var keys []tea.KeyMsg
+	for _, r := range text {
+		if r == ' ' {
+			keys = append(keys, tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+		} else {
+			keys = append(keys, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		}
+	}
+	return keys
+}
+
+// ParseScript reads a script, reporting the first invalid line
+func ParseScript(r io.Reader) (*Script, error) {
+	script := &Script{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		action, arg, _ := strings.Cut(text, " ")
+		arg = strings.TrimSpace(arg)
+		fields := strings.Fields(arg)
+		step := scriptStep{line: line}
+
+		switch action {
+		case "key":
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: key needs at least one key", line)
+			}
+			for _, name := range fields {
+				key, err := parseKey(name)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				step.keys = append(step.keys, key)
+			}
+		case "type":
+			// Keep the text as written, including inner spaces
+			typed, _ := strings.CutPrefix(strings.TrimLeft(scanner.Text(), " \t"), "type ")
+			if typed == "" || typed == "type" {
+				return nil, fmt.Errorf("line %d: type needs text", line)
+			}
+			step.keys = typeKeys(typed)
+		case "wait":
+			d, err := time.ParseDuration(arg)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			step.action = scriptWait
+			step.wait = d
+		case "expect":
+			if arg == "" {
+				return nil, fmt.Errorf("line %d: expect needs text", line)
+			}
+			step.action = scriptExpect
+			step.text = arg
+		case "resize":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: resize needs a width and height", line)
+			}
+			w, errW := strconv.Atoi(fields[0])
+			h, errH := strconv.Atoi(fields[1])
+			if errW != nil || errH != nil || w < 1 || h < 1 {
+				return nil, fmt.Errorf("line %d: invalid size %q", line, arg)
+			}
+			step.action = scriptResize
+			step.width, step.height = w, h
+		case "screen":
+			step.action = scriptScreen
+		case "focus":
+			step.action = scriptSend
+			step.msg = tea.FocusMsg{}
+		case "blur":
+			step.action = scriptSend
+			step.msg = tea.BlurMsg{}
+		case "today":
+			if len(script.steps) > 0 {
+				return nil, fmt.Errorf("line %d: today must come before other actions", line)
+			}
+			script.today = true
+			continue
+		case "low-memory":
+			if len(script.steps) > 0 {
+				return nil, fmt.Errorf("line %d: low-memory must come before other actions", line)
+			}
+			script.lowMemory = true
+			continue
+		case "clock", "ids":
+			if len(script.steps) > 0 {
+				return nil, fmt.Errorf("line %d: %s must come before other actions", line, action)
+			}
+			if action == "ids" {
+				if len(fields) != 1 {
+					return nil, fmt.Errorf("line %d: ids needs a prefix", line)
+				}
+				script.ids = clock.NewSequence(fields[0])
+				continue
+			}
+			if len(fields) < 1 || len(fields) > 2 {
+				return nil, fmt.Errorf("line %d: clock needs a time and optional step", line)
+			}
+			start, err := time.Parse(time.RFC3339, fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			tick := time.Second
+			if len(fields) == 2 {
+				if tick, err = time.ParseDuration(fields[1]); err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+			script.clock = clock.NewFixed(start, tick)
+			continue
+		default:
+			return nil, fmt.Errorf("line %d: unknown action %q", line, action)
+		}
+		script.steps = append(script.steps, step)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// RunScript runs the app headless with the script's actions as input,
+// writing requested screens to out. It returns an error for the first
+// expectation that isn't met.
+func RunScript(script *Script, out io.Writer) error {
+	app := InitialModel()
+	if script.clock != nil {
+		app.clock = script.clock
+	}
+	if script.ids != nil {
+		app.ids = script.ids
+	}
+	if script.today {
+		app = app.OpenToday()
+	}
+	if script.lowMemory {
+		app = app.LowMemory()
+	}
+
+	m := &scriptModel{app: app, steps: script.steps, out: out}
+	// An empty input rather than none, which the program can't go back to
+	// after running an external editor
+	p := tea.NewProgram(m, tea.WithInput(strings.NewReader("")), tea.WithOutput(out), tea.WithoutRenderer())
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+	return m.err
+}
+
+// scriptStepMsg asks the script model to perform its next step
+type scriptStepMsg struct{}
+
+func nextScriptStep() tea.Msg { return scriptStepMsg{} }
+
+// scriptModel wraps the app, performing one script step at a time. Steps
+// wait for the app to handle the previous one, so keys arrive in order.
+type scriptModel struct {
+	app   tea.Model
+	steps []scriptStep
+	next  int
+	out   io.Writer
+	err   error
+}
+
+func (m *scriptModel) Init() tea.Cmd {
+	m.app, _ = m.app.Update(tea.WindowSizeMsg{Width: defaultScriptWidth, Height: defaultScriptHeight})
+	return tea.Batch(m.app.Init(), nextScriptStep)
+}
+
+func (m *scriptModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if _, ok := msg.(scriptStepMsg); !ok {
+		var cmd tea.Cmd
+		m.app, cmd = m.app.Update(msg)
+		return m, cmd
+	}
+
+	if m.next >= len(m.steps) {
+		return m, tea.Quit
+	}
+	step := m.steps[m.next]
+	m.next++
+
+	switch step.action {
+	case scriptKeys:
+		cmds := make([]tea.Cmd, 0, len(step.keys)+1)
+		for _, key := range step.keys {
+			var cmd tea.Cmd
+			m.app, cmd = m.app.Update(key)
+			cmds = append(cmds, cmd)
+		}
+		return m, tea.Batch(append(cmds, nextScriptStep)...)
+
+	case scriptWait:
+		return m, tea.Tick(step.wait, func(time.Time) tea.Msg { return scriptStepMsg{} })
+
+	case scriptExpect:
+		screen := ansi.Strip(m.app.View())
+		if !strings.Contains(screen, step.text) {
+			m.err = fmt.Errorf("line %d: expected %q on screen:\n%s", step.line, step.text, screen)
+			return m, tea.Quit
+		}
+
+	case scriptResize:
+		var cmd tea.Cmd
+		m.app, cmd = m.app.Update(tea.WindowSizeMsg{Width: step.width, Height: step.height})
+		return m, tea.Batch(cmd, nextScriptStep)
+
+	case scriptScreen:
+		fmt.Fprintln(m.out, ansi.Strip(m.app.View()))
+
+	case scriptSend:
+		var cmd tea.Cmd
+		m.app, cmd = m.app.Update(step.msg)
+		return m, tea.Batch(cmd, nextScriptStep)
+	}
+	return m, nextScriptStep
+}
+
+func (m *scriptModel) View() string {
+	return ""
+}
@@ -0,0 +1,464 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"journal/internal/model"
+	"journal/internal/search"
+	"journal/internal/storage"
+	"journal/internal/theme"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchContextLines is the number of lines shown around each matching line
+const searchContextLines = 1
+
+// searchSnippetLines caps the number of snippet lines shown per result
+const searchSnippetLines = 4
+
+// searchFetchMax is the most entries a search reads one at a time; when
+// the index narrows a query to more, the whole journal is read instead
+const searchFetchMax = 50
+
+type searchResult struct {
+	entry   *model.Entry
+	version *model.SaveRecord // Set when the match is in a previous version
+	snippet []search.SnippetLine
+	count   int
+}
+
+// SearchModel searches entry content through the store's search index,
+// reading only the entries the index finds and keeping them for later
+// queries. Regular expressions can't be looked up, so the first one reads
+// the whole journal. In low-memory mode entries aren't kept between
+// queries, and are read one at a time however many the index finds.
+type SearchModel struct {
+	store          storage.Store
+	entries        map[string]*model.Entry // Entries read so far, by ID
+	loaded         bool                    // Every entry has been read
+	queryInput     textinput.Model
+	query          search.Query
+	options        search.Options
+	includeHistory bool // Also search previous versions of entries
+	queryError     string
+	searchError    string // Reading the entries failed
+	results        []searchResult
+	selectedIndex  int
+	offset         int
+	Back           bool
+	Open           bool // Open the selected result in the editor
+	OpenHistory    bool // Open the history view at the selected result's version
+	width          int
+	height         int
+}
+
+func NewSearchModel(store storage.Store, options search.Options) SearchModel {
+	ti := textinput.New()
+	ti.Placeholder = "Search entries..."
+	ti.CharLimit = 256
+	ti.Width = 50
+	ti.Focus()
+
+	return SearchModel{
+		store:      store,
+		entries:    make(map[string]*model.Entry),
+		queryInput: ti,
+		options:    options,
+	}
+}
+
+// Options returns the current search mode toggles
+func (m SearchModel) Options() search.Options {
+	return m.options
+}
+
+func (m *SearchModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+func (m SearchModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// SelectedEntry returns the entry of the selected result
+func (m SearchModel) SelectedEntry() *model.Entry {
+	if m.selectedIndex >= 0 && m.selectedIndex < len(m.results) {
+		return m.results[m.selectedIndex].entry
+	}
+	return nil
+}
+
+// SelectedVersion returns the save time of the selected result's version,
+// or the zero time when the match is in the current content
+func (m SearchModel) SelectedVersion() time.Time {
+	if m.selectedIndex >= 0 && m.selectedIndex < len(m.results) && m.results[m.selectedIndex].version != nil {
+		return m.results[m.selectedIndex].version.SavedAt
+	}
+	return time.Time{}
+}
+
+func (m *SearchModel) runSearch() {
+	var err error
+	m.query, err = search.ParseWith(m.queryInput.Value(), m.options)
+	m.results = nil
+	m.selectedIndex = 0
+	m.offset = 0
+	m.queryError = ""
+	m.searchError = ""
+
+	if err != nil {
+		m.queryError = err.Error()
+		return
+	}
+	if m.query.Empty() {
+		return
+	}
+
+	candidates, err := m.candidates()
+	if err != nil {
+		m.searchError = err.Error()
+		return
+	}
+	for _, entry := range candidates {
+		if m.query.Matches(entry.Content) {
+			m.results = append(m.results, m.newResult(entry, nil, entry.Content))
+		}
+
+		if !m.includeHistory {
+			continue
+		}
+		for j := range entry.History {
+			record := &entry.History[j]
+			// Skip versions identical to the current content, already listed above
+			if record.Content == entry.Content || !m.query.Matches(record.Content) {
+				continue
+			}
+			m.results = append(m.results, m.newResult(entry, record, record.Content))
+		}
+	}
+}
+
+// candidates returns the entries that may match the query, newest first.
+// Plain queries are looked up in the store's index; regular expressions
+// need every entry.
+func (m *SearchModel) candidates() ([]*model.Entry, error) {
+	lowMemory := isLowMemory(m.store)
+	if lowMemory {
+		clear(m.entries)
+		m.loaded = false
+	}
+
+	var entries []*model.Entry
+	if m.options.Regex {
+		if err := m.loadAll(); err != nil {
+			return nil, err
+		}
+		for _, entry := range m.entries {
+			entries = append(entries, entry)
+		}
+	} else {
+		ids, err := m.store.SearchEntries(strings.Fields(m.query.Raw), m.includeHistory)
+		if err != nil {
+			return nil, err
+		}
+		if !m.loaded && !lowMemory {
+			var missing int
+			for _, id := range ids {
+				if m.entries[id] == nil {
+					missing++
+				}
+			}
+			if missing > searchFetchMax {
+				if err := m.loadAll(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, id := range ids {
+			entry := m.entries[id]
+			if entry == nil {
+				if m.includeHistory {
+					entry, err = getEntryWithHistory(m.store, id)
+				} else {
+					entry, err = m.store.GetEntry(id)
+				}
+				if err != nil {
+					return nil, err
+				}
+				m.entries[id] = entry
+			}
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Date > entries[j].Date
+	})
+	return entries, nil
+}
+
+// loadAll reads every entry of the journal, once
+func (m *SearchModel) loadAll() error {
+	if m.loaded {
+		return nil
+	}
+	journal, err := m.store.Load()
+	if err != nil {
+		return err
+	}
+	for i := range journal.Entries {
+		m.entries[journal.Entries[i].ID] = &journal.Entries[i]
+	}
+	m.loaded = true
+	return nil
+}
+
+func (m SearchModel) newResult(entry *model.Entry, version *model.SaveRecord, content string) searchResult {
+	matches := m.query.Find(content)
+	return searchResult{
+		entry:   entry,
+		version: version,
+		snippet: search.Snippet(content, matches, searchContextLines, searchSnippetLines),
+		count:   len(matches),
+	}
+}
+
+// visibleResults returns how many results fit on screen
+func (m SearchModel) visibleResults() int {
+	visible := (m.height - 10) / (searchSnippetLines + 2)
+	if visible < 2 {
+		visible = 2
+	}
+	return visible
+}
+
+func (m *SearchModel) adjustScroll() {
+	visible := m.visibleResults()
+	if m.selectedIndex < m.offset {
+		m.offset = m.selectedIndex
+	} else if m.selectedIndex >= m.offset+visible {
+		m.offset = m.selectedIndex - visible + 1
+	}
+}
+
+func (m SearchModel) Update(msg tea.Msg) (SearchModel, tea.Cmd) {
+	var cmd tea.Cmd
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "up", "ctrl+p":
+			if m.selectedIndex > 0 {
+				m.selectedIndex--
+				m.adjustScroll()
+			}
+			return m, nil
+		case "down", "ctrl+n":
+			if m.selectedIndex < len(m.results)-1 {
+				m.selectedIndex++
+				m.adjustScroll()
+			}
+			return m, nil
+		case "enter":
+			if len(m.results) > 0 {
+				if m.results[m.selectedIndex].version != nil {
+					m.OpenHistory = true
+				} else {
+					m.Open = true
+				}
+			}
+			return m, nil
+		case "alt+h":
+			m.includeHistory = !m.includeHistory
+			m.runSearch()
+			return m, nil
+		case "esc":
+			m.Back = true
+			return m, nil
+		default:
+			if toggleSearchOption(&m.options, msg.String()) {
+				m.runSearch()
+				return m, nil
+			}
+		}
+	}
+
+	before := m.queryInput.Value()
+	m.queryInput, cmd = m.queryInput.Update(msg)
+	if m.queryInput.Value() != before {
+		m.runSearch()
+	}
+	return m, cmd
+}
+
+// toggleSearchOption flips the search mode bound to key, reporting whether
+// the key was a search toggle
+func toggleSearchOption(opts *search.Options, key string) bool {
+	switch key {
+	case "alt+r":
+		opts.Regex = !opts.Regex
+	case "alt+c":
+		opts.CaseSensitive = !opts.CaseSensitive
+	case "alt+w":
+		opts.WholeWord = !opts.WholeWord
+	default:
+		return false
+	}
+	return true
+}
+
+// renderSearchOptions shows the state of the search mode toggles
+func renderSearchOptions(opts search.Options) string {
+	t := theme.Current()
+	onStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	offStyle := lipgloss.NewStyle().Foreground(t.Disabled)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Muted)
+
+	toggle := func(key, label string, on bool) string {
+		if on {
+			return keyStyle.Render(key+" ") + onStyle.Render("["+label+"]")
+		}
+		return keyStyle.Render(key+" ") + offStyle.Render(" "+label+" ")
+	}
+
+	return strings.Join([]string{
+		toggle("Alt+R", "regex", opts.Regex),
+		toggle("Alt+C", "case", opts.CaseSensitive),
+		toggle("Alt+W", "word", opts.WholeWord),
+	}, "  ")
+}
+
+// highlight renders text with the matched ranges in the highlight style
+func highlight(text string, matches []search.Match, base, hl lipgloss.Style) string {
+	var b strings.Builder
+	pos := 0
+	for _, match := range matches {
+		if match.Start < pos || match.End > len(text) {
+			continue
+		}
+		b.WriteString(base.Render(text[pos:match.Start]))
+		b.WriteString(hl.Render(text[match.Start:match.End]))
+		pos = match.End
+	}
+	b.WriteString(base.Render(text[pos:]))
+	return b.String()
+}
+
+func (m SearchModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	dateStyle := lipgloss.NewStyle().Foreground(t.Info).Bold(true)
+	selectedDateStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true)
+	countStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	versionStyle := lipgloss.NewStyle().Foreground(t.Warning).Italic(true)
+	lineStyle := lipgloss.NewStyle().Foreground(t.Text)
+	lineNumStyle := lipgloss.NewStyle().Foreground(t.Disabled)
+	highlightStyle := lipgloss.NewStyle().Foreground(t.Warning).Bold(true).Underline(true)
+	emptyStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true).PaddingLeft(2)
+	scrollStyle := lipgloss.NewStyle().Foreground(t.Muted).Italic(true)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	dividerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	scopeKeyStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	scopeOnStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	scopeOffStyle := lipgloss.NewStyle().Foreground(t.Disabled)
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Search"))
+	b.WriteString("\n\n")
+	b.WriteString("  ")
+	b.WriteString(m.queryInput.View())
+	b.WriteString("\n  ")
+	b.WriteString(renderSearchOptions(m.options))
+	b.WriteString("  ")
+	if m.includeHistory {
+		b.WriteString(scopeKeyStyle.Render("Alt+H ") + scopeOnStyle.Render("[history]"))
+	} else {
+		b.WriteString(scopeKeyStyle.Render("Alt+H ") + scopeOffStyle.Render(" history "))
+	}
+	b.WriteString("\n")
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", 60)))
+	b.WriteString("\n\n")
+
+	lineWidth := m.width - 12
+	if lineWidth < 30 {
+		lineWidth = 70
+	}
+
+	if m.queryError != "" {
+		b.WriteString(errorStyle.Render("  Invalid pattern: " + m.queryError))
+		b.WriteString("\n")
+	} else if m.searchError != "" {
+		b.WriteString(errorStyle.Render("  Search failed: " + m.searchError))
+		b.WriteString("\n")
+	} else if m.query.Empty() {
+		if m.includeHistory {
+			b.WriteString(emptyStyle.Render("Type to search entry content and previous versions."))
+		} else {
+			b.WriteString(emptyStyle.Render("Type to search entry content."))
+		}
+		b.WriteString("\n")
+	} else if len(m.results) == 0 {
+		b.WriteString(emptyStyle.Render("No matching entries."))
+		b.WriteString("\n")
+	} else {
+		end := m.offset + m.visibleResults()
+		if end > len(m.results) {
+			end = len(m.results)
+		}
+
+		for i := m.offset; i < end; i++ {
+			result := m.results[i]
+			label := fmt.Sprintf("%d matches", result.count)
+			if result.count == 1 {
+				label = "1 match"
+			}
+			if i == m.selectedIndex {
+				b.WriteString(selectedDateStyle.Render("> [" + result.entry.Date + "]"))
+			} else {
+				b.WriteString(dateStyle.Render("  [" + result.entry.Date + "]"))
+			}
+			b.WriteString(" " + countStyle.Render(label))
+			if result.version != nil {
+				b.WriteString(" " + versionStyle.Render("found in version from "+result.version.SavedAt.Format("2006-01-02 15:04")))
+			}
+			b.WriteString("\n")
+
+			for _, line := range result.snippet {
+				if line.Gap {
+					b.WriteString(lineNumStyle.Render("      ..."))
+					b.WriteString("\n")
+				}
+				line = line.Clip(lineWidth)
+				b.WriteString(lineNumStyle.Render(fmt.Sprintf("  %4d ", line.Number)))
+				b.WriteString(highlight(line.Text, line.Matches, lineStyle, highlightStyle))
+				b.WriteString("\n")
+			}
+			b.WriteString("\n")
+		}
+
+		if len(m.results) > m.visibleResults() {
+			scrollInfo := fmt.Sprintf("(%d-%d of %d)", m.offset+1, end, len(m.results))
+			b.WriteString(scrollStyle.Render("  " + scrollInfo))
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n")
+
+	var parts []string
+	parts = append(parts, keyStyle.Render("Up/Down")+" navigate")
+	parts = append(parts, keyStyle.Render("Enter")+" open")
+	parts = append(parts, keyStyle.Render("Esc")+" back")
+	b.WriteString(helpStyle.Render(strings.Join(parts, " | ")))
+
+	return b.String()
+}
@@ -1,26 +1,68 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"journal/internal/model"
+	"journal/internal/storage"
 	"journal/internal/theme"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
+// JournalFix is a way of fixing a journal whose files can't be read
+type JournalFix int
+
+const (
+	FixNone     JournalFix = iota
+	FixLocate              // Point the journal to where its files are now
+	FixRemove              // Remove the journal from the list
+	FixRecreate            // Create an empty journal in its place
+)
+
+// fixOption is a choice offered for a journal whose files can't be read
+type fixOption struct {
+	fix   JournalFix
+	label string
+}
+
+// fixOptions are the choices offered for a journal whose files can't be
+// read, in order
+var fixOptions = []fixOption{
+	{FixLocate, "Locate it"},
+	{FixRemove, "Remove it from the list"},
+	{FixRecreate, "Recreate it empty"},
+}
+
 type SelectorModel struct {
 	journals      []model.JournalDB
+	problems      []error // Why each journal can't be opened, or nil
 	selectedIndex int
 	Selected      *model.JournalDB
 	CreateNew     bool
+	OpenExisting  bool
+	PickTheme     bool
 	Done          bool
 	themeIndex    int
 	themes        []string
 	ThemeChanged  bool
 	NewTheme      string
+	Notice        string // Shown above the journal list, e.g. backup results
+	width         int
+
+	// Fixing a journal whose files can't be read
+	fixing       bool
+	fixSelected  int
+	locating     bool
+	pathInput    textinput.Model
+	pathError    string
+	Fix          JournalFix // Chosen for Selected
+	NewPath      string     // Where FixLocate found the journal
+	NewEncrypted bool       // Whether the journal at NewPath is encrypted
 }
 
 func NewSelectorModel(journals []model.JournalDB, currentTheme string) SelectorModel {
@@ -33,8 +75,19 @@ func NewSelectorModel(journals []model.JournalDB, currentTheme string) SelectorM
 		}
 	}
 
+	problems := make([]error, len(journals))
+	for i := range journals {
+		problems[i] = storage.CheckJournal(&journals[i])
+	}
+
+	pi := textinput.New()
+	pi.CharLimit = 256
+	pi.Width = 50
+
 	return SelectorModel{
 		journals:      journals,
+		problems:      problems,
+		pathInput:     pi,
 		selectedIndex: 0, // Most recent is first
 		themes:        themes,
 		themeIndex:    themeIndex,
@@ -47,8 +100,15 @@ func (m SelectorModel) Init() tea.Cmd {
 }
 
 func (m SelectorModel) Update(msg tea.Msg) (SelectorModel, tea.Cmd) {
-	// Total options = journals + "Create new journal"
-	totalOptions := len(m.journals) + 1
+	if m.locating {
+		return m.updateLocate(msg)
+	}
+	if m.fixing {
+		return m.updateFix(msg), nil
+	}
+
+	// Total options = journals + "Create new journal" + "Open existing journal file"
+	totalOptions := len(m.journals) + 2
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
@@ -82,12 +142,23 @@ func (m SelectorModel) Update(msg tea.Msg) (SelectorModel, tea.Cmd) {
 			theme.Set(m.NewTheme)
 			m.ThemeChanged = true
 		case "enter":
+			if m.selectedIndex < len(m.journals) && m.problems[m.selectedIndex] != nil {
+				// Offer to fix it rather than fail to open it
+				m.fixing = true
+				m.fixSelected = 0
+				return m, nil
+			}
 			if m.selectedIndex < len(m.journals) {
 				m.Selected = &m.journals[m.selectedIndex]
-			} else {
+			} else if m.selectedIndex == len(m.journals) {
 				m.CreateNew = true
+			} else {
+				m.OpenExisting = true
 			}
 			m.Done = true
+		case "t":
+			m.PickTheme = true
+			m.Done = true
 		case "q":
 			return m, tea.Quit
 		}
@@ -96,6 +167,113 @@ func (m SelectorModel) Update(msg tea.Msg) (SelectorModel, tea.Cmd) {
 	return m, nil
 }
 
+// updateFix handles choosing how to fix the selected journal
+func (m SelectorModel) updateFix(msg tea.Msg) SelectorModel {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m
+	}
+	switch key.String() {
+	case "up", "k":
+		if m.fixSelected > 0 {
+			m.fixSelected--
+		}
+	case "down", "j":
+		if m.fixSelected < len(m.fixOptions())-1 {
+			m.fixSelected++
+		}
+	case "esc":
+		m.fixing = false
+	case "enter":
+		fix := m.fixOptions()[m.fixSelected].fix
+		if fix == FixLocate {
+			j := m.journals[m.selectedIndex]
+			m.locating = true
+			m.pathError = ""
+			m.pathInput.Placeholder = "New path of the journal file"
+			if j.Format == model.FormatMarkdown {
+				m.pathInput.Placeholder = "New path of the journal folder"
+			}
+			m.pathInput.SetValue("")
+			m.pathInput.Focus()
+			return m
+		}
+		m.Fix = fix
+		m.Selected = &m.journals[m.selectedIndex]
+		m.Done = true
+	}
+	return m
+}
+
+// updateLocate handles entering where the selected journal's files are now
+func (m SelectorModel) updateLocate(msg tea.Msg) (SelectorModel, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		switch key.String() {
+		case "esc":
+			m.locating = false
+			m.pathInput.Blur()
+			return m, nil
+		case "enter":
+			if m.pathInput.Value() == "" {
+				return m, nil
+			}
+			if m.pathError = m.located(m.pathInput.Value()); m.pathError != "" {
+				return m, nil
+			}
+			m.Fix = FixLocate
+			m.Selected = &m.journals[m.selectedIndex]
+			m.Done = true
+			return m, nil
+		}
+	}
+	m.pathError = ""
+	var cmd tea.Cmd
+	m.pathInput, cmd = m.pathInput.Update(msg)
+	return m, cmd
+}
+
+// located checks that the selected journal's files are at path, setting
+// NewPath and NewEncrypted when they are, and otherwise returns what is
+// wrong
+func (m *SelectorModel) located(path string) string {
+	j := m.journals[m.selectedIndex]
+	abs, err := storage.AbsPath(path)
+	if err != nil {
+		return err.Error()
+	}
+	for i, other := range m.journals {
+		if i != m.selectedIndex && storage.SamePath(other.Path, abs) {
+			return "This journal is already in your list"
+		}
+	}
+	moved := j
+	moved.Path = abs
+	if err := storage.CheckJournal(&moved); errors.Is(err, storage.ErrPathNotFound) {
+		return "Nothing found at this path"
+	} else if err != nil {
+		return err.Error()
+	}
+
+	encrypted := false
+	if j.Format != model.FormatMarkdown {
+		exists, enc, err := storage.InspectJournalFile(abs)
+		if err != nil {
+			return err.Error()
+		}
+		if !exists {
+			return "This file is empty"
+		}
+		encrypted = enc
+	}
+	m.NewPath = abs
+	m.NewEncrypted = encrypted
+	return ""
+}
+
+func (m *SelectorModel) SetSize(width, height int) {
+	m.width = width
+}
+
 func (m SelectorModel) View() string {
 	t := theme.Current()
 	var b strings.Builder
@@ -109,6 +287,52 @@ func (m SelectorModel) View() string {
 	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
 	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
 	themeStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	noticeStyle := lipgloss.NewStyle().Foreground(t.Info).Italic(true)
+	warningStyle := lipgloss.NewStyle().Foreground(t.Warning).Bold(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+
+	if m.fixing {
+		j := m.journals[m.selectedIndex]
+		b.WriteString("\n")
+		b.WriteString(titleStyle.Render("Journal"))
+		b.WriteString("\n\n")
+		b.WriteString(warningStyle.Render(fmt.Sprintf("\"%s\" can't be opened: %s", j.Name, problemReason(j, m.problems[m.selectedIndex]))))
+		b.WriteString("\n")
+		b.WriteString("    ")
+		b.WriteString(pathStyle.Render(displayPath(j)))
+		b.WriteString("\n\n")
+
+		if m.locating {
+			b.WriteString(mutedStyle.Render("Where is it now?"))
+			b.WriteString("\n\n")
+			b.WriteString("    ")
+			b.WriteString(m.pathInput.View())
+			b.WriteString("\n\n")
+			if m.pathError != "" {
+				b.WriteString("    ")
+				b.WriteString(errorStyle.Render(m.pathError))
+				b.WriteString("\n\n")
+			}
+			b.WriteString(helpStyle.Render(keyStyle.Render("Enter") + " confirm | " + keyStyle.Render("Esc") + " back"))
+			return b.String()
+		}
+
+		for i, opt := range m.fixOptions() {
+			label := opt.label
+			if opt.fix == FixRecreate && j.Encrypted {
+				label += ", without encryption"
+			}
+			if i == m.fixSelected {
+				b.WriteString(selectedStyle.Render("> " + accentStyle.Render(label)))
+			} else {
+				b.WriteString(itemStyle.Render("  " + label))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+		b.WriteString(helpStyle.Render(keyStyle.Render("Up/Down") + " navigate | " + keyStyle.Render("Enter") + " select | " + keyStyle.Render("Esc") + " back"))
+		return b.String()
+	}
 
 	b.WriteString("\n")
 	b.WriteString(titleStyle.Render("Journal"))
@@ -117,9 +341,18 @@ func (m SelectorModel) View() string {
 	// Theme selector at top
 	b.WriteString(mutedStyle.Render("Theme: "))
 	b.WriteString(themeStyle.Render(m.themes[m.themeIndex]))
-	b.WriteString(mutedStyle.Render("  (use Left/Right to change)"))
+	if isCompact(m.width) {
+		b.WriteString(mutedStyle.Render("  (Left/Right, t)"))
+	} else {
+		b.WriteString(mutedStyle.Render("  (use Left/Right to change, t to preview all)"))
+	}
 	b.WriteString("\n\n")
 
+	if m.Notice != "" {
+		b.WriteString(noticeStyle.Render(m.Notice))
+		b.WriteString("\n\n")
+	}
+
 	b.WriteString(titleStyle.Render("Select Journal"))
 	b.WriteString("\n\n")
 
@@ -129,26 +362,52 @@ func (m SelectorModel) View() string {
 			name = "Unnamed Journal"
 		}
 
-		encrypted := ""
+		cursor := "  "
+		if i == m.selectedIndex {
+			cursor = "> "
+		}
+		pieces := []string{cursor + name}
 		if j.Encrypted {
-			encrypted = mutedStyle.Render(" [encrypted]")
+			pieces = append(pieces, mutedStyle.Render("[encrypted]"))
+		} else if j.Format == model.FormatMarkdown {
+			pieces = append(pieces, mutedStyle.Render("[markdown]"))
+		} else if j.Format == model.FormatPostgres {
+			pieces = append(pieces, mutedStyle.Render("[postgres]"))
 		}
-
-		lastOpened := ""
 		if !j.LastOpened.IsZero() {
-			lastOpened = mutedStyle.Render(fmt.Sprintf(" (last: %s)", j.LastOpened.Format("2006-01-02")))
+			pieces = append(pieces, mutedStyle.Render(fmt.Sprintf("(last: %s)", j.LastOpened.Format("2006-01-02"))))
+		}
+		if problem := m.problems[i]; problem != nil {
+			pieces = append(pieces, warningStyle.Render(journalProblem(problem)))
 		}
 
-		line := name + encrypted + lastOpened
-
+		compact := isCompact(m.width)
+		lineWidth := 0
+		if compact {
+			lineWidth = m.width - 2
+		}
+		line := joinWrapped(pieces, " ", lineWidth, "    ")
 		if i == m.selectedIndex {
-			b.WriteString(selectedStyle.Render("> " + line))
+			b.WriteString(selectedStyle.Render(line))
 		} else {
-			b.WriteString(itemStyle.Render("  " + line))
+			b.WriteString(itemStyle.Render(line))
 		}
 		b.WriteString("\n")
+		path := displayPath(j)
 		b.WriteString("    ")
-		b.WriteString(pathStyle.Render(j.Path))
+		if compact {
+			// The path and stats are stacked, the path cut to fit
+			b.WriteString(pathStyle.Render(fitWidth(path, m.width-4)))
+			if stats := journalStats(j.Stats); stats != "" {
+				b.WriteString("\n    ")
+				b.WriteString(mutedStyle.Render(fitWidth(stats, m.width-4)))
+			}
+		} else {
+			b.WriteString(pathStyle.Render(path))
+			if stats := journalStats(j.Stats); stats != "" {
+				b.WriteString(mutedStyle.Render("  " + stats))
+			}
+		}
 		b.WriteString("\n\n")
 	}
 
@@ -159,9 +418,104 @@ func (m SelectorModel) View() string {
 	} else {
 		b.WriteString(itemStyle.Render("  " + newOption))
 	}
+	b.WriteString("\n")
+
+	// Open existing option
+	openOption := "Open existing journal file"
+	if m.selectedIndex == len(m.journals)+1 {
+		b.WriteString(selectedStyle.Render("> " + accentStyle.Render(openOption)))
+	} else {
+		b.WriteString(itemStyle.Render("  " + openOption))
+	}
 	b.WriteString("\n\n")
 
-	b.WriteString(helpStyle.Render(keyStyle.Render("Up/Down") + " navigate | " + keyStyle.Render("Left/Right") + " theme | " + keyStyle.Render("Enter") + " select | " + keyStyle.Render("q") + " quit"))
+	parts := []string{
+		keyStyle.Render("Up/Down") + " navigate",
+		keyStyle.Render("Left/Right") + " theme",
+		keyStyle.Render("t") + " all themes",
+		keyStyle.Render("Enter") + " select",
+		keyStyle.Render("q") + " quit",
+	}
+	b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
 
 	return b.String()
 }
+
+// SetStats shows newly counted stats for the journal at path
+func (m *SelectorModel) SetStats(path string, stats model.Stats) {
+	for i := range m.journals {
+		if m.journals[i].Path == path {
+			m.journals[i].Stats = &stats
+		}
+	}
+}
+
+// journalStats describes a journal's cached stats, or returns an empty
+// string before they have been counted
+func journalStats(stats *model.Stats) string {
+	if stats == nil {
+		return ""
+	}
+	parts := []string{fmt.Sprintf("%d entries", stats.Entries)}
+	if stats.Entries == 1 {
+		parts[0] = "1 entry"
+	}
+	if stats.LastEntry != "" {
+		parts = append(parts, "latest "+stats.LastEntry)
+	}
+	if stats.Size > 0 {
+		parts = append(parts, storage.FormatFileSize(stats.Size))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// fixOptions returns the choices offered for the selected journal.
+// Recreating it is only offered when its files are gone, as it would
+// otherwise replace them.
+func (m SelectorModel) fixOptions() []fixOption {
+	if errors.Is(m.problems[m.selectedIndex], storage.ErrPathNotFound) {
+		return fixOptions
+	}
+	var options []fixOption
+	for _, opt := range fixOptions {
+		if opt.fix != FixRecreate {
+			options = append(options, opt)
+		}
+	}
+	return options
+}
+
+// journalProblem is the badge shown for a journal that can't be opened
+func journalProblem(err error) string {
+	switch {
+	case errors.Is(err, storage.ErrPathNotFound):
+		return "[missing]"
+	case errors.Is(err, storage.ErrPermission):
+		return "[unreadable]"
+	}
+	return "[can't open]"
+}
+
+// problemReason explains why journal j can't be opened
+func problemReason(j model.JournalDB, err error) string {
+	what := "file"
+	if j.Format == model.FormatMarkdown {
+		what = "folder"
+	}
+	switch {
+	case errors.Is(err, storage.ErrPathNotFound):
+		return "its " + what + " was moved or deleted"
+	case errors.Is(err, storage.ErrPermission):
+		return "you don't have permission to read its " + what
+	}
+	return err.Error()
+}
+
+// displayPath returns where journal is kept, for showing on screen: its
+// path, or for a server its connection string with the password hidden
+func displayPath(journal model.JournalDB) string {
+	if journal.Format == model.FormatPostgres {
+		return storage.RedactDSN(journal.Path)
+	}
+	return journal.Path
+}
@@ -1,9 +1,11 @@
 package ui
 
 import (
+	"fmt"
 	"strings"
 
 	"journal/internal/model"
+	"journal/internal/storage"
 	"journal/internal/theme"
 
 	"github.com/charmbracelet/bubbles/textinput"
@@ -16,17 +18,34 @@ type settingsField int
 const (
 	settingsFieldPath settingsField = iota
 	settingsFieldMigrate
+	settingsFieldMood
+	settingsFieldBackup
+	settingsFieldAutosave
+	settingsFieldAwaySave
+	settingsFieldLock
+	settingsFieldRestore
+	settingsFieldExport
+	settingsFieldEncryption
 )
 
 type SettingsModel struct {
-	config        *model.Config
-	activeJournal *model.JournalDB
-	pathInput     textinput.Model
-	focusedField  settingsField
-	Migrate       bool
-	DBPath        string
-	Saved         bool
-	Cancelled     bool
+	config          *model.Config
+	activeJournal   *model.JournalDB
+	pathInput       textinput.Model
+	focusedField    settingsField
+	Migrate         bool
+	MoodTracking    bool
+	BackupSchedule  string
+	AutosaveMinutes int
+	AwaySaveMinutes int
+	LockAfterDays   int // Of the active journal
+	DBPath          string
+	Saved           bool
+	Cancelled       bool
+	OpenRestore     bool // Open the restore-from-backup wizard
+	OpenExport      bool // Open the export of the whole journal
+	OpenEncryption  bool // Open the encrypt/decrypt conversion
+	Error           string
 }
 
 func NewSettingsModel(config *model.Config, activeJournal *model.JournalDB) SettingsModel {
@@ -36,13 +55,23 @@ func NewSettingsModel(config *model.Config, activeJournal *model.JournalDB) Sett
 	ti.Width = 50
 	ti.Focus()
 
+	lockAfterDays := 0
+	if activeJournal != nil {
+		lockAfterDays = activeJournal.LockAfterDays
+	}
+
 	return SettingsModel{
-		config:        config,
-		activeJournal: activeJournal,
-		pathInput:     ti,
-		focusedField:  settingsFieldPath,
-		Migrate:       true,
-		DBPath:        config.ActiveJournal,
+		config:          config,
+		activeJournal:   activeJournal,
+		pathInput:       ti,
+		focusedField:    settingsFieldPath,
+		Migrate:         true,
+		MoodTracking:    config.MoodTracking,
+		BackupSchedule:  config.BackupSchedule,
+		AutosaveMinutes: config.AutosaveMinutes,
+		AwaySaveMinutes: config.AwaySaveMinutes,
+		LockAfterDays:   lockAfterDays,
+		DBPath:          config.ActiveJournal,
 	}
 }
 
@@ -55,22 +84,64 @@ func (m SettingsModel) Update(msg tea.Msg) (SettingsModel, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		m.Error = ""
 		switch msg.String() {
 		case "tab", "shift+tab":
-			if m.focusedField == settingsFieldPath {
-				m.focusedField = settingsFieldMigrate
-				m.pathInput.Blur()
+			if msg.String() == "tab" {
+				m.focusedField = (m.focusedField + 1) % (settingsFieldEncryption + 1)
 			} else {
-				m.focusedField = settingsFieldPath
+				m.focusedField = (m.focusedField + settingsFieldEncryption) % (settingsFieldEncryption + 1)
+			}
+			if m.focusedField == settingsFieldPath {
 				m.pathInput.Focus()
 				return m, textinput.Blink
 			}
+			m.pathInput.Blur()
 			return m, nil
 
 		case "enter", " ":
-			if m.focusedField == settingsFieldMigrate {
+			switch m.focusedField {
+			case settingsFieldMigrate:
 				m.Migrate = !m.Migrate
 				return m, nil
+			case settingsFieldMood:
+				m.MoodTracking = !m.MoodTracking
+				return m, nil
+			case settingsFieldBackup:
+				m.BackupSchedule = nextBackupSchedule(m.BackupSchedule)
+				return m, nil
+			case settingsFieldAutosave:
+				m.AutosaveMinutes = nextMinutes(autosaveChoices, m.AutosaveMinutes)
+				return m, nil
+			case settingsFieldAwaySave:
+				m.AwaySaveMinutes = nextMinutes(awaySaveChoices, m.AwaySaveMinutes)
+				return m, nil
+			case settingsFieldLock:
+				m.LockAfterDays = nextMinutes(lockChoices, m.LockAfterDays)
+				return m, nil
+			case settingsFieldRestore:
+				if m.markdown() {
+					m.Error = "Markdown journals are plain files; back up and restore the folder with your usual tools"
+				} else if m.postgres() {
+					m.Error = "PostgreSQL journals are backed up and restored on the server"
+				} else if m.activeJournal != nil {
+					m.OpenRestore = true
+				}
+				return m, nil
+			case settingsFieldExport:
+				if m.activeJournal != nil {
+					m.OpenExport = true
+				}
+				return m, nil
+			case settingsFieldEncryption:
+				if m.markdown() {
+					m.Error = "Markdown journals can't be encrypted"
+				} else if m.postgres() {
+					m.Error = "PostgreSQL journals can't be encrypted; use an encrypted connection (sslmode=verify-full)"
+				} else if m.activeJournal != nil {
+					m.OpenEncryption = true
+				}
+				return m, nil
 			}
 
 		case "esc":
@@ -78,7 +149,22 @@ func (m SettingsModel) Update(msg tea.Msg) (SettingsModel, tea.Cmd) {
 			return m, nil
 
 		case "ctrl+s":
+			if m.markdown() && m.pathInput.Value() != m.config.ActiveJournal {
+				m.Error = "To move a Markdown journal, move its folder and add it again from the journal selector"
+				return m, nil
+			}
+			if m.postgres() && m.pathInput.Value() != m.config.ActiveJournal {
+				m.Error = "To use another server, add it as a new journal from the journal selector"
+				return m, nil
+			}
 			m.DBPath = m.pathInput.Value()
+			if m.DBPath != m.config.ActiveJournal {
+				// Relative paths in the config file mean the config
+				// directory, so resolve a typed one here
+				if abs, err := storage.AbsPath(m.DBPath); err == nil {
+					m.DBPath = abs
+				}
+			}
 			m.Saved = true
 			return m, nil
 		}
@@ -91,6 +177,54 @@ func (m SettingsModel) Update(msg tea.Msg) (SettingsModel, tea.Cmd) {
 	return m, cmd
 }
 
+// markdown reports whether the active journal is a folder of Markdown files
+func (m SettingsModel) markdown() bool {
+	return m.activeJournal != nil && m.activeJournal.Format == model.FormatMarkdown
+}
+
+// postgres reports whether the active journal is on a PostgreSQL server
+func (m SettingsModel) postgres() bool {
+	return m.activeJournal != nil && m.activeJournal.Format == model.FormatPostgres
+}
+
+// nextBackupSchedule cycles through the available backup schedules
+func nextBackupSchedule(current string) string {
+	for i, s := range storage.BackupSchedules {
+		if s == current {
+			return storage.BackupSchedules[(i+1)%len(storage.BackupSchedules)]
+		}
+	}
+	return storage.BackupOff
+}
+
+// autosaveChoices and awaySaveChoices are the minutes the editor's
+// autosave and save-when-away settings cycle through, 0 for off
+var (
+	autosaveChoices = []int{0, 1, 2, 5, 10, 15, 30}
+	awaySaveChoices = []int{0, 1, 5, 10, 30, 60}
+)
+
+// nextMinutes cycles through choices, going back to off after the last
+func nextMinutes(choices []int, current int) int {
+	for i, c := range choices {
+		if c == current {
+			return choices[(i+1)%len(choices)]
+		}
+	}
+	return 0
+}
+
+// formatMinutes renders a setting in minutes, 0 being off
+func formatMinutes(minutes int) string {
+	switch minutes {
+	case 0:
+		return "off"
+	case 1:
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
+
 func (m SettingsModel) View() string {
 	t := theme.Current()
 	var b strings.Builder
@@ -106,6 +240,7 @@ func (m SettingsModel) View() string {
 	checkmarkStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
 	dividerStyle := lipgloss.NewStyle().Foreground(t.Muted)
 	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
 
 	b.WriteString("\n")
 	b.WriteString(titleStyle.Render("Journal Settings"))
@@ -118,6 +253,12 @@ func (m SettingsModel) View() string {
 		if m.activeJournal.Encrypted {
 			b.WriteString(mutedStyle.Render(" [encrypted]"))
 		}
+		if m.markdown() {
+			b.WriteString(mutedStyle.Render(" [markdown]"))
+		}
+		if m.postgres() {
+			b.WriteString(mutedStyle.Render(" [postgres]"))
+		}
 		b.WriteString("\n\n")
 	}
 
@@ -125,10 +266,18 @@ func (m SettingsModel) View() string {
 	b.WriteString("\n\n")
 
 	// Path input
-	b.WriteString(labelStyle.Render("Current database path:"))
+	current := m.config.ActiveJournal
+	if m.markdown() {
+		b.WriteString(labelStyle.Render("Current folder:"))
+	} else if m.postgres() {
+		b.WriteString(labelStyle.Render("Current server:"))
+		current = storage.RedactDSN(current)
+	} else {
+		b.WriteString(labelStyle.Render("Current database path:"))
+	}
 	b.WriteString("\n")
 	b.WriteString("  ")
-	b.WriteString(valueStyle.Render(m.config.ActiveJournal))
+	b.WriteString(valueStyle.Render(current))
 	b.WriteString("\n\n")
 
 	pathLabel := "New path:"
@@ -155,9 +304,100 @@ func (m SettingsModel) View() string {
 	}
 	b.WriteString("\n\n")
 
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", 60)))
+	b.WriteString("\n\n")
+
+	// Mood tracking checkbox (application-wide)
+	moodCheckbox := "[ ]"
+	if m.MoodTracking {
+		moodCheckbox = "[" + checkmarkStyle.Render("x") + "]"
+	}
+	moodLabel := moodCheckbox + " Track mood in the editor " + mutedStyle.Render("(all journals)")
+	if m.focusedField == settingsFieldMood {
+		b.WriteString(checkboxSelectedStyle.Render("> " + moodLabel))
+	} else {
+		b.WriteString(checkboxStyle.Render("  " + moodLabel))
+	}
+	b.WriteString("\n")
+
+	// Scheduled backups (application-wide)
+	schedule := m.BackupSchedule
+	if schedule == storage.BackupOff {
+		schedule = "off"
+	}
+	backupLabel := "Automatic backups: " + valueStyle.Render("<"+schedule+">") + " " + mutedStyle.Render("(all journals)")
+	if m.focusedField == settingsFieldBackup {
+		b.WriteString(checkboxSelectedStyle.Render("> " + backupLabel))
+	} else {
+		b.WriteString(checkboxStyle.Render("  " + backupLabel))
+	}
+	b.WriteString("\n")
+
+	autosaveLabel := "Autosave while editing: " + valueStyle.Render("<every "+formatMinutes(m.AutosaveMinutes)+">")
+	if m.AutosaveMinutes == 0 {
+		autosaveLabel = "Autosave while editing: " + valueStyle.Render("<off>")
+	}
+	if m.focusedField == settingsFieldAutosave {
+		b.WriteString(checkboxSelectedStyle.Render("> " + autosaveLabel))
+	} else {
+		b.WriteString(checkboxStyle.Render("  " + autosaveLabel))
+	}
+	b.WriteString("\n")
+
+	awayLabel := "Save when away from the terminal: " + valueStyle.Render("<after "+formatMinutes(m.AwaySaveMinutes)+">")
+	if m.AwaySaveMinutes == 0 {
+		awayLabel = "Save when away from the terminal: " + valueStyle.Render("<off>")
+	}
+	if m.focusedField == settingsFieldAwaySave {
+		b.WriteString(checkboxSelectedStyle.Render("> " + awayLabel))
+	} else {
+		b.WriteString(checkboxStyle.Render("  " + awayLabel))
+	}
+	b.WriteString("\n")
+
+	lockLabel := "Lock entries older than: " + valueStyle.Render("<"+formatDays(m.LockAfterDays)+">") + " " + mutedStyle.Render("(this journal)")
+	if m.focusedField == settingsFieldLock {
+		b.WriteString(checkboxSelectedStyle.Render("> " + lockLabel))
+	} else {
+		b.WriteString(checkboxStyle.Render("  " + lockLabel))
+	}
+	b.WriteString("\n")
+
+	restoreLabel := "Restore from backup..."
+	if m.focusedField == settingsFieldRestore {
+		b.WriteString(checkboxSelectedStyle.Render("> " + restoreLabel))
+	} else {
+		b.WriteString(checkboxStyle.Render("  " + restoreLabel))
+	}
+	b.WriteString("\n")
+
+	exportLabel := "Export journal..."
+	if m.focusedField == settingsFieldExport {
+		b.WriteString(checkboxSelectedStyle.Render("> " + exportLabel))
+	} else {
+		b.WriteString(checkboxStyle.Render("  " + exportLabel))
+	}
+	b.WriteString("\n")
+
+	encryptionLabel := "Encrypt journal..."
+	if m.activeJournal != nil && m.activeJournal.Encrypted {
+		encryptionLabel = "Decrypt journal permanently..."
+	}
+	if m.focusedField == settingsFieldEncryption {
+		b.WriteString(checkboxSelectedStyle.Render("> " + encryptionLabel))
+	} else {
+		b.WriteString(checkboxStyle.Render("  " + encryptionLabel))
+	}
+	b.WriteString("\n\n")
+
+	if m.Error != "" {
+		b.WriteString(errorStyle.Render(m.Error))
+		b.WriteString("\n\n")
+	}
+
 	var parts []string
 	parts = append(parts, keyStyle.Render("Tab")+" switch fields")
-	parts = append(parts, keyStyle.Render("Space/Enter")+" toggle")
+	parts = append(parts, keyStyle.Render("Space/Enter")+" toggle/change")
 	parts = append(parts, keyStyle.Render("Ctrl+S")+" save")
 	parts = append(parts, keyStyle.Render("Esc")+" cancel")
 
@@ -1,12 +1,14 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 
+	"journal/internal/model"
 	"journal/internal/storage"
 	"journal/internal/theme"
 
@@ -19,10 +21,16 @@ type setupStep int
 
 const (
 	stepEnterName setupStep = iota
+	stepChooseFormat
 	stepChoosePath
+	stepCreateDir
+	stepExistingFile
 	stepChooseEncryption
+	stepEncryptionMode
 	stepEnterPassword
 	stepConfirmPassword
+	stepPasswordHint
+	stepRecoverySheet
 )
 
 type SetupModel struct {
@@ -31,13 +39,27 @@ type SetupModel struct {
 	nameInput       textinput.Model
 	passwordInput   textinput.Model
 	confirmInput    textinput.Model
+	hintInput       textinput.Model
+	sheetInput      textinput.Model
+	sheetOpt        int
 	selectedOpt     int
 	encryptSelected int
+	modeSelected    int
+	formatSelected  int
+	createDirOpt    int
+	existingOpt     int
 	showPathInput   bool
 	DBPath          string
 	Name            string
+	Format          string // model.FormatSQLite, FormatMarkdown, or FormatPostgres
 	Encrypt         bool
+	EncryptionMode  string // model.EncryptionWholeFile or EncryptionSession
+	Existing        bool   // DBPath is a journal file to open rather than create
+	Overwrite       bool   // Replace the journal file at DBPath
+	openExisting    bool   // Adding a journal file or folder that isn't in the config
 	Password        string
+	PasswordHint    string
+	RecoverySheet   string // Where to write the recovery sheet, or empty for none
 	Done            bool
 	Error           string
 	defaultPath     string
@@ -71,6 +93,16 @@ func NewSetupModel(existingPaths ...string) SetupModel {
 	ci.CharLimit = 256
 	ci.Width = 30
 
+	hi := textinput.New()
+	hi.Placeholder = "e.g. the song from the wedding"
+	hi.CharLimit = 100
+	hi.Width = 50
+
+	si := textinput.New()
+	si.Placeholder = "Enter path..."
+	si.CharLimit = 256
+	si.Width = 50
+
 	baseDir, _ := storage.GetConfigPath()
 	baseDir = filepath.Dir(baseDir)
 
@@ -80,12 +112,52 @@ func NewSetupModel(existingPaths ...string) SetupModel {
 		nameInput:     ni,
 		passwordInput: pi,
 		confirmInput:  ci,
+		hintInput:     hi,
+		sheetInput:    si,
 		selectedOpt:   0,
 		baseDir:       baseDir,
 		existingPaths: existingPaths,
 	}
 }
 
+// encryptionMode is a way of decrypting an encrypted journal while it is
+// open, as offered at setup
+type encryptionMode struct {
+	mode      string // model.EncryptionWholeFile or EncryptionSession
+	label     string
+	tradeoffs []string
+	available bool
+}
+
+var encryptionModes = []encryptionMode{
+	{
+		mode:  model.EncryptionWholeFile,
+		label: "Whole file",
+		tradeoffs: []string{
+			"Decrypted into memory for each action, and dropped right after",
+			"Uses memory only during an action, but every action waits for the whole journal to be decrypted",
+			"Best for small journals",
+		},
+		available: true,
+	},
+	{
+		mode:  model.EncryptionSession,
+		label: "Session",
+		tradeoffs: []string{
+			"Decrypted into memory once when unlocked, so browsing and searching are instant",
+			"Uses memory in proportion to the journal, and keeps it readable there until it is closed",
+			"Saving still encrypts the whole journal; changes synced from elsewhere are picked up",
+		},
+		available: true,
+	},
+	{
+		label: "Page-level",
+		tradeoffs: []string{
+			"Only the parts of the journal in use are decrypted, and only changed parts are saved",
+		},
+	},
+}
+
 // sanitizeFilename converts a journal name to a safe filename
 func sanitizeFilename(name string) string {
 	// Convert to lowercase
@@ -108,13 +180,18 @@ func sanitizeFilename(name string) string {
 
 func (m *SetupModel) generateDefaultPath() {
 	base := sanitizeFilename(m.Name)
-	candidate := filepath.Join(m.baseDir, base+".db")
+	ext := ".db"
+	if m.Format == model.FormatMarkdown {
+		// A folder of entry files
+		ext = ""
+	}
+	candidate := filepath.Join(m.baseDir, base+ext)
 
 	// Check against both existing config paths and files on disk
 	suffix := 0
 	for m.pathExists(candidate) {
 		suffix++
-		candidate = filepath.Join(m.baseDir, fmt.Sprintf("%s_%d.db", base, suffix))
+		candidate = filepath.Join(m.baseDir, fmt.Sprintf("%s_%d%s", base, suffix, ext))
 	}
 
 	m.defaultPath = candidate
@@ -123,12 +200,8 @@ func (m *SetupModel) generateDefaultPath() {
 func (m *SetupModel) pathExists(path string) bool {
 	// Check against existing journal paths in config
 	for _, p := range m.existingPaths {
-		expanded, err := storage.ExpandPath(p)
-		if err == nil {
-			candidateExpanded, err2 := storage.ExpandPath(path)
-			if err2 == nil && expanded == candidateExpanded {
-				return true
-			}
+		if storage.SamePath(p, path) {
+			return true
 		}
 	}
 	// Also check if file exists on disk
@@ -141,6 +214,137 @@ func (m *SetupModel) pathExists(path string) bool {
 	return false
 }
 
+// pathChosen moves on from the path step. Markdown and PostgreSQL journals
+// are never encrypted, so they are done; they are also opened rather than
+// replaced when they already exist. A journal file already at the path
+// moves to the step asking what to do with it.
+func (m *SetupModel) pathChosen() {
+	m.Existing, m.Overwrite = false, false
+	if m.Format != model.FormatSQLite {
+		m.Done = true
+		return
+	}
+	for _, p := range m.existingPaths {
+		if storage.SamePath(p, m.DBPath) {
+			m.pathError("This journal is already in your list")
+			return
+		}
+	}
+	exists, encrypted, err := storage.InspectJournalFile(m.DBPath)
+	if err != nil {
+		m.pathError(err.Error())
+		return
+	}
+	if exists {
+		m.Encrypt = encrypted
+		m.existingOpt = 0
+		m.step = stepExistingFile
+		return
+	}
+	m.step = stepChooseEncryption
+}
+
+// OpenExisting makes setup add a journal file or Markdown folder that
+// already exists, such as one restored from a backup or copied from
+// another machine, rather than create one
+func (m *SetupModel) OpenExisting() {
+	m.openExisting = true
+}
+
+// askExistingPath moves to the input for the path of an existing journal
+func (m *SetupModel) askExistingPath() tea.Cmd {
+	m.step = stepChoosePath
+	m.showPathInput = true
+	m.textInput.Placeholder = "Path of a journal file or Markdown folder"
+	m.textInput.SetValue("")
+	m.textInput.Focus()
+	return textinput.Blink
+}
+
+// existingChosen adds the existing journal at DBPath: a folder is a
+// Markdown journal and a file a database, whose encryption is detected
+// and whose password is asked for when it has one
+func (m *SetupModel) existingChosen() tea.Cmd {
+	for _, p := range m.existingPaths {
+		if storage.SamePath(p, m.DBPath) {
+			m.pathError("This journal is already in your list")
+			return nil
+		}
+	}
+	info, err := os.Stat(m.DBPath)
+	if err != nil {
+		m.pathError("Nothing found at this path")
+		return nil
+	}
+	m.showPathInput = false
+	m.textInput.Blur()
+	if info.IsDir() {
+		// Creating a Markdown journal picks up the files already there
+		m.Format = model.FormatMarkdown
+		m.Done = true
+		return nil
+	}
+
+	exists, encrypted, err := storage.InspectJournalFile(m.DBPath)
+	if err != nil {
+		m.pathError(err.Error())
+		return nil
+	}
+	if !exists {
+		m.pathError("This file is empty")
+		return nil
+	}
+	m.Format = model.FormatSQLite
+	m.Existing = true
+	m.Encrypt = encrypted
+	if !encrypted {
+		m.Done = true
+		return nil
+	}
+	m.step = stepEnterPassword
+	m.passwordInput.Focus()
+	return textinput.Blink
+}
+
+// pathError returns to the custom path input, showing msg
+func (m *SetupModel) pathError(msg string) {
+	m.Error = msg
+	m.step = stepChoosePath
+	m.showPathInput = true
+	m.textInput.SetValue(m.DBPath)
+	m.textInput.Focus()
+}
+
+// checkDir checks the folder a custom path would be created in, reporting
+// whether setup can go on. A missing folder moves to the step offering to
+// create it, and other problems are shown as errors.
+func (m *SetupModel) checkDir() bool {
+	err := storage.CheckJournalDir(m.DBPath)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, storage.ErrPathNotFound):
+		m.createDirOpt = 0
+		m.step = stepCreateDir
+	case errors.Is(err, storage.ErrPermission):
+		m.Error = "Can't create files in " + filepath.Dir(m.DBPath)
+	default:
+		m.Error = err.Error()
+	}
+	return false
+}
+
+// defaultSheetPath is where the recovery sheet is offered to be saved: the
+// home folder rather than beside the journal, so the two aren't copied
+// together
+func (m SetupModel) defaultSheetPath() string {
+	name := sanitizeFilename(m.Name) + "-recovery-sheet.txt"
+	if home, err := storage.ExpandPath("~/"); err == nil {
+		return filepath.Join(home, name)
+	}
+	return name
+}
+
 func (m SetupModel) Init() tea.Cmd {
 	return textinput.Blink
 }
@@ -158,31 +362,94 @@ func (m SetupModel) Update(msg tea.Msg) (SetupModel, tea.Cmd) {
 				if m.Name == "" {
 					m.Name = "My Journal"
 				}
-				m.generateDefaultPath()
-				m.step = stepChoosePath
+				m.step = stepChooseFormat
 				m.nameInput.Blur()
+				if m.openExisting {
+					return m, m.askExistingPath()
+				}
 				return m, nil
 			}
 			m.nameInput, cmd = m.nameInput.Update(msg)
 			return m, cmd
 
+		case stepChooseFormat:
+			switch msg.String() {
+			case "up", "k":
+				if m.formatSelected > 0 {
+					m.formatSelected--
+				}
+			case "down", "j":
+				if m.formatSelected < 3 {
+					m.formatSelected++
+				}
+			case "enter":
+				if m.formatSelected == 3 {
+					m.openExisting = true
+					return m, m.askExistingPath()
+				}
+				m.step = stepChoosePath
+				switch m.formatSelected {
+				case 0:
+					m.Format = model.FormatSQLite
+				case 1:
+					m.Format = model.FormatMarkdown
+				case 2:
+					// A server has no default location; ask for its address
+					m.Format = model.FormatPostgres
+					m.showPathInput = true
+					m.textInput.Placeholder = "postgres://user@host/journal"
+					m.textInput.Focus()
+					return m, textinput.Blink
+				}
+				m.textInput.Placeholder = "Enter path..."
+				m.generateDefaultPath()
+				return m, nil
+			case "esc":
+				m.step = stepEnterName
+				m.nameInput.Focus()
+				return m, textinput.Blink
+			}
+
 		case stepChoosePath:
 			if m.showPathInput {
 				switch msg.String() {
 				case "enter":
+					if m.textInput.Value() != "" && m.openExisting {
+						m.DBPath = m.textInput.Value()
+						if abs, err := storage.AbsPath(m.DBPath); err == nil {
+							m.DBPath = abs
+						}
+						return m, m.existingChosen()
+					}
 					if m.textInput.Value() != "" {
 						m.DBPath = m.textInput.Value()
-						m.step = stepChooseEncryption
+						if m.Format != model.FormatPostgres {
+							// Relative paths in the config file mean the config
+							// directory, so resolve a typed one here
+							if abs, err := storage.AbsPath(m.DBPath); err == nil {
+								m.DBPath = abs
+							}
+							if !m.checkDir() {
+								return m, nil
+							}
+						}
 						m.showPathInput = false
 						m.textInput.Blur()
-						return m, nil
+						m.pathChosen()
+						return m, textinput.Blink
 					}
 					return m, nil
 				case "esc":
+					m.Error = ""
 					m.showPathInput = false
 					m.textInput.Blur()
+					if m.Format == model.FormatPostgres || m.openExisting {
+						m.openExisting = false
+						m.step = stepChooseFormat
+					}
 					return m, nil
 				}
+				m.Error = ""
 				m.textInput, cmd = m.textInput.Update(msg)
 				return m, cmd
 			}
@@ -199,7 +466,7 @@ func (m SetupModel) Update(msg tea.Msg) (SetupModel, tea.Cmd) {
 			case "enter":
 				if m.selectedOpt == 0 {
 					m.DBPath = m.defaultPath
-					m.step = stepChooseEncryption
+					m.pathChosen()
 					return m, nil
 				} else {
 					m.showPathInput = true
@@ -207,11 +474,71 @@ func (m SetupModel) Update(msg tea.Msg) (SetupModel, tea.Cmd) {
 					return m, textinput.Blink
 				}
 			case "esc":
-				m.step = stepEnterName
-				m.nameInput.Focus()
+				m.step = stepChooseFormat
+				return m, nil
+			}
+
+		case stepCreateDir:
+			switch msg.String() {
+			case "up", "k":
+				m.createDirOpt = 0
+			case "down", "j":
+				m.createDirOpt = 1
+			case "enter":
+				if m.createDirOpt == 1 {
+					m.step = stepChoosePath
+					return m, textinput.Blink
+				}
+				if err := storage.CreateJournalDir(m.DBPath); err != nil {
+					m.Error = err.Error()
+					m.step = stepChoosePath
+					return m, textinput.Blink
+				}
+				m.showPathInput = false
+				m.textInput.Blur()
+				m.pathChosen()
+			case "esc":
+				m.step = stepChoosePath
 				return m, textinput.Blink
 			}
 
+		case stepExistingFile:
+			switch msg.String() {
+			case "up", "k":
+				if m.existingOpt > 0 {
+					m.existingOpt--
+				}
+			case "down", "j":
+				if m.existingOpt < 2 {
+					m.existingOpt++
+				}
+			case "enter":
+				switch m.existingOpt {
+				case 0:
+					m.Existing = true
+					if !m.Encrypt {
+						m.Done = true
+						return m, nil
+					}
+					m.step = stepEnterPassword
+					m.passwordInput.Focus()
+					return m, textinput.Blink
+				case 1:
+					m.step = stepChoosePath
+					m.showPathInput = true
+					m.textInput.SetValue(m.DBPath)
+					m.textInput.Focus()
+					return m, textinput.Blink
+				case 2:
+					m.Overwrite = true
+					m.Encrypt = false
+					m.step = stepChooseEncryption
+				}
+			case "esc":
+				m.step = stepChoosePath
+				return m, nil
+			}
+
 		case stepChooseEncryption:
 			switch msg.String() {
 			case "up", "k":
@@ -228,18 +555,59 @@ func (m SetupModel) Update(msg tea.Msg) (SetupModel, tea.Cmd) {
 					m.Done = true
 				} else {
 					m.Encrypt = true
-					m.step = stepEnterPassword
-					m.passwordInput.Focus()
-					return m, textinput.Blink
+					m.step = stepEncryptionMode
 				}
 			case "esc":
 				m.step = stepChoosePath
+				if m.Overwrite {
+					m.step = stepExistingFile
+				}
 				return m, nil
 			}
 
+		case stepEncryptionMode:
+			switch msg.String() {
+			case "up", "k":
+				if m.modeSelected > 0 {
+					m.modeSelected--
+				}
+			case "down", "j":
+				if m.modeSelected < len(encryptionModes)-1 {
+					m.modeSelected++
+				}
+			case "enter":
+				mode := encryptionModes[m.modeSelected]
+				if !mode.available {
+					m.Error = mode.label + " encryption isn't available yet"
+					return m, nil
+				}
+				m.EncryptionMode = mode.mode
+				m.step = stepEnterPassword
+				m.passwordInput.Focus()
+				return m, textinput.Blink
+			case "esc":
+				m.step = stepChooseEncryption
+			}
+			m.Error = ""
+			return m, nil
+
 		case stepEnterPassword:
 			switch msg.String() {
 			case "enter":
+				if m.passwordInput.Value() != "" && m.Existing {
+					// Check the password now rather than after setup
+					if err := storage.UnlockJournal(m.DBPath, m.passwordInput.Value()); err != nil {
+						m.Error = err.Error()
+						if errors.Is(err, storage.ErrInvalidPassword) {
+							m.Error = "Invalid password"
+						}
+						m.passwordInput.SetValue("")
+						return m, nil
+					}
+					m.Password = m.passwordInput.Value()
+					m.Done = true
+					return m, nil
+				}
 				if m.passwordInput.Value() != "" {
 					m.Password = m.passwordInput.Value()
 					m.step = stepConfirmPassword
@@ -248,7 +616,16 @@ func (m SetupModel) Update(msg tea.Msg) (SetupModel, tea.Cmd) {
 				}
 				return m, nil
 			case "esc":
-				m.step = stepChooseEncryption
+				m.step = stepEncryptionMode
+				if m.Existing {
+					m.Existing = false
+					m.step = stepExistingFile
+				}
+				if m.openExisting {
+					m.step = stepChoosePath
+					m.showPathInput = true
+					m.textInput.Focus()
+				}
 				m.passwordInput.SetValue("")
 				m.passwordInput.Blur()
 				return m, nil
@@ -261,7 +638,10 @@ func (m SetupModel) Update(msg tea.Msg) (SetupModel, tea.Cmd) {
 			switch msg.String() {
 			case "enter":
 				if m.confirmInput.Value() == m.Password {
-					m.Done = true
+					m.step = stepPasswordHint
+					m.confirmInput.Blur()
+					m.hintInput.Focus()
+					return m, textinput.Blink
 				} else {
 					m.Error = "Passwords do not match"
 					m.confirmInput.SetValue("")
@@ -277,6 +657,68 @@ func (m SetupModel) Update(msg tea.Msg) (SetupModel, tea.Cmd) {
 			m.Error = ""
 			m.confirmInput, cmd = m.confirmInput.Update(msg)
 			return m, cmd
+
+		case stepPasswordHint:
+			switch msg.String() {
+			case "enter":
+				hint := strings.TrimSpace(m.hintInput.Value())
+				if hint != "" && strings.Contains(strings.ToLower(hint), strings.ToLower(m.Password)) {
+					m.Error = "The hint can't contain the password"
+					return m, nil
+				}
+				m.PasswordHint = hint
+				m.step = stepRecoverySheet
+				m.sheetOpt = 0
+				m.hintInput.Blur()
+				if m.sheetInput.Value() == "" {
+					m.sheetInput.SetValue(m.defaultSheetPath())
+				}
+				m.sheetInput.Focus()
+				return m, textinput.Blink
+			case "esc":
+				m.step = stepConfirmPassword
+				m.confirmInput.SetValue("")
+				m.hintInput.Blur()
+				m.confirmInput.Focus()
+				return m, textinput.Blink
+			}
+			m.Error = ""
+			m.hintInput, cmd = m.hintInput.Update(msg)
+			return m, cmd
+
+		case stepRecoverySheet:
+			switch msg.String() {
+			case "up", "down":
+				m.sheetOpt = 1 - m.sheetOpt
+				if m.sheetOpt == 0 {
+					m.sheetInput.Focus()
+					return m, textinput.Blink
+				}
+				m.sheetInput.Blur()
+				return m, nil
+			case "enter":
+				m.RecoverySheet = ""
+				if m.sheetOpt == 0 {
+					if m.sheetInput.Value() == "" {
+						return m, nil
+					}
+					m.RecoverySheet = m.sheetInput.Value()
+					if abs, err := storage.AbsPath(m.RecoverySheet); err == nil {
+						m.RecoverySheet = abs
+					}
+				}
+				m.Done = true
+				return m, nil
+			case "esc":
+				m.step = stepPasswordHint
+				m.sheetInput.Blur()
+				m.hintInput.Focus()
+				return m, textinput.Blink
+			}
+			if m.sheetOpt == 0 {
+				m.sheetInput, cmd = m.sheetInput.Update(msg)
+			}
+			return m, cmd
 		}
 	}
 
@@ -313,7 +755,81 @@ func (m SetupModel) View() string {
 		b.WriteString("\n\n")
 		b.WriteString(helpStyle.Render(keyStyle.Render("Enter") + " continue"))
 
+	case stepChooseFormat:
+		b.WriteString(promptStyle.Render("How should \"" + m.Name + "\" be stored?"))
+		b.WriteString("\n\n")
+
+		opt1 := "Single database file (can be encrypted)"
+		if m.formatSelected == 0 {
+			b.WriteString(selectedStyle.Render("> " + opt1))
+		} else {
+			b.WriteString(optionStyle.Render("  " + opt1))
+		}
+		b.WriteString("\n")
+
+		opt2 := "Folder of Markdown files, one per entry"
+		if m.formatSelected == 1 {
+			b.WriteString(selectedStyle.Render("> " + opt2))
+		} else {
+			b.WriteString(optionStyle.Render("  " + opt2))
+		}
+		b.WriteString("\n")
+		b.WriteString("    ")
+		b.WriteString(pathStyle.Render("Readable and editable with other tools; not encrypted"))
+		b.WriteString("\n")
+
+		opt3 := "PostgreSQL server, shared between devices"
+		if m.formatSelected == 2 {
+			b.WriteString(selectedStyle.Render("> " + opt3))
+		} else {
+			b.WriteString(optionStyle.Render("  " + opt3))
+		}
+		b.WriteString("\n")
+		b.WriteString("    ")
+		b.WriteString(pathStyle.Render("Needs a server you can reach from each device"))
+		b.WriteString("\n")
+
+		opt4 := "Open an existing journal file or folder"
+		if m.formatSelected == 3 {
+			b.WriteString(selectedStyle.Render("> " + opt4))
+		} else {
+			b.WriteString(optionStyle.Render("  " + opt4))
+		}
+		b.WriteString("\n")
+		b.WriteString("    ")
+		b.WriteString(pathStyle.Render("e.g. restored from a backup or copied from another machine"))
+		b.WriteString("\n\n")
+
+		b.WriteString(helpStyle.Render(keyStyle.Render("Up/Down") + " navigate  " + keyStyle.Render("Enter") + " select  " + keyStyle.Render("Esc") + " back"))
+
 	case stepChoosePath:
+		if m.openExisting {
+			b.WriteString(promptStyle.Render("Where is the journal to open as \"" + m.Name + "\"?"))
+			b.WriteString("\n\n")
+			b.WriteString("    ")
+			b.WriteString(m.textInput.View())
+			b.WriteString("\n\n")
+			if m.Error != "" {
+				b.WriteString("    ")
+				b.WriteString(errorStyle.Render(m.Error))
+				b.WriteString("\n\n")
+			}
+			b.WriteString(helpStyle.Render("    " + keyStyle.Render("Enter") + " open  " + keyStyle.Render("Esc") + " back"))
+			break
+		}
+		if m.Format == model.FormatPostgres {
+			b.WriteString(promptStyle.Render("Connection string for the server storing \"" + m.Name + "\":"))
+			b.WriteString("\n\n")
+			b.WriteString("    ")
+			b.WriteString(m.textInput.View())
+			b.WriteString("\n")
+			b.WriteString("    ")
+			b.WriteString(pathStyle.Render("Keep the password in ~/.pgpass rather than here; it is saved in the config file"))
+			b.WriteString("\n\n")
+			b.WriteString(helpStyle.Render("    " + keyStyle.Render("Enter") + " confirm  " + keyStyle.Render("Esc") + " back"))
+			break
+		}
+
 		b.WriteString(promptStyle.Render("Where would you like to store \"" + m.Name + "\"?"))
 		b.WriteString("\n\n")
 
@@ -341,12 +857,67 @@ func (m SetupModel) View() string {
 			b.WriteString("    ")
 			b.WriteString(m.textInput.View())
 			b.WriteString("\n\n")
+			if m.Error != "" {
+				b.WriteString("    ")
+				b.WriteString(errorStyle.Render(m.Error))
+				b.WriteString("\n\n")
+			}
 			b.WriteString(helpStyle.Render("    " + keyStyle.Render("Enter") + " confirm  " + keyStyle.Render("Esc") + " cancel"))
 		} else {
 			b.WriteString("\n")
 			b.WriteString(helpStyle.Render(keyStyle.Render("Up/Down") + " navigate  " + keyStyle.Render("Enter") + " select  " + keyStyle.Render("Esc") + " back"))
 		}
 
+	case stepCreateDir:
+		b.WriteString(promptStyle.Render("The folder for this journal doesn't exist:"))
+		b.WriteString("\n")
+		b.WriteString("    ")
+		b.WriteString(pathStyle.Render(filepath.Dir(m.DBPath)))
+		b.WriteString("\n\n")
+
+		opt1 := "Create it"
+		if m.createDirOpt == 0 {
+			b.WriteString(selectedStyle.Render("> " + opt1))
+		} else {
+			b.WriteString(optionStyle.Render("  " + opt1))
+		}
+		b.WriteString("\n")
+
+		opt2 := "Choose another path"
+		if m.createDirOpt == 1 {
+			b.WriteString(selectedStyle.Render("> " + opt2))
+		} else {
+			b.WriteString(optionStyle.Render("  " + opt2))
+		}
+		b.WriteString("\n\n")
+
+		b.WriteString(helpStyle.Render(keyStyle.Render("Up/Down") + " navigate  " + keyStyle.Render("Enter") + " select  " + keyStyle.Render("Esc") + " back"))
+
+	case stepExistingFile:
+		kind := "A journal"
+		if m.Encrypt {
+			kind = "An encrypted journal"
+		}
+		b.WriteString(promptStyle.Render(kind + " already exists at:"))
+		b.WriteString("\n")
+		b.WriteString("    ")
+		b.WriteString(pathStyle.Render(m.DBPath))
+		b.WriteString("\n\n")
+
+		for i, opt := range []string{"Open it", "Choose another path", "Overwrite it with a new journal"} {
+			if m.existingOpt == i {
+				b.WriteString(selectedStyle.Render("> " + opt))
+			} else {
+				b.WriteString(optionStyle.Render("  " + opt))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    ")
+		b.WriteString(pathStyle.Render("Overwriting backs the current file up first"))
+		b.WriteString("\n\n")
+
+		b.WriteString(helpStyle.Render(keyStyle.Render("Up/Down") + " navigate  " + keyStyle.Render("Enter") + " select  " + keyStyle.Render("Esc") + " back"))
+
 	case stepChooseEncryption:
 		b.WriteString(promptStyle.Render("Would you like to encrypt your journal?"))
 		b.WriteString("\n\n")
@@ -369,12 +940,51 @@ func (m SetupModel) View() string {
 
 		b.WriteString(helpStyle.Render(keyStyle.Render("Enter") + " select  " + keyStyle.Render("Esc") + " back"))
 
+	case stepEncryptionMode:
+		b.WriteString(promptStyle.Render("How should the journal be decrypted while you use it?"))
+		b.WriteString("\n\n")
+
+		for i, mode := range encryptionModes {
+			label := mode.label
+			if !mode.available {
+				label += " (not available yet)"
+			}
+			if i == m.modeSelected {
+				b.WriteString(selectedStyle.Render("> " + label))
+			} else {
+				b.WriteString(optionStyle.Render("  " + label))
+			}
+			b.WriteString("\n")
+			for _, line := range mode.tradeoffs {
+				b.WriteString("    ")
+				b.WriteString(pathStyle.Render(line))
+				b.WriteString("\n")
+			}
+			b.WriteString("\n")
+		}
+		if m.Error != "" {
+			b.WriteString("  ")
+			b.WriteString(errorStyle.Render(m.Error))
+			b.WriteString("\n\n")
+		}
+
+		b.WriteString(helpStyle.Render(keyStyle.Render("Up/Down") + " navigate  " + keyStyle.Render("Enter") + " select  " + keyStyle.Render("Esc") + " back"))
+
 	case stepEnterPassword:
-		b.WriteString(promptStyle.Render("Enter a password for encryption:"))
+		if m.Existing {
+			b.WriteString(promptStyle.Render("Enter the journal's password:"))
+		} else {
+			b.WriteString(promptStyle.Render("Enter a password for encryption:"))
+		}
 		b.WriteString("\n\n")
 		b.WriteString("  ")
 		b.WriteString(m.passwordInput.View())
 		b.WriteString("\n\n")
+		if m.Error != "" {
+			b.WriteString("  ")
+			b.WriteString(errorStyle.Render(m.Error))
+			b.WriteString("\n\n")
+		}
 		b.WriteString(helpStyle.Render(keyStyle.Render("Enter") + " continue  " + keyStyle.Render("Esc") + " back"))
 
 	case stepConfirmPassword:
@@ -393,6 +1003,56 @@ func (m SetupModel) View() string {
 
 		b.WriteString("\n")
 		b.WriteString(helpStyle.Render(keyStyle.Render("Enter") + " confirm  " + keyStyle.Render("Esc") + " back"))
+
+	case stepPasswordHint:
+		b.WriteString(promptStyle.Render("Add a password hint (optional):"))
+		b.WriteString("\n")
+		b.WriteString("    ")
+		b.WriteString(pathStyle.Render("Shown after 3 wrong passwords. It is saved unencrypted in the config file,"))
+		b.WriteString("\n")
+		b.WriteString("    ")
+		b.WriteString(pathStyle.Render("so make it something only you would understand"))
+		b.WriteString("\n\n")
+		b.WriteString("  ")
+		b.WriteString(m.hintInput.View())
+		b.WriteString("\n\n")
+		if m.Error != "" {
+			b.WriteString("  ")
+			b.WriteString(errorStyle.Render(m.Error))
+			b.WriteString("\n\n")
+		}
+		b.WriteString(helpStyle.Render(keyStyle.Render("Enter") + " continue (leave empty for none)  " + keyStyle.Render("Esc") + " back"))
+
+	case stepRecoverySheet:
+		b.WriteString(promptStyle.Render("Save a recovery sheet?"))
+		b.WriteString("\n")
+		b.WriteString("    ")
+		b.WriteString(pathStyle.Render("A page to print, naming the journal's file, encryption, and hint, with a line"))
+		b.WriteString("\n")
+		b.WriteString("    ")
+		b.WriteString(pathStyle.Render("to write the password on by hand. The password itself is never saved."))
+		b.WriteString("\n\n")
+
+		opt1 := "Save it to:"
+		if m.sheetOpt == 0 {
+			b.WriteString(selectedStyle.Render("> " + opt1))
+		} else {
+			b.WriteString(optionStyle.Render("  " + opt1))
+		}
+		b.WriteString("\n")
+		b.WriteString("    ")
+		b.WriteString(m.sheetInput.View())
+		b.WriteString("\n")
+
+		opt2 := "Skip"
+		if m.sheetOpt == 1 {
+			b.WriteString(selectedStyle.Render("> " + opt2))
+		} else {
+			b.WriteString(optionStyle.Render("  " + opt2))
+		}
+		b.WriteString("\n\n")
+
+		b.WriteString(helpStyle.Render(keyStyle.Render("Up/Down") + " navigate  " + keyStyle.Render("Enter") + " create journal  " + keyStyle.Render("Esc") + " back"))
 	}
 
 	return b.String()
@@ -0,0 +1,131 @@
+package ui
+
+import (
+	"strings"
+	"time"
+
+	"journal/internal/share"
+	"journal/internal/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// shareDuration is how long a shared entry's link works if it isn't
+// opened
+const shareDuration = 10 * time.Minute
+
+// shareTickMsg updates the time left on the link of server
+type shareTickMsg struct {
+	server *share.Server
+}
+
+// shareDoneMsg reports that server has stopped serving its entry
+type shareDoneMsg struct {
+	server *share.Server
+}
+
+// ShareModel shows the link an entry is being served at, for opening it
+// on another device, until the link is used or expires
+type ShareModel struct {
+	server *share.Server
+	date   string
+	done   bool
+	Back   bool
+}
+
+func NewShareModel(server *share.Server, date string) ShareModel {
+	return ShareModel{server: server, date: date}
+}
+
+func (m ShareModel) Init() tea.Cmd {
+	server := m.server
+	return tea.Batch(m.tick(), func() tea.Msg {
+		<-server.Done()
+		return shareDoneMsg{server: server}
+	})
+}
+
+// tick schedules the next update of the time left. Ticks carry their
+// server, so those of a share since stopped are dropped.
+func (m ShareModel) tick() tea.Cmd {
+	server := m.server
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return shareTickMsg{server: server}
+	})
+}
+
+// Stop stops serving the entry
+func (m ShareModel) Stop() {
+	if m.server != nil {
+		m.server.Stop()
+	}
+}
+
+func (m ShareModel) Update(msg tea.Msg) (ShareModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case shareTickMsg:
+		if msg.server == m.server && !m.done {
+			return m, m.tick()
+		}
+	case shareDoneMsg:
+		if msg.server == m.server {
+			m.done = true
+		}
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q", "enter":
+			m.Stop()
+			m.Back = true
+		}
+	}
+	return m, nil
+}
+
+func (m ShareModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	labelStyle := lipgloss.NewStyle().Foreground(t.Text).Bold(true)
+	urlStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	successStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	warningStyle := lipgloss.NewStyle().Foreground(t.Warning)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Share Entry"))
+	b.WriteString("\n\n")
+	b.WriteString(labelStyle.Render("Entry: ") + m.date)
+	b.WriteString("\n\n")
+
+	viewedAt := m.server.ViewedAt()
+	switch {
+	case !viewedAt.IsZero():
+		b.WriteString(successStyle.Render("Viewed at " + viewedAt.Format("15:04:05") + "; the link no longer works."))
+		b.WriteString("\n\n")
+	case m.done:
+		b.WriteString(mutedStyle.Render("The link expired without being opened."))
+		b.WriteString("\n\n")
+	default:
+		b.WriteString("Open this link on a device on the same network:")
+		b.WriteString("\n\n")
+		b.WriteString("  " + urlStyle.Render(m.server.URL))
+		b.WriteString("\n\n")
+		left := time.Until(m.server.Expires).Round(time.Second)
+		b.WriteString(mutedStyle.Render("The link works once, and expires in " + formatWritingTime(max(left, 0)) + "."))
+		b.WriteString("\n")
+		b.WriteString(warningStyle.Render("The page is sent unencrypted; share it only on a network you trust."))
+		b.WriteString("\n\n")
+	}
+
+	label := " stop sharing"
+	if m.done {
+		label = " back"
+	}
+	b.WriteString(helpStyle.Render(keyStyle.Render("Esc") + label))
+
+	return b.String()
+}
@@ -0,0 +1,227 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"journal/internal/model"
+	"journal/internal/stats"
+	"journal/internal/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// StatsModel shows how much has been written in the journal: totals,
+// writing streaks, and entries and goals completed per month
+type StatsModel struct {
+	summary stats.Summary
+	offset  int // First month shown, counting back from the latest
+	Back    bool
+	width   int
+	height  int
+}
+
+func NewStatsModel(journal *model.Journal, today time.Time) StatsModel {
+	return StatsModel{summary: stats.Summarize(journal, today)}
+}
+
+func (m *StatsModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+func (m StatsModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m StatsModel) visibleRows() int {
+	rows := m.height - 18 - len(m.thisMonth().Goals) - m.typingRows()
+	if rows < 5 {
+		rows = 5
+	}
+	return rows
+}
+
+func (m StatsModel) Update(msg tea.Msg) (StatsModel, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		last := max(len(m.summary.Months)-m.visibleRows(), 0)
+		switch msg.String() {
+		case "up", "k":
+			m.offset = max(m.offset-1, 0)
+		case "down", "j":
+			m.offset = min(m.offset+1, last)
+		case "pgup":
+			m.offset = max(m.offset-m.visibleRows(), 0)
+		case "pgdown":
+			m.offset = min(m.offset+m.visibleRows(), last)
+		case "esc", "q":
+			m.Back = true
+		}
+	}
+	return m, nil
+}
+
+// typingRows returns the lines taken by the typing figures, which are
+// shown once something has been typed in the editor
+func (m StatsModel) typingRows() int {
+	if m.summary.Typing.Sessions == 0 {
+		return 0
+	}
+	return 7
+}
+
+// thisMonth returns the current month's counts and goals
+func (m StatsModel) thisMonth() stats.MonthCount {
+	if len(m.summary.Months) == 0 {
+		return stats.MonthCount{}
+	}
+	return m.summary.Months[len(m.summary.Months)-1]
+}
+
+// dayCount describes a number of days
+func dayCount(days int) string {
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
+
+func (m StatsModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	labelStyle := lipgloss.NewStyle().Foreground(t.Muted).Width(18)
+	valueStyle := lipgloss.NewStyle().Foreground(t.Text).Bold(true)
+	streakStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	monthStyle := lipgloss.NewStyle().Foreground(t.Info)
+	barStyle := lipgloss.NewStyle().Foreground(t.Accent)
+	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	emptyStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true).PaddingLeft(2)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	dividerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+
+	s := m.summary
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Statistics"))
+	b.WriteString("\n\n")
+
+	if s.Entries == 0 {
+		b.WriteString(emptyStyle.Render("No entries yet."))
+		b.WriteString("\n\n")
+		b.WriteString(helpStyle.Render(keyStyle.Render("Esc/q") + " back"))
+		return b.String()
+	}
+
+	row := func(label, value string) {
+		b.WriteString(labelStyle.Render(label) + value)
+		b.WriteString("\n")
+	}
+	row("Entries", valueStyle.Render(fmt.Sprintf("%d", s.Entries)))
+	row("Words", valueStyle.Render(fmt.Sprintf("%d", s.Words)))
+	row("Words per entry", valueStyle.Render(fmt.Sprintf("%d", s.AverageWords())))
+	current := streakStyle.Render(dayCount(s.CurrentStreak))
+	if s.CurrentStreak == 0 {
+		current = mutedStyle.Render("none, write today to start one")
+	}
+	row("Current streak", current)
+	row("Longest streak", streakStyle.Render(dayCount(s.LongestStreak))+mutedStyle.Render(", ended "+s.LongestEnd))
+	b.WriteString("\n")
+
+	// How the entries were typed, for writing as a practice
+	if typing := s.Typing; typing.Sessions > 0 {
+		minutes := max(typing.Active.Minutes(), 1.0/60)
+		noun := "sessions"
+		if typing.Sessions == 1 {
+			noun = "session"
+		}
+		row("Typing", valueStyle.Render(formatWritingTime(typing.Active))+mutedStyle.Render(fmt.Sprintf(" over %d %s", typing.Sessions, noun)))
+		row("Typing speed", valueStyle.Render(fmt.Sprintf("%.0f", float64(typing.Keystrokes)/minutes))+mutedStyle.Render(" keys a minute"))
+		row("Deletions", valueStyle.Render(fmt.Sprintf("%d%%", typing.Deletions*100/typing.Keystrokes))+mutedStyle.Render(" of keystrokes"))
+		pauses := mutedStyle.Render("none")
+		if typing.Pauses > 0 {
+			pauses = valueStyle.Render(fmt.Sprintf("%d", typing.Pauses)) +
+				mutedStyle.Render(", one every "+formatWritingTime(typing.Active/time.Duration(typing.Pauses))+" of typing")
+		}
+		row("Pauses", pauses)
+		flow := mutedStyle.Render("no stretch of " + formatWritingTime(model.FlowStretch) + " without a pause yet")
+		if typing.Flow > 0 {
+			flow = valueStyle.Render(fmt.Sprintf("%d%%", int(typing.Flow*100/typing.Active))) +
+				mutedStyle.Render(" of typing time, longest stretch "+formatWritingTime(typing.LongestFlow))
+		}
+		row("In flow", flow)
+		row("Distractions", valueStyle.Render(fmt.Sprintf("%d", typing.Distractions))+mutedStyle.Render(" times another window was switched to while typing"))
+		b.WriteString("\n")
+	}
+
+	// The current month's goals, with the days that referred to each
+	month := m.thisMonth()
+	if len(month.Goals) == 0 {
+		b.WriteString(mutedStyle.Render("No goals this month. Press G in the entry list to set some."))
+		b.WriteString("\n\n")
+	} else {
+		b.WriteString(mutedStyle.Render(fmt.Sprintf("Goals this month: %d of %d done (%d%%)", month.GoalsDone(), len(month.Goals), month.GoalsPercent())))
+		b.WriteString("\n")
+		for _, goal := range month.Goals {
+			line := "[ ] " + goal.Text
+			if goal.Done {
+				line = streakStyle.Render("[x]") + " " + goal.Text
+			}
+			if goal.Days > 0 {
+				line += mutedStyle.Render("  mentioned on " + dayCount(goal.Days))
+			}
+			b.WriteString("  " + fitWidth(line, m.width-2))
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString(mutedStyle.Render("Entries and goals done per month, newest first"))
+	b.WriteString("\n")
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", min(60, max(m.width, 20)))))
+	b.WriteString("\n")
+
+	most := 0
+	for _, month := range s.Months {
+		most = max(most, month.Entries)
+	}
+	barWidth := min(24, max(m.width-46, 5))
+	end := min(m.offset+m.visibleRows(), len(s.Months))
+	for i := m.offset; i < end; i++ {
+		month := s.Months[len(s.Months)-1-i]
+		name := month.Month
+		if date, err := time.Parse("2006-01", month.Month); err == nil {
+			name = date.Format("Jan 2006")
+		}
+		bar := ""
+		if most > 0 {
+			bar = strings.Repeat("█", (month.Entries*barWidth+most-1)/most)
+		}
+		line := monthStyle.Render(fmt.Sprintf("%-9s", name)) +
+			fmt.Sprintf(" %3d ", month.Entries) +
+			barStyle.Render(fmt.Sprintf("%-*s", barWidth, bar)) +
+			mutedStyle.Render(fmt.Sprintf(" %-12s", fmt.Sprintf("%d words", month.Words)))
+		if len(month.Goals) > 0 {
+			line += fmt.Sprintf(" goals %d/%d %d%%", month.GoalsDone(), len(month.Goals), month.GoalsPercent())
+		}
+		b.WriteString("  " + fitWidth(line, m.width-2))
+		b.WriteString("\n")
+	}
+	if len(s.Months) > m.visibleRows() {
+		b.WriteString(mutedStyle.Render(fmt.Sprintf("  (%d-%d of %d months)", m.offset+1, end, len(s.Months))))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	var parts []string
+	if len(s.Months) > m.visibleRows() {
+		parts = append(parts, keyStyle.Render("Up/Down")+" scroll")
+	}
+	parts = append(parts, keyStyle.Render("Esc/q")+" back")
+	b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
+
+	return b.String()
+}
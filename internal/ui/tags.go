@@ -0,0 +1,164 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"journal/internal/model"
+	"journal/internal/storage"
+	"journal/internal/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TagsModel lists the tags of the journal's entries, most used first, and
+// picks one to filter the entry list by
+type TagsModel struct {
+	tags          []model.TagCount
+	current       string // Tag the list is filtered by, if any
+	selectedIndex int
+	offset        int
+	width         int
+	height        int
+	Back          bool
+	Chosen        bool // Filter the list by the selected tag
+}
+
+func NewTagsModel(store storage.Store, current string) (TagsModel, error) {
+	tags, err := store.ListTags()
+	if err != nil {
+		return TagsModel{}, err
+	}
+	m := TagsModel{tags: tags, current: current}
+	for i, tag := range tags {
+		if tag.Tag == current {
+			m.selectedIndex = i
+		}
+	}
+	return m, nil
+}
+
+func (m *TagsModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+	m.adjustScroll()
+}
+
+func (m TagsModel) Init() tea.Cmd {
+	return nil
+}
+
+// Selected returns the selected tag
+func (m TagsModel) Selected() (string, bool) {
+	if m.selectedIndex >= len(m.tags) {
+		return "", false
+	}
+	return m.tags[m.selectedIndex].Tag, true
+}
+
+func (m TagsModel) visibleRows() int {
+	rows := m.height - 8
+	if rows < 5 {
+		rows = 10
+	}
+	return rows
+}
+
+func (m *TagsModel) adjustScroll() {
+	visible := m.visibleRows()
+	if m.selectedIndex < m.offset {
+		m.offset = m.selectedIndex
+	} else if m.selectedIndex >= m.offset+visible {
+		m.offset = m.selectedIndex - visible + 1
+	}
+}
+
+func (m TagsModel) Update(msg tea.Msg) (TagsModel, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "up", "k":
+			if m.selectedIndex > 0 {
+				m.selectedIndex--
+				m.adjustScroll()
+			}
+		case "down", "j":
+			if m.selectedIndex < len(m.tags)-1 {
+				m.selectedIndex++
+				m.adjustScroll()
+			}
+		case "enter":
+			if len(m.tags) > 0 {
+				m.Chosen = true
+			}
+		case "esc", "q":
+			m.Back = true
+		}
+	}
+	return m, nil
+}
+
+func (m TagsModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	itemStyle := lipgloss.NewStyle().Foreground(t.Text).PaddingLeft(2)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true).PaddingLeft(2)
+	countStyle := lipgloss.NewStyle().Foreground(t.Info)
+	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	emptyStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true).PaddingLeft(2)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Tags"))
+	if len(m.tags) > 0 {
+		b.WriteString(mutedStyle.Render(fmt.Sprintf("  (%d)", len(m.tags))))
+	}
+	b.WriteString("\n\n")
+
+	if len(m.tags) == 0 {
+		b.WriteString(emptyStyle.Render("No tags yet. Add them in the Tags field of the editor."))
+		b.WriteString("\n")
+	} else {
+		width := 0
+		for _, tag := range m.tags {
+			width = max(width, len([]rune(tag.Tag))+1)
+		}
+		width = min(width, max(m.width-24, 10))
+
+		end := min(m.offset+m.visibleRows(), len(m.tags))
+		for i := m.offset; i < end; i++ {
+			tag := m.tags[i]
+			name := fitWidth("#"+tag.Tag, width)
+			count := "entries"
+			if tag.Entries == 1 {
+				count = "entry"
+			}
+			line := fmt.Sprintf("%-*s  ", width, name) + countStyle.Render(fmt.Sprintf("%d %s", tag.Entries, count))
+			if tag.Tag == m.current {
+				line += mutedStyle.Render("  (filtered)")
+			}
+			if i == m.selectedIndex {
+				b.WriteString(selectedStyle.Render("> " + line))
+			} else {
+				b.WriteString(itemStyle.Render("  " + line))
+			}
+			b.WriteString("\n")
+		}
+		if len(m.tags) > m.visibleRows() {
+			b.WriteString(mutedStyle.Render(fmt.Sprintf("  (%d-%d of %d)", m.offset+1, end, len(m.tags))))
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n")
+
+	var parts []string
+	parts = append(parts, keyStyle.Render("Up/Down")+" navigate")
+	parts = append(parts, keyStyle.Render("Enter")+" filter list")
+	parts = append(parts, keyStyle.Render("Esc/q")+" back")
+	b.WriteString(helpStyle.Render(joinWrapped(parts, " | ", m.width, "")))
+
+	return b.String()
+}
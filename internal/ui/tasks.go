@@ -0,0 +1,251 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"journal/internal/clock"
+	"journal/internal/model"
+	"journal/internal/storage"
+	"journal/internal/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TasksModel lists the checkbox items written in entries, open ones
+// first of all, and ticks them off in the entries they are written in
+type TasksModel struct {
+	store         storage.Store
+	clock         clock.Clock
+	lock          *entryLock // Tasks of locked entries can't be ticked
+	tasks         []model.Task
+	showDone      bool
+	selectedIndex int
+	offset        int
+	width         int
+	height        int
+	Back          bool
+	Open          bool // Open the selected task's entry in the editor
+	Error         string
+	Message       string
+}
+
+func NewTasksModel(store storage.Store, clk clock.Clock, lock *entryLock) (TasksModel, error) {
+	m := TasksModel{store: store, clock: clk, lock: lock}
+	return m, m.reload()
+}
+
+func (m *TasksModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+func (m TasksModel) Init() tea.Cmd {
+	return nil
+}
+
+// reload reads the tasks again, keeping the selection in range
+func (m *TasksModel) reload() error {
+	tasks, err := m.store.ListTasks(!m.showDone)
+	if err != nil {
+		return err
+	}
+	m.tasks = tasks
+	if m.selectedIndex >= len(m.tasks) {
+		m.selectedIndex = max(len(m.tasks)-1, 0)
+	}
+	m.adjustScroll()
+	return nil
+}
+
+// Selected returns the selected task
+func (m TasksModel) Selected() (model.Task, bool) {
+	if m.selectedIndex >= len(m.tasks) {
+		return model.Task{}, false
+	}
+	return m.tasks[m.selectedIndex], true
+}
+
+func (m TasksModel) visibleRows() int {
+	rows := m.height - 8
+	if rows < 5 {
+		rows = 10
+	}
+	return rows
+}
+
+func (m *TasksModel) adjustScroll() {
+	visible := m.visibleRows()
+	if m.selectedIndex < m.offset {
+		m.offset = m.selectedIndex
+	} else if m.selectedIndex >= m.offset+visible {
+		m.offset = m.selectedIndex - visible + 1
+	}
+}
+
+func (m TasksModel) Update(msg tea.Msg) (TasksModel, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		m.Error = ""
+		m.Message = ""
+
+		switch msg.String() {
+		case "up", "k":
+			if m.selectedIndex > 0 {
+				m.selectedIndex--
+				m.adjustScroll()
+			}
+		case "down", "j":
+			if m.selectedIndex < len(m.tasks)-1 {
+				m.selectedIndex++
+				m.adjustScroll()
+			}
+		case " ", "x":
+			if err := m.toggle(); err != nil {
+				m.Error = err.Error()
+			}
+		case "a":
+			m.showDone = !m.showDone
+			if err := m.reload(); err != nil {
+				m.Error = err.Error()
+			}
+		case "enter":
+			if len(m.tasks) > 0 {
+				m.Open = true
+			}
+		case "esc", "q":
+			m.Back = true
+		}
+	}
+	return m, nil
+}
+
+// checkbox matches the box of a checkbox item
+var checkbox = regexp.MustCompile(`^(\s*[-*+] )\[([ xX])\]`)
+
+// toggle ticks the selected task, or unticks it when done, by rewriting its
+// line in the entry. The entry's previous content goes to its history, as
+// when it is saved from the editor.
+func (m *TasksModel) toggle() error {
+	task, ok := m.Selected()
+	if !ok {
+		return nil
+	}
+	if m.lock.Locked(task.EntryID, task.Date) {
+		return errors.New(m.lock.Reason(task.Date))
+	}
+	entry, err := m.store.GetEntry(task.EntryID)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(entry.Content, "\n")
+	if task.Line >= len(lines) || !checkbox.MatchString(lines[task.Line]) {
+		return fmt.Errorf("the entry for %s has changed, so the task couldn't be found", task.Date)
+	}
+	box := "[x]"
+	if task.Done {
+		box = "[ ]"
+	}
+	lines[task.Line] = checkbox.ReplaceAllString(lines[task.Line], "${1}"+box)
+
+	entry.History = append(entry.History, model.SaveRecord{
+		Content:     entry.Content,
+		SavedAt:     entry.UpdatedAt,
+		Attachments: entry.AttachmentFilenames(),
+		Tags:        entry.Tags,
+	})
+	entry.Content = strings.Join(lines, "\n")
+	entry.UpdatedAt = m.clock.Now()
+	if err := m.store.SaveEntries([]model.Entry{*entry}); err != nil {
+		return err
+	}
+
+	if task.Done {
+		m.Message = "Reopened: " + task.Text
+	} else {
+		m.Message = "Done: " + task.Text
+	}
+	return m.reload()
+}
+
+func (m TasksModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	itemStyle := lipgloss.NewStyle().Foreground(t.Text).PaddingLeft(2)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true).PaddingLeft(2)
+	doneStyle := lipgloss.NewStyle().Foreground(t.TextDim).Strikethrough(true)
+	dateStyle := lipgloss.NewStyle().Foreground(t.Info)
+	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	emptyStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true).PaddingLeft(2)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	successStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Tasks"))
+	if m.showDone {
+		b.WriteString(mutedStyle.Render("  (all)"))
+	} else {
+		b.WriteString(mutedStyle.Render("  (open)"))
+	}
+	b.WriteString("\n\n")
+
+	if len(m.tasks) == 0 {
+		if m.showDone {
+			b.WriteString(emptyStyle.Render("No tasks yet. Write \"- [ ] something to do\" in an entry."))
+		} else {
+			b.WriteString(emptyStyle.Render("No open tasks."))
+		}
+		b.WriteString("\n")
+	} else {
+		end := min(m.offset+m.visibleRows(), len(m.tasks))
+		for i := m.offset; i < end; i++ {
+			task := m.tasks[i]
+			box, text := "[ ]", task.Text
+			if task.Done {
+				box, text = "[x]", doneStyle.Render(task.Text)
+			}
+			line := dateStyle.Render("["+task.Date+"]") + " " + box + " " + text
+			if i == m.selectedIndex {
+				b.WriteString(selectedStyle.Render("> " + line))
+			} else {
+				b.WriteString(itemStyle.Render("  " + line))
+			}
+			b.WriteString("\n")
+		}
+		if len(m.tasks) > m.visibleRows() {
+			b.WriteString(mutedStyle.Render(fmt.Sprintf("  (%d-%d of %d)", m.offset+1, end, len(m.tasks))))
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n")
+
+	if m.Error != "" {
+		b.WriteString(errorStyle.Render(m.Error))
+		b.WriteString("\n\n")
+	}
+	if m.Message != "" {
+		b.WriteString(successStyle.Render(m.Message))
+		b.WriteString("\n\n")
+	}
+
+	var parts []string
+	parts = append(parts, keyStyle.Render("Up/Down")+" navigate")
+	parts = append(parts, keyStyle.Render("Space")+" done/undo")
+	parts = append(parts, keyStyle.Render("Enter")+" open entry")
+	if m.showDone {
+		parts = append(parts, keyStyle.Render("a")+" open only")
+	} else {
+		parts = append(parts, keyStyle.Render("a")+" show done")
+	}
+	parts = append(parts, keyStyle.Render("Esc/q")+" back")
+	b.WriteString(helpStyle.Render(strings.Join(parts, " | ")))
+
+	return b.String()
+}
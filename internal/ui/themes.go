@@ -0,0 +1,143 @@
+package ui
+
+import (
+	"strings"
+
+	"journal/internal/theme"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ThemeModel is the theme gallery: the whole app takes on each theme as it
+// is highlighted, next to a sample of the things themes colour
+type ThemeModel struct {
+	themes    []string
+	selected  int
+	original  string
+	Chosen    string
+	Done      bool
+	Cancelled bool
+}
+
+func NewThemeModel(currentTheme string) ThemeModel {
+	themes := theme.List()
+	selected := 0
+	for i, t := range themes {
+		if t == currentTheme {
+			selected = i
+			break
+		}
+	}
+	return ThemeModel{
+		themes:   themes,
+		selected: selected,
+		original: currentTheme,
+	}
+}
+
+func (m ThemeModel) Update(msg tea.Msg) (ThemeModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.selected > 0 {
+				m.selected--
+			}
+			theme.Set(m.themes[m.selected])
+		case "down", "j":
+			if m.selected < len(m.themes)-1 {
+				m.selected++
+			}
+			theme.Set(m.themes[m.selected])
+		case "enter":
+			m.Chosen = m.themes[m.selected]
+			m.Done = true
+		case "esc", "q":
+			theme.Set(m.original)
+			m.Cancelled = true
+		}
+	}
+	return m, nil
+}
+
+func (m ThemeModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true)
+	itemStyle := lipgloss.NewStyle().Foreground(t.Text)
+	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Themes"))
+	if theme.IsLight() {
+		b.WriteString(mutedStyle.Render("  (light background variants)"))
+	}
+	b.WriteString("\n\n")
+
+	var list strings.Builder
+	for i, name := range m.themes {
+		label := name
+		if name == m.original {
+			label += mutedStyle.Render(" (current)")
+		}
+		if i == m.selected {
+			list.WriteString(selectedStyle.Render("> " + label))
+		} else {
+			list.WriteString(itemStyle.Render("  " + label))
+		}
+		list.WriteString("\n")
+	}
+
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
+		lipgloss.NewStyle().Width(24).Render(list.String()),
+		themeSample(t),
+	))
+	b.WriteString("\n\n")
+
+	b.WriteString(helpStyle.Render(keyStyle.Render("Up/Down") + " preview | " + keyStyle.Render("Enter") + " use theme | " + keyStyle.Render("Esc") + " cancel"))
+
+	return b.String()
+}
+
+// themeSample renders a small screen in theme t with one of each kind of
+// text the app colours
+func themeSample(t theme.Theme) string {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true)
+	itemStyle := lipgloss.NewStyle().Foreground(t.Text)
+	dimStyle := lipgloss.NewStyle().Foreground(t.TextDim)
+	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	accentStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	infoStyle := lipgloss.NewStyle().Foreground(t.Info).Italic(true)
+	successStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	warningStyle := lipgloss.NewStyle().Foreground(t.Warning).Bold(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	disabledStyle := lipgloss.NewStyle().Foreground(t.Disabled)
+
+	lines := []string{
+		titleStyle.Render("Journal Entries"),
+		"",
+		selectedStyle.Render("> [2026-03-14] Long walk by the river"),
+		itemStyle.Render("  [2026-03-13] Finished the bookshelf"),
+		dimStyle.Render("    Sanded and oiled it in the evening..."),
+		"",
+		itemStyle.Render("Travel") + mutedStyle.Render(" [encrypted]") + " " + warningStyle.Render("[missing]"),
+		infoStyle.Render("~/.journal/travel.db"),
+		"",
+		successStyle.Render("Entry saved"),
+		errorStyle.Render("Invalid password"),
+		disabledStyle.Render("  Restore (no backups yet)"),
+		"",
+		accentStyle.Render("Enter") + mutedStyle.Render(" edit | ") + accentStyle.Render("q") + mutedStyle.Render(" quit"),
+	}
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.Muted).
+		Padding(0, 1).
+		Render(strings.Join(lines, "\n"))
+}
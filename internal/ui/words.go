@@ -0,0 +1,291 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"journal/internal/model"
+	"journal/internal/stats"
+	"journal/internal/storage"
+	"journal/internal/theme"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const wordReportSize = 50
+
+// sparkBlocks are used to draw word usage over time
+var sparkBlocks = []rune(" ▁▂▃▄▅▆▇█")
+
+type WordReportModel struct {
+	journal       *model.Journal
+	report        stats.WordReport
+	period        stats.Period
+	selectedIndex int
+	offset        int
+	exportMode    bool
+	pathInput     textinput.Model
+	Back          bool
+	Error         string
+	Message       string
+	width         int
+	height        int
+}
+
+func NewWordReportModel(journal *model.Journal) WordReportModel {
+	ti := textinput.New()
+	ti.Placeholder = "Enter CSV file path..."
+	ti.CharLimit = 512
+	ti.Width = 50
+
+	if home, _ := storage.ExpandPath("~/"); home != "" {
+		ti.SetValue(filepath.Join(home, "journal-words.csv"))
+	}
+
+	return WordReportModel{
+		journal:   journal,
+		report:    stats.WordFrequency(journal, wordReportSize, stats.PeriodMonth),
+		period:    stats.PeriodMonth,
+		pathInput: ti,
+	}
+}
+
+func (m *WordReportModel) SetSize(width, height int) {
+	m.width = width
+	m.height = height
+}
+
+func (m WordReportModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m WordReportModel) visibleRows() int {
+	rows := m.height - 13
+	if rows < 5 {
+		rows = 10
+	}
+	return rows
+}
+
+func (m *WordReportModel) adjustScroll() {
+	visible := m.visibleRows()
+	if m.selectedIndex < m.offset {
+		m.offset = m.selectedIndex
+	} else if m.selectedIndex >= m.offset+visible {
+		m.offset = m.selectedIndex - visible + 1
+	}
+}
+
+func (m WordReportModel) Update(msg tea.Msg) (WordReportModel, tea.Cmd) {
+	var cmd tea.Cmd
+
+	if m.exportMode {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "enter":
+				if err := m.exportCSV(m.pathInput.Value()); err != nil {
+					m.Error = err.Error()
+				} else {
+					m.Message = "Exported to " + m.pathInput.Value()
+					m.exportMode = false
+					m.pathInput.Blur()
+				}
+				return m, nil
+			case "esc":
+				m.exportMode = false
+				m.pathInput.Blur()
+				return m, nil
+			}
+		}
+		m.Error = ""
+		m.pathInput, cmd = m.pathInput.Update(msg)
+		return m, cmd
+	}
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		m.Error = ""
+		m.Message = ""
+
+		switch msg.String() {
+		case "up", "k":
+			if m.selectedIndex > 0 {
+				m.selectedIndex--
+				m.adjustScroll()
+			}
+		case "down", "j":
+			if m.selectedIndex < len(m.report.Words)-1 {
+				m.selectedIndex++
+				m.adjustScroll()
+			}
+		case "p":
+			if m.period == stats.PeriodMonth {
+				m.period = stats.PeriodYear
+			} else {
+				m.period = stats.PeriodMonth
+			}
+			m.report = stats.WordFrequency(m.journal, wordReportSize, m.period)
+		case "e":
+			if len(m.report.Words) > 0 {
+				m.exportMode = true
+				m.pathInput.Focus()
+				return m, textinput.Blink
+			}
+		case "esc", "q":
+			m.Back = true
+		}
+	}
+
+	return m, nil
+}
+
+func (m WordReportModel) exportCSV(path string) error {
+	expanded, err := storage.ExpandPath(path)
+	if err != nil {
+		return err
+	}
+	f, err := storage.CreateFile(expanded)
+	if err != nil {
+		return err
+	}
+	if err := m.report.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sparkline renders counts as a row of block characters scaled to the maximum
+func sparkline(counts []int) string {
+	max := 0
+	for _, n := range counts {
+		if n > max {
+			max = n
+		}
+	}
+	var b strings.Builder
+	for _, n := range counts {
+		idx := 0
+		if max > 0 && n > 0 {
+			idx = 1 + n*(len(sparkBlocks)-2)/max
+		}
+		b.WriteRune(sparkBlocks[idx])
+	}
+	return b.String()
+}
+
+func (m WordReportModel) View() string {
+	t := theme.Current()
+	var b strings.Builder
+
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(t.Title)
+	itemStyle := lipgloss.NewStyle().Foreground(t.Text).PaddingLeft(2)
+	selectedStyle := lipgloss.NewStyle().Foreground(t.Selected).Bold(true).PaddingLeft(2)
+	countStyle := lipgloss.NewStyle().Foreground(t.Warning)
+	sparkStyle := lipgloss.NewStyle().Foreground(t.Info)
+	mutedStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	emptyStyle := lipgloss.NewStyle().Foreground(t.TextDim).Italic(true).PaddingLeft(2)
+	helpStyle := lipgloss.NewStyle().Foreground(t.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(t.Accent).Bold(true)
+	errorStyle := lipgloss.NewStyle().Foreground(t.Error).Bold(true)
+	successStyle := lipgloss.NewStyle().Foreground(t.Success).Bold(true)
+	dividerStyle := lipgloss.NewStyle().Foreground(t.Muted)
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("Word Frequency"))
+	b.WriteString("\n\n")
+
+	if total, entries := stats.WritingTime(m.journal); entries > 0 {
+		noun := "entries"
+		if entries == 1 {
+			noun = "entry"
+		}
+		b.WriteString(mutedStyle.Render(fmt.Sprintf("Writing time %s over %d %s", formatWritingTime(total), entries, noun)))
+		b.WriteString("\n")
+	}
+
+	periodName := "month"
+	if m.period == stats.PeriodYear {
+		periodName = "year"
+	}
+
+	// Limit the trend to the most recent periods that fit on screen
+	trendLen := len(m.report.Periods)
+	maxTrend := m.width - 40
+	if maxTrend < 12 {
+		maxTrend = 12
+	}
+	if trendLen > maxTrend {
+		trendLen = maxTrend
+	}
+	trendStart := len(m.report.Periods) - trendLen
+
+	if len(m.report.Periods) > 0 {
+		b.WriteString(mutedStyle.Render(fmt.Sprintf("Usage by %s, %s to %s",
+			periodName, m.report.Periods[trendStart], m.report.Periods[len(m.report.Periods)-1])))
+		b.WriteString("\n")
+	}
+	b.WriteString(dividerStyle.Render(strings.Repeat("-", 60)))
+	b.WriteString("\n\n")
+
+	if m.exportMode {
+		b.WriteString("Export report as CSV:\n\n")
+		b.WriteString("  ")
+		b.WriteString(m.pathInput.View())
+		b.WriteString("\n\n")
+		if m.Error != "" {
+			b.WriteString("  ")
+			b.WriteString(errorStyle.Render(m.Error))
+			b.WriteString("\n\n")
+		}
+		b.WriteString(helpStyle.Render(keyStyle.Render("Enter") + " export | " + keyStyle.Render("Esc") + " cancel"))
+		return b.String()
+	}
+
+	if len(m.report.Words) == 0 {
+		b.WriteString(emptyStyle.Render("Not enough writing yet to build a report."))
+		b.WriteString("\n")
+	} else {
+		end := m.offset + m.visibleRows()
+		if end > len(m.report.Words) {
+			end = len(m.report.Words)
+		}
+		for i := m.offset; i < end; i++ {
+			wc := m.report.Words[i]
+			line := fmt.Sprintf("%2d. %-18s %s  %s", i+1, wc.Word,
+				countStyle.Render(fmt.Sprintf("%5d", wc.Total)),
+				sparkStyle.Render(sparkline(wc.ByPeriod[trendStart:])))
+			if i == m.selectedIndex {
+				b.WriteString(selectedStyle.Render("> " + line))
+			} else {
+				b.WriteString(itemStyle.Render("  " + line))
+			}
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n")
+
+	if m.Error != "" {
+		b.WriteString(errorStyle.Render(m.Error))
+		b.WriteString("\n\n")
+	}
+	if m.Message != "" {
+		b.WriteString(successStyle.Render(m.Message))
+		b.WriteString("\n\n")
+	}
+
+	var parts []string
+	parts = append(parts, keyStyle.Render("Up/Down")+" navigate")
+	if m.period == stats.PeriodMonth {
+		parts = append(parts, keyStyle.Render("p")+" by year")
+	} else {
+		parts = append(parts, keyStyle.Render("p")+" by month")
+	}
+	parts = append(parts, keyStyle.Render("e")+" export CSV")
+	parts = append(parts, keyStyle.Render("Esc/q")+" back")
+	b.WriteString(helpStyle.Render(strings.Join(parts, " | ")))
+
+	return b.String()
+}
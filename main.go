@@ -1,18 +1,90 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
+	"journal/internal/cli"
 	"journal/internal/ui"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
-	p := tea.NewProgram(ui.InitialModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	args, stopProfile, err := cli.StartProfile(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	args, closeLog, err := cli.StartDebugLog(args)
+	if err != nil {
+		stopProfile()
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	stop := func() {
+		closeLog()
+		stopProfile()
+	}
+
+	args, today := cli.TodayFlag(args)
+	args, lowMemory := cli.LowMemoryFlag(args)
+	args, script, err := cli.ScriptFile(args)
+	if err != nil {
+		stop()
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if script != "" {
+		err := runScript(script)
+		stop()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Script failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cli.IsCommand(args) {
+		err := cli.Run(args)
+		stop()
+		if errors.Is(err, cli.ErrNoMatches) {
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	app := ui.InitialModel()
+	if today {
+		app = app.OpenToday()
+	}
+	if lowMemory {
+		app = app.LowMemory()
+	}
+	p := tea.NewProgram(app, tea.WithAltScreen(), tea.WithReportFocus())
+	_, err = p.Run()
+	stop()
+	if err != nil {
 		fmt.Printf("Error running program: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// runScript runs the interactive journal headless, driven by a script file
+func runScript(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	script, err := ui.ParseScript(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return ui.RunScript(script, os.Stdout)
+}